@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var benchOpts = controller.BenchmarkOptions{
+	Pods:       5000,
+	Namespaces: 50,
+	Updates:    200000,
+	CrashRatio: 0.01,
+	Workers:    1,
+}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the cost of processing pod updates",
+	Long: "Pass generated pod updates through the cache transform, event handlers, queue, backoff and routing " +
+		"as fast as possible and print the throughput and memory used, without connecting to Kubernetes or " +
+		"Mattermost. Compare the results to the baselines before and after changing the hot path.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := controller.Benchmark(benchOpts)
+		if err != nil {
+			return err
+		}
+		fmt.Print(result)
+		return nil
+	},
+}
+
+func init() {
+	flags := benchCmd.Flags()
+	flags.IntVar(&benchOpts.Pods, "pods", benchOpts.Pods, "number of distinct pods")
+	flags.IntVar(&benchOpts.Namespaces, "namespaces", benchOpts.Namespaces, "number of namespaces the pods are spread over")
+	flags.IntVar(&benchOpts.Updates, "updates", benchOpts.Updates, "number of pod updates")
+	flags.Float64Var(&benchOpts.CrashRatio, "crash-ratio", benchOpts.CrashRatio, "fraction of updates reporting a crash")
+	flags.IntVar(&benchOpts.Workers, "workers", benchOpts.Workers, "number of workers processing the queue")
+	rootCmd.AddCommand(benchCmd)
+}
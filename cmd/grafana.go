@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var grafanaTitle = "Mattermost Informer"
+
+var grafanaCmd = &cobra.Command{
+	Use:   "grafana-dashboard",
+	Short: "Print a Grafana dashboard of the informer's metrics",
+	Long: "Print the JSON of a Grafana dashboard showing the notifications by namespace, workload, reason and " +
+		"outcome, the time to recovery and failed deliveries. Import it in Grafana or provision it from a file.",
+	Example: "  mattermost-informer grafana-dashboard > dashboards/mattermost-informer.json",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dashboard, err := controller.GrafanaDashboard(grafanaTitle)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(dashboard))
+		return nil
+	},
+}
+
+func init() {
+	grafanaCmd.Flags().StringVar(&grafanaTitle, "title", grafanaTitle, "title of the dashboard")
+	rootCmd.AddCommand(grafanaCmd)
+}
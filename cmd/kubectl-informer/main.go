@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const requestTimeout = 30 * time.Second
+
+var (
+	kubeconfig = clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides  = &clientcmd.ConfigOverrides{}
+	service    = "mattermost-informer"
+	token      = os.Getenv("INFORMER_API_TOKEN")
+)
+
+var rootCmd = &cobra.Command{
+	Use:          "kubectl-informer",
+	Short:        "Interact with the Mattermost informer running in the cluster",
+	SilenceUsage: true,
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the informer",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		status, err := client.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Namespaces:\t%s\n", strings.Join(status.Namespaces, ", "))
+		fmt.Fprintf(w, "Queued pods:\t%d\n", status.QueueLength)
+		fmt.Fprintf(w, "Pending notifications:\t%d\n", status.PendingNotifications)
+		fmt.Fprintf(w, "Firing alerts:\t%d\n", status.FiringAlerts)
+		fmt.Fprintf(w, "Active silences:\t%d\n", status.Silences)
+		return w.Flush()
+	},
+}
+
+var (
+	alertsSince time.Duration
+	alertsLimit int
+)
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts [NAMESPACE[/POD]]",
+	Short: "List the sent alerts, optionally of a namespace or pods matching a glob pattern",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		query := &api.AlertQuery{Limit: alertsLimit}
+		if len(args) > 0 {
+			query.Namespace, query.Pod = splitTarget(args[0])
+		}
+		if alertsSince > 0 {
+			query.Since = time.Now().Add(-alertsSince)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		alerts, err := client.Alerts(ctx, query)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tNAMESPACE\tPOD\tCONTAINER\tREASON\tSEVERITY\tNOTIFIER")
+		for _, alert := range alerts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", alert.Time.Local().Format(time.RFC3339),
+				alert.Namespace, alert.Pod, alert.Container, alert.Reason, alert.Severity, alert.Notifier)
+		}
+		return w.Flush()
+	},
+}
+
+var firingCmd = &cobra.Command{
+	Use:   "firing",
+	Short: "List the alerts which have not been resolved yet",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		alerts, err := client.Firing(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "FINGERPRINT\tSINCE\tNAMESPACE\tPOD\tCONTAINER\tREASON\tSEVERITY\tACKNOWLEDGED BY")
+		for _, alert := range alerts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", alert.Fingerprint, alert.Time.Local().Format(time.RFC3339),
+				alert.Namespace, alert.Pod, alert.Container, alert.Reason, alert.Severity, alert.AcknowledgedBy)
+		}
+		return w.Flush()
+	},
+}
+
+var resendCmd = &cobra.Command{
+	Use:   "resend FINGERPRINT",
+	Short: "Send a firing alert again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		queued, err := client.Resend(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Alert %s queued for %s\n", args[0], strings.Join(queued.Notifiers, ", "))
+		return nil
+	},
+}
+
+var silenceCmd = &cobra.Command{
+	Use:   "silence",
+	Short: "Manage silences suppressing notifications",
+}
+
+var (
+	silenceContainer string
+	silenceDuration  = time.Hour
+	silenceComment   string
+)
+
+var silenceCreateCmd = &cobra.Command{
+	Use:   "create NAMESPACE[/POD]",
+	Short: "Silence the notifications of a namespace or of pods matching a glob pattern",
+	Example: "  kubectl informer silence create shop/api-* --duration 2h --comment 'migrating database'\n" +
+		"  kubectl informer silence create staging --duration 30m",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		namespace, pod := splitTarget(args[0])
+		silence := &api.Silence{
+			Namespace: namespace,
+			Pod:       pod,
+			Container: silenceContainer,
+			Until:     time.Now().Add(silenceDuration),
+			Comment:   silenceComment,
+		}
+		if u, err := user.Current(); err == nil {
+			silence.CreatedBy = u.Username
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		created, err := client.CreateSilence(ctx, silence)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created silence %s until %s\n", created.ID, created.Until.Local().Format(time.RFC3339))
+		return nil
+	},
+}
+
+var silenceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the active silences",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		silences, err := client.Silences(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAMESPACE\tPOD\tCONTAINER\tUNTIL\tCREATED BY\tCOMMENT")
+		for _, s := range silences {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, orAll(s.Namespace), orAll(s.Pod), orAll(s.Container),
+				s.Until.Local().Format(time.RFC3339), s.CreatedBy, s.Comment)
+		}
+		return w.Flush()
+	},
+}
+
+var silenceDeleteCmd = &cobra.Command{
+	Use:   "delete ID",
+	Short: "Delete a silence before it expires",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		if err := client.DeleteSilence(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted silence %s\n", args[0])
+		return nil
+	},
+}
+
+var testRequest = api.TestRequest{
+	Namespace: "default",
+	Pod:       "informer-test",
+	Container: "app",
+	Reason:    "CrashLoopBackOff",
+	Severity:  "info",
+}
+
+var testSendCmd = &cobra.Command{
+	Use:   "test-send [NAMESPACE]",
+	Short: "Let the informer send a test notification through its routes",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			testRequest.Namespace = args[0]
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		queued, err := client.SendTest(ctx, &testRequest)
+		if err != nil {
+			return err
+		}
+		channel := queued.Channel
+		if channel == "" {
+			channel = "default channel"
+		}
+		fmt.Printf("Test notification to %s queued for %s\n", channel, strings.Join(queued.Notifiers, ", "))
+		return nil
+	},
+}
+
+// splitTarget splits NAMESPACE/POD arguments.
+func splitTarget(target string) (namespace, pod string) {
+	namespace, pod, _ = strings.Cut(target, "/")
+	return namespace, pod
+}
+
+func orAll(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
+
+// newClient creates an API client for the informer in the namespace of the current context,
+// unless overridden by --namespace.
+func newClient() (*api.Client, error) {
+	if token == "" {
+		return nil, fmt.Errorf("no API token given, set --token or INFORMER_API_TOKEN")
+	}
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(kubeconfig, overrides)
+	config, err := loader.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load kubeconfig: %v", err)
+	}
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine namespace: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return api.NewClient(clientset.CoreV1().RESTClient(), namespace, service, token), nil
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&kubeconfig.ExplicitPath, "kubeconfig", "", "path to the kubeconfig file")
+	flags.StringVar(&overrides.CurrentContext, "context", "", "name of the kubeconfig context to use")
+	flags.StringVarP(&overrides.Context.Namespace, "namespace", "n", "", "namespace the informer runs in, defaults to the namespace of the context")
+	flags.StringVar(&service, "service", service, "name of the informer's service")
+	flags.StringVar(&token, "token", token, "API token of the informer, defaults to the INFORMER_API_TOKEN environment variable")
+
+	silenceCreateCmd.Flags().StringVar(&silenceContainer, "container", "", "only silence the named container")
+	silenceCreateCmd.Flags().DurationVar(&silenceDuration, "duration", silenceDuration, "time until the silence expires")
+	silenceCreateCmd.Flags().StringVar(&silenceComment, "comment", "", "reason for the silence")
+	silenceCmd.AddCommand(silenceCreateCmd, silenceListCmd, silenceDeleteCmd)
+
+	alertsCmd.Flags().DurationVar(&alertsSince, "since", 0, "only list alerts sent within the duration, e.g. 24h")
+	alertsCmd.Flags().IntVar(&alertsLimit, "limit", 100, "maximum number of alerts listed")
+
+	testSendCmd.Flags().StringVar(&testRequest.Severity, "severity", testRequest.Severity, "severity of the test notification, used for routing")
+	testSendCmd.Flags().StringVar(&testRequest.Reason, "reason", testRequest.Reason, "reason of the crash, used for routing")
+
+	rootCmd.AddCommand(statusCmd, alertsCmd, firingCmd, resendCmd, silenceCmd, testSendCmd)
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/klog/v2"
+)
+
+var logFormat = "text"
+
+// setupLogging routes the klog output to a JSON logger if requested.
+func setupLogging() error {
+	switch logFormat {
+	case "text":
+		return nil
+	case "json":
+		cfg := zap.NewProductionConfig()
+		// Verbosity is already filtered by klog using the -v flag.
+		cfg.Level = zap.NewAtomicLevelAt(zapcore.Level(-10))
+		cfg.Sampling = nil
+		cfg.EncoderConfig.TimeKey = "ts"
+		cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		logger, err := cfg.Build()
+		if err != nil {
+			return fmt.Errorf("failed to create JSON logger: %v", err)
+		}
+		klog.SetLogger(zapr.NewLogger(logger))
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q, must be text or json", logFormat)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderRequest = controller.RenderRequest{
+		Sample: controller.TestAlert{
+			Namespace: "default",
+			Pod:       "informer-test",
+			Container: "app",
+			Reason:    "CrashLoopBackOff",
+			Severity:  "warning",
+		},
+	}
+	renderGolden string
+	renderUpdate bool
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render the title and text of an alert",
+	Long: "Render the templates of the configuration, or those of a template file, for a sample crash, a pod " +
+		"manifest or a live pod. With --golden the output is compared to a golden file instead of being " +
+		"printed, and the command fails if they differ.",
+	Example: "  mattermost-informer render --config config.yaml --template templates.yaml\n" +
+		"  mattermost-informer render --config config.yaml --pod shop/payments-5d8f --kubeconfig ~/.kube/config\n" +
+		"  mattermost-informer render --config config.yaml --pod-file testdata/oom.yaml --golden testdata/oom.golden",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := controller.Render(runOpts, renderRequest)
+		if err != nil {
+			return err
+		}
+		if renderGolden != "" {
+			return controller.CompareGolden(renderGolden, output, renderUpdate)
+		}
+		_, err = os.Stdout.Write(output)
+		return err
+	},
+}
+
+func init() {
+	flags := renderCmd.Flags()
+	flags.StringVar(&renderRequest.TemplatePath, "template", "", "YAML file with title and text templates replacing those of the configuration")
+	flags.StringVar(&renderRequest.PodPath, "pod-file", "", "manifest of the pod to render")
+	flags.StringVar(&renderRequest.Pod, "pod", "", "live pod to render as namespace/name")
+	flags.StringVar(&renderRequest.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig used to read the live pod")
+	flags.StringVar(&renderRequest.Context, "context", "", "kubeconfig context used to read the live pod")
+	flags.StringVar(&renderRequest.Container, "container", "", "container to render, by default the first one which crashed")
+	flags.StringVar(&renderRequest.Locale, "locale", "", "locale to render in, by default the locale of the configuration")
+	flags.StringVarP(&renderRequest.Sample.Namespace, "namespace", "n", renderRequest.Sample.Namespace, "namespace of the sample pod")
+	flags.StringVar(&renderRequest.Sample.Reason, "reason", renderRequest.Sample.Reason, "reason of the sample crash")
+	flags.StringVar(&renderRequest.Sample.Severity, "severity", renderRequest.Sample.Severity, "severity of the sample alert")
+	flags.StringVar(&renderGolden, "golden", "", "compare the output to the golden file instead of printing it")
+	flags.BoolVar(&renderUpdate, "update", false, "replace the golden file with the output")
+	rootCmd.AddCommand(renderCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay fixture...",
+	Short: "Print the notifications the configuration would send for pod fixtures",
+	Long: "Run the pods of YAML or JSON fixtures through detection, routing and templating and print the " +
+		"notifications which would be sent, without connecting to Kubernetes or Mattermost. Fixtures may be " +
+		"manifests, lists as printed by kubectl get -o yaml, or recorded watch streams. Namespaces, workloads " +
+		"and AlertRules contained in the fixtures are taken into account.",
+	Example: "  kubectl get pods -n shop -o yaml > pods.yaml\n" +
+		"  mattermost-informer replay --config config.yaml pods.yaml\n" +
+		"  kubectl get --raw '/api/v1/namespaces/shop/pods?watch=1' > watch.json\n" +
+		"  mattermost-informer replay --config config.yaml --opt-out watch.json",
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controller.Replay(runOpts, args, os.Stdout)
+	},
+}
+
+func init() {
+	replayCmd.Flags().BoolVar(&runOpts.OptOut, "opt-out", runOpts.OptOut, "monitor all pods unless annotated with espe.tech/mattermost=ignore")
+	replayCmd.Flags().StringVar(&runOpts.PodSelector, "pod-selector", runOpts.PodSelector, "only replay pods matching the label selector, selected pods need no annotation")
+	rootCmd.AddCommand(replayCmd)
+}
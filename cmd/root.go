@@ -1,18 +1,117 @@
 package cmd
 
 import (
+	goflag "flag"
+	"os"
+
 	"github.com/lnsp/mattermost-informer/pkg/controller"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
 )
 
+var runOpts = controller.DefaultOptions()
+
 var rootCmd = &cobra.Command{
-	Use:  "mattermost-informer",
-	Long: "Broadcast pod crashes to a Mattermost channel",
-	Run: func(cmd *cobra.Command, args []string) {
-		controller.Run()
+	Use:          "mattermost-informer",
+	Long:         "Broadcast pod crashes to a Mattermost channel",
+	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setupLogging()
 	},
+	// Running without a subcommand is equivalent to run.
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controller.Run(runOpts)
+	},
+}
+
+func init() {
+	klogFlags := goflag.NewFlagSet("klog", goflag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	rootCmd.PersistentFlags().AddGoFlagSet(klogFlags)
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormat, "log format, either text or json")
+	rootCmd.PersistentFlags().StringVarP(&runOpts.ConfigPath, "config", "c", runOpts.ConfigPath, "path to the configuration file")
+	rootCmd.PersistentFlags().StringVar(&runOpts.ConfigResource, "config-resource", "", "name of a MattermostInformer resource in the informer's namespace to read the configuration from instead of the configuration file")
+	addRunFlags(rootCmd.Flags())
+}
+
+// addRunFlags registers the flags of the run command.
+func addRunFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVarP(&runOpts.Namespaces, "namespace", "n", runOpts.Namespaces, "comma-separated namespaces to watch, defaults to the namespace the informer runs in")
+	flags.StringArrayVar(&runOpts.Clusters, "cluster", runOpts.Clusters, "further cluster to watch as name=kubeconfig or name=kubeconfig:context, can be repeated")
+	flags.BoolVarP(&runOpts.AllNamespaces, "all-namespaces", "A", runOpts.AllNamespaces, "watch all namespaces")
+	flags.StringVar(&runOpts.NamespaceSelector, "namespace-selector", runOpts.NamespaceSelector, "watch namespaces matching the label selector (e.g. mattermost-informer=enabled) as they come and go")
+	flags.StringSliceVar(&runOpts.NamespaceInclude, "namespace-include", runOpts.NamespaceInclude, "only watch namespaces matching one of the glob patterns (e.g. team-*)")
+	flags.StringSliceVar(&runOpts.NamespaceExclude, "namespace-exclude", runOpts.NamespaceExclude, "do not watch namespaces matching one of the glob patterns (e.g. kube-*)")
+	flags.StringVar(&runOpts.PodSelector, "pod-selector", runOpts.PodSelector, "only watch pods matching the label selector (e.g. alerts=mattermost), selected pods need no annotation")
+	flags.StringVar(&runOpts.PodFieldSelector, "pod-field-selector", runOpts.PodFieldSelector, "only watch pods matching the field selector, by default pods which have not completed")
+	flags.BoolVar(&runOpts.OptOut, "opt-out", runOpts.OptOut, "monitor all pods unless annotated with espe.tech/mattermost=ignore")
+	flags.BoolVar(&runOpts.AlertRules, "alert-rules", runOpts.AlertRules, "watch AlertRule resources instead of only alerting on annotated pods")
+	flags.BoolVar(&runOpts.OnCallSchedules, "on-call-schedules", runOpts.OnCallSchedules, "watch OnCallSchedule resources to mention the users on call as annotated")
+	flags.BoolVar(&runOpts.WatchRollouts, "watch-rollouts", runOpts.WatchRollouts, "notify about degraded Argo Rollouts and failed analysis runs")
+	flags.BoolVar(&runOpts.WatchFlux, "watch-flux", runOpts.WatchFlux, "notify about Flux HelmReleases and Kustomizations failing to reconcile")
+	flags.BoolVar(&runOpts.WatchAutoscaler, "watch-autoscaler", runOpts.WatchAutoscaler, "notify about the Cluster Autoscaler or Karpenter failing to scale up")
+	flags.BoolVar(&runOpts.WatchArgoCD, "watch-argocd", runOpts.WatchArgoCD, "notify about Argo CD Applications which are degraded or out of sync")
+	flags.StringVar(&runOpts.ArgoCDNamespace, "argocd-namespace", runOpts.ArgoCDNamespace, "namespace of the Argo CD Applications")
+	flags.StringVar(&runOpts.ArgoCDURL, "argocd-url", runOpts.ArgoCDURL, "URL of the Argo CD UI applications are linked to, e.g. https://argocd.example.com")
+	flags.DurationVar(&runOpts.ArgoCDGracePeriod, "argocd-grace-period", runOpts.ArgoCDGracePeriod, "time an application has to be degraded or out of sync before it is notified")
+	flags.BoolVar(&runOpts.RestartAnomalies, "restart-anomalies", runOpts.RestartAnomalies, "alert when the restart rate of a workload deviates from its baseline as configured by anomalies")
+	flags.BoolVar(&runOpts.MetricsServer, "metrics-server", runOpts.MetricsServer, "add the current CPU and memory usage of crashing containers reported by metrics-server to alerts")
+	flags.BoolVar(&runOpts.ServiceLevelObjectives, "service-level-objectives", runOpts.ServiceLevelObjectives, "warn about ServiceLevelObjectives whose error budget burns too fast, requires --notification-records")
+	flags.IntVar(&runOpts.Workers, "workers", runOpts.Workers, "number of pods processed in parallel")
+	flags.DurationVar(&runOpts.ResyncPeriod, "resync-period", runOpts.ResyncPeriod, "interval in which all pods are re-evaluated, 0 disables resyncs")
+	flags.DurationVar(&runOpts.QueueBaseDelay, "queue-base-delay", runOpts.QueueBaseDelay, "delay before the first retry of a failing pod, doubled with each retry")
+	flags.DurationVar(&runOpts.QueueMaxDelay, "queue-max-delay", runOpts.QueueMaxDelay, "maximum delay between retries of a failing pod")
+	flags.IntVar(&runOpts.MaxRetries, "max-retries", runOpts.MaxRetries, "number of retries before a failing pod is dropped until its next update")
+	flags.BoolVar(&runOpts.Sharding, "sharding", runOpts.Sharding, "split the watched namespaces between all replicas running with sharding, coordinated via Leases")
+	flags.DurationVar(&runOpts.ShardLeaseDuration, "shard-lease-duration", runOpts.ShardLeaseDuration, "time after which the namespaces of an unresponsive replica are taken over by the others")
+	flags.IntVar(&runOpts.Senders, "senders", runOpts.Senders, "number of notifications sent in parallel, notifications to the same channel are sent in order")
+	flags.IntVar(&runOpts.SendQueueSize, "send-queue-size", runOpts.SendQueueSize, "number of notifications buffered per sender before new notifications are dropped")
+	flags.IntVar(&runOpts.SendRetries, "send-retries", runOpts.SendRetries, "number of retries of a failed notification")
+	flags.IntVar(&runOpts.CircuitBreakerFailures, "circuit-breaker-failures", runOpts.CircuitBreakerFailures, "number of consecutive failures after which deliveries to a notifier are stopped")
+	flags.DurationVar(&runOpts.CircuitBreakerCooldown, "circuit-breaker-cooldown", runOpts.CircuitBreakerCooldown, "time deliveries to a failing notifier are stopped before it is probed again")
+	flags.StringVar(&runOpts.SpoolDir, "spool-dir", runOpts.SpoolDir, "directory undelivered notifications are persisted in and delivered from once the notifier is reachable again")
+	flags.IntVar(&runOpts.SpoolMaxEntries, "spool-max-entries", runOpts.SpoolMaxEntries, "maximum number of spooled notifications")
+	flags.DurationVar(&runOpts.SpoolMaxAge, "spool-max-age", runOpts.SpoolMaxAge, "time after which spooled notifications are dropped")
+	flags.IntVar(&runOpts.SpoolMaxAttempts, "spool-max-attempts", runOpts.SpoolMaxAttempts, "number of failed deliveries from the spool after which a notification is dropped")
+	flags.DurationVar(&runOpts.StateTTL, "state-ttl", runOpts.StateTTL, "time after which the notification state of a pod is forgotten, must exceed the longest backoff")
+	flags.IntVar(&runOpts.StateMaxEntries, "state-max-entries", runOpts.StateMaxEntries, "maximum number of notification state entries kept in memory, the oldest are evicted first")
+	flags.StringVar(&runOpts.StateConfigMap, "state-configmap", runOpts.StateConfigMap, "name of a ConfigMap in the informer's namespace to persist the notification state to across restarts")
+	flags.StringVar(&runOpts.ActionSecretFile, "action-secret-file", runOpts.ActionSecretFile, "file holding the secret signing the acknowledge buttons of alerts, shared by all replicas")
+	flags.BoolVar(&runOpts.NotificationRecords, "notification-records", runOpts.NotificationRecords, "persist every sent notification as NotificationRecord resource in the informer's namespace")
+	flags.DurationVar(&runOpts.NotificationRecordRetention, "notification-record-retention", runOpts.NotificationRecordRetention, "time after which NotificationRecords are deleted")
+	flags.BoolVar(&runOpts.WeeklyReport, "weekly-report", runOpts.WeeklyReport, "post a weekly reliability report to each channel as scheduled by weeklyReport, requires --notification-records")
+	flags.BoolVar(&runOpts.TopCrashers, "top-crashers", runOpts.TopCrashers, "post the workloads with the most alerts as scheduled by topCrashers, requires --notification-records")
+	flags.DurationVar(&runOpts.ShutdownTimeout, "shutdown-timeout", runOpts.ShutdownTimeout, "time given to process the queued pods after receiving SIGTERM")
+	flags.BoolVar(&runOpts.StartupNotice, "startup-notice", runOpts.StartupNotice, "post the version and watched namespaces to the ops channel when starting")
+	flags.BoolVar(&runOpts.ShutdownNotice, "shutdown-notice", runOpts.ShutdownNotice, "post a notice to the ops channel when shutting down")
+	flags.DurationVar(&runOpts.HeartbeatInterval, "heartbeat-interval", runOpts.HeartbeatInterval, "interval in which the watched namespaces and firing alerts are posted to the ops channel as heartbeat, 0 disables the heartbeat")
+	flags.StringVar(&runOpts.OTLPEndpoint, "otlp-endpoint", runOpts.OTLPEndpoint, "address of an OpenTelemetry collector (e.g. otel-collector:4317) to export traces to using gRPC")
+	flags.BoolVar(&runOpts.OTLPInsecure, "otlp-insecure", runOpts.OTLPInsecure, "connect to the OpenTelemetry collector without TLS")
+	flags.StringVar(&runOpts.SentryDSN, "sentry-dsn", os.Getenv("SENTRY_DSN"), "Sentry DSN to report internal errors to, defaults to the SENTRY_DSN environment variable")
+	flags.StringVar(&runOpts.SentryEnvironment, "sentry-environment", runOpts.SentryEnvironment, "environment reported to Sentry, e.g. production")
+	flags.BoolVar(&runOpts.SkipPermissionCheck, "skip-permission-check", runOpts.SkipPermissionCheck, "do not verify the RBAC permissions of the service account on startup")
+	flags.BoolVar(&runOpts.LeaderElect, "leader-elect", runOpts.LeaderElect, "run the controller on a single elected replica while the other replicas stand by")
+	flags.StringVar(&runOpts.MetricsAddr, "metrics-addr", runOpts.MetricsAddr, "address to serve Prometheus metrics on, 0 disables serving metrics")
+	flags.BoolVar(&runOpts.EnableDebug, "enable-debug", runOpts.EnableDebug, "serve pprof profiles at /debug/pprof and the internal state at /debug/state")
+	flags.StringVar(&runOpts.DebugAddr, "debug-addr", runOpts.DebugAddr, "address to serve the debug handlers and the dashboard on")
+	flags.StringVar(&runOpts.GRPCAddr, "grpc-addr", runOpts.GRPCAddr, "address to stream alerts on via gRPC (e.g. :9091), requires apiToken to be configured")
+	flags.StringVar(&runOpts.GRPCCertFile, "grpc-cert-file", runOpts.GRPCCertFile, "TLS certificate of the alert stream")
+	flags.StringVar(&runOpts.GRPCKeyFile, "grpc-key-file", runOpts.GRPCKeyFile, "TLS key of the alert stream")
+	flags.BoolVar(&runOpts.GRPCInsecure, "grpc-insecure", runOpts.GRPCInsecure, "serve the alert stream without TLS, sending the API token in plain text")
+	flags.BoolVar(&runOpts.EnableDashboard, "enable-dashboard", runOpts.EnableDashboard, "serve a read-only overview of firing alerts, recent notifications and silences at /dashboard")
+	flags.DurationVar(&runOpts.SyntheticCrashInterval, "synthetic-crash-interval", runOpts.SyntheticCrashInterval, "interval in which a synthetic crash is sent to the syntheticChannel to verify the delivery of alerts, 0 disables injection")
+	flags.StringVar(&runOpts.Mattermost.URL, "mattermost-url", "", "override the Mattermost server URL")
+	flags.StringVar(&runOpts.Mattermost.Team, "mattermost-team", "", "override the Mattermost team")
+	flags.StringVar(&runOpts.Mattermost.Channel, "mattermost-channel", "", "override the default Mattermost channel")
+	flags.StringVar(&runOpts.Mattermost.TokenFile, "mattermost-token-file", "", "override the file the Mattermost access token is read from")
+	flags.StringVar(&runOpts.Mattermost.WebhookURL, "mattermost-webhook-url", "", "override the Mattermost incoming webhook URL")
 }
 
 func Execute() {
-	rootCmd.Execute()
+	err := rootCmd.Execute()
+	klog.Flush()
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Watch pods and broadcast crashes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controller.Run(runOpts)
+	},
+}
+
+func init() {
+	addRunFlags(runCmd.Flags())
+	rootCmd.AddCommand(runCmd)
+}
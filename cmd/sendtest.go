@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var testAlert = controller.TestAlert{
+	Namespace: "default",
+	Pod:       "informer-test",
+	Container: "app",
+	Reason:    "CrashLoopBackOff",
+	Severity:  "info",
+}
+
+var sendTestCmd = &cobra.Command{
+	Use:   "send-test",
+	Short: "Send a test crash notification using the configuration",
+	Long: "Send a synthetic crash notification using the routes, templates and notifiers of the configuration. " +
+		"The command exits with an error if any routed notifier fails to deliver it.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		notifiers, err := controller.SendTest(runOpts, testAlert)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Test notification delivered via %s\n", strings.Join(notifiers, ", "))
+		return nil
+	},
+}
+
+func init() {
+	flags := sendTestCmd.Flags()
+	flags.StringVarP(&testAlert.Namespace, "namespace", "n", testAlert.Namespace, "namespace of the synthetic pod, used for routing")
+	flags.StringVar(&testAlert.Pod, "pod", testAlert.Pod, "name of the synthetic pod")
+	flags.StringVar(&testAlert.Container, "container", testAlert.Container, "name of the crashing container")
+	flags.StringVar(&testAlert.Reason, "reason", testAlert.Reason, "reason of the crash, used for routing")
+	flags.StringVar(&testAlert.Severity, "severity", testAlert.Severity, "severity of the alert, used for routing")
+	rootCmd.AddCommand(sendTestCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var soakOpts = controller.SoakOptions{
+	Pods:               5000,
+	Namespaces:         50,
+	Rate:               1000,
+	CrashRatio:         0.01,
+	ChurnRatio:         0.01,
+	Workers:            1,
+	Duration:           4 * time.Hour,
+	SampleInterval:     time.Minute,
+	Warmup:             10 * time.Minute,
+	MaxHeapGrowth:      0.2,
+	MaxGoroutineGrowth: 10,
+}
+
+var soakCmd = &cobra.Command{
+	Use:   "soak",
+	Short: "Run the controller against generated churn and fail on resource drift",
+	Long: "Run the controller against generated pod updates, crashes and replaced pods for a long time, without " +
+		"connecting to Kubernetes or Mattermost. The heap, goroutines, notification state and firing alerts are " +
+		"printed every sample interval, and the command fails once the heap or goroutines grew beyond the " +
+		"tolerated drift since the end of the warmup.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controller.Soak(soakOpts, os.Stdout)
+	},
+}
+
+func init() {
+	flags := soakCmd.Flags()
+	flags.IntVar(&soakOpts.Pods, "pods", soakOpts.Pods, "number of pods existing at a time")
+	flags.IntVar(&soakOpts.Namespaces, "namespaces", soakOpts.Namespaces, "number of namespaces the pods are spread over")
+	flags.IntVar(&soakOpts.Rate, "rate", soakOpts.Rate, "number of pod updates per second")
+	flags.Float64Var(&soakOpts.CrashRatio, "crash-ratio", soakOpts.CrashRatio, "fraction of updates reporting a crash")
+	flags.Float64Var(&soakOpts.ChurnRatio, "churn-ratio", soakOpts.ChurnRatio, "fraction of updates replacing the pod by a new one")
+	flags.IntVar(&soakOpts.Workers, "workers", soakOpts.Workers, "number of workers processing the queue")
+	flags.DurationVar(&soakOpts.Duration, "duration", soakOpts.Duration, "length of the run")
+	flags.DurationVar(&soakOpts.SampleInterval, "sample-interval", soakOpts.SampleInterval, "interval in which the resource usage is sampled")
+	flags.DurationVar(&soakOpts.Warmup, "warmup", soakOpts.Warmup, "time after which the baseline resource usage is sampled")
+	flags.Float64Var(&soakOpts.MaxHeapGrowth, "max-heap-growth", soakOpts.MaxHeapGrowth, "tolerated growth of the heap relative to the baseline")
+	flags.IntVar(&soakOpts.MaxGoroutineGrowth, "max-goroutine-growth", soakOpts.MaxGoroutineGrowth, "tolerated number of additional goroutines")
+	rootCmd.AddCommand(soakCmd)
+}
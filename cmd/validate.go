@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/spf13/cobra"
+)
+
+var validateOffline bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [manifest...]",
+	Short: "Validate the configuration file or MattermostInformer and AlertRule manifests",
+	Long: "Validate the configuration file, or the MattermostInformer and AlertRule resources of the given manifests. " +
+		"Unless --offline is given, the channels of routes and rules are verified against the Mattermost servers. " +
+		"All problems found are reported at once.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		errs := controller.Validate(runOpts, args, validateOffline)
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("found %d problems", len(errs))
+		}
+		if len(args) == 0 {
+			fmt.Printf("Configuration %s is valid\n", runOpts.ConfigPath)
+		} else {
+			fmt.Println("Manifests are valid")
+		}
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().BoolVar(&validateOffline, "offline", validateOffline, "do not connect to Mattermost to verify channels")
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,92 @@
+#!/usr/bin/env bash
+# Runs the informer in a kind cluster against a real Mattermost server in a container, forces a
+# crash loop and verifies the alert posted to Mattermost. Requires docker, kind, kubectl, curl and jq.
+# Set KEEP=1 to keep the cluster and Mattermost running afterwards.
+set -euo pipefail
+
+cd "$(dirname "$0")/.."
+
+CLUSTER=informer-e2e
+MATTERMOST=informer-e2e-mattermost
+MATTERMOST_URL=http://localhost:8065
+# The Mattermost release is pinned so that runs are reproducible, override it to test another one.
+MATTERMOST_IMAGE=${MATTERMOST_IMAGE:-mattermost/mattermost-preview:9.11.0}
+IMAGE=mattermost-informer:e2e
+TIMEOUT=${TIMEOUT:-300}
+
+cleanup() {
+  if [ "${KEEP:-0}" = "1" ]; then
+    echo "Keeping cluster $CLUSTER and container $MATTERMOST"
+    return
+  fi
+  kind delete cluster --name "$CLUSTER" >/dev/null 2>&1 || true
+  docker rm -f "$MATTERMOST" >/dev/null 2>&1 || true
+}
+trap cleanup EXIT
+
+fail() {
+  echo "FAIL: $*" >&2
+  kubectl logs deployment/mattermost-informer --tail=50 >&2 || true
+  exit 1
+}
+
+mmctl() {
+  docker exec "$MATTERMOST" mmctl --local "$@"
+}
+
+echo "Starting Mattermost"
+docker run -d --name "$MATTERMOST" -p 8065:8065 "$MATTERMOST_IMAGE" >/dev/null
+for _ in $(seq 60); do
+  if curl -sf "$MATTERMOST_URL/api/v4/system/ping" >/dev/null && mmctl system status >/dev/null 2>&1; then
+    break
+  fi
+  sleep 5
+done
+mmctl config set ServiceSettings.EnableUserAccessTokens true >/dev/null
+mmctl user create --email informer@example.com --username informer --password 'Informer-e2e-1' --system-admin >/dev/null
+mmctl team create --name e2e --display-name E2E >/dev/null
+mmctl team users add e2e informer
+mmctl channel create --team e2e --name alerts --display-name Alerts >/dev/null
+mmctl channel users add e2e:alerts informer
+TOKEN=$(mmctl token generate informer e2e | awk '{print $1}')
+[ -n "$TOKEN" ] || fail "could not create access token"
+
+echo "Creating kind cluster"
+kind create cluster --name "$CLUSTER" --wait 120s
+# Pods in the cluster reach Mattermost by its container name on the kind network.
+docker network connect kind "$MATTERMOST"
+
+echo "Deploying the informer"
+docker build -t "$IMAGE" --build-arg VERSION=e2e .
+kind load docker-image "$IMAGE" --name "$CLUSTER"
+kubectl create configmap mattermost-informer-cfg --from-file=config.yaml=e2e/config.yaml
+kubectl create secret generic mattermost-informer-token --from-literal=token="$TOKEN"
+sed -e "s|image: lnsp/mattermost-informer|image: $IMAGE|" -e "s|imagePullPolicy: Always|imagePullPolicy: Never|" informer.yaml | kubectl apply -f -
+kubectl rollout status deployment/mattermost-informer --timeout=120s || fail "informer did not become ready"
+
+echo "Forcing a crash loop"
+kubectl apply -f e2e/crashloop.yaml
+
+CHANNEL_ID=$(curl -sf -H "Authorization: Bearer $TOKEN" "$MATTERMOST_URL/api/v4/teams/name/e2e/channels/name/alerts" | jq -r .id)
+deadline=$((SECONDS + TIMEOUT))
+while [ $SECONDS -lt $deadline ]; do
+  ATTACHMENT=$(curl -sf -H "Authorization: Bearer $TOKEN" "$MATTERMOST_URL/api/v4/channels/$CHANNEL_ID/posts" |
+    jq -c '[.posts[] | .props.attachments[]? | select(.title | contains("Crash loop detected"))] | first // empty')
+  if [ -n "$ATTACHMENT" ]; then
+    break
+  fi
+  sleep 5
+done
+[ -n "${ATTACHMENT:-}" ] || fail "no alert posted within ${TIMEOUT}s"
+
+echo "Verifying the alert"
+field() {
+  jq -r --arg title "$1" '.fields[] | select(.title == $title) | .value' <<<"$ATTACHMENT"
+}
+jq -e '.text | contains("Container app of pod crashloop")' <<<"$ATTACHMENT" >/dev/null || fail "unexpected text: $ATTACHMENT"
+[ "$(jq -r .footer <<<"$ATTACHMENT")" = "kind-e2e (e2e)" ] || fail "unexpected footer: $ATTACHMENT"
+[ "$(field Severity)" = "critical" ] || fail "unexpected severity: $ATTACHMENT"
+[ "$(field Reason)" = "Error" ] || fail "unexpected reason: $ATTACHMENT"
+field Logs | grep -q e2e-crash-marker || fail "logs missing: $ATTACHMENT"
+
+echo "PASS"
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// TokenHeader carries the API token. A header other than Authorization is used, since requests are
+// sent through the service proxy of the Kubernetes API server, which authenticates using Authorization.
+const TokenHeader = "X-Informer-Token"
+
+// Prefix is the path all API endpoints are served under.
+const Prefix = "/api/v1/"
+
+// Status summarizes the state of the informer.
+type Status struct {
+	Namespaces           []string `json:"namespaces"`
+	QueueLength          int      `json:"queueLength"`
+	PendingNotifications int      `json:"pendingNotifications"`
+	FiringAlerts         int      `json:"firingAlerts"`
+	Silences             int      `json:"silences"`
+}
+
+// Alert is a notification which has been sent. Cluster, Severity, Channel and Notifier are only
+// known if the informer persists NotificationRecords.
+type Alert struct {
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container,omitempty"`
+	Reason    string    `json:"reason"`
+	Severity  string    `json:"severity,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	Notifier  string    `json:"notifier,omitempty"`
+}
+
+// AlertQuery filters the alert history. Empty fields match everything.
+type AlertQuery struct {
+	Namespace string
+	Pod       string
+	Since     time.Time
+	Limit     int
+}
+
+// FiringAlert is an alert which has been sent but not resolved yet.
+type FiringAlert struct {
+	Fingerprint string    `json:"fingerprint"`
+	Time        time.Time `json:"time"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Namespace   string    `json:"namespace"`
+	Pod         string    `json:"pod"`
+	Container   string    `json:"container,omitempty"`
+	Reason      string    `json:"reason"`
+	Severity    string    `json:"severity"`
+	Channel     string    `json:"channel,omitempty"`
+	Notifiers   []string  `json:"notifiers"`
+	// AcknowledgedBy is the user who acknowledged the alert, empty if nobody did.
+	AcknowledgedBy string `json:"acknowledgedBy,omitempty"`
+}
+
+// Acknowledgement records who acknowledged an alert, when and how.
+type Acknowledgement struct {
+	Fingerprint string    `json:"fingerprint"`
+	Time        time.Time `json:"time"`
+	// FiredAt is the time of the first notification about the alert.
+	FiredAt time.Time `json:"firedAt"`
+	User    string    `json:"user"`
+	// Via is either button or reaction.
+	Via       string `json:"via"`
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	Severity  string `json:"severity"`
+}
+
+// Silence suppresses the notifications of matching containers until it expires. Empty fields
+// match everything, Pod may be a glob pattern like api-*.
+type Silence struct {
+	ID        string    `json:"id,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Until     time.Time `json:"until"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// TestRequest asks the informer to send a synthetic crash notification through its routes.
+type TestRequest struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Reason    string `json:"reason"`
+	Severity  string `json:"severity"`
+}
+
+// Queued lists the notifiers a notification has been queued for.
+type Queued struct {
+	Channel   string   `json:"channel"`
+	Notifiers []string `json:"notifiers"`
+}
+
+// Client calls the API of an informer through the service proxy of the Kubernetes API server.
+type Client struct {
+	rest      rest.Interface
+	namespace string
+	service   string
+	token     string
+}
+
+// NewClient creates a client for the informer behind the named service, whose port is named http.
+func NewClient(restClient rest.Interface, namespace, service, token string) *Client {
+	return &Client{rest: restClient, namespace: namespace, service: service, token: token}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params map[string]string, body, result interface{}) error {
+	req := c.rest.Verb(method).
+		Namespace(c.namespace).
+		Resource("services").
+		Name(c.service+":http").
+		SubResource("proxy").
+		Suffix(Prefix+path).
+		SetHeader(TokenHeader, c.token)
+	for key, value := range params {
+		if value != "" {
+			req = req.Param(key, value)
+		}
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req = req.SetHeader("Content-Type", "application/json").Body(data)
+	}
+	data, err := req.DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("request to informer failed: %v", err)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("invalid response from informer: %v", err)
+	}
+	return nil
+}
+
+// Status returns the state of the informer.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	return &status, c.do(ctx, http.MethodGet, "status", nil, nil, &status)
+}
+
+// Alerts returns the sent alerts matching the query, newest first.
+func (c *Client) Alerts(ctx context.Context, query *AlertQuery) ([]Alert, error) {
+	params := map[string]string{"namespace": query.Namespace, "pod": query.Pod}
+	if !query.Since.IsZero() {
+		params["since"] = query.Since.Format(time.RFC3339)
+	}
+	if query.Limit > 0 {
+		params["limit"] = strconv.Itoa(query.Limit)
+	}
+	var alerts []Alert
+	if err := c.do(ctx, http.MethodGet, "alerts", params, nil, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// Silences returns the active silences.
+func (c *Client) Silences(ctx context.Context) ([]Silence, error) {
+	var silences []Silence
+	if err := c.do(ctx, http.MethodGet, "silences", nil, nil, &silences); err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+// CreateSilence creates a silence and returns it with its ID.
+func (c *Client) CreateSilence(ctx context.Context, silence *Silence) (*Silence, error) {
+	var created Silence
+	return &created, c.do(ctx, http.MethodPost, "silences", nil, silence, &created)
+}
+
+// DeleteSilence deletes the silence with the given ID.
+func (c *Client) DeleteSilence(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "silences/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Firing returns the alerts which have not been resolved yet, newest first.
+func (c *Client) Firing(ctx context.Context) ([]FiringAlert, error) {
+	var alerts []FiringAlert
+	if err := c.do(ctx, http.MethodGet, "firing", nil, nil, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// Acknowledgements returns the recent acknowledgements of alerts, newest first.
+func (c *Client) Acknowledgements(ctx context.Context) ([]Acknowledgement, error) {
+	var acks []Acknowledgement
+	if err := c.do(ctx, http.MethodGet, "acknowledgements", nil, nil, &acks); err != nil {
+		return nil, err
+	}
+	return acks, nil
+}
+
+// Resend sends the firing alert with the given fingerprint again.
+func (c *Client) Resend(ctx context.Context, fingerprint string) (*Queued, error) {
+	var queued Queued
+	return &queued, c.do(ctx, http.MethodPost, "firing/"+url.PathEscape(fingerprint)+"/resend", nil, nil, &queued)
+}
+
+// SendTest asks the informer to send a test notification.
+func (c *Client) SendTest(ctx context.Context, test *TestRequest) (*Queued, error) {
+	var queued Queued
+	return &queued, c.do(ctx, http.MethodPost, "test", nil, test, &queued)
+}
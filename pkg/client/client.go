@@ -1,18 +1,67 @@
 package client
 
 import (
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 func InCluster() (kubernetes.Interface, error) {
 	// creates the connection
 	config, err := rest.InClusterConfig()
 	if err != nil {
-		klog.Fatal(err)
+		return nil, err
 	}
 
 	// creates the clientset
+	return newClientset(config)
+}
+
+// FromKubeconfig creates a client for the cluster of a kubeconfig file, using its current
+// context unless another context is given.
+func FromKubeconfig(path, context string) (kubernetes.Interface, error) {
+	config, err := kubeconfig(path, context)
+	if err != nil {
+		return nil, err
+	}
+	return newClientset(config)
+}
+
+// DynamicFromKubeconfig creates a dynamic client for custom resources of the cluster of a
+// kubeconfig file, see FromKubeconfig.
+func DynamicFromKubeconfig(path, context string) (dynamic.Interface, error) {
+	config, err := kubeconfig(path, context)
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
+func kubeconfig(path, context string) (*rest.Config, error) {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+}
+
+func newClientset(config *rest.Config) (kubernetes.Interface, error) {
+	// Built-in resources support protobuf, which is much cheaper to decode than JSON.
+	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
+	config.ContentType = "application/vnd.kubernetes.protobuf"
 	return kubernetes.NewForConfig(config)
 }
+
+// Config returns the configuration used to connect to the cluster.
+func Config() (*rest.Config, error) {
+	return rest.InClusterConfig()
+}
+
+// InClusterDynamic creates a dynamic client for custom resources.
+func InClusterDynamic() (dynamic.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
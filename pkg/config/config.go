@@ -0,0 +1,675 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"text/template"
+	"time"
+
+	// The image ships without time zone data.
+	_ "time/tzdata"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"github.com/lnsp/mattermost-informer/pkg/kibana"
+	"github.com/lnsp/mattermost-informer/pkg/loki"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultPath is the location of the configuration file if none is given.
+const DefaultPath = "/etc/mattermost-informer/config.yaml"
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Config is the configuration of the informer.
+type Config struct {
+	Mattermost utils.MattermostConfig `json:"mattermost"`
+	// Cluster identifies the cluster in messages.
+	Cluster Cluster `json:"cluster"`
+	// ListenAddr is the address of the HTTP endpoint. Changes require a restart.
+	ListenAddr string `json:"listenAddr"`
+	SlashToken string `json:"slashToken"`
+	// APIToken authenticates requests to the API at /api/v1/, e.g. by the kubectl plugin.
+	// The API is disabled if empty.
+	APIToken string `json:"apiToken"`
+	// ExternalURL is the URL Mattermost reaches the HTTP endpoint at, e.g.
+	// http://mattermost-informer.monitoring:8080. It enables the buttons of alerts.
+	ExternalURL string `json:"externalURL"`
+
+	// Backoff is the default interval between the first two notifications for the same pod. Each
+	// further notification multiplies the interval by BackoffFactor up to MaxBackoff, until all
+	// containers of the pod are ready again.
+	Backoff       metav1.Duration `json:"backoff"`
+	BackoffFactor float64         `json:"backoffFactor"`
+	MaxBackoff    metav1.Duration `json:"maxBackoff"`
+	// MinRestarts is the number of restarts a container needs before it is alerted about, so that
+	// one-off crashes recovering by themselves are not notified.
+	MinRestarts int32 `json:"minRestarts"`
+	// Flapping delays resolving alerts and collapses containers which keep recovering and
+	// crashing again into a single notification.
+	Flapping Flapping `json:"flapping"`
+	// Anomalies configures the detection of unusual restart rates enabled with --restart-anomalies.
+	Anomalies Anomalies `json:"anomalies"`
+	// DefaultSeverity is used for pods without a severity annotation.
+	DefaultSeverity string   `json:"defaultSeverity"`
+	Playbook        Playbook `json:"playbook"`
+
+	// Priorities maps severities to Mattermost message priorities (urgent or important).
+	Priorities map[string]string `json:"priorities"`
+	// Emojis maps termination reasons or severities to emojis shown in front of the alert title.
+	Emojis map[string]string `json:"emojis"`
+	// Icons maps termination reasons or severities to icon URLs overriding the profile picture of alerts.
+	Icons map[string]string `json:"icons"`
+	// Colors maps termination reasons, severities or resolved to the colors of message attachments,
+	// e.g. #FFA500. Alerts without a color use notify.DefaultColor.
+	Colors map[string]string `json:"colors"`
+	// Escalations map severities to the escalation of alerts nobody acknowledged in time.
+	Escalations map[string]Escalation `json:"escalations"`
+
+	// Notifiers configure further notification backends alerts can be routed to.
+	Notifiers []Notifier `json:"notifiers"`
+	// Routes select the channel and notifiers of an alert. The first matching route wins,
+	// alerts not matching any route are sent to the default Mattermost channel.
+	Routes    []Route   `json:"routes"`
+	Templates Templates `json:"templates"`
+	// BusinessHours are the working hours routes can be restricted to.
+	BusinessHours BusinessHours `json:"businessHours"`
+	// Locale is the language titles, texts and field labels are rendered in, e.g. de. Namespaces
+	// and pods may select another locale with the espe.tech/mattermost-locale annotation.
+	Locale string `json:"locale"`
+	// Catalogs translate messages per locale, taking precedence over the builtin catalogs.
+	Catalogs i18n.Catalogs `json:"catalogs"`
+	// Timezone is the IANA time zone times are displayed in, e.g. Europe/Berlin, by default UTC.
+	// ChannelTimezones override it for the alerts routed to a channel.
+	Timezone         string            `json:"timezone"`
+	ChannelTimezones map[string]string `json:"channelTimezones"`
+	// OpsChannel receives notices about the informer itself, like starts and shutdowns. If empty
+	// they are posted to the default channel.
+	OpsChannel string `json:"opsChannel"`
+	// CapacityChannel receives the notifications about failed scale-ups, if empty they are routed
+	// like other alerts.
+	CapacityChannel string `json:"capacityChannel"`
+	// SendResolved posts a message to notifiers not resolving incidents themselves, like Mattermost,
+	// once the container of an alert recovered.
+	SendResolved bool `json:"sendResolved"`
+	// SyntheticChannel receives the synthetic crashes injected with --synthetic-crash-interval. If
+	// empty they are posted to the ops channel.
+	SyntheticChannel string `json:"syntheticChannel"`
+
+	// WeeklyReport schedules the weekly reliability report enabled with --weekly-report.
+	WeeklyReport Report `json:"weeklyReport"`
+	// TopCrashers schedules the leaderboard of crashing workloads enabled with --top-crashers.
+	TopCrashers TopCrashers `json:"topCrashers"`
+
+	Alertmanager Alertmanager `json:"alertmanager"`
+	// Loki optionally provides the logs of alerts.
+	Loki loki.Config `json:"loki"`
+	// Kibana optionally adds a link to the logs in Kibana to alerts.
+	Kibana kibana.Config `json:"kibana"`
+
+	location         *time.Location
+	channelLocations map[string]*time.Location
+}
+
+// Alertmanager configures the receiver relaying Prometheus alerts sent by Alertmanager webhooks.
+type Alertmanager struct {
+	// Token authenticates Alertmanager, the receiver is disabled if empty.
+	Token string `json:"token"`
+	// SendResolved also sends resolved alerts to notifiers not resolving incidents themselves.
+	SendResolved bool      `json:"sendResolved"`
+	Templates    Templates `json:"templates"`
+}
+
+// Flapping configures the hysteresis of resolving alerts.
+type Flapping struct {
+	// ResolveAfter is how long a container has to stay ready before its alert is resolved.
+	ResolveAfter metav1.Duration `json:"resolveAfter"`
+	// Threshold is the number of times a container may crash again while recovering within Window
+	// before it is considered flapping, 0 disables the detection.
+	Threshold int             `json:"threshold"`
+	Window    metav1.Duration `json:"window"`
+}
+
+// Escalation re-sends alerts which were neither acknowledged with a reaction to their Mattermost
+// post nor with their acknowledge button within After.
+type Escalation struct {
+	After metav1.Duration `json:"after"`
+	// Mentions are added to the escalated alert, e.g. @channel or @sre-leads.
+	Mentions []string `json:"mentions"`
+	// Channel and Notifiers receive the escalated alert instead of the channel and notifiers of
+	// the alert, e.g. a pager.
+	Channel   string   `json:"channel"`
+	Notifiers []string `json:"notifiers"`
+}
+
+// Anomalies configures when the restart rate of a workload deviates from its baseline.
+type Anomalies struct {
+	// Interval is the time restarts are counted over, e.g. 1h.
+	Interval metav1.Duration `json:"interval"`
+	// Threshold is the number of standard deviations the restarts of the current interval must
+	// exceed the mean of the previous intervals by.
+	Threshold float64 `json:"threshold"`
+	// MinRestarts is the minimum number of restarts in an interval to be alerted about.
+	MinRestarts int `json:"minRestarts"`
+	// Warmup is the number of intervals observed before a baseline is trusted.
+	Warmup int `json:"warmup"`
+}
+
+// Report schedules a report posted once a week.
+type Report struct {
+	// Weekday and Hour select when the report is posted in the configured time zone.
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+
+	weekday time.Weekday
+}
+
+// Day returns the weekday the report is posted on.
+func (r *Report) Day() time.Weekday {
+	return r.weekday
+}
+
+func (r *Report) validate() error {
+	if r.Hour < 0 || r.Hour > 23 {
+		return fmt.Errorf("hour %d is not between 0 and 23", r.Hour)
+	}
+	var err error
+	r.weekday, err = utils.ParseWeekday(r.Weekday)
+	return err
+}
+
+// TopCrashers schedules a leaderboard of the workloads with the most alerts.
+type TopCrashers struct {
+	Report
+	// Window is the time alerts are counted over and Limit the number of listed workloads.
+	Window metav1.Duration `json:"window"`
+	Limit  int             `json:"limit"`
+	// Channel receives the leaderboard, if empty it is posted to the ops channel.
+	Channel string `json:"channel"`
+}
+
+func (t *TopCrashers) validate() error {
+	if t.Window.Duration <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	if t.Limit <= 0 {
+		return fmt.Errorf("limit must be positive")
+	}
+	return t.Report.validate()
+}
+
+// BusinessHours are the working hours on the given days, except on holidays.
+type BusinessHours struct {
+	// TimeZone is the IANA time zone of the hours, by default the configured time zone.
+	TimeZone string `json:"timezone"`
+	// Days are week days like Mon or Monday.
+	Days []string `json:"days"`
+	// Start and End are times of the day like 09:00. Hours ending before they start end on the
+	// next day, hours ending when they start last a whole day.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Holidays are dates like 2026-12-24 outside of the business hours.
+	Holidays []string `json:"holidays"`
+
+	location   *time.Location
+	days       map[time.Weekday]bool
+	start, end time.Duration
+	holidays   map[string]bool
+}
+
+func (b *BusinessHours) compile(location *time.Location) error {
+	b.location = location
+	if b.TimeZone != "" {
+		var err error
+		if b.location, err = time.LoadLocation(b.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone: %v", err)
+		}
+	}
+	b.days = make(map[time.Weekday]bool)
+	for _, value := range b.Days {
+		day, err := utils.ParseWeekday(value)
+		if err != nil {
+			return err
+		}
+		b.days[day] = true
+	}
+	var err error
+	if b.start, err = utils.ParseTimeOfDay(b.Start); err != nil {
+		return err
+	}
+	if b.end, err = utils.ParseTimeOfDay(b.End); err != nil {
+		return err
+	}
+	b.holidays = make(map[string]bool)
+	for _, holiday := range b.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			return fmt.Errorf("holiday %q is not a date like 2006-01-02", holiday)
+		}
+		b.holidays[holiday] = true
+	}
+	return nil
+}
+
+// Contains checks if the time is within the business hours. Hours lasting past midnight belong to
+// the day they start on.
+func (b *BusinessHours) Contains(t time.Time) bool {
+	if b.location != nil {
+		t = t.In(b.location)
+	}
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if b.start < b.end {
+		return b.isBusinessDay(t) && sinceMidnight >= b.start && sinceMidnight < b.end
+	}
+	if sinceMidnight >= b.start {
+		return b.isBusinessDay(t)
+	}
+	return sinceMidnight < b.end && b.isBusinessDay(t.AddDate(0, 0, -1))
+}
+
+func (b *BusinessHours) isBusinessDay(t time.Time) bool {
+	return b.days[t.Weekday()] && !b.holidays[t.Format("2006-01-02")]
+}
+
+// Cluster names the cluster and its environment, so that channels fed by multiple clusters
+// stay unambiguous.
+type Cluster struct {
+	Name string `json:"name"`
+	// Environment is e.g. production or staging.
+	Environment string `json:"environment"`
+	// Environments are the environments of further clusters watched with --cluster, by name.
+	Environments map[string]string `json:"environments"`
+}
+
+// Identity returns the name and environment of the named cluster, or the local cluster if the
+// name is empty.
+func (c *Cluster) Identity(name string) (cluster, environment string) {
+	if name == "" {
+		return c.Name, c.Environment
+	}
+	return name, c.Environments[name]
+}
+
+// Notifier configures a notification backend.
+type Notifier struct {
+	// Name is used to reference the notifier in routes.
+	Name string `json:"name"`
+	// Type selects the backend, e.g. mattermost.
+	Type string `json:"type"`
+	// Config holds the settings of the backend.
+	Config json.RawMessage `json:"config"`
+}
+
+// Playbook configures the Mattermost Playbook started for severe alerts.
+type Playbook struct {
+	ID string `json:"id"`
+	// Severity is the minimum severity of alerts starting a playbook run.
+	Severity string `json:"severity"`
+}
+
+// Values of Route.Hours.
+const (
+	HoursBusiness = "business"
+	HoursOff      = "offHours"
+)
+
+// Route sends matching alerts to a channel. Empty match lists match everything.
+type Route struct {
+	Namespaces []string `json:"namespaces"`
+	Severities []string `json:"severities"`
+	Reasons    []string `json:"reasons"`
+	// Hours restricts the route to the business hours or the off hours, empty matches any time.
+	Hours   string `json:"hours"`
+	Channel string `json:"channel"`
+	// Notifiers are the names of the notifiers receiving matching alerts, by default Mattermost.
+	Notifiers []string `json:"notifiers"`
+}
+
+// Matches checks if an alert with the given properties matches the route.
+func (r *Route) Matches(namespace, severity string, reasons ...string) bool {
+	if !matchAny(r.Namespaces, namespace) || !matchAny(r.Severities, severity) {
+		return false
+	}
+	return matchAny(r.Reasons, reasons...)
+}
+
+func matchAny(patterns []string, values ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if pattern == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Location returns the time zone times are displayed in for the given channel, UTC if none is configured.
+func (c *Config) Location(channel string) *time.Location {
+	if location, ok := c.channelLocations[channel]; ok {
+		return location
+	}
+	if c.location != nil {
+		return c.location
+	}
+	return time.UTC
+}
+
+// loadLocations loads the configured time zones.
+func (c *Config) loadLocations() []error {
+	var errs []error
+	var err error
+	if c.location, err = time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("invalid timezone: %v", err))
+	}
+	c.channelLocations = make(map[string]*time.Location, len(c.ChannelTimezones))
+	for channel, timezone := range c.ChannelTimezones {
+		if c.channelLocations[channel], err = time.LoadLocation(timezone); err != nil {
+			errs = append(errs, fmt.Errorf("invalid timezone of channel %s: %v", channel, err))
+		}
+	}
+	return errs
+}
+
+// Route returns the first route matching alerts with the given properties at the given time, nil
+// if none matches.
+func (c *Config) Route(now time.Time, namespace, severity string, reasons ...string) *Route {
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if route.Hours != "" && (route.Hours == HoursBusiness) != c.BusinessHours.Contains(now) {
+			continue
+		}
+		if route.Matches(namespace, severity, reasons...) {
+			return route
+		}
+	}
+	return nil
+}
+
+// Routing returns the channel and the names of the notifiers alerts with the given properties are
+// routed to at the given time, both taken from the same route. An empty channel denotes the
+// default channel.
+func (c *Config) Routing(now time.Time, namespace, severity string, reasons ...string) (string, []string) {
+	route := c.Route(now, namespace, severity, reasons...)
+	if route == nil {
+		return "", []string{notify.TypeMattermost}
+	}
+	if len(route.Notifiers) == 0 {
+		return route.Channel, []string{notify.TypeMattermost}
+	}
+	return route.Channel, route.Notifiers
+}
+
+// Templates are Go text templates rendering the title and text of alerts. Templates can translate
+// messages with the t function, e.g. {{t "Crash loop detected!"}}, which formats further arguments.
+type Templates struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+
+	title, text *template.Template
+}
+
+// Render renders the title and text templates in the given locale, translating with the catalogs
+// besides the builtin ones.
+func (t *Templates) Render(catalogs i18n.Catalogs, locale string, data interface{}) (title, text string, err error) {
+	funcs := translateFuncs(catalogs, locale)
+	var buf bytes.Buffer
+	if err := template.Must(t.title.Clone()).Funcs(funcs).Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("could not render title: %v", err)
+	}
+	title = buf.String()
+	buf.Reset()
+	if err := template.Must(t.text.Clone()).Funcs(funcs).Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("could not render text: %v", err)
+	}
+	return title, buf.String(), nil
+}
+
+// Compile parses the templates, it must be called before Render.
+func (t *Templates) Compile() error {
+	funcs := translateFuncs(nil, i18n.DefaultLocale)
+	var err error
+	if t.title, err = template.New("title").Funcs(funcs).Parse(t.Title); err != nil {
+		return fmt.Errorf("invalid title template: %v", err)
+	}
+	if t.text, err = template.New("text").Funcs(funcs).Parse(t.Text); err != nil {
+		return fmt.Errorf("invalid text template: %v", err)
+	}
+	return nil
+}
+
+// translateFuncs returns the template functions translating to the locale.
+func translateFuncs(catalogs i18n.Catalogs, locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(message string, args ...interface{}) string {
+			if len(args) == 0 {
+				return catalogs.Translate(locale, message)
+			}
+			return catalogs.Sprintf(locale, message, args...)
+		},
+	}
+}
+
+// Default returns the configuration used for unset values.
+func Default() *Config {
+	return &Config{
+		ListenAddr:      ":8080",
+		Backoff:         metav1.Duration{Duration: 10 * time.Minute},
+		BackoffFactor:   3,
+		MaxBackoff:      metav1.Duration{Duration: time.Hour},
+		DefaultSeverity: "warning",
+		Playbook:        Playbook{Severity: "critical"},
+		WeeklyReport:    Report{Weekday: "Monday", Hour: 9},
+		Priorities:      map[string]string{"critical": "urgent", "warning": "important"},
+		Emojis:          map[string]string{"critical": "rotating_light", "warning": "warning", "info": "information_source"},
+		Flapping: Flapping{
+			ResolveAfter: metav1.Duration{Duration: 5 * time.Minute},
+			Threshold:    3,
+			Window:       metav1.Duration{Duration: time.Hour},
+		},
+		Anomalies: Anomalies{
+			Interval:    metav1.Duration{Duration: time.Hour},
+			Threshold:   3,
+			MinRestarts: 3,
+			Warmup:      24,
+		},
+		BusinessHours: BusinessHours{
+			Days:  []string{"Mon", "Tue", "Wed", "Thu", "Fri"},
+			Start: "09:00",
+			End:   "17:00",
+		},
+		TopCrashers: TopCrashers{
+			Report: Report{Weekday: "Monday", Hour: 9},
+			Window: metav1.Duration{Duration: 7 * 24 * time.Hour},
+			Limit:  10,
+		},
+		Templates: Templates{
+			Title: `{{t "Crash loop detected!"}}`,
+			Text:  `{{t "Container %s of pod %s keeps crashing, maybe its time to intervene." .Container .Pod}} {{t "Last crash at %s." (.Time.Format "2006-01-02 15:04 MST")}}`,
+		},
+		Loki: loki.Config{
+			Window: metav1.Duration{Duration: 15 * time.Minute},
+			Limit:  100,
+		},
+		Kibana: kibana.Config{
+			Before: metav1.Duration{Duration: 15 * time.Minute},
+			After:  metav1.Duration{Duration: 5 * time.Minute},
+		},
+		Alertmanager: Alertmanager{
+			Templates: Templates{
+				Title: `{{if eq .Status "resolved"}}{{t "Resolved"}}: {{end}}{{.Reason}}`,
+				Text:  "{{or .Annotations.summary .Annotations.description}}",
+			},
+		},
+	}
+}
+
+// Parse parses and validates a YAML configuration. All problems found are reported at once.
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("could not parse configuration: %v", err)
+	}
+	var errs []error
+	if cfg.MinRestarts < 0 {
+		errs = append(errs, fmt.Errorf("minimum restarts must not be negative"))
+	}
+	if cfg.BackoffFactor < 1 {
+		errs = append(errs, fmt.Errorf("backoff factor must be at least 1"))
+	}
+	if cfg.Flapping.ResolveAfter.Duration < 0 || cfg.Flapping.Threshold < 0 || (cfg.Flapping.Threshold > 0 && cfg.Flapping.Window.Duration <= 0) {
+		errs = append(errs, fmt.Errorf("flapping needs a non-negative resolve delay and threshold and a positive window"))
+	}
+	if cfg.Anomalies.Interval.Duration <= 0 || cfg.Anomalies.Threshold <= 0 || cfg.Anomalies.MinRestarts < 1 || cfg.Anomalies.Warmup < 1 {
+		errs = append(errs, fmt.Errorf("anomalies need a positive interval, threshold, minimum of restarts and warmup"))
+	}
+	if cfg.Locale != "" && !i18n.Supported(cfg.Locale, cfg.Catalogs) {
+		errs = append(errs, fmt.Errorf("locale %q has no catalog, must be one of %v or configured in catalogs", cfg.Locale, i18n.Locales()))
+	}
+	if err := cfg.Templates.Compile(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Alertmanager.Templates.Compile(); err != nil {
+		errs = append(errs, fmt.Errorf("alertmanager: %v", err))
+	}
+	if err := cfg.Loki.Compile(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Kibana.Compile(); err != nil {
+		errs = append(errs, err)
+	}
+	for severity, escalation := range cfg.Escalations {
+		if escalation.After.Duration <= 0 {
+			errs = append(errs, fmt.Errorf("escalation of %s needs a positive delay", severity))
+		}
+	}
+	if cfg.ExternalURL != "" {
+		if u, err := url.Parse(cfg.ExternalURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("external URL %q is not an absolute HTTP URL", cfg.ExternalURL))
+		}
+	}
+	for key, color := range cfg.Colors {
+		if !colorPattern.MatchString(color) {
+			errs = append(errs, fmt.Errorf("color %q of %s is not a hex color like #AD2200", color, key))
+		}
+	}
+	if err := cfg.WeeklyReport.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("weekly report: %v", err))
+	}
+	if err := cfg.TopCrashers.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("top crashers: %v", err))
+	}
+	errs = append(errs, cfg.loadLocations()...)
+	if err := cfg.BusinessHours.compile(cfg.Location("")); err != nil {
+		errs = append(errs, fmt.Errorf("business hours: %v", err))
+	}
+	for i, route := range cfg.Routes {
+		if route.Hours != "" && route.Hours != HoursBusiness && route.Hours != HoursOff {
+			errs = append(errs, fmt.Errorf("route %d has unknown hours %q, must be %s or %s", i+1, route.Hours, HoursBusiness, HoursOff))
+		}
+	}
+	errs = append(errs, cfg.validateNotifiers()...)
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return cfg, nil
+}
+
+// validateNotifiers checks that all notifiers have a unique name and known type, and that
+// routes only reference known notifiers.
+func (c *Config) validateNotifiers() []error {
+	var errs []error
+	names := map[string]bool{notify.TypeMattermost: true}
+	types := make(map[string]bool)
+	for _, kind := range notify.Types() {
+		types[kind] = true
+	}
+	for _, notifier := range c.Notifiers {
+		if notifier.Name == "" || names[notifier.Name] {
+			errs = append(errs, fmt.Errorf("notifier name %q is empty or not unique", notifier.Name))
+		}
+		if !types[notifier.Type] {
+			errs = append(errs, fmt.Errorf("notifier %s has unknown type %q, must be one of %v", notifier.Name, notifier.Type, notify.Types()))
+		}
+		names[notifier.Name] = true
+	}
+	for i, route := range c.Routes {
+		for _, name := range route.Notifiers {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("route %d references unknown notifier %q", i+1, name))
+			}
+		}
+	}
+	for severity, escalation := range c.Escalations {
+		for _, name := range escalation.Notifiers {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("escalation of %s references unknown notifier %q", severity, name))
+			}
+		}
+	}
+	return errs
+}
+
+// Load reads the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration: %v", err)
+	}
+	return Parse(data)
+}
+
+// Watch calls onChange with the new configuration whenever the file at path changes, until stopCh is closed.
+// Invalid configurations are logged and ignored.
+func Watch(path string, stopCh <-chan struct{}, onChange func(*Config)) error {
+	return WatchFile(path, stopCh, func(data []byte) {
+		cfg, err := Parse(data)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid configuration", "path", path)
+			return
+		}
+		klog.InfoS("Reloaded configuration", "path", path)
+		onChange(cfg)
+	})
+}
+
+// WatchFile calls onChange with the new content whenever the file at path changes, until stopCh is closed.
+// The parent directory is watched since ConfigMap and Secret volumes replace files by swapping symlinks.
+func WatchFile(path string, stopCh <-chan struct{}, onChange func([]byte)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+	last, _ := ioutil.ReadFile(path)
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case err := <-watcher.Errors:
+			klog.ErrorS(err, "Watching file failed", "path", path)
+		case <-watcher.Events:
+			data, err := ioutil.ReadFile(path)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			onChange(data)
+		}
+	}
+}
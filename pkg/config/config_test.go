@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBusinessHoursContains(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		start, end string
+		time       time.Time
+		contains   bool
+	}{
+		{"during the day", "09:00", "17:00", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), true},
+		{"at the end of the day", "09:00", "17:00", time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC), false},
+		{"until midnight", "09:00", "24:00", time.Date(2026, 3, 2, 23, 59, 0, 0, time.UTC), true},
+		{"on the weekend", "09:00", "17:00", time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC), false},
+		{"on a holiday", "09:00", "17:00", time.Date(2026, 12, 24, 12, 0, 0, 0, time.UTC), false},
+		{"before midnight of a night", "22:00", "06:00", time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC), true},
+		{"after midnight of a night", "22:00", "06:00", time.Date(2026, 3, 3, 5, 0, 0, 0, time.UTC), true},
+		{"after a night", "22:00", "06:00", time.Date(2026, 3, 3, 6, 0, 0, 0, time.UTC), false},
+		// The night starting on Friday lasts until Saturday, the one of Sunday is off.
+		{"after midnight of a Friday night", "22:00", "06:00", time.Date(2026, 3, 7, 5, 0, 0, 0, time.UTC), true},
+		{"after midnight of a Sunday night", "22:00", "06:00", time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC), false},
+		{"whole day", "09:00", "09:00", time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC), true},
+	} {
+		hours := BusinessHours{Days: []string{"Mon", "tuesday", "Wed", "Thu", "Fri"}, Start: test.start, End: test.end, Holidays: []string{"2026-12-24"}}
+		if err := hours.compile(time.UTC); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if contains := hours.Contains(test.time); contains != test.contains {
+			t.Errorf("%s: Contains(%s) = %v, want %v", test.name, test.time, contains, test.contains)
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	cfg, err := Parse([]byte(`
+businessHours:
+  timezone: Europe/Berlin
+notifiers:
+- name: pager
+  type: webhook
+  config:
+    url: https://pager.example.com
+routes:
+- namespaces: [payments]
+  hours: business
+  channel: payments-alerts
+- namespaces: [payments]
+  hours: offHours
+  channel: payments-on-call
+  notifiers: [mattermost, pager]
+- severities: [critical]
+  channel: critical
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	for _, test := range []struct {
+		namespace, severity string
+		time                time.Time
+		channel             string
+		notifiers           []string
+	}{
+		{"payments", "warning", time.Date(2026, 3, 2, 16, 59, 59, 0, berlin), "payments-alerts", []string{"mattermost"}},
+		{"payments", "warning", time.Date(2026, 3, 2, 17, 0, 0, 0, berlin), "payments-on-call", []string{"mattermost", "pager"}},
+		{"shop", "critical", time.Date(2026, 3, 2, 12, 0, 0, 0, berlin), "critical", []string{"mattermost"}},
+		{"shop", "warning", time.Date(2026, 3, 2, 12, 0, 0, 0, berlin), "", []string{"mattermost"}},
+	} {
+		channel, notifiers := cfg.Routing(test.time, test.namespace, test.severity, "CrashLoopBackOff")
+		if channel != test.channel || !reflect.DeepEqual(notifiers, test.notifiers) {
+			t.Errorf("Routing(%s, %s, %s) = %s, %v, want %s, %v", test.time, test.namespace, test.severity, channel, notifiers, test.channel, test.notifiers)
+		}
+	}
+}
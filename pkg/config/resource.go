@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// ResourceGVR identifies the MattermostInformer custom resource holding the configuration in its spec.
+var ResourceGVR = schema.GroupVersionResource{
+	Group:    "informer.espe.tech",
+	Version:  "v1alpha1",
+	Resource: "mattermostinformers",
+}
+
+// FromResource parses the configuration from the spec of a MattermostInformer resource.
+func FromResource(obj *unstructured.Unstructured) (*Config, error) {
+	spec, _, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// LoadResource reads the configuration from the named MattermostInformer resource.
+func LoadResource(client dynamic.Interface, namespace, name string) (*Config, error) {
+	obj, err := client.Resource(ResourceGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get configuration resource: %v", err)
+	}
+	cfg, err := FromResource(obj)
+	reportStatus(client, obj, err)
+	return cfg, err
+}
+
+// WatchResource calls onChange with the new configuration whenever the named MattermostInformer
+// resource changes, until stopCh is closed. Invalid configurations are reported in the resource status
+// and ignored.
+func WatchResource(client dynamic.Interface, namespace, name string, stopCh <-chan struct{}, onChange func(*Config)) {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, namespace, func(options *metav1.ListOptions) {
+		options.FieldSelector = "metadata.name=" + name
+	})
+	informer := factory.ForResource(ResourceGVR).Informer()
+	var generation int64
+	update := func(obj interface{}) {
+		resource, ok := obj.(*unstructured.Unstructured)
+		if !ok || resource.GetGeneration() == generation {
+			return
+		}
+		generation = resource.GetGeneration()
+		cfg, err := FromResource(resource)
+		reportStatus(client, resource, err)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid configuration", "resource", klog.KRef(namespace, name))
+			return
+		}
+		klog.InfoS("Reloaded configuration", "resource", klog.KRef(namespace, name), "generation", generation)
+		onChange(cfg)
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    update,
+		UpdateFunc: func(old, new interface{}) { update(new) },
+	})
+	informer.Run(stopCh)
+}
+
+// reportStatus records the outcome of parsing the configuration in the resource status.
+func reportStatus(client dynamic.Interface, obj *unstructured.Unstructured, parseErr error) {
+	obj = obj.DeepCopy()
+	message := ""
+	if parseErr != nil {
+		message = parseErr.Error()
+	}
+	status := map[string]interface{}{
+		"observedGeneration": obj.GetGeneration(),
+		"valid":              parseErr == nil,
+		"error":              message,
+	}
+	if err := unstructured.SetNestedMap(obj.Object, status, "status"); err != nil {
+		klog.ErrorS(err, "Updating configuration status failed", "resource", klog.KObj(obj))
+		return
+	}
+	if _, err := client.Resource(ResourceGVR).Namespace(obj.GetNamespace()).UpdateStatus(context.TODO(), obj, metav1.UpdateOptions{}); err != nil {
+		klog.ErrorS(err, "Updating configuration status failed", "resource", klog.KObj(obj))
+	}
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	acknowledgementsKey = "acknowledgements.json"
+	// acknowledgementRetention is how long acknowledgements are kept for the API.
+	acknowledgementRetention = 7 * 24 * time.Hour
+	// minActionSecretLength is the minimum length of the secret signing the acknowledge buttons.
+	minActionSecretLength = 32
+)
+
+// acknowledgementState is the form the acknowledgements are persisted in.
+type acknowledgementState struct {
+	// Secret held the secret signing the buttons in earlier versions, it is removed when found.
+	Secret           string                         `json:"secret,omitempty"`
+	Acknowledgements map[string]api.Acknowledgement `json:"acknowledgements"`
+}
+
+// acknowledgements holds the latest acknowledgement per fingerprint. The zero value is ready to use,
+// buttons can be signed once the secret is set.
+type acknowledgements struct {
+	mu      sync.Mutex
+	secret  []byte
+	entries map[string]api.Acknowledgement
+}
+
+// setSecret sets the secret signing the tokens of acknowledge buttons, which all replicas share.
+func (a *acknowledgements) setSecret(secret string) error {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minActionSecretLength {
+		return fmt.Errorf("action secret must be at least %d characters long", minActionSecretLength)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.secret = []byte(secret)
+	return nil
+}
+
+// canSign reports whether a secret to sign the tokens of acknowledge buttons is set.
+func (a *acknowledgements) canSign() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.secret != nil
+}
+
+// token returns the token authenticating the acknowledge button of the alert with the fingerprint
+// fired at the given time, so that the token of an old alert does not acknowledge later ones.
+func (a *acknowledgements) token(fingerprint string, firedAt time.Time) (string, error) {
+	a.mu.Lock()
+	secret := a.secret
+	a.mu.Unlock()
+	if secret == nil {
+		return "", fmt.Errorf("no action secret configured")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fingerprint + "/" + firedAt.UTC().Format(time.RFC3339)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify checks the token of an acknowledge button.
+func (a *acknowledgements) verify(fingerprint string, firedAt time.Time, token string) bool {
+	expected, err := a.token(fingerprint, firedAt)
+	return err == nil && hmac.Equal([]byte(expected), []byte(token))
+}
+
+func (a *acknowledgements) add(ack api.Acknowledgement) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.entries == nil {
+		a.entries = make(map[string]api.Acknowledgement)
+	}
+	a.entries[ack.Fingerprint] = ack
+}
+
+// acknowledged returns the acknowledgement of the alert with the fingerprint if it has been
+// acknowledged since the given time.
+func (a *acknowledgements) acknowledged(fingerprint string, since time.Time) (api.Acknowledgement, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ack, ok := a.entries[fingerprint]
+	if !ok || ack.Time.Before(since) {
+		return api.Acknowledgement{}, false
+	}
+	return ack, true
+}
+
+// list returns the acknowledgements, newest first.
+func (a *acknowledgements) list() []api.Acknowledgement {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acks := make([]api.Acknowledgement, 0, len(a.entries))
+	for _, ack := range a.entries {
+		acks = append(acks, ack)
+	}
+	sort.Slice(acks, func(i, j int) bool { return acks[i].Time.After(acks[j].Time) })
+	return acks
+}
+
+// merge adopts the newer persisted acknowledgements, dropping those older than expiry. It returns
+// the merged state and whether it differs from the persisted one.
+func (a *acknowledgements) merge(stored acknowledgementState, expiry time.Time) (acknowledgementState, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The secret persisted by earlier versions is readable by everyone reading the ConfigMap.
+	changed := stored.Secret != ""
+	if a.entries == nil {
+		a.entries = make(map[string]api.Acknowledgement)
+	}
+	for fingerprint, ack := range stored.Acknowledgements {
+		if local, ok := a.entries[fingerprint]; !ok || ack.Time.After(local.Time) {
+			a.entries[fingerprint] = ack
+		}
+	}
+	merged := acknowledgementState{
+		Acknowledgements: make(map[string]api.Acknowledgement, len(a.entries)),
+	}
+	for fingerprint, ack := range a.entries {
+		if ack.Time.Before(expiry) {
+			delete(a.entries, fingerprint)
+			changed = changed || hasAcknowledgement(stored, fingerprint)
+			continue
+		}
+		merged.Acknowledgements[fingerprint] = ack
+		if persisted, ok := stored.Acknowledgements[fingerprint]; !ok || !persisted.Time.Equal(ack.Time) {
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
+func hasAcknowledgement(state acknowledgementState, fingerprint string) bool {
+	_, ok := state.Acknowledgements[fingerprint]
+	return ok
+}
+
+// syncAcknowledgements merges the acknowledgements with those persisted in the state ConfigMap by
+// all replicas, and writes back the merged acknowledgements if they changed. Without a state
+// ConfigMap, acknowledgements are only kept in memory.
+func (c *Controller) syncAcknowledgements() error {
+	if c.stateConfigMap == "" {
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := c.stateClient.CoreV1().ConfigMaps(c.stateNamespace).Get(context.TODO(), c.stateConfigMap, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			configMap = nil
+		} else if err != nil {
+			return err
+		}
+		var stored acknowledgementState
+		if configMap != nil {
+			if data, ok := configMap.Data[acknowledgementsKey]; ok {
+				if err := json.Unmarshal([]byte(data), &stored); err != nil {
+					klog.ErrorS(err, "Discarding invalid acknowledgements", "configMap", klog.KRef(c.stateNamespace, c.stateConfigMap))
+				}
+			}
+		}
+		merged, changed := c.acks.merge(stored, c.clock.Now().Add(-acknowledgementRetention))
+		if !changed {
+			return nil
+		}
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		return c.writeState(configMap, map[string][]byte{acknowledgementsKey: data})
+	})
+}
+
+// acknowledge records who acknowledged an alert, stops its escalation and persists the
+// acknowledgement right away, so that other replicas learn about it.
+func (c *Controller) acknowledge(ack api.Acknowledgement) {
+	c.acks.add(ack)
+	for _, controller := range append([]*Controller{c}, c.clusters...) {
+		controller.escalations.cancel(ack.Fingerprint)
+	}
+	acknowledgementsTotal.WithLabelValues(ack.Namespace, ack.Via).Inc()
+	if !ack.FiredAt.IsZero() {
+		timeToAcknowledge.WithLabelValues(ack.Severity).Observe(ack.Time.Sub(ack.FiredAt).Seconds())
+	}
+	klog.InfoS("Alert acknowledged", "pod", klog.KRef(ack.Namespace, ack.Pod), "container", ack.Container,
+		"user", ack.User, "via", ack.Via)
+	if err := c.syncAcknowledgements(); err != nil {
+		klog.ErrorS(err, "Persisting acknowledgement failed", "pod", klog.KRef(ack.Namespace, ack.Pod))
+	}
+}
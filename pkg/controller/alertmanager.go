@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"k8s.io/klog/v2"
+)
+
+// alertmanagerMessage is the payload of Alertmanager webhooks, see
+// https://prometheus.io/docs/alerting/latest/configuration/#webhook_config.
+type alertmanagerMessage struct {
+	Version  string              `json:"version"`
+	GroupKey string              `json:"groupKey"`
+	Status   string              `json:"status"`
+	Receiver string              `json:"receiver"`
+	Alerts   []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+const alertmanagerMaxBody = 1 << 20
+
+// AlertmanagerHandler returns a HTTP handler implementing the Alertmanager webhook receiver.
+// Requests not carrying the configured bearer token are rejected.
+func (c *Controller) AlertmanagerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cfg, _ := c.settings()
+		if cfg.Alertmanager.Token == "" ||
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+cfg.Alertmanager.Token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		var msg alertmanagerMessage
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, alertmanagerMaxBody)).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i := range msg.Alerts {
+			c.relayAlert(r.Context(), cfg, &msg.Alerts[i])
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// relayAlert sends a Prometheus alert using the templates and routes of the configuration.
+// Resolved alerts resolve the incidents of notifiers supporting it.
+func (c *Controller) relayAlert(ctx context.Context, cfg *config.Config, am *alertmanagerAlert) {
+	severity := am.Labels["severity"]
+	if _, err := ParseSeverity(severity); err != nil {
+		severity = cfg.DefaultSeverity
+	}
+	data := &alertData{
+		Namespace:   am.Labels["namespace"],
+		Pod:         am.Labels["pod"],
+		Container:   am.Labels["container"],
+		Reason:      am.Labels["alertname"],
+		Severity:    severity,
+		Status:      am.Status,
+		Labels:      am.Labels,
+		Annotations: am.Annotations,
+	}
+	channel, names := cfg.Routing(c.clock.Now(), data.Namespace, severity, data.Reason)
+	data.Time = am.StartsAt.In(cfg.Location(channel))
+	locale := c.locale(cfg, nil, data.Namespace, c.namespaceAnnotation(data.Namespace, annotationMattermostLocale))
+	title, text, err := cfg.Alertmanager.Templates.Render(cfg.Catalogs, locale, data)
+	if err != nil {
+		klog.ErrorS(err, "Rendering Alertmanager alert failed", "alertname", data.Reason)
+		return
+	}
+	emoji, ok := cfg.Emojis[data.Reason]
+	if !ok {
+		emoji = cfg.Emojis[severity]
+	}
+	icon, ok := cfg.Icons[data.Reason]
+	if !ok {
+		icon = cfg.Icons[severity]
+	}
+	color, ok := cfg.Colors[data.Reason]
+	if !ok {
+		color = cfg.Colors[severity]
+	}
+	if resolved, ok := cfg.Colors["resolved"]; ok && am.Status == "resolved" {
+		color = resolved
+	}
+	fingerprint := am.Fingerprint
+	if fingerprint == "" {
+		fingerprint = notify.Fingerprint(data.Namespace, data.Pod, data.Reason)
+	}
+	alert := &notify.Alert{
+		Time:        am.StartsAt,
+		Fingerprint: fingerprint,
+		Namespace:   data.Namespace,
+		Pod:         data.Pod,
+		Container:   data.Container,
+		Reason:      data.Reason,
+		Severity:    severity,
+		Title:       title,
+		Text:        text,
+		Channel:     channel,
+		Priority:    cfg.Priorities[severity],
+		Emoji:       emoji,
+		IconURL:     icon,
+		Color:       color,
+		Locale:      locale,
+	}
+	klog.InfoS("Relaying Alertmanager alert", "alertname", data.Reason, "status", am.Status,
+		"severity", severity, "channel", alert.Channel, "notifiers", names)
+	if am.Status == "resolved" {
+		c.publish(stream.EventResolved, alert, "", nil)
+		for _, name := range names {
+			resolver, ok := c.notifier(name).(notify.Resolver)
+			if (!ok && !cfg.Alertmanager.SendResolved) || c.spoolResolve(name, alert) {
+				continue
+			}
+			if ok {
+				c.enqueueDelivery(ctx, "resolve", name, alert, resolver.Resolve, nil)
+			} else {
+				c.enqueueAlert(ctx, name, alert, nil)
+			}
+		}
+		return
+	}
+	c.publish(stream.EventDetected, alert, "", nil)
+	recordHistory := c.history.recordOnDelivery(alertRecord{
+		Time:      c.clock.Now(),
+		Namespace: data.Namespace,
+		Pod:       data.Pod,
+		Container: data.Container,
+		Reason:    data.Reason,
+	})
+	for _, name := range names {
+		c.enqueueAlert(ctx, name, alert, recordHistory)
+	}
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// anomalyWeight is the weight of the last interval in the baseline, so that roughly the last
+	// 20 intervals shape it.
+	anomalyWeight = 0.1
+	// anomalyMinDeviation keeps workloads which never restarted from being alerted on a single restart.
+	anomalyMinDeviation = 1.0
+	// anomalyMaxGap bounds the number of empty intervals folded into a baseline at once, after
+	// which it has converged to zero anyway.
+	anomalyMaxGap = 200
+)
+
+// restartRate counts the restarts of a workload in the current interval and keeps the
+// exponentially weighted mean and variance of the previous intervals as its baseline.
+type restartRate struct {
+	interval       time.Time
+	count          int
+	mean, variance float64
+	// observed is the number of intervals in the baseline, alerted is set once the current
+	// interval has been alerted about.
+	observed int
+	alerted  bool
+}
+
+func (r *restartRate) fold(count int) {
+	x := float64(count)
+	if r.observed == 0 {
+		r.mean = x
+	} else {
+		diff := x - r.mean
+		increment := anomalyWeight * diff
+		r.mean += increment
+		r.variance = (1 - anomalyWeight) * (r.variance + diff*increment)
+	}
+	r.observed++
+}
+
+// advance moves the rate to the interval starting at start, folding the completed intervals into
+// the baseline.
+func (r *restartRate) advance(start time.Time, length time.Duration) {
+	if r.interval.IsZero() {
+		r.interval = start
+		return
+	}
+	if !start.After(r.interval) {
+		return
+	}
+	r.fold(r.count)
+	// Intervals without restarts have not been seen.
+	gaps := int(start.Sub(r.interval)/length) - 1
+	if gaps > anomalyMaxGap {
+		gaps = anomalyMaxGap
+	}
+	for i := 0; i < gaps; i++ {
+		r.fold(0)
+	}
+	r.interval, r.count, r.alerted = start, 0, false
+}
+
+func (r *restartRate) deviation() float64 {
+	return math.Max(math.Sqrt(r.variance), anomalyMinDeviation)
+}
+
+// persistedRate is the form the restart rate of a workload is persisted in.
+type persistedRate struct {
+	Interval time.Time `json:"interval"`
+	Count    int       `json:"count,omitempty"`
+	Mean     float64   `json:"mean"`
+	Variance float64   `json:"variance"`
+	Observed int       `json:"observed"`
+	Alerted  bool      `json:"alerted,omitempty"`
+}
+
+// restartRates tracks the restart rates of workloads. The zero value is ready to use.
+type restartRates struct {
+	mu sync.Mutex
+	// restarts holds the last restart count per namespace/pod/container key.
+	restarts  map[string]int32
+	workloads map[string]*restartRate
+}
+
+// restarted records the restart count of a container and returns the number of restarts since it
+// was last recorded. The first count of a container is taken as is, as its restarts may be old.
+func (r *restartRates) restarted(key string, count int32) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.restarts == nil {
+		r.restarts = make(map[string]int32)
+	}
+	previous, ok := r.restarts[key]
+	r.restarts[key] = count
+	if !ok || count <= previous {
+		return 0
+	}
+	return int(count - previous)
+}
+
+// forgetPod forgets the restart counts of the pod with the given namespace/name key.
+func (r *restartRates) forgetPod(pod string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.restarts {
+		if strings.HasPrefix(key, pod+"/") {
+			delete(r.restarts, key)
+		}
+	}
+}
+
+// add counts restarts of the workload at now. It returns a copy of the rate and true the first
+// time the restarts of the current interval deviate from the baseline.
+func (r *restartRates) add(workload string, restarts int, now time.Time, cfg *config.Anomalies) (restartRate, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.workloads == nil {
+		r.workloads = make(map[string]*restartRate)
+	}
+	rate, ok := r.workloads[workload]
+	if !ok {
+		r.prune(now.Add(-anomalyMaxGap * cfg.Interval.Duration))
+		rate = &restartRate{}
+		r.workloads[workload] = rate
+	}
+	rate.advance(now.Truncate(cfg.Interval.Duration), cfg.Interval.Duration)
+	rate.count += restarts
+	if rate.alerted || rate.observed < cfg.Warmup || rate.count < cfg.MinRestarts ||
+		float64(rate.count) <= rate.mean+cfg.Threshold*rate.deviation() {
+		return *rate, false
+	}
+	rate.alerted = true
+	return *rate, true
+}
+
+// prune forgets the workloads which did not restart since before, e.g. because they have been
+// deleted. Their baseline has converged to zero anyway.
+func (r *restartRates) prune(before time.Time) {
+	for workload, rate := range r.workloads {
+		if rate.interval.Before(before) {
+			delete(r.workloads, workload)
+		}
+	}
+}
+
+// snapshot returns the rates of the namespace/workload keys whose namespace is accepted by owns.
+func (r *restartRates) snapshot(owns func(namespace string) bool) map[string]persistedRate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rates := make(map[string]persistedRate)
+	for workload, rate := range r.workloads {
+		if owns(keyNamespace(workload)) {
+			rates[workload] = persistedRate{
+				Interval: rate.interval,
+				Count:    rate.count,
+				Mean:     rate.mean,
+				Variance: rate.variance,
+				Observed: rate.observed,
+				Alerted:  rate.alerted,
+			}
+		}
+	}
+	return rates
+}
+
+// restore adds the persisted rates, keeping those which are already tracked.
+func (r *restartRates) restore(rates map[string]persistedRate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.workloads == nil {
+		r.workloads = make(map[string]*restartRate)
+	}
+	for workload, persisted := range rates {
+		if _, ok := r.workloads[workload]; ok {
+			continue
+		}
+		r.workloads[workload] = &restartRate{
+			interval: persisted.Interval,
+			count:    persisted.Count,
+			mean:     persisted.Mean,
+			variance: persisted.Variance,
+			observed: persisted.Observed,
+			alerted:  persisted.Alerted,
+		}
+	}
+}
+
+// observeRestarts counts the new restarts of the containers of a pod towards the restart rate of
+// its workload and alerts if the rate is unusual for the workload.
+func (c *Controller) observeRestarts(ctx context.Context, pod *v1.Pod) {
+	restarts, silenced := 0, ""
+	for _, container := range pod.Status.ContainerStatuses {
+		restarted := c.anomalies.restarted(podKey(pod)+"/"+container.Name, container.RestartCount)
+		if silence, ok := c.silences.match(pod.Namespace, pod.Name, container.Name); ok && restarted > 0 {
+			silenced = silence.ID
+		}
+		restarts += restarted
+	}
+	if restarts == 0 {
+		return
+	}
+	cfg, _ := c.settings()
+	workload := c.workload(ctx, pod)
+	rate, anomalous := c.anomalies.add(pod.Namespace+"/"+workload, restarts, c.clock.Now(), &cfg.Anomalies)
+	if !anomalous {
+		return
+	} else if silenced != "" {
+		klog.InfoS("Restart rate notification silenced", "namespace", pod.Namespace, "workload", workload, "silence", silenced)
+		return
+	}
+	c.sendAnomalyNotification(ctx, cfg, pod.Namespace, workload, rate)
+}
+
+// sendAnomalyNotification notifies about the unusual restart rate of a workload using the routes
+// of the configuration.
+func (c *Controller) sendAnomalyNotification(ctx context.Context, cfg *config.Config, namespace, workload string, rate restartRate) {
+	const reason = "RestartRateAnomaly"
+	severity := cfg.DefaultSeverity
+	now := c.clock.Now()
+	channel, names := cfg.Routing(now, namespace, severity, reason)
+	locale := c.locale(cfg, nil, namespace, c.namespaceAnnotation(namespace, annotationMattermostLocale))
+	alert := &notify.Alert{
+		Time:        now,
+		Fingerprint: c.fingerprint(namespace, workload, "restart-rate"),
+		Namespace:   namespace,
+		Workload:    workload,
+		Reason:      reason,
+		Severity:    severity,
+		Title:       cfg.Catalogs.Sprintf(locale, "Unusual restart rate of %s", workload),
+		Text: cfg.Catalogs.Sprintf(locale, "Workload %s in namespace %s restarted %d times since %s, while it usually restarts %.1f ± %.1f times per %s.",
+			workload, namespace, rate.count, rate.interval.In(cfg.Location("")).Format("15:04 MST"), rate.mean, math.Sqrt(rate.variance), cfg.Anomalies.Interval.Duration),
+		Channel:  channel,
+		Priority: cfg.Priorities[severity],
+		Emoji:    cfg.Emojis[severity],
+		IconURL:  cfg.Icons[severity],
+		Color:    cfg.Colors[severity],
+		Locale:   locale,
+	}
+	c.identify(cfg, alert)
+	c.assignOnCall(namespace, c.namespaceAnnotation(namespace, annotationMattermostOnCall), alert)
+	klog.InfoS("Sending restart rate notification", "namespace", namespace, "workload", workload,
+		"restarts", rate.count, "mean", rate.mean, "channel", alert.Channel, "notifiers", names)
+	c.publish(stream.EventDetected, alert, "", nil)
+	recordHistory := c.history.recordOnDelivery(alertRecord{
+		Time:      alert.Time,
+		Namespace: namespace,
+		Pod:       workload,
+		Reason:    reason,
+	})
+	for _, name := range names {
+		c.enqueueAlert(ctx, name, alert, recordHistory)
+	}
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRestartRatesArePersistedAndPruned(t *testing.T) {
+	cfg := &config.Anomalies{Interval: metav1.Duration{Duration: time.Hour}, Threshold: 3, MinRestarts: 3, Warmup: 2}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var rates restartRates
+	for i := 0; i < 3; i++ {
+		rates.add("apps/Deployment/web", 1, now.Add(time.Duration(i)*time.Hour), cfg)
+	}
+
+	// The baseline survives a restart, so the workload is alerted about without another warmup.
+	var restored restartRates
+	restored.restore(rates.snapshot(func(namespace string) bool { return namespace == "apps" }))
+	if _, anomalous := restored.add("apps/Deployment/web", 10, now.Add(3*time.Hour), cfg); !anomalous {
+		t.Fatal("restored baseline was not used")
+	}
+
+	// Workloads which did not restart for long are forgotten once another one restarts.
+	restored.add("apps/Deployment/api", 1, now.Add((anomalyMaxGap+4)*time.Hour), cfg)
+	if _, ok := restored.workloads["apps/Deployment/web"]; ok {
+		t.Fatal("stale workload was not pruned")
+	}
+}
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// errNotFound is returned by API handlers if the requested object does not exist.
+var errNotFound = fmt.Errorf("not found")
+
+// APIHandler returns a HTTP handler serving the JSON API used by the kubectl plugin and external
+// tooling. Requests must carry the configured API token, the API is disabled if no token is configured.
+func (c *Controller) APIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg, _ := c.settings()
+		token := r.Header.Get(api.TokenHeader)
+		if cfg.APIToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.APIToken)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		var (
+			result interface{}
+			err    error
+		)
+		endpoint := strings.Split(strings.TrimPrefix(r.URL.Path, api.Prefix), "/")
+		switch {
+		case matchEndpoint(r, endpoint, http.MethodGet, "status"):
+			result = c.apiStatus()
+		case matchEndpoint(r, endpoint, http.MethodGet, "alerts"):
+			result, err = c.apiAlerts(r)
+		case matchEndpoint(r, endpoint, http.MethodGet, "firing"):
+			result = c.apiFiring()
+		case matchEndpoint(r, endpoint, http.MethodPost, "firing", "*", "resend"):
+			result, err = c.apiResend(r, endpoint[1])
+		case matchEndpoint(r, endpoint, http.MethodGet, "acknowledgements"):
+			result = c.acks.list()
+		case matchEndpoint(r, endpoint, http.MethodGet, "silences"):
+			result = c.silences.list()
+		case matchEndpoint(r, endpoint, http.MethodPost, "silences"):
+			result, err = c.apiCreateSilence(r)
+		case matchEndpoint(r, endpoint, http.MethodDelete, "silences", "*"):
+			err = c.apiDeleteSilence(r, endpoint[1])
+		case matchEndpoint(r, endpoint, http.MethodPost, "test"):
+			result, err = c.apiSendTest(r)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		var stateErr *stateError
+		switch {
+		case err == errNotFound:
+			http.NotFound(w, r)
+		case errors.As(err, &stateErr):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case result == nil:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(result)
+		}
+	})
+}
+
+// matchEndpoint checks the method and the segments of the endpoint, * matches any segment.
+func matchEndpoint(r *http.Request, endpoint []string, method string, pattern ...string) bool {
+	if r.Method != method || len(endpoint) != len(pattern) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != "*" && segment != endpoint[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Controller) apiStatus() *api.Status {
+	state := c.debugState()
+	return &api.Status{
+		Namespaces:           state.Namespaces,
+		QueueLength:          state.QueueLength,
+		PendingNotifications: state.Pending,
+		FiringAlerts:         len(c.apiFiring()),
+		Silences:             len(c.silences.list()),
+	}
+}
+
+// apiAlerts queries the history of sent alerts. The NotificationRecords are queried if enabled,
+// otherwise the most recent alerts kept in memory.
+func (c *Controller) apiAlerts(r *http.Request) ([]api.Alert, error) {
+	query := r.URL.Query()
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %q", value)
+		}
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+	var alerts []api.Alert
+	if c.records != nil {
+		records, err := c.records.List(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			spec := record.Spec
+			alerts = append(alerts, api.Alert{
+				Time:      spec.SentAt.Time,
+				Cluster:   spec.Cluster,
+				Namespace: spec.Namespace,
+				Pod:       spec.Pod,
+				Container: spec.Container,
+				Reason:    spec.Reason,
+				Severity:  spec.Severity,
+				Channel:   spec.Channel,
+				Notifier:  spec.Notifier,
+			})
+		}
+	} else {
+		for _, record := range c.history.list() {
+			alerts = append(alerts, api.Alert{
+				Time:      record.Time,
+				Namespace: record.Namespace,
+				Pod:       record.Pod,
+				Container: record.Container,
+				Reason:    record.Reason,
+			})
+		}
+	}
+	matches := make([]api.Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if len(matches) >= limit || alert.Time.Before(since) {
+			break
+		}
+		if namespace := query.Get("namespace"); namespace != "" && alert.Namespace != namespace {
+			continue
+		}
+		if pattern := query.Get("pod"); pattern != "" {
+			if matched, _ := path.Match(pattern, alert.Pod); !matched {
+				continue
+			}
+		}
+		matches = append(matches, alert)
+	}
+	return matches, nil
+}
+
+// apiFiring returns the firing alerts of all clusters, newest first.
+func (c *Controller) apiFiring() []api.FiringAlert {
+	var firing []firingAlert
+	for _, controller := range append([]*Controller{c}, c.clusters...) {
+		firing = append(firing, controller.firing.list()...)
+	}
+	alerts := make([]api.FiringAlert, len(firing))
+	for i, f := range firing {
+		alerts[i] = api.FiringAlert{
+			Fingerprint: f.alert.Fingerprint,
+			Time:        f.alert.Time,
+			Cluster:     f.alert.Cluster,
+			Namespace:   f.alert.Namespace,
+			Pod:         f.alert.Pod,
+			Container:   f.alert.Container,
+			Reason:      f.alert.Reason,
+			Severity:    f.alert.Severity,
+			Channel:     f.alert.Channel,
+			Notifiers:   f.notifiers,
+		}
+		if ack, ok := c.acks.acknowledged(f.alert.Fingerprint, f.since); ok {
+			alerts[i].AcknowledgedBy = ack.User
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Time.After(alerts[j].Time) })
+	return alerts
+}
+
+// apiResend sends the firing alert with the given fingerprint again to its notifiers.
+func (c *Controller) apiResend(r *http.Request, fingerprint string) (*api.Queued, error) {
+	for _, controller := range append([]*Controller{c}, c.clusters...) {
+		firing, ok := controller.firing.get(fingerprint)
+		if !ok {
+			continue
+		}
+		klog.InfoS("Resending alert", "pod", klog.KRef(firing.alert.Namespace, firing.alert.Pod),
+			"container", firing.alert.Container, "notifiers", firing.notifiers)
+		queued := &api.Queued{Channel: firing.alert.Channel}
+		for _, name := range firing.notifiers {
+			if controller.enqueueAlert(r.Context(), name, firing.alert, nil) {
+				queued.Notifiers = append(queued.Notifiers, name)
+			}
+		}
+		return queued, nil
+	}
+	return nil, errNotFound
+}
+
+func (c *Controller) apiCreateSilence(r *http.Request) (*api.Silence, error) {
+	var silence api.Silence
+	if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+		return nil, fmt.Errorf("invalid silence: %v", err)
+	}
+	if !silence.Until.After(c.clock.Now()) {
+		return nil, fmt.Errorf("silence must end in the future")
+	}
+	if _, err := path.Match(silence.Pod, ""); err != nil {
+		return nil, fmt.Errorf("invalid pod pattern: %v", err)
+	}
+	created, err := c.createSilence(silence)
+	if err != nil {
+		return nil, err
+	}
+	klog.InfoS("Created silence", "id", created.ID, "namespace", created.Namespace, "pod", created.Pod,
+		"container", created.Container, "until", created.Until, "createdBy", created.CreatedBy)
+	return &created, nil
+}
+
+func (c *Controller) apiDeleteSilence(r *http.Request, id string) error {
+	if deleted, err := c.deleteSilence(id); err != nil {
+		return err
+	} else if !deleted {
+		return errNotFound
+	}
+	klog.InfoS("Deleted silence", "id", id)
+	return nil
+}
+
+func (c *Controller) apiSendTest(r *http.Request) (*api.Queued, error) {
+	var test api.TestRequest
+	if err := json.NewDecoder(r.Body).Decode(&test); err != nil {
+		return nil, fmt.Errorf("invalid test request: %v", err)
+	}
+	cfg, _ := c.settings()
+	alert, names, err := newTestAlert(cfg, TestAlert(test), c.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	klog.InfoS("Sending test notification", "channel", alert.Channel, "notifiers", names)
+	queued := &api.Queued{Channel: alert.Channel}
+	for _, name := range names {
+		if c.enqueueAlert(r.Context(), name, alert, nil) {
+			queued.Notifiers = append(queued.Notifiers, name)
+		}
+	}
+	return queued, nil
+}
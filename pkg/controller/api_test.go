@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPICreateSilenceRequiresFutureEnd(t *testing.T) {
+	c, _ := newTestController(t)
+	for _, test := range []struct {
+		until time.Time
+		valid bool
+	}{
+		{until: testStart.Add(-time.Minute)},
+		{until: testStart},
+		{until: testStart.Add(time.Hour), valid: true},
+	} {
+		body := `{"namespace":"apps","until":"` + test.until.Format(time.RFC3339) + `"}`
+		silence, err := c.apiCreateSilence(httptest.NewRequest("POST", "/api/v1/silences", strings.NewReader(body)))
+		if test.valid != (err == nil) {
+			t.Errorf("silence until %s: got error %v, want valid %t", test.until, err, test.valid)
+			continue
+		}
+		if err == nil && !silence.Until.Equal(test.until) {
+			t.Errorf("silence ends at %s, want %s", silence.Until, test.until)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// applicationGVR identifies Argo CD Applications.
+var applicationGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+
+// applicationCheck notifies about Argo CD Applications which are degraded or out of sync for
+// longer than the grace period. Applications are routed by their destination namespace and
+// linked in the Argo CD UI at url, if given.
+func applicationCheck(url string, gracePeriod time.Duration) resourceCheck {
+	return resourceCheck{
+		gvr:         applicationGVR,
+		kind:        "Application",
+		gracePeriod: gracePeriod,
+		unfiltered:  true,
+		check: func(ctx context.Context, app *unstructured.Unstructured) *resourceProblem {
+			return checkApplication(url, app)
+		},
+	}
+}
+
+func checkApplication(url string, app *unstructured.Unstructured) *resourceProblem {
+	health, _, _ := unstructured.NestedString(app.Object, "status", "health", "status")
+	sync, _, _ := unstructured.NestedString(app.Object, "status", "sync", "status")
+	problem := &resourceProblem{}
+	switch {
+	case health == "Degraded":
+		message, _, _ := unstructured.NestedString(app.Object, "status", "health", "message")
+		problem.Reason = "ApplicationDegraded"
+		problem.Title = fmt.Sprintf("Application %s is degraded", app.GetName())
+		problem.Text = fmt.Sprintf("Argo CD application %s is degraded. %s", app.GetName(), message)
+	case sync == "OutOfSync":
+		message, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "message")
+		problem.Reason = "ApplicationOutOfSync"
+		problem.Title = fmt.Sprintf("Application %s is out of sync", app.GetName())
+		problem.Text = fmt.Sprintf("Argo CD application %s is out of sync. %s", app.GetName(), message)
+	default:
+		return nil
+	}
+	problem.Text = strings.TrimSpace(problem.Text)
+	problem.Namespace, _, _ = unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+	if url != "" {
+		problem.Links = []notify.Link{{
+			Title: "Argo CD",
+			URL:   fmt.Sprintf("%s/applications/%s/%s", strings.TrimSuffix(url, "/"), app.GetNamespace(), app.GetName()),
+		}}
+	}
+	return problem
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/state"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// autoscalerReasons are the reasons of Cluster Autoscaler events about failed scale-ups.
+var autoscalerReasons = map[string]bool{
+	"FailedScaleUp":     true,
+	"NotTriggerScaleUp": true,
+	"ScaleUpTimedOut":   true,
+}
+
+const (
+	// karpenterController is the reporting controller of Karpenter events.
+	karpenterController = "karpenter"
+	// eventTimeoutTTL and eventTimeoutMaxEntries bound the store of notified events.
+	eventTimeoutTTL        = 24 * time.Hour
+	eventTimeoutMaxEntries = 1000
+)
+
+// isScalingFailure checks if the event reports that the cluster could not scale up, either by
+// the Cluster Autoscaler or as a warning of Karpenter, e.g. an InsufficientCapacityError.
+func isScalingFailure(event *v1.Event) bool {
+	if autoscalerReasons[event.Reason] {
+		return true
+	}
+	karpenter := event.Source.Component == karpenterController || event.ReportingController == karpenterController
+	return karpenter && event.Type == v1.EventTypeWarning
+}
+
+// watchAutoscalerEvents watches the events in the given namespace, or all namespaces if
+// metav1.NamespaceAll is given, for failed scale-ups. It must be called before the controller is run.
+func (c *Controller) watchAutoscalerEvents(namespace string) {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0, informers.WithNamespace(namespace))
+	// The events are deduplicated separately from the pods, whose timeouts are keyed by pod.
+	c.eventTimeouts = state.NewMemoryStoreWithClock(eventTimeoutTTL, eventTimeoutMaxEntries, c.clock)
+	c.eventInformer = factory.Core().V1().Events().Informer()
+	c.eventInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handleEvent,
+		UpdateFunc: func(old, new interface{}) { c.handleEvent(new) },
+	})
+}
+
+// handleEvent notifies about scaling failures, repeated events of the same object and reason
+// are only notified once per backoff.
+func (c *Controller) handleEvent(obj interface{}) {
+	event, ok := obj.(*v1.Event)
+	if !ok || !isScalingFailure(event) {
+		return
+	}
+	// Events listed on startup may be stale, only recent ones are notified.
+	if eventTime(event).Before(c.clock.Now().Add(-time.Minute)) {
+		return
+	}
+	involved := event.InvolvedObject
+	if involved.Kind == "Pod" && !c.watchesNamespace(involved.Namespace) {
+		return
+	}
+	cfg, _ := c.settings()
+	key := fmt.Sprintf("%s/%s/%s/%s", involved.Namespace, involved.Kind, involved.Name, event.Reason)
+	if !c.eventTimeouts.Refresh(key, cfg.Backoff.Duration) {
+		return
+	}
+	c.sendScalingNotification(context.Background(), event)
+}
+
+// eventTime returns the time the event has last been observed.
+func eventTime(event *v1.Event) time.Time {
+	switch {
+	case event.Series != nil:
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// sendScalingNotification notifies about a failed scale-up in the capacity channel, or the
+// channel of the matching route if none is configured.
+func (c *Controller) sendScalingNotification(ctx context.Context, event *v1.Event) {
+	cfg, _ := c.settings()
+	involved := event.InvolvedObject
+	severity := cfg.DefaultSeverity
+	alert := &notify.Alert{
+		Time:        eventTime(event),
+		Fingerprint: c.fingerprint(involved.Namespace, involved.Kind+"/"+involved.Name, event.Reason),
+		Namespace:   involved.Namespace,
+		Workload:    involved.Kind + "/" + involved.Name,
+		Reason:      event.Reason,
+		Severity:    severity,
+		Title:       "Cluster failed to scale up",
+		Text:        fmt.Sprintf("%s %s: %s", involved.Kind, involved.Name, event.Message),
+		Channel:     cfg.CapacityChannel,
+		Priority:    cfg.Priorities[severity],
+		Emoji:       cfg.Emojis[severity],
+		IconURL:     cfg.Icons[severity],
+		Color:       cfg.Colors[severity],
+	}
+	if color, ok := cfg.Colors[event.Reason]; ok {
+		alert.Color = color
+	}
+	c.identify(cfg, alert)
+	if involved.Kind == "Pod" {
+		alert.Pod = involved.Name
+	}
+	channel, names := cfg.Routing(alert.Time, alert.Namespace, severity, event.Reason)
+	if alert.Channel == "" {
+		alert.Channel = channel
+	}
+	klog.InfoS("Sending scaling notification", "object", klog.KRef(involved.Namespace, involved.Name),
+		"kind", involved.Kind, "reason", event.Reason, "channel", alert.Channel, "notifiers", names)
+	c.publish(stream.EventDetected, alert, "", nil)
+	for _, name := range names {
+		c.enqueueAlert(ctx, name, alert, nil)
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// backoffSteps counts the consecutive notifications per timeout key, which grow the backoff
+// until the pod recovers. The zero value is ready to use.
+type backoffSteps struct {
+	mu    sync.Mutex
+	steps map[string]int
+}
+
+// get returns the number of notifications sent for key since the pod last recovered.
+func (b *backoffSteps) get(key string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.steps[key]
+}
+
+// inc records a notification for key.
+func (b *backoffSteps) inc(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.steps == nil {
+		b.steps = make(map[string]int)
+	}
+	b.steps[key]++
+}
+
+// deletePrefix forgets the counts of all keys starting with prefix.
+func (b *backoffSteps) deletePrefix(prefix string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key := range b.steps {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.steps, key)
+		}
+	}
+}
+
+// snapshot returns a copy of the counts of the keys whose namespace is accepted by owns.
+func (b *backoffSteps) snapshot(owns func(namespace string) bool) map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	steps := make(map[string]int)
+	for key, step := range b.steps {
+		if owns(keyNamespace(key)) {
+			steps[key] = step
+		}
+	}
+	return steps
+}
+
+// restore adds the persisted counts, e.g. after a restart.
+func (b *backoffSteps) restore(steps map[string]int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.steps == nil {
+		b.steps = make(map[string]int)
+	}
+	for key, step := range steps {
+		b.steps[key] = step
+	}
+}
+
+// scaleBackoff returns the backoff after step notifications, growing from base by factor up to
+// max. The maximum never lowers the base interval.
+func scaleBackoff(base time.Duration, step int, factor float64, max time.Duration) time.Duration {
+	backoff := base
+	for i := 0; i < step && backoff < max; i++ {
+		backoff = time.Duration(float64(backoff) * factor)
+	}
+	if backoff > max && base <= max {
+		backoff = max
+	}
+	return backoff
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2"
+)
+
+// BenchmarkOptions configure a benchmark run.
+type BenchmarkOptions struct {
+	// Pods is the number of distinct pods, spread over Namespaces namespaces.
+	Pods       int
+	Namespaces int
+	// Updates is the number of pod updates passed to the event handlers.
+	Updates int
+	// CrashRatio is the fraction of updates reporting a crashing container.
+	CrashRatio float64
+	// Workers is the number of workers processing the queue.
+	Workers int
+}
+
+// BenchmarkResult reports the cost of a benchmark run.
+type BenchmarkResult struct {
+	Updates  int
+	Duration time.Duration
+	// Processed is the number of pods processed by the workers, which is lower than the number of
+	// updates since updates of queued pods are merged.
+	Processed     int64
+	Notifications int64
+	// AllocatedBytes and Allocations are allocated in total, HeapBytes remain in use at the end.
+	AllocatedBytes uint64
+	Allocations    uint64
+	HeapBytes      uint64
+}
+
+func (r *BenchmarkResult) String() string {
+	seconds := r.Duration.Seconds()
+	return fmt.Sprintf("updates: %d (%.0f/s)\nprocessed: %d\nnotifications: %d\nduration: %s\nallocated: %d bytes (%d per update)\nallocations: %d (%d per update)\nheap in use: %d bytes\n",
+		r.Updates, float64(r.Updates)/seconds, r.Processed, r.Notifications, r.Duration,
+		r.AllocatedBytes, r.AllocatedBytes/uint64(r.Updates), r.Allocations, r.Allocations/uint64(r.Updates), r.HeapBytes)
+}
+
+// benchNotifier counts the alerts it receives.
+type benchNotifier struct {
+	sent int64
+}
+
+func (n *benchNotifier) Send(ctx context.Context, alert *notify.Alert) error {
+	atomic.AddInt64(&n.sent, 1)
+	return nil
+}
+
+func (n *benchNotifier) count() int64 {
+	return atomic.LoadInt64(&n.sent)
+}
+
+// Benchmark passes generated pod updates through the transform, the event handlers, the queue,
+// the backoff and routing as fast as possible, and measures the time and memory used. The pods
+// are stored in the informer cache directly, so neither an API server nor a notifier is contacted.
+// Logging is disabled, since it would dominate the measurement.
+func Benchmark(opts BenchmarkOptions) (*BenchmarkResult, error) {
+	if opts.Pods <= 0 || opts.Namespaces <= 0 || opts.Updates <= 0 || opts.Workers <= 0 {
+		return nil, fmt.Errorf("pods, namespaces, updates and workers must be positive")
+	}
+	c, notifier, err := newBenchController(opts.Pods)
+	if err != nil {
+		return nil, err
+	}
+	indexer := c.pods[metav1.NamespaceAll].indexer
+	pods := make([]*v1.Pod, opts.Pods)
+	for i := range pods {
+		pods[i] = benchPod(i, 0, opts.Namespaces)
+	}
+	restarts := make([]int32, len(pods))
+	random := rand.New(rand.NewSource(1))
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.dispatcher.Run(ctx)
+	var (
+		workers   sync.WaitGroup
+		processed int64
+	)
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for c.processNextItem(ctx) {
+				atomic.AddInt64(&processed, 1)
+			}
+		}()
+	}
+	for i := 0; i < opts.Updates; i++ {
+		// Informers hand out new objects for every update, which are transformed before caching.
+		index := random.Intn(len(pods))
+		pod := pods[index].DeepCopy()
+		crash := random.Float64() < opts.CrashRatio
+		if crash {
+			restarts[index]++
+		}
+		setBenchStatus(pod, crash, restarts[index])
+		obj, _ := stripObject(pod)
+		if err := indexer.Update(obj); err != nil {
+			return nil, err
+		}
+		c.enqueue(obj, reasonUpdated)
+	}
+	c.queue.ShutDown()
+	workers.Wait()
+	c.dispatcher.stop()
+	duration := time.Since(start)
+
+	runtime.GC()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	return &BenchmarkResult{
+		Updates:        opts.Updates,
+		Duration:       duration,
+		Processed:      processed,
+		Notifications:  notifier.count(),
+		AllocatedBytes: after.TotalAlloc - before.TotalAlloc,
+		Allocations:    after.Mallocs - before.Mallocs,
+		HeapBytes:      after.HeapInuse,
+	}, nil
+}
+
+// newBenchController creates a controller for benchmarks whose pods are stored in the indexer of
+// the informer for all namespaces, notifying a notifier counting the alerts. Logging is disabled.
+func newBenchController(pods int) (*Controller, *benchNotifier, error) {
+	cfg, err := config.Parse([]byte("{}"))
+	if err != nil {
+		return nil, nil, err
+	}
+	klog.LogToStderr(false)
+	klog.SetOutput(ioutil.Discard)
+
+	c := NewController(cfg, fake.NewSimpleClientset(), nil, newQueue(DefaultOptions(), "bench"))
+	notifier := &benchNotifier{}
+	c.notifiers = map[string]notify.Notifier{notify.TypeMattermost: notifier}
+	// Each pod is notified at most once within the backoff.
+	c.dispatcher = newDispatcher(4, pods, 0, c.clock)
+	c.watchNamespace(metav1.NamespaceAll)
+	return c, notifier, nil
+}
+
+// setBenchStatus sets the status of the container of a benchmark pod to crashing or ready.
+func setBenchStatus(pod *v1.Pod, crash bool, restarts int32) {
+	status := &pod.Status.ContainerStatuses[0]
+	status.RestartCount = restarts
+	if crash {
+		status.Ready = false
+		status.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+		status.LastTerminationState = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}}
+		return
+	}
+	status.Ready = true
+	status.State = v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+}
+
+// benchPod returns the i-th pod of a benchmark in the given generation, a typical annotated pod
+// of a deployment. Pods of a new generation replace the pods of the previous one.
+func benchPod(i, generation, namespaces int) *v1.Pod {
+	name := fmt.Sprintf("app-%d-%d", i, generation)
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fmt.Sprintf("bench-%d", i%namespaces),
+			Name:      name,
+			UID:       types.UID(fmt.Sprintf("%08d-%08d", i, generation)),
+			Labels:    map[string]string{"app": name, "pod-template-hash": "5d8f7c9b6"},
+			Annotations: map[string]string{
+				annotationEnableMattermost: annotationEnableMattermostInform,
+				annotationLastApplied:      `{"apiVersion":"v1","kind":"Pod"}`,
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate}},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:  "app",
+				Image: "registry.example.com/app:1.0.0",
+				Env:   []v1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}},
+			}},
+			Volumes: []v1.Volume{{Name: "config"}},
+		},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{{Name: "app", Ready: true}},
+		},
+	}
+}
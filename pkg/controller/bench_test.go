@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BenchmarkPodUpdates passes pod updates through the transform, the event handlers, the queue, the
+// backoff and routing, like the bench subcommand. An operation is a single update.
+func BenchmarkPodUpdates(b *testing.B) {
+	for _, bench := range []struct {
+		pods       int
+		crashRatio float64
+	}{
+		{pods: 1000, crashRatio: 0},
+		{pods: 1000, crashRatio: 0.01},
+		{pods: 20000, crashRatio: 0.05},
+	} {
+		b.Run(fmt.Sprintf("pods=%d/crashes=%g", bench.pods, bench.crashRatio), func(b *testing.B) {
+			benchmarkPodUpdates(b, bench.pods, 50, bench.crashRatio)
+		})
+	}
+}
+
+func benchmarkPodUpdates(b *testing.B, count, namespaces int, crashRatio float64) {
+	c, _, err := newBenchController(count)
+	if err != nil {
+		b.Fatal(err)
+	}
+	indexer := c.pods[metav1.NamespaceAll].indexer
+	pods := make([]*v1.Pod, count)
+	for i := range pods {
+		pods[i] = benchPod(i, 0, namespaces)
+	}
+	restarts := make([]int32, count)
+	random := rand.New(rand.NewSource(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.dispatcher.Run(ctx)
+	defer c.dispatcher.stop()
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		for c.processNextItem(ctx) {
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index := random.Intn(count)
+		pod := pods[index].DeepCopy()
+		crash := random.Float64() < crashRatio
+		if crash {
+			restarts[index]++
+		}
+		setBenchStatus(pod, crash, restarts[index])
+		obj, _ := stripObject(pod)
+		if err := indexer.Update(obj); err != nil {
+			b.Fatal(err)
+		}
+		c.enqueue(obj, reasonUpdated)
+	}
+	// The queue is drained before the timer is stopped, so that the processing is measured.
+	c.queue.ShutDown()
+	workers.Wait()
+	b.StopTimer()
+}
+
+// BenchmarkStripObject measures the transform applied to pods before they are cached.
+func BenchmarkStripObject(b *testing.B) {
+	pod := benchPod(0, 0, 1)
+	setBenchStatus(pod, true, 5)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := stripObject(pod.DeepCopy()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
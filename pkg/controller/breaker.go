@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// errCircuitOpen is returned for deliveries to a notifier whose circuit breaker is open.
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker stops deliveries to a channel of a notifier after threshold consecutive failures.
+// Once the cooldown has passed, a single delivery is let through to probe whether the notifier
+// recovered.
+type circuitBreaker struct {
+	name      string
+	channel   string
+	threshold int
+	cooldown  time.Duration
+	clock     clock.PassiveClock
+
+	mu       sync.Mutex
+	failures int
+	// openedAt is the time the breaker tripped or the last probe failed, zero while closed.
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a delivery may be attempted.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || b.clock.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the result of a delivery, tripping or closing the breaker. Permanent errors, e.g.
+// rejected alerts, do not tell whether the notifier is available and are not counted.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if utils.IsPermanent(err) {
+		return
+	}
+	if err == nil {
+		if !b.openedAt.IsZero() {
+			klog.InfoS("Notifier recovered, closing circuit breaker", "notifier", b.name, "channel", b.channel, "outage", b.clock.Since(b.openedAt).Round(time.Second))
+			circuitOpen.WithLabelValues(b.name, b.channel).Set(0)
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if !b.openedAt.IsZero() {
+		b.openedAt = b.clock.Now()
+		return
+	}
+	if b.failures >= b.threshold {
+		klog.ErrorS(err, "Notifier keeps failing, opening circuit breaker", "notifier", b.name, "channel", b.channel, "failures", b.failures, "cooldown", b.cooldown)
+		circuitOpen.WithLabelValues(b.name, b.channel).Set(1)
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// circuitBreakers holds a circuit breaker per notifier and channel, so that a failing channel, e.g.
+// one the bot has been removed from, does not stop the deliveries to other channels.
+type circuitBreakers struct {
+	threshold int
+	cooldown  time.Duration
+	clock     clock.PassiveClock
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(threshold int, cooldown time.Duration, clock clock.PassiveClock) *circuitBreakers {
+	return &circuitBreakers{threshold: threshold, cooldown: cooldown, clock: clock, breakers: make(map[string]*circuitBreaker)}
+}
+
+// get returns the circuit breaker of the channel of the named notifier.
+func (c *circuitBreakers) get(name, channel string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := name + "/" + channel
+	breaker, ok := c.breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{name: name, channel: channel, threshold: c.threshold, cooldown: c.cooldown, clock: c.clock}
+		c.breakers[key] = breaker
+	}
+	return breaker
+}
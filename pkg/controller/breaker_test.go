@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	rejected := utils.Permanent(errors.New("rejected"))
+	// Each step waits, records a result unless it is allowOnly and then checks whether a
+	// delivery is allowed.
+	type step struct {
+		wait      time.Duration
+		result    error
+		allowOnly bool
+		allowed   bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"trips after threshold failures", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+		}},
+		{"success resets failures", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: nil, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+		}},
+		{"permanent errors are not counted", []step{
+			{result: unavailable, allowed: true},
+			{result: rejected, allowed: true},
+			{result: rejected, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+		}},
+		{"stays open during cooldown", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+			{wait: time.Minute - time.Second, allowOnly: true, allowed: false},
+		}},
+		{"single probe after cooldown", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+			{wait: time.Minute, allowOnly: true, allowed: true},
+			{allowOnly: true, allowed: false},
+			{wait: time.Hour, allowOnly: true, allowed: false},
+		}},
+		{"failed probe restarts cooldown", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+			{wait: time.Minute, allowOnly: true, allowed: true},
+			{result: unavailable, allowed: false},
+			{wait: time.Minute - time.Second, allowOnly: true, allowed: false},
+			{wait: time.Second, allowOnly: true, allowed: true},
+		}},
+		{"successful probe closes", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+			{wait: time.Minute, allowOnly: true, allowed: true},
+			{result: nil, allowed: true},
+			{allowOnly: true, allowed: true},
+		}},
+		{"rejected probe allows another probe", []step{
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: true},
+			{result: unavailable, allowed: false},
+			{wait: time.Minute, allowOnly: true, allowed: true},
+			{result: rejected, allowed: true},
+			{allowOnly: true, allowed: false},
+		}},
+	}
+	for _, test := range tests {
+		clock := testingclock.NewFakePassiveClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		breaker := newCircuitBreakers(3, time.Minute, clock).get("mattermost", "alerts")
+		for i, step := range test.steps {
+			clock.SetTime(clock.Now().Add(step.wait))
+			if !step.allowOnly {
+				breaker.record(step.result)
+			}
+			if allowed := breaker.allow(); allowed != step.allowed {
+				t.Errorf("%s: step %d: expected allowed %v, got %v", test.name, i, step.allowed, allowed)
+			}
+		}
+	}
+}
+
+func TestCircuitBreakersPerChannel(t *testing.T) {
+	clock := testingclock.NewFakePassiveClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	breakers := newCircuitBreakers(1, time.Minute, clock)
+	breakers.get("mattermost", "removed").record(errors.New("unavailable"))
+	if breakers.get("mattermost", "removed").allow() {
+		t.Error("expected breaker of failing channel to be open")
+	}
+	if !breakers.get("mattermost", "alerts").allow() || !breakers.get("webhook", "removed").allow() {
+		t.Error("expected breakers of other channels and notifiers to be closed")
+	}
+}
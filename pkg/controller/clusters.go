@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lnsp/mattermost-informer/pkg/client"
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/state"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// parseCluster parses a cluster given as name=kubeconfig or name=kubeconfig:context.
+func parseCluster(value string) (name, kubeconfig, context string, err error) {
+	name, kubeconfig, ok := strings.Cut(value, "=")
+	if !ok || name == "" || kubeconfig == "" {
+		return "", "", "", fmt.Errorf("invalid cluster %q, must be name=kubeconfig or name=kubeconfig:context", value)
+	}
+	kubeconfig, context, _ = strings.Cut(kubeconfig, ":")
+	return name, kubeconfig, context, nil
+}
+
+// newClusterController creates a controller watching the pods and resources in the namespaces of
+// another cluster. It has its own informers, queue and notification state, which is persisted in
+// a state ConfigMap of its own in the local cluster, but shares the configuration, notifiers and
+// NotificationRecords with c.
+func (c *Controller) newClusterController(opts Options, value string, namespaces []string, ownNamespace string) (*Controller, error) {
+	name, kubeconfig, context, err := parseCluster(value)
+	if err != nil {
+		return nil, err
+	}
+	if c.clusterController(name) != nil {
+		return nil, fmt.Errorf("cluster %s is configured twice", name)
+	}
+	clientset, err := client.FromKubeconfig(kubeconfig, context)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to cluster %s: %v", name, err)
+	}
+	cfg, mattermost := c.settings()
+	cluster := NewController(cfg, clientset, mattermost, newQueue(opts, "pods-"+name))
+	cluster.cluster = name
+	cluster.notifiers = c.notifiers
+	cluster.podSelector = c.podSelector
+	cluster.podFieldSelector = c.podFieldSelector
+	cluster.resyncPeriod = c.resyncPeriod
+	cluster.maxRetries = c.maxRetries
+	cluster.dispatcher = newDispatcher(opts.Senders, opts.SendQueueSize, opts.SendRetries, c.clock)
+	cluster.breakers = c.breakers
+	cluster.spool = c.spool
+	cluster.clock = c.clock
+	cluster.timeouts = state.NewMemoryStoreWithClock(opts.StateTTL, opts.StateMaxEntries, c.clock)
+	cluster.namespaceFilter = c.namespaceFilter
+	cluster.namespaceSelector = c.namespaceSelector
+	cluster.optOut = c.optOut
+	cluster.records = c.records
+	cluster.recordWrites = c.recordWrites
+	cluster.silences = c.silences
+	cluster.acks = c.acks
+	cluster.events = c.events
+	cluster.metricsServer = c.metricsServer
+	cluster.stateClient = c.stateClient
+	cluster.stateNamespace = c.stateNamespace
+	if c.stateConfigMap != "" {
+		cluster.stateConfigMap = c.stateConfigMap + "-" + name
+	}
+	if cluster.namespaceSelector == nil {
+		for _, namespace := range namespaces {
+			cluster.watchNamespace(namespace)
+		}
+	}
+	cluster.namespaces, cluster.namespaceInformer = cluster.newNamespaceInformer()
+	if !opts.SkipPermissionCheck {
+		if err := cluster.checkPermissions(requiredPermissions(&opts, namespaces)); err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", name, err)
+		}
+	}
+	var dynamicClient dynamic.Interface
+	if opts.WatchArgoCD || len(opts.watchedResources()) > 0 {
+		if dynamicClient, err = client.DynamicFromKubeconfig(kubeconfig, context); err != nil {
+			return nil, fmt.Errorf("could not connect to cluster %s: %v", name, err)
+		}
+	}
+	cluster.watchOptional(&opts, dynamicClient, namespaces, ownNamespace)
+	if namespaces[0] == metav1.NamespaceAll {
+		klog.InfoS("Watching all namespaces", "cluster", name)
+	} else {
+		klog.InfoS("Watching namespaces", "cluster", name, "namespaces", namespaces)
+	}
+	return cluster, nil
+}
+
+// clusterController returns the controller of the named cluster, c for the local cluster and nil
+// if the cluster is unknown.
+func (c *Controller) clusterController(name string) *Controller {
+	if name == c.cluster {
+		return c
+	}
+	for _, cluster := range c.clusters {
+		if cluster.cluster == name {
+			return cluster
+		}
+	}
+	return nil
+}
+
+// fingerprint identifies the alerts of the cluster, see notify.Fingerprint. Alerts of other
+// clusters include the cluster name so that they do not collide with alerts of the local cluster.
+func (c *Controller) fingerprint(namespace, name, detail string) string {
+	if c.cluster != "" {
+		namespace = c.cluster + "/" + namespace
+	}
+	return notify.Fingerprint(namespace, name, detail)
+}
+
+// identify sets the cluster and environment of the alert and prefixes its title with them.
+func (c *Controller) identify(cfg *config.Config, alert *notify.Alert) {
+	alert.Cluster, alert.Environment = cfg.Cluster.Identity(c.cluster)
+	if identity := alert.Identity(); identity != "" {
+		alert.Title = "[" + identity + "] " + alert.Title
+	}
+}
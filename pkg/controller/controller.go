@@ -1,272 +1,1408 @@
 package controller
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/mattermost/mattermost-server/model"
-
+	"github.com/lnsp/mattermost-informer/pkg/api"
 	"github.com/lnsp/mattermost-informer/pkg/client"
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/loki"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/oncall"
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	"github.com/lnsp/mattermost-informer/pkg/reporting"
+	"github.com/lnsp/mattermost-informer/pkg/rules"
+	"github.com/lnsp/mattermost-informer/pkg/shard"
+	"github.com/lnsp/mattermost-informer/pkg/spool"
+	"github.com/lnsp/mattermost-informer/pkg/state"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"github.com/lnsp/mattermost-informer/pkg/tracing"
 	"github.com/lnsp/mattermost-informer/pkg/utils"
-	"k8s.io/klog"
+	"github.com/lnsp/mattermost-informer/pkg/version"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/klog/v2"
 
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
 
 type Controller struct {
-	indexer    cache.Indexer
-	queue      workqueue.RateLimitingInterface
-	informer   cache.Controller
+	queue workqueue.TypedRateLimitingInterface[workItem]
+	// reasons holds the latest reason per queued pod.
+	reasons   queueReasons
+	clientset kubernetes.Interface
+	// lastProcessed is the time in unix nanoseconds the last item has been processed.
+	lastProcessed int64
+
+	// pods holds the pod informers by namespace, the informer watching all namespaces
+	// is stored under metav1.NamespaceAll.
+	podsMu      sync.RWMutex
+	pods        map[string]*podInformer
+	podSelector string
+	// podFieldSelector filters the pods on the server, e.g. to skip completed pods.
+	podFieldSelector string
+	resyncPeriod     time.Duration
+	// maxRetries is the number of times a failing pod is retried before it is dropped.
+	maxRetries      int
+	namespaceFilter namespaceFilter
+	// namespaceSelector enables watching namespaces by label, nil if disabled.
+	namespaceSelector labels.Selector
+	// shard assigns namespaces to the replicas, nil if sharding is disabled.
+	shard  *shard.Membership
+	stopCh <-chan struct{}
+
+	// factory holds the cluster-wide informers.
+	factory informers.SharedInformerFactory
+	// namespaces caches the namespaces for their annotations.
+	namespaces        cache.Indexer
+	namespaceInformer cache.SharedIndexInformer
+	// owners caches the annotations of workloads owning pods.
+	owners ownerCache
+
+	// mu guards the configuration and the Mattermost client, which are replaced on reload.
+	mu         sync.RWMutex
+	config     *config.Config
 	mattermost *utils.MattermostClient
-	clientset  kubernetes.Interface
+	notifiers  map[string]notify.Notifier
+
+	// resourceWatchers notify about unhealthy resources besides pods.
+	resourceWatchers []*resourceWatcher
+	// eventInformer watches events for failed scale-ups and eventTimeouts holds the time they have
+	// last been notified, both nil if disabled.
+	eventInformer cache.SharedIndexInformer
+	eventTimeouts state.Store
+
+	// records persists the sent notifications as NotificationRecords, nil if disabled. The writes
+	// are queued in recordWrites, which is shared with the controllers of further clusters.
+	records      *records.Store
+	recordWrites chan recordWrite
+	// recorder records events on pods for sent, suppressed and failed notifications, nil if disabled.
+	recorder record.EventRecorder
+
+	// dispatcher sends the notifications in the background.
+	dispatcher *dispatcher
+	// breakers stop deliveries to failing notifiers.
+	breakers *circuitBreakers
+	// spool persists undelivered notifications, nil if disabled.
+	spool *alertSpool
+	// events streams the alerts to subscribers of the gRPC API, nil if disabled.
+	events *stream.Hub
+
+	// rules holds the AlertRules, nil if disabled.
+	rules *rules.Store
+	// onCall holds the OnCallSchedules, nil if disabled.
+	onCall *oncall.Store
+	// optOut monitors all pods unless annotated to be ignored.
+	optOut bool
+	// metricsServer adds the current usage of crashing containers to alerts.
+	metricsServer bool
+	// anomalies tracks the restart rates of workloads, nil if disabled.
+	anomalies *restartRates
+
+	// timeouts holds the time of the last notification per pod, container and rule.
+	timeouts state.Store
+	// backoffs counts the notifications per timeout key since the pod last recovered.
+	backoffs backoffSteps
+	// suppressed holds the containers and rules whose suppression has been recorded as event.
+	suppressed keySet
+	// invalid holds the invalid annotation values that have been warned about per pod.
+	invalid keySet
+	history alertHistory
+	firing  firingAlerts
+	// escalations holds the alerts waiting to be acknowledged.
+	escalations escalations
+	// acks are shared with the controllers of further clusters.
+	acks *acknowledgements
+	// silences are shared with the controllers of further clusters.
+	silences *silences
+	// stateConfigMap persists the timeouts and namespace states across restarts, empty if disabled.
+	// It is stored using stateClient, which is the client of the local cluster.
+	stateConfigMap string
+	stateClient    kubernetes.Interface
+	stateNamespace string
+	// stateDirty is set to 1 if the timeouts changed since they have been persisted.
+	stateDirty int32
+	// savedStates are the namespace states last persisted, stateMu serializes persisting them.
+	stateMu     sync.Mutex
+	savedStates []byte
 
-	timeouts map[string]time.Time
+	// leading is set once the controller runs, which requires being elected if leaderElection is enabled.
+	leaderElection bool
+	leading        int32
+
+	// clock is the source of the time of alerts, backoffs, grace periods and silences.
+	clock clock.WithDelayedExecution
+
+	// cluster is the name of the cluster watched by this controller, empty for the local cluster.
+	cluster string
+	// clusters are the controllers of further clusters, which are run alongside this controller.
+	clusters []*Controller
 }
 
 // NewController instantiates a new controller.
-func NewController(clientset kubernetes.Interface, mattermost *utils.MattermostClient, queue workqueue.RateLimitingInterface, indexer cache.Indexer, informer cache.Controller) *Controller {
+func NewController(cfg *config.Config, clientset kubernetes.Interface, mattermost *utils.MattermostClient, queue workqueue.TypedRateLimitingInterface[workItem]) *Controller {
+	mattermostNotifier := notify.NewMattermost(mattermost)
+	mattermostNotifier.SetCatalogs(cfg.Catalogs)
 	return &Controller{
-		clientset:  clientset,
-		mattermost: mattermost,
-		informer:   informer,
-		indexer:    indexer,
-		queue:      queue,
-		timeouts:   make(map[string]time.Time),
+		config:        cfg,
+		clientset:     clientset,
+		stateClient:   clientset,
+		mattermost:    mattermost,
+		notifiers:     map[string]notify.Notifier{notify.TypeMattermost: mattermostNotifier},
+		queue:         queue,
+		pods:          make(map[string]*podInformer),
+		factory:       informers.NewSharedInformerFactory(clientset, 0),
+		lastProcessed: time.Now().UnixNano(),
+		maxRetries:    5,
+		dispatcher:    newDispatcher(4, 1000, 3, clock.RealClock{}),
+		breakers:      newCircuitBreakers(5, time.Minute, clock.RealClock{}),
+		timeouts:      state.NewMemoryStore(24*time.Hour, 10000),
+		silences:      &silences{clock: clock.RealClock{}},
+		acks:          &acknowledgements{},
+		clock:         clock.RealClock{},
 	}
 }
 
-func (c *Controller) processNextItem() bool {
+// NewForClientset creates a controller watching the pods of the given namespaces using the clientset,
+// configured by the options like Run, but without leader election, sharding, further resources and
+// the HTTP endpoints. It is used to run the controller against test clusters.
+func NewForClientset(opts Options, cfg *config.Config, clientset kubernetes.Interface, mattermost *utils.MattermostClient, namespaces []string) (*Controller, error) {
+	if opts.QueueBaseDelay <= 0 || opts.QueueMaxDelay < opts.QueueBaseDelay {
+		return nil, fmt.Errorf("queue delays must be positive with the maximum delay not below the base delay")
+	}
+	if opts.Senders <= 0 || opts.SendQueueSize <= 0 {
+		return nil, fmt.Errorf("number of senders and send queue size must be positive")
+	}
+	if opts.CircuitBreakerFailures <= 0 {
+		return nil, fmt.Errorf("circuit breaker failures must be positive")
+	}
+	if opts.StateTTL <= 0 || opts.StateMaxEntries <= 0 {
+		return nil, fmt.Errorf("state TTL and maximum entries must be positive")
+	}
+	if opts.MaxRetries < 0 {
+		return nil, fmt.Errorf("maximum retries must not be negative")
+	}
+	c := NewController(cfg, clientset, mattermost, newQueue(opts, "pods"))
+	if opts.Clock != nil {
+		c.clock = opts.Clock
+		c.silences.clock = opts.Clock
+		c.markProcessed()
+	}
+	var err error
+	if c.notifiers, err = newNotifiers(cfg, mattermost, c.clock); err != nil {
+		return nil, err
+	}
+	c.podSelector = opts.PodSelector
+	c.podFieldSelector = opts.PodFieldSelector
+	c.resyncPeriod = opts.ResyncPeriod
+	c.maxRetries = opts.MaxRetries
+	// Pods selected by label are monitored without being annotated.
+	c.optOut = opts.OptOut || opts.PodSelector != ""
+	c.metricsServer = opts.MetricsServer
+	if opts.RestartAnomalies {
+		c.anomalies = &restartRates{}
+	}
+	c.dispatcher = newDispatcher(opts.Senders, opts.SendQueueSize, opts.SendRetries, c.clock)
+	c.breakers = newCircuitBreakers(opts.CircuitBreakerFailures, opts.CircuitBreakerCooldown, c.clock)
+	c.timeouts = state.NewMemoryStoreWithClock(opts.StateTTL, opts.StateMaxEntries, c.clock)
+	for _, namespace := range namespaces {
+		c.watchNamespace(namespace)
+	}
+	c.namespaces, c.namespaceInformer = c.newNamespaceInformer()
+	return c, nil
+}
+
+// watchNamespace adds an informer for the pods in the given namespace, or all namespaces
+// if metav1.NamespaceAll is given. It must be called before the controller is run.
+func (c *Controller) watchNamespace(namespace string) {
+	c.pods[namespace] = c.newPodInformer(namespace)
+}
+
+// SetStateStore replaces the store holding the time of the last notifications, e.g. by a fake in
+// tests. It must be called before the controller is run.
+func (c *Controller) SetStateStore(store state.Store) {
+	c.timeouts = store
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
 	// Wait until there is a new item in the working queue
-	key, quit := c.queue.Get()
+	item, quit := c.queue.Get()
 	if quit {
 		return false
 	}
-	// Tell the queue that we are done with processing this key. This unblocks the key for other workers
+	// Tell the queue that we are done with processing this item. This unblocks the item for other workers
 	// This allows safe parallel processing because two pods with the same key are never processed in
 	// parallel.
-	defer c.queue.Done(key)
+	defer c.queue.Done(item)
+	defer c.markProcessed()
 
+	reason := c.reasons.take(item)
+	ctx, span := tracing.Tracer.Start(ctx, "processNextItem", trace.WithAttributes(
+		attribute.String("key", item.key()), attribute.String("reason", reason)))
+	defer span.End()
 	// Invoke the method containing the business logic
-	err := c.syncToStdout(key.(string))
+	err := c.syncToStdout(ctx, item, reason)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
 	// Handle the error if something went wrong during the execution of the business logic
-	c.handleErr(err, key)
+	c.handleErr(err, item)
 	return true
 }
 
 const (
 	annotationEnableMattermost       = "espe.tech/mattermost"
 	annotationEnableMattermostInform = "inform"
+	annotationEnableMattermostIgnore = "ignore"
 )
 
-func (c *Controller) hasValidAnnotation(pod *v1.Pod) bool {
-	return pod.GetObjectMeta().GetAnnotations()[annotationEnableMattermost] == annotationEnableMattermostInform
+func (c *Controller) hasValidAnnotation(ctx context.Context, pod *v1.Pod) bool {
+	value := c.annotation(ctx, pod, annotationEnableMattermost)
+	if c.optOut {
+		return value != annotationEnableMattermostIgnore
+	}
+	return value == annotationEnableMattermostInform
+}
+
+func (c *Controller) isIgnored(ctx context.Context, pod *v1.Pod) bool {
+	return c.annotation(ctx, pod, annotationEnableMattermost) == annotationEnableMattermostIgnore
 }
 
 const (
-	annotationMattermostBackoff        = "espe.tech/mattermost-backoff"
-	annotationMattermostBackoffDefault = time.Minute * 10
+	annotationMattermostBackoff           = "espe.tech/mattermost-backoff"
+	annotationMattermostChannel           = "espe.tech/mattermost-channel"
+	annotationMattermostMinRestarts       = "espe.tech/mattermost-min-restarts"
+	annotationMattermostMessage           = "espe.tech/mattermost-message"
+	annotationMattermostLocale            = "espe.tech/mattermost-locale"
+	annotationMattermostContainers        = "espe.tech/mattermost-containers"
+	annotationMattermostExcludeContainers = "espe.tech/mattermost-exclude-containers"
+	annotationMattermostOnCall            = "espe.tech/mattermost-on-call"
 )
 
-func (c *Controller) refreshBackoff(pod *v1.Pod, container *v1.ContainerStatus) bool {
-	backoff := annotationMattermostBackoffDefault
-	if backoffVal := pod.GetObjectMeta().GetAnnotations()[annotationMattermostBackoff]; backoffVal != "" {
-		if seconds, err := strconv.Atoi(backoffVal); err != nil {
-			backoff = time.Duration(seconds) * time.Second
+// watchesContainer checks if the container is selected by the container annotations, which list
+// glob patterns separated by commas. Excluded containers take precedence over included ones.
+func (c *Controller) watchesContainer(ctx context.Context, pod *v1.Pod, container string) bool {
+	include := annotationList(c.annotation(ctx, pod, annotationMattermostContainers))
+	if len(include) > 0 && !matchPattern(include, container) {
+		return false
+	}
+	return !matchPattern(annotationList(c.annotation(ctx, pod, annotationMattermostExcludeContainers)), container)
+}
+
+// annotationList splits a comma-separated annotation value, ignoring empty entries.
+func annotationList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
 		}
 	}
-	if time.Since(c.timeouts[pod.GetName()]) < backoff {
+	return list
+}
+
+// withMinRestarts returns the rule requiring the restarts set by the annotation of the pod or the
+// configuration, unless the rule sets them itself. They are enforced by rules.Rule.Matches.
+func (c *Controller) withMinRestarts(ctx context.Context, pod *v1.Pod, rule *rules.Rule) *rules.Rule {
+	if rule.MinRestarts > 0 {
+		return rule
+	}
+	cfg, _ := c.settings()
+	restarts := cfg.MinRestarts
+	if value := c.annotation(ctx, pod, annotationMattermostMinRestarts); value != "" {
+		if annotated, err := strconv.ParseInt(value, 10, 32); err == nil && annotated >= 0 {
+			restarts = int32(annotated)
+		} else {
+			c.warnInvalidAnnotation(pod, annotationMattermostMinRestarts, value, fmt.Errorf("invalid number of restarts %q", value))
+		}
+	}
+	if restarts == 0 {
+		return rule
+	}
+	withRestarts := *rule
+	withRestarts.MinRestarts = restarts
+	return &withRestarts
+}
+
+// withCustomMessage prepends the message set by the owners of a workload to the generated text.
+func withCustomMessage(custom, text string) string {
+	if custom = strings.TrimSpace(custom); custom == "" {
+		return text
+	}
+	return custom + "\n\n" + text
+}
+
+// parseBackoff parses a backoff annotation, either a duration like 1h30m or a number of seconds.
+func parseBackoff(value string) (time.Duration, error) {
+	backoff, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, atoiErr := strconv.Atoi(value)
+		if atoiErr != nil {
+			return 0, fmt.Errorf("backoff %q is neither a duration nor a number of seconds", value)
+		}
+		backoff = time.Duration(seconds) * time.Second
+	}
+	if backoff <= 0 {
+		return 0, fmt.Errorf("backoff %q must be positive", value)
+	}
+	return backoff, nil
+}
+
+func (c *Controller) refreshBackoff(ctx context.Context, pod *v1.Pod, container *v1.ContainerStatus, rule *rules.Rule) bool {
+	cfg, _ := c.settings()
+	backoff := cfg.Backoff.Duration
+	if value := c.annotation(ctx, pod, annotationMattermostBackoff); value != "" {
+		if annotated, err := parseBackoff(value); err == nil {
+			backoff = annotated
+		} else {
+			c.warnInvalidAnnotation(pod, annotationMattermostBackoff, value, err)
+		}
+	}
+	if rule.Backoff > 0 {
+		backoff = rule.Backoff
+	}
+	key := podKey(pod) + "/" + rule.Namespace + "/" + rule.Name
+	backoff = scaleBackoff(backoff, c.backoffs.get(key), cfg.BackoffFactor, cfg.MaxBackoff.Duration)
+	if !c.timeouts.Refresh(key, backoff) {
 		return false
 	}
-	c.timeouts[pod.GetName()] = time.Now()
+	c.backoffs.inc(key)
+	atomic.StoreInt32(&c.stateDirty, 1)
 	return true
 }
 
-func (c *Controller) clearTimeout(pod *v1.Pod) {
-	delete(c.timeouts, pod.GetName())
+func podKey(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
+
+// clearTimeout forgets the notification timeouts of the pod with the given namespace/name key.
+func (c *Controller) clearTimeout(pod string) {
+	c.backoffs.deletePrefix(pod + "/")
+	c.suppressed.deletePrefix(pod + "/")
+	c.invalid.deletePrefix(pod + "/")
+	if c.timeouts.DeletePrefix(pod+"/") > 0 {
+		atomic.StoreInt32(&c.stateDirty, 1)
+	}
+}
+
+// requestTimeout bounds the Kubernetes API requests made while processing a pod.
+const requestTimeout = 30 * time.Second
+
+// podLogs fetches the logs of a container. If tailLines is positive, only the last lines are returned.
+func (c *Controller) podLogs(ctx context.Context, pod *v1.Pod, container string, tailLines int64) ([]byte, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "podLogs", trace.WithAttributes(attribute.String("container", container)))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	options := &v1.PodLogOptions{Container: container}
+	if tailLines > 0 {
+		options.TailLines = &tailLines
+	}
+	logs, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, options).Do(ctx).Raw()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return logs, err
+}
+
+// alertLogs returns the logs shown in an alert, from the Kubernetes logs API and Loki if configured.
+func (c *Controller) alertLogs(ctx context.Context, cfg *config.Config, pod *v1.Pod, container string) []byte {
+	var logs []byte
+	if !cfg.Loki.Enabled() || !cfg.Loki.Replace {
+		logs, _ = c.podLogs(ctx, pod, container, 0)
+	}
+	if cfg.Loki.Enabled() {
+		ctx, span := tracing.Tracer.Start(ctx, "lokiLogs", trace.WithAttributes(attribute.String("container", container)))
+		defer span.End()
+		lokiLogs, err := loki.NewClient(&cfg.Loki).Logs(ctx, pod.Namespace, pod.Name, container, c.clock.Now())
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			klog.ErrorS(err, "Fetching logs from Loki failed", "pod", klog.KObj(pod), "container", container)
+		} else if len(logs) > 0 {
+			logs = append(append(logs, "\n--- Loki ---\n"...), lokiLogs...)
+		} else {
+			logs = lokiLogs
+		}
+	}
+	return logs
+}
+
+// alertData is passed to the message templates.
+type alertData struct {
+	Namespace    string
+	Pod          string
+	Container    string
+	Reason       string
+	Severity     string
+	RestartCount int32
+	Cluster      string
+	Environment  string
+	// Time is the time of the crash in the time zone of the channel.
+	Time time.Time
+	// Status, Labels and Annotations are only set for alerts received from Alertmanager.
+	Status      string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// terminationReason returns the reason of the last termination, falling back to the waiting reason.
+func terminationReason(container *v1.ContainerStatus) string {
+	if terminated := container.LastTerminationState.Terminated; terminated != nil && terminated.Reason != "" {
+		return terminated.Reason
+	}
+	if container.State.Waiting != nil {
+		return container.State.Waiting.Reason
+	}
+	return ""
 }
 
-func (c *Controller) sendCrashNotification(pod *v1.Pod, container *v1.ContainerStatus) {
-	logs, _ := c.clientset.
-		CoreV1().Pods(pod.Namespace).
-		GetLogs(pod.Name, &v1.PodLogOptions{Container: container.Name}).Do().Raw()
-	message := fmt.Sprintf("Container %s of pod %s keeps crashing, maybe its time to intervene.", container.Name, pod.Name)
-	attachment := &model.SlackAttachment{
-		Color: "#AD2200",
-		Text:  message,
-		Title: "Crash loop detected!",
-		Fields: []*model.SlackAttachmentField{
-			{
-				Title: "Logs",
-				Value: "```\n" + string(logs) + "```",
-			},
-		},
+func (c *Controller) sendCrashNotification(ctx context.Context, pod *v1.Pod, container *v1.ContainerStatus, rule *rules.Rule) {
+	if silence, ok := c.silences.match(pod.Namespace, pod.Name, container.Name); ok {
+		klog.InfoS("Notification silenced", "pod", klog.KObj(pod), "container", container.Name, "silence", silence.ID)
+		c.recordEvent(pod, v1.EventTypeNormal, eventReasonSuppressed,
+			"Notification for container %s suppressed by silence %s until %s", container.Name, silence.ID, silence.Until.Format(time.RFC3339))
+		return
+	}
+	cfg, mattermost := c.settings()
+	if c.firing.isFlapping(podKey(pod)+"/"+container.Name, c.clock.Now(), cfg.Flapping.Window.Duration) {
+		klog.InfoS("Notification suppressed while the container is flapping", "pod", klog.KObj(pod), "container", container.Name)
+		c.recordEvent(pod, v1.EventTypeNormal, eventReasonSuppressed,
+			"Notification for container %s suppressed while it is flapping", container.Name)
+		return
+	}
+	severity := c.severity(ctx, pod)
+	if rule.Severity != "" {
+		if ruleSeverity, err := ParseSeverity(rule.Severity); err == nil {
+			severity = ruleSeverity
+		} else {
+			klog.ErrorS(err, "Alert rule has invalid severity", "rule", klog.KRef(rule.Namespace, rule.Name))
+		}
+	}
+	templates := &cfg.Templates
+	if rule.Templates != nil {
+		templates = rule.Templates
+	}
+	reason := rule.Reason(container)
+	locale := c.locale(cfg, pod, pod.Namespace, c.annotation(ctx, pod, annotationMattermostLocale))
+	now := c.clock.Now()
+	// The channel and the notifiers are taken from the same route, even at the end of the
+	// business hours.
+	routed, names := cfg.Routing(now, pod.Namespace, severity.String(), container.State.Waiting.Reason, terminationReason(container))
+	channel := rule.Channel
+	if channel == "" {
+		channel = c.annotation(ctx, pod, annotationMattermostChannel)
+	}
+	if channel == "" {
+		channel = routed
+	}
+	crashedAt := now
+	if container.LastTerminationState.Terminated != nil {
+		crashedAt = container.LastTerminationState.Terminated.FinishedAt.Time
+	}
+	cluster, environment := cfg.Cluster.Identity(c.cluster)
+	title, message, err := templates.Render(cfg.Catalogs, locale, &alertData{
+		Namespace:    pod.Namespace,
+		Pod:          pod.Name,
+		Container:    container.Name,
+		Reason:       reason,
+		Severity:     severity.String(),
+		RestartCount: container.RestartCount,
+		Cluster:      cluster,
+		Environment:  environment,
+		Time:         crashedAt.In(cfg.Location(channel)),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Rendering message failed", "pod", klog.KObj(pod), "container", container.Name)
+		return
 	}
+	message = withCustomMessage(c.annotation(ctx, pod, annotationMattermostMessage), message)
+	logs := c.alertLogs(ctx, cfg, pod, container.Name)
+	alert := &notify.Alert{
+		Time:         now,
+		Fingerprint:  c.fingerprint(pod.Namespace, pod.Name, container.Name),
+		Namespace:    pod.Namespace,
+		Pod:          pod.Name,
+		Container:    container.Name,
+		Workload:     c.workload(ctx, pod),
+		Reason:       reason,
+		Severity:     severity.String(),
+		RestartCount: container.RestartCount,
+		Title:        title,
+		Text:         message,
+		Logs:         string(logs),
+		IncidentURL:  c.startPlaybookRun(ctx, cfg, mattermost, pod, container, severity, message),
+		Resources:    c.containerResources(ctx, pod, container.Name),
+		Priority:     cfg.Priorities[severity.String()],
+		Emoji:        lookupStyle(cfg.Emojis, container, severity),
+		IconURL:      lookupStyle(cfg.Icons, container, severity),
+		Color:        lookupStyle(cfg.Colors, container, severity),
+		Channel:      channel,
+		Locale:       locale,
+	}
+	c.identify(cfg, alert)
+	c.assignOnCall(pod.Namespace, c.annotation(ctx, pod, annotationMattermostOnCall), alert)
 	// Check for termination message
 	if container.LastTerminationState.Terminated != nil {
-		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
-			Title: "Reason",
-			Value: container.LastTerminationState.Terminated.Reason,
-		})
+		alert.TerminationReason = container.LastTerminationState.Terminated.Reason
+	}
+	if cfg.Kibana.Enabled() {
+		if link, err := cfg.Kibana.DiscoverURL(pod.Namespace, pod.Name, container.Name, crashedAt); err == nil {
+			alert.Links = append(alert.Links, notify.Link{Title: cfg.Catalogs.Translate(locale, "Kibana logs"), URL: link})
+		} else {
+			klog.ErrorS(err, "Generating Kibana link failed", "pod", klog.KObj(pod))
+		}
+	}
+	c.armEscalation(cfg, alert, names)
+	klog.InfoS("Sending crash notification", "pod", klog.KObj(pod), "container", container.Name, "reason", reason,
+		"severity", severity, "channel", alert.Channel, "notifiers", names)
+	c.publish(stream.EventDetected, alert, "", nil)
+	recordHistory := c.history.recordOnDelivery(alertRecord{
+		Time:      alert.Time,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Container: container.Name,
+		Reason:    reason,
+	})
+	sent := false
+	for _, name := range names {
+		recordNotification := c.notificationRecorder(pod, alert, name)
+		done := func(postID string, err error) {
+			countNotification(alert, name, err)
+			recordNotification(postID, err)
+			recordHistory(postID, err)
+		}
+		if c.enqueueAlert(ctx, name, alert, done) {
+			sent = true
+		}
+	}
+	if sent {
+		c.firing.add(alert, names)
+	}
+}
+
+// enqueueAlert queues the alert for delivery by the named notifier and reports whether it has been queued.
+// If done is not nil, it is called with the result of the delivery and the ID of the message if
+// the notifier reports it. Undelivered alerts are spooled, if enabled, unless the notifier rejected
+// them permanently, and done is called once they are delivered from the spool.
+func (c *Controller) enqueueAlert(ctx context.Context, name string, alert *notify.Alert, done func(postID string, err error)) bool {
+	notifier := c.notifier(name)
+	if notifier == nil {
+		klog.ErrorS(nil, "Dropping notification for unknown notifier", "notifier", name)
+		if done != nil {
+			done("", fmt.Errorf("unknown notifier %s", name))
+		}
+		return false
+	}
+	var postID string
+	send := notifier.Send
+	if poster, ok := notifier.(notify.Poster); ok {
+		send = func(ctx context.Context, alert *notify.Alert) (err error) {
+			postID, err = poster.Post(ctx, alert)
+			return err
+		}
+	}
+	finish := func(err error) {
+		if alert.Synthetic {
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			syntheticDeliveries.WithLabelValues(name, result).Inc()
+		}
+		if err != nil {
+			c.publish(stream.EventFailed, alert, name, err)
+			if !utils.IsPermanent(err) && c.spoolAlert(name, alert, done) {
+				return
+			}
+		} else {
+			c.publish(stream.EventNotified, alert, name, nil)
+		}
+		if done != nil {
+			done(postID, err)
+		}
+	}
+	return c.enqueueDelivery(ctx, "notify", name, alert, send, finish)
+}
+
+// enqueueDelivery queues a call of send with the alert, traced as a span with the given name.
+// If done is not nil, it is called with the result of the delivery.
+func (c *Controller) enqueueDelivery(ctx context.Context, op, name string, alert *notify.Alert, send func(context.Context, *notify.Alert) error, done func(error)) bool {
+	parent := trace.SpanContextFromContext(ctx)
+	breaker := c.breakers.get(name, alert.Channel)
+	err := c.dispatcher.enqueue(name, alert.Channel, func(ctx context.Context) error {
+		if !breaker.allow() {
+			return errCircuitOpen
+		}
+		ctx, span := tracing.Tracer.Start(trace.ContextWithRemoteSpanContext(ctx, parent), op,
+			trace.WithAttributes(attribute.String("notifier", name), attribute.String("channel", alert.Channel)))
+		defer span.End()
+		err := send(ctx, alert)
+		breaker.record(err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}, done)
+	if err != nil {
+		klog.ErrorS(err, "Dropping notification", "pod", klog.KRef(alert.Namespace, alert.Pod), "notifier", name)
+		if done != nil {
+			done(err)
+		}
+		return false
 	}
-	c.mattermost.SendAttachements(attachment)
+	return true
 }
 
-func (c *Controller) handlePodUpdate(pod *v1.Pod) {
+// lookupStyle looks up the value configured for the termination reason, the waiting reason
+// or the severity of an alert, in this order.
+func lookupStyle(values map[string]string, container *v1.ContainerStatus, severity Severity) string {
+	if terminated := container.LastTerminationState.Terminated; terminated != nil {
+		if value, ok := values[terminated.Reason]; ok {
+			return value
+		}
+	}
+	if waiting := container.State.Waiting; waiting != nil {
+		if value, ok := values[waiting.Reason]; ok {
+			return value
+		}
+	}
+	return values[severity.String()]
+}
+
+// startPlaybookRun starts the configured playbook if the alert is severe enough.
+// It returns a link to the run or an empty string if no run has been started.
+func (c *Controller) startPlaybookRun(ctx context.Context, cfg *config.Config, mattermost *utils.MattermostClient, pod *v1.Pod, container *v1.ContainerStatus, severity Severity, description string) string {
+	if cfg.Playbook.ID == "" {
+		return ""
+	}
+	threshold, err := ParseSeverity(cfg.Playbook.Severity)
+	if err != nil {
+		klog.ErrorS(err, "Invalid playbook severity")
+		return ""
+	}
+	if severity < threshold {
+		return ""
+	}
+	name := fmt.Sprintf("Crash loop of %s/%s", pod.Name, container.Name)
+	link, err := mattermost.StartPlaybookRun(ctx, cfg.Playbook.ID, name, description)
+	if err != nil {
+		klog.ErrorS(err, "Starting playbook run failed", "pod", klog.KObj(pod), "container", container.Name)
+		return ""
+	}
+	return link
+}
+
+// activeRules returns the rules applying to the pod. Annotated pods not selected by any
+// AlertRule are handled by the default rule.
+func (c *Controller) activeRules(ctx context.Context, pod *v1.Pod) []*rules.Rule {
+	if c.isIgnored(ctx, pod) {
+		return nil
+	}
+	var active []*rules.Rule
+	if c.rules != nil {
+		for _, rule := range c.rules.List(pod.Namespace) {
+			if rule.Selector.Matches(labels.Set(pod.Labels)) {
+				active = append(active, c.withMinRestarts(ctx, pod, rule))
+			}
+		}
+	}
+	if len(active) == 0 && c.hasValidAnnotation(ctx, pod) {
+		return []*rules.Rule{c.withMinRestarts(ctx, pod, rules.Default)}
+	}
+	return active
+}
+
+func (c *Controller) handlePodUpdate(ctx context.Context, pod *v1.Pod) {
+	active := c.activeRules(ctx, pod)
+	if c.anomalies != nil && len(active) > 0 {
+		c.observeRestarts(ctx, pod)
+	}
+	// Pods without container statuses have not started yet and did not recover.
+	ready := len(pod.Status.ContainerStatuses) > 0
 	for _, container := range pod.Status.ContainerStatuses {
-		if !container.Ready && container.State.Waiting != nil && c.hasValidAnnotation(pod) {
-			switch container.State.Waiting.Reason {
-			case "CrashLoopBackOff":
-				if !c.refreshBackoff(pod, &container) {
-					continue
+		ready = ready && container.Ready
+		if !container.Ready {
+			c.relapseContainer(ctx, pod, &container)
+		}
+		for _, rule := range active {
+			if !rule.Matches(pod, &container) || !c.watchesContainer(ctx, pod, container.Name) {
+				continue
+			}
+			// The suppression is recorded once per notification, not on every update of the pod.
+			suppressedKey := podKey(pod) + "/" + container.Name + "/" + rule.Namespace + "/" + rule.Name
+			if !c.refreshBackoff(ctx, pod, &container, rule) {
+				if c.suppressed.add(suppressedKey) {
+					c.recordEvent(pod, v1.EventTypeNormal, eventReasonSuppressed,
+						"Notification for container %s suppressed until the backoff interval has passed", container.Name)
 				}
-				c.sendCrashNotification(pod, &container)
+				continue
 			}
+			c.suppressed.remove(suppressedKey)
+			c.sendCrashNotification(ctx, pod, &container, rule)
+		}
+		if container.Ready {
+			c.recoverContainer(ctx, podKey(pod)+"/"+container.Name)
 		}
 	}
+	// The backoff starts over once all containers recovered and their alerts are resolved.
+	if ready && !c.firing.hasPod(podKey(pod)) {
+		c.backoffs.deletePrefix(podKey(pod) + "/")
+	}
 }
 
 // syncToStdout is the business logic of the controller. In this controller it simply prints
 // information about the pod to stdout. In case an error happened, it has to simply return the error.
 // The retry logic should not be part of the business logic.
-func (c *Controller) syncToStdout(key string) error {
-	obj, exists, err := c.indexer.GetByKey(key)
+func (c *Controller) syncToStdout(ctx context.Context, item workItem, reason string) error {
+	ctx, span := tracing.Tracer.Start(ctx, "syncToStdout")
+	defer span.End()
+	obj, exists, err := c.getPod(item.key())
 	if err != nil {
-		klog.Errorf("Fetching object with key %s from store failed with %v", key, err)
+		klog.ErrorS(err, "Fetching pod from store failed", "key", item.key())
 		return err
 	}
-	if !exists {
-		// Below we will warm up our cache with a Pod, so that we will see a delete for one pod
-		klog.Infof("Pod %s does not exist anymore\n", key)
-		// Clean up intervals if possible
-		if obj != nil {
-			c.clearTimeout(obj.(*v1.Pod))
+	pod, ok := obj.(*v1.Pod)
+	if !exists || !ok {
+		klog.InfoS("Pod does not exist anymore", "pod", klog.KRef(item.Namespace, item.Name))
+		// Clean up intervals
+		c.clearTimeout(item.key())
+		c.resolveAlerts(ctx, c.firing.takePod(item.key()))
+		if c.anomalies != nil {
+			c.anomalies.forgetPod(item.key())
 		}
-	} else {
-		klog.Infof("Received create/update/delete for Pod %s\n", key)
-		// Note that you also have to check the uid if you have a local controlled resource, which
-		// is dependent on the actual instance, to detect that a Pod was recreated with the same name
-		c.handlePodUpdate(obj.(*v1.Pod))
+		return nil
 	}
+	klog.InfoS("Received create/update/delete for pod", "pod", klog.KObj(pod), "reason", reason)
+	// Note that you also have to check the uid if you have a local controlled resource, which
+	// is dependent on the actual instance, to detect that a Pod was recreated with the same name
+	c.handlePodUpdate(ctx, pod)
 	return nil
 }
 
 // handleErr checks if an error happened and makes sure we will retry later.
-func (c *Controller) handleErr(err error, key interface{}) {
+func (c *Controller) handleErr(err error, item workItem) {
 	if err == nil {
 		// Forget about the #AddRateLimited history of the key on every successful synchronization.
 		// This ensures that future processing of updates for this key is not delayed because of
 		// an outdated error history.
-		c.queue.Forget(key)
+		c.queue.Forget(item)
 		return
 	}
 
-	// This controller retries 5 times if something goes wrong. After that, it stops trying.
-	if c.queue.NumRequeues(key) < 5 {
-		klog.Infof("Error syncing pod %v: %v", key, err)
+	// This controller retries maxRetries times if something goes wrong. After that, it stops trying.
+	if c.queue.NumRequeues(item) < c.maxRetries {
+		klog.ErrorS(err, "Syncing pod failed", "key", item.key())
 
 		// Re-enqueue the key rate limited. Based on the rate limiter on the
 		// queue and the re-enqueue history, the key will be processed later again.
-		c.queue.AddRateLimited(key)
+		c.reasons.set(item, reasonRetry)
+		c.queue.AddRateLimited(item)
 		return
 	}
 
-	c.queue.Forget(key)
+	c.queue.Forget(item)
 	// Report to an external entity that, even after several retries, we could not successfully process this key
-	runtime.HandleError(err)
-	klog.Infof("Dropping pod %q out of the queue: %v", key, err)
+	runtime.HandleErrorWithContext(context.TODO(), err, "Dropping pod out of the queue", "key", item.key())
 }
 
-func (c *Controller) Run(threadiness int, stopCh chan struct{}) {
+// Run starts the informers and workers. Once stopCh is closed, the workers finish the pods
+// remaining in the queue before Run returns. The workers pass ctx to all requests they make,
+// cancelling it aborts the requests in flight.
+func (c *Controller) Run(ctx context.Context, threadiness int, stopCh chan struct{}) {
 	defer runtime.HandleCrash()
 
 	// Let the workers stop when we are done
 	defer c.queue.ShutDown()
-	klog.Info("Starting Pod controller")
+	klog.InfoS("Starting Pod controller")
 
-	go c.informer.Run(stopCh)
+	c.stopCh = stopCh
+	c.factory.Start(stopCh)
+	synced := []cache.InformerSynced{c.namespaceInformer.HasSynced}
+	c.podsMu.RLock()
+	for _, informer := range c.pods {
+		informer.factory.Start(stopCh)
+		synced = append(synced, informer.informer.HasSynced)
+	}
+	c.podsMu.RUnlock()
+	if c.rules != nil {
+		go c.rules.Run(stopCh)
+		synced = append(synced, c.rules.HasSynced)
+	}
+	if c.onCall != nil {
+		go c.onCall.Run(stopCh)
+		synced = append(synced, c.onCall.HasSynced)
+	}
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
-	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+	if !cache.WaitForCacheSync(stopCh, synced...) {
 		runtime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
 		return
 	}
+	c.markProcessed()
 
+	c.dispatcher.Run(ctx)
+	watchers := c.runResourceWatchers(stopCh)
+	var resolver sync.WaitGroup
+	resolver.Add(1)
+	go func() {
+		defer resolver.Done()
+		c.runPendingResolves(ctx, stopCh)
+	}()
+	resolver.Add(1)
+	go func() {
+		defer resolver.Done()
+		c.runEscalations(ctx, stopCh)
+	}()
+	if c.eventInformer != nil {
+		go c.eventInformer.Run(stopCh)
+	}
+	var workers sync.WaitGroup
 	for i := 0; i < threadiness; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			c.runWorker(ctx)
+		}()
 	}
 
 	<-stopCh
-	klog.Info("Stopping Pod controller")
+	klog.InfoS("Stopping Pod controller, draining queued pods", "pods", c.queue.Len())
+	// The workers keep processing until the queue is empty.
+	c.queue.ShutDown()
+	workers.Wait()
+	watchers.Wait()
+	resolver.Wait()
+	klog.InfoS("Sending queued notifications", "notifications", c.dispatcher.pending())
+	c.dispatcher.stop()
+	klog.InfoS("Stopped Pod controller")
 }
 
-func (c *Controller) runWorker() {
-	for c.processNextItem() {
+// watchOptional adds the stores and watchers of the resources besides pods enabled by the options.
+// They are watched in the single watched namespace or all namespaces.
+func (c *Controller) watchOptional(opts *Options, dynamicClient dynamic.Interface, namespaces []string, ownNamespace string) {
+	namespace := resourceNamespace(namespaces)
+	if opts.AlertRules {
+		c.rules = rules.NewStore(dynamicClient, namespace, ownNamespace)
+	}
+	if opts.WatchRollouts {
+		c.watchResources(dynamicClient, namespace, c.rolloutCheck())
+	}
+	if opts.WatchArgoCD {
+		c.watchResources(dynamicClient, opts.ArgoCDNamespace, applicationCheck(opts.ArgoCDURL, opts.ArgoCDGracePeriod))
+	}
+	if opts.WatchAutoscaler {
+		c.watchAutoscalerEvents(namespace)
+	}
+	if opts.WatchFlux {
+		for _, check := range fluxChecks(c.clientset.Discovery()) {
+			c.watchResources(dynamicClient, namespace, check)
+		}
+	}
+	if opts.ServiceLevelObjectives {
+		c.watchResources(dynamicClient, namespace, c.sloCheck())
 	}
 }
 
-func Run() {
-	mattermost, err := utils.NewMattermostClient()
-	if err != nil {
-		klog.Fatal(err)
+func resourceNamespace(namespaces []string) string {
+	if len(namespaces) == 1 {
+		return namespaces[0]
 	}
+	return metav1.NamespaceAll
+}
 
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+// newQueue creates a workqueue for pods. Retries are delayed exponentially per pod, with an
+// overall limit of 10 retries per second.
+func newQueue(opts Options, name string) workqueue.TypedRateLimitingInterface[workItem] {
+	rateLimiter := workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[workItem](opts.QueueBaseDelay, opts.QueueMaxDelay),
+		&workqueue.TypedBucketRateLimiter[workItem]{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+	return workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter, workqueue.TypedRateLimitingQueueConfig[workItem]{Name: name})
+}
+
+// Run starts the informer and blocks forever.
+func Run(opts Options) error {
 	clientset, err := client.InCluster()
 	if err != nil {
-		klog.Fatal(err)
+		return err
+	}
+
+	ownNamespace, err := utils.Namespace()
+	if err != nil && ((len(opts.Namespaces) == 0 && !opts.AllNamespaces) || opts.ConfigResource != "" || opts.AlertRules || opts.Sharding || opts.LeaderElect || opts.StateConfigMap != "") {
+		return err
+	}
+	namespaces := opts.Namespaces
+	if opts.AllNamespaces || opts.NamespaceSelector != "" {
+		namespaces = []string{metav1.NamespaceAll}
+	} else if len(namespaces) == 0 {
+		namespaces = []string{ownNamespace}
+	}
+	var namespaceSelector labels.Selector
+	if opts.NamespaceSelector != "" {
+		if namespaceSelector, err = labels.Parse(opts.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid namespace selector: %v", err)
+		}
+	}
+	filter, err := newNamespaceFilter(opts.NamespaceInclude, opts.NamespaceExclude)
+	if err != nil {
+		return err
+	}
+	if _, err := labels.Parse(opts.PodSelector); err != nil {
+		return fmt.Errorf("invalid pod selector: %v", err)
+	}
+	if _, err := fields.ParseSelector(opts.PodFieldSelector); err != nil {
+		return fmt.Errorf("invalid pod field selector: %v", err)
+	}
+	if opts.Sharding && opts.LeaderElect {
+		return fmt.Errorf("sharding and leader election are mutually exclusive")
+	}
+	if opts.Sharding {
+		if ownNamespace == "" {
+			return fmt.Errorf("sharding requires running inside the cluster")
+		}
+		if opts.ShardLeaseDuration < 3*time.Second {
+			return fmt.Errorf("shard lease duration must be at least 3s")
+		}
+		// Watch each owned namespace separately so that the watch load is split between the replicas.
+		if namespaceSelector == nil && namespaces[0] == metav1.NamespaceAll {
+			namespaceSelector = labels.Everything()
+		}
+	}
+
+	var (
+		cfg           *config.Config
+		dynamicClient dynamic.Interface
+	)
+	if opts.ConfigResource != "" || opts.NotificationRecords || opts.WatchArgoCD || len(opts.watchedResources()) > 0 {
+		if dynamicClient, err = client.InClusterDynamic(); err != nil {
+			return err
+		}
+	}
+	if opts.ConfigResource != "" {
+		cfg, err = opts.loadResource(dynamicClient, ownNamespace)
+	} else {
+		cfg, err = opts.LoadConfig()
+	}
+	if err != nil {
+		return err
 	}
 
-	namespace, err := utils.Namespace()
+	mattermost, err := utils.NewMattermostClient(cfg.Mattermost)
 	if err != nil {
-		klog.Fatal(err)
+		return err
 	}
-	klog.Infof("Watching namespace %s", namespace)
 
-	// create the pod watcher
-	podListWatcher := cache.NewListWatchFromClient(clientset.CoreV1().RESTClient(), "pods", namespace, fields.Everything())
+	klog.InfoS("Starting Mattermost informer", "version", version.String())
+	// Namespaces selected by labels are watched as they come and go.
+	watched := namespaces
+	if namespaceSelector != nil {
+		klog.InfoS("Watching namespaces by label", "selector", namespaceSelector.String())
+		watched = nil
+	} else if namespaces[0] == metav1.NamespaceAll {
+		klog.InfoS("Watching all namespaces")
+	} else {
+		klog.InfoS("Watching namespaces", "namespaces", namespaces)
+	}
+	controller, err := NewForClientset(opts, cfg, clientset, mattermost, watched)
+	if err != nil {
+		return err
+	}
+	if opts.ActionSecretFile != "" {
+		// The secret is loaded before any alert is sent, so that all buttons are signed with it.
+		secret, err := ioutil.ReadFile(opts.ActionSecretFile)
+		if err != nil {
+			return fmt.Errorf("could not read action secret: %v", err)
+		}
+		if err := controller.acks.setSecret(string(secret)); err != nil {
+			return err
+		}
+	}
+	if opts.SpoolDir != "" {
+		if opts.SpoolMaxAge <= 0 || opts.SpoolMaxAttempts <= 0 {
+			return fmt.Errorf("spool maximum age and attempts must be positive")
+		}
+		s, err := spool.New(opts.SpoolDir, opts.SpoolMaxEntries)
+		if err != nil {
+			return err
+		}
+		controller.spool = newAlertSpool(s, opts.SpoolMaxAge, opts.SpoolMaxAttempts)
+		klog.InfoS("Spooling undelivered notifications", "dir", opts.SpoolDir)
+	}
+	controller.namespaceFilter = filter
+	controller.namespaceSelector = namespaceSelector
+	if opts.Sharding {
+		identity, err := opts.shardIdentity()
+		if err != nil {
+			return err
+		}
+		controller.shard = shard.NewMembershipWithClock(clientset, ownNamespace, identity, opts.ShardLeaseDuration, controller.rebalance, controller.clock)
+		if err := controller.shard.Sync(); err != nil {
+			return fmt.Errorf("failed to join shard members: %v", err)
+		}
+		klog.InfoS("Sharding namespaces", "identity", identity)
+	}
+	if !opts.SkipPermissionCheck {
+		permissions := requiredPermissions(&opts, namespaces)
+		if opts.NotificationRecords {
+			for _, verb := range []string{"create", "list", "update", "delete"} {
+				permissions = append(permissions, permission{Namespace: ownNamespace, Verb: verb, Group: records.GVR.Group, Resource: records.GVR.Resource})
+			}
+		}
+		if err := controller.checkPermissions(permissions); err != nil {
+			mattermost.Send(context.TODO(), "", fmt.Sprintf("Mattermost informer failed to start: %v", err))
+			return err
+		}
+	}
+	controller.watchOptional(&opts, dynamicClient, namespaces, ownNamespace)
+	if opts.OnCallSchedules {
+		controller.onCall = oncall.NewStoreWithClock(dynamicClient, resourceNamespace(namespaces), ownNamespace, controller.clock)
+	}
+
+	var shutdownTracing func(context.Context) error
+	if opts.OTLPEndpoint != "" {
+		if shutdownTracing, err = tracing.Setup(opts.OTLPEndpoint, opts.OTLPInsecure); err != nil {
+			return err
+		}
+		klog.InfoS("Exporting traces", "endpoint", opts.OTLPEndpoint)
+	}
+	if opts.SentryDSN != "" {
+		flushReports, err := reporting.Setup(opts.SentryDSN, opts.SentryEnvironment, version.String())
+		if err != nil {
+			return err
+		}
+		defer flushReports(5 * time.Second)
+		klog.InfoS("Reporting internal errors to Sentry")
+	}
 
-	// create the workqueue
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	if opts.NotificationRecords {
+		if opts.NotificationRecordRetention <= 0 {
+			return fmt.Errorf("notification record retention must be positive")
+		}
+		controller.records = records.NewStore(dynamicClient, ownNamespace, opts.NotificationRecordRetention)
+		controller.recordWrites = make(chan recordWrite, recordWriteQueueSize)
+	} else if opts.WeeklyReport {
+		return fmt.Errorf("the weekly report requires --notification-records")
+	} else if opts.TopCrashers {
+		return fmt.Errorf("the top crashers require --notification-records")
+	} else if opts.ServiceLevelObjectives {
+		return fmt.Errorf("service level objectives require --notification-records")
+	}
+	if opts.WeeklyReport && opts.NotificationRecordRetention < 2*reportWeek {
+		klog.InfoS("Notification records are not kept long enough for the weekly report to compare to the previous week", "retention", opts.NotificationRecordRetention)
+	}
+	if opts.GRPCAddr != "" {
+		controller.events = stream.NewHub()
+		token := func() string {
+			cfg, _ := controller.settings()
+			return cfg.APIToken
+		}
+		var creds credentials.TransportCredentials
+		if opts.GRPCCertFile != "" || opts.GRPCKeyFile != "" {
+			if creds, err = stream.ServerTLS(opts.GRPCCertFile, opts.GRPCKeyFile); err != nil {
+				return err
+			}
+		} else if !opts.GRPCInsecure {
+			return fmt.Errorf("the alert stream requires --grpc-cert-file and --grpc-key-file, or --grpc-insecure")
+		}
+		server, err := stream.Serve(opts.GRPCAddr, controller.events, token, creds)
+		if err != nil {
+			return err
+		}
+		defer server.Stop()
+		klog.InfoS("Streaming alerts via gRPC", "addr", opts.GRPCAddr)
+	}
+	controller.stateConfigMap = opts.StateConfigMap
+	controller.stateNamespace = ownNamespace
+	for _, value := range opts.Clusters {
+		cluster, err := controller.newClusterController(opts, value, namespaces, ownNamespace)
+		if err != nil {
+			return err
+		}
+		controller.clusters = append(controller.clusters, cluster)
+	}
+	controller.leaderElection = opts.LeaderElect
 
-	// Bind the workqueue to a cache with the help of an informer. This way we make sure that
-	// whenever the cache is updated, the pod key is added to the workqueue.
-	// Note that when we finally process the item from the workqueue, we might see a newer version
-	// of the Pod than the version which was responsible for triggering the update.
-	indexer, informer := cache.NewIndexerInformer(podListWatcher, &v1.Pod{}, 0, cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			key, err := cache.MetaNamespaceKeyFunc(obj)
-			if err == nil {
-				queue.Add(key)
+	restConfig, err := client.Config()
+	if err != nil {
+		return err
+	}
+	// Leave time for persisting the state after the queue has been drained.
+	gracefulShutdownTimeout := opts.ShutdownTimeout + 10*time.Second
+	mgr, err := manager.New(restConfig, manager.Options{
+		Metrics:                 metricsserver.Options{BindAddress: opts.MetricsAddr},
+		LeaderElection:          opts.LeaderElect,
+		LeaderElectionID:        "mattermost-informer",
+		LeaderElectionNamespace: ownNamespace,
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create manager: %v", err)
+	}
+	controller.recorder = mgr.GetEventRecorderFor("mattermost-informer")
+	mux := http.NewServeMux()
+	server := &http.Server{Addr: cfg.ListenAddr, Handler: mux}
+	// The controller only runs while this replica is the leader, if leader election is enabled.
+	err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		atomic.StoreInt32(&controller.leading, 1)
+		if controller.stateConfigMap != "" {
+			if err := controller.loadState(); err != nil {
+				return fmt.Errorf("failed to load notification state: %v", err)
 			}
-		},
-		UpdateFunc: func(old interface{}, new interface{}) {
-			key, err := cache.MetaNamespaceKeyFunc(new)
-			if err == nil {
-				queue.Add(key)
+			for _, cluster := range controller.clusters {
+				if err := cluster.loadState(); err != nil {
+					return fmt.Errorf("failed to load notification state of cluster %s: %v", cluster.cluster, err)
+				}
 			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			// IndexerInformer uses a delta queue, therefore for deletes we have to use this
-			// key function.
-			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
-			if err == nil {
-				queue.Add(key)
+		}
+		if controller.records != nil {
+			if err := controller.restoreFiring(ctx); err != nil {
+				return fmt.Errorf("failed to load notification records: %v", err)
+			}
+		}
+
+		stop := make(chan struct{})
+		// Processing continues after stop is closed until the queue is drained or the shutdown times out.
+		workCtx, cancelWork := context.WithCancel(context.Background())
+		defer cancelWork()
+		var background sync.WaitGroup
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			controller.Run(workCtx, opts.Workers, stop)
+		}()
+		for _, cluster := range controller.clusters {
+			background.Add(1)
+			go func(cluster *Controller) {
+				defer background.Done()
+				cluster.Run(workCtx, opts.Workers, stop)
+			}(cluster)
+		}
+		// The producers of notifications are awaited before the state is persisted.
+		runBackground := func(run func()) {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				run()
+			}()
+		}
+		if controller.stateConfigMap != "" {
+			runBackground(func() { controller.runStateSync(stop) })
+		}
+		if controller.records != nil {
+			runBackground(func() { controller.runRecordCollection(stop) })
+			runBackground(func() { controller.runRecordWriter(stop) })
+		}
+		if opts.WeeklyReport {
+			runBackground(func() { controller.runWeeklyReports(stop) })
+		}
+		if opts.TopCrashers {
+			runBackground(func() { controller.runTopCrashers(stop) })
+		}
+		if controller.spool != nil {
+			runBackground(func() { controller.runSpoolFlush(stop) })
+		}
+		if opts.SyntheticCrashInterval > 0 {
+			runBackground(func() { controller.runSyntheticCrashes(opts.SyntheticCrashInterval, stop) })
+		}
+		if opts.StartupNotice {
+			// Posting must not delay the start, e.g. while Mattermost is slow to respond.
+			watched := describeWatched(namespaces, namespaceSelector)
+			runBackground(func() { controller.postStartupNotice(watched) })
+		}
+		if opts.HeartbeatInterval > 0 {
+			runBackground(func() { controller.runHeartbeat(opts.HeartbeatInterval, stop) })
+		}
+		if controller.shard != nil {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				controller.shard.Run(stop)
+			}()
+		}
+		reload := func(cfg *config.Config) {
+			opts.apply(cfg)
+			controller.Reload(cfg)
+		}
+		if tokenFile := cfg.Mattermost.TokenFile; tokenFile != "" && cfg.Mattermost.WebhookURL == "" {
+			go func() {
+				if err := config.WatchFile(tokenFile, stop, func([]byte) { controller.Reauthenticate() }); err != nil {
+					klog.ErrorS(err, "Mattermost token will not be reloaded")
+				}
+			}()
+		}
+		if opts.ConfigResource != "" {
+			go config.WatchResource(dynamicClient, ownNamespace, opts.ConfigResource, stop, reload)
+		} else {
+			go func() {
+				if err := config.Watch(opts.ConfigPath, stop, reload); err != nil {
+					klog.ErrorS(err, "Configuration will not be reloaded")
+				}
+			}()
+		}
+
+		<-ctx.Done()
+		// Stop accepting webhooks and API requests before the dispatcher is drained.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.ErrorS(err, "Shutting down HTTP server failed")
+		}
+		controller.shutdown(opts, stop, &background, cancelWork)
+		return nil
+	}))
+	if err != nil {
+		return err
+	}
+
+	mux.Handle("/slash", controller.SlashCommandHandler())
+	mux.Handle("/alertmanager", controller.AlertmanagerHandler())
+	mux.Handle("/actions", controller.ActionHandler())
+	mux.Handle(api.Prefix, controller.APIHandler())
+	mux.Handle("/healthz", controller.HealthzHandler())
+	mux.Handle("/readyz", controller.ReadyzHandler())
+	go func() {
+		klog.InfoS("Listening", "addr", cfg.ListenAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			klog.ErrorS(err, "Serving HTTP failed", "addr", cfg.ListenAddr)
+			klog.FlushAndExit(klog.ExitFlushTimeout, 1)
+		}
+	}()
+	// Profiles, the internal state and the dashboard are not authenticated and never served on the public listener.
+	if opts.EnableDebug || opts.EnableDashboard {
+		debugMux := http.NewServeMux()
+		if opts.EnableDebug {
+			controller.registerDebugHandlers(debugMux)
+		}
+		if opts.EnableDashboard {
+			debugMux.Handle("/dashboard", controller.DashboardHandler())
+		}
+		debugServer := &http.Server{Addr: opts.DebugAddr, Handler: debugMux}
+		go func() {
+			klog.InfoS("Serving debug handlers", "addr", opts.DebugAddr)
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				klog.ErrorS(err, "Serving debug handlers failed", "addr", opts.DebugAddr)
+				klog.FlushAndExit(klog.ExitFlushTimeout, 1)
 			}
-		},
-	}, cache.Indexers{})
+		}()
+		defer debugServer.Close()
+	}
 
-	controller := NewController(clientset, mattermost, queue, indexer, informer)
+	// Run until a termination signal is received
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if shutdownTracing != nil {
+		if err := shutdownTracing(ctx); err != nil {
+			klog.ErrorS(err, "Flushing traces failed")
+		}
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %v", err)
+	}
+	return nil
+}
 
-	stop := make(chan struct{})
-	defer close(stop)
-	go controller.Run(1, stop)
+// describeWatched describes the watched namespaces for the startup notice.
+func describeWatched(namespaces []string, selector labels.Selector) string {
+	switch {
+	case selector != nil && !selector.Empty():
+		return fmt.Sprintf("namespaces matching %s", selector)
+	case selector != nil || namespaces[0] == metav1.NamespaceAll:
+		return "all namespaces"
+	case len(namespaces) == 1:
+		return "namespace " + namespaces[0]
+	default:
+		return "namespaces " + strings.Join(namespaces, ", ")
+	}
+}
 
-	// Wait forever
-	select {}
+// postStartupNotice announces the version and watched namespaces in the ops channel, so that
+// upgrades and restarts are visible.
+func (c *Controller) postStartupNotice(watched string) {
+	cfg, mattermost := c.settings()
+	message := fmt.Sprintf("Mattermost informer %s started watching %s", version.Version, watched)
+	if cfg.Cluster.Name != "" {
+		message += " in cluster " + cfg.Cluster.Name
+	}
+	if len(c.clusters) > 0 {
+		names := make([]string, len(c.clusters))
+		for i, cluster := range c.clusters {
+			names[i] = cluster.cluster
+		}
+		message += fmt.Sprintf(" and clusters %s", strings.Join(names, ", "))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := mattermost.Send(ctx, cfg.OpsChannel, message+"."); err != nil {
+		klog.ErrorS(err, "Posting startup notice failed")
+	}
+}
+
+// shutdown stops the informers, waits for the queued pods to be processed and persists the state.
+// If this takes longer than the shutdown timeout, the remaining work is abandoned.
+func (c *Controller) shutdown(opts Options, stop chan struct{}, background *sync.WaitGroup, cancelWork context.CancelFunc) {
+	klog.InfoS("Shutting down")
+	close(stop)
+
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(opts.ShutdownTimeout):
+		klog.InfoS("Abandoning queued pods", "pods", c.queue.Len(), "timeout", opts.ShutdownTimeout)
+		cancelWork()
+	}
+
+	if c.records != nil {
+		c.flushRecordWrites()
+	}
+	if c.stateConfigMap != "" {
+		c.saveStates()
+	}
+	if opts.ShutdownNotice {
+		cfg, mattermost := c.settings()
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		if err := mattermost.Send(ctx, cfg.OpsChannel, "Mattermost informer is shutting down, crash notifications are paused."); err != nil {
+			klog.ErrorS(err, "Posting shutdown notice failed")
+		}
+	}
 }
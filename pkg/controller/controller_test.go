@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// testStart is the time fake clocks of tests start at.
+var testStart = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestController creates a controller with the default configuration for the given objects,
+// whose time is set by the returned fake clock.
+func newTestController(t *testing.T, objects ...runtime.Object) (*Controller, *testingclock.FakeClock) {
+	t.Helper()
+	cfg, err := config.Parse([]byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	clock := testingclock.NewFakeClock(testStart)
+	opts.Clock = clock
+	c, err := NewForClientset(opts, cfg, fake.NewSimpleClientset(objects...), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.queue.ShutDown)
+	return c, clock
+}
+
+func TestOptOutMonitoring(t *testing.T) {
+	tests := []struct {
+		name       string
+		optOut     bool
+		annotation string
+		want       bool
+	}{
+		{name: "opt-in without annotation", want: false},
+		{name: "opt-in", annotation: annotationEnableMattermostInform, want: true},
+		{name: "opt-out without annotation", optOut: true, want: true},
+		{name: "opt-out ignored", optOut: true, annotation: annotationEnableMattermostIgnore, want: false},
+		{name: "opt-out with unknown value", optOut: true, annotation: "maybe", want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newTestController(t)
+			c.optOut = test.optOut
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}
+			if test.annotation != "" {
+				pod.Annotations = map[string]string{annotationEnableMattermost: test.annotation}
+			}
+			if got := c.hasValidAnnotation(context.Background(), pod); got != test.want {
+				t.Errorf("hasValidAnnotation() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/version"
+	"k8s.io/klog/v2"
+)
+
+// dashboardData is passed to the dashboard template.
+type dashboardData struct {
+	Version    string
+	Identity   string
+	Now        time.Time
+	Location   *time.Location
+	Firing     []*notify.Alert
+	Recent     []alertRecord
+	Silences   []api.Silence
+	Namespaces []namespaceStats
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(now, t time.Time) string { return now.Sub(t).Round(time.Second).String() },
+	"until": func(now, t time.Time) string { return t.Sub(now).Round(time.Second).String() },
+	"orAll": func(s string) string {
+		if s == "" {
+			return "*"
+		}
+		return s
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>Mattermost informer</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.3em 1em 0.3em 0; border-bottom: 1px solid #ddd; vertical-align: top; }
+.critical { color: #ad2200; font-weight: bold; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>Mattermost informer</h1>
+<p class="muted">Version {{.Version}}{{with .Identity}}, cluster {{.}}{{end}}, updated {{.Now.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Firing alerts ({{len .Firing}})</h2>
+{{if .Firing}}<table>
+<tr><th>Since</th><th>Namespace</th><th>Pod</th><th>Container</th><th>Reason</th><th>Severity</th><th>Channel</th></tr>
+{{range .Firing}}<tr><td>{{since $.Now .Time}}</td><td>{{.Namespace}}</td><td>{{.Pod}}</td><td>{{.Container}}</td><td>{{.Reason}}</td><td class="{{.Severity}}">{{.Severity}}</td><td>{{or .Channel "default"}}</td></tr>
+{{end}}</table>{{else}}<p>No alerts are firing.</p>{{end}}
+
+<h2>Recent notifications</h2>
+{{if .Recent}}<table>
+<tr><th>Time</th><th>Namespace</th><th>Pod</th><th>Container</th><th>Reason</th></tr>
+{{range .Recent}}<tr><td>{{(.Time.In $.Location).Format "2006-01-02 15:04:05 MST"}}</td><td>{{.Namespace}}</td><td>{{.Pod}}</td><td>{{.Container}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>{{else}}<p>No notifications have been sent yet.</p>{{end}}
+
+<h2>Active silences</h2>
+{{if .Silences}}<table>
+<tr><th>Namespace</th><th>Pod</th><th>Container</th><th>Expires in</th><th>Created by</th><th>Comment</th></tr>
+{{range .Silences}}<tr><td>{{orAll .Namespace}}</td><td>{{orAll .Pod}}</td><td>{{orAll .Container}}</td><td>{{until $.Now .Until}}</td><td>{{.CreatedBy}}</td><td>{{.Comment}}</td></tr>
+{{end}}</table>{{else}}<p>No silences are active.</p>{{end}}
+
+<h2>Namespaces</h2>
+{{if .Namespaces}}<table>
+<tr><th>Namespace</th><th>Alerts</th><th>Last alert</th></tr>
+{{range .Namespaces}}<tr><td>{{.Namespace}}</td><td>{{.Alerts}}</td><td>{{since $.Now .Last}} ago</td></tr>
+{{end}}</table>
+<p class="muted">Alerts are counted since the informer started.</p>{{else}}<p>No namespace has alerted yet.</p>{{end}}
+</body>
+</html>
+`))
+
+// DashboardHandler serves a read-only HTML overview of the firing alerts, recent notifications,
+// active silences and alerts per namespace.
+func (c *Controller) DashboardHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cfg, _ := c.settings()
+		cluster, environment := cfg.Cluster.Identity(c.cluster)
+		data := &dashboardData{
+			Version:    version.Version,
+			Identity:   (&notify.Alert{Cluster: cluster, Environment: environment}).Identity(),
+			Now:        c.clock.Now().In(cfg.Location("")),
+			Location:   cfg.Location(""),
+			Recent:     c.history.list(),
+			Silences:   c.silences.list(),
+			Namespaces: c.history.namespaces(),
+		}
+		for _, firing := range c.firing.list() {
+			data.Firing = append(data.Firing, firing.alert)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTemplate.Execute(w, data); err != nil {
+			klog.ErrorS(err, "Rendering dashboard failed")
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+func TestDashboardShowsTimesByClock(t *testing.T) {
+	c, clock := newTestController(t)
+	c.firing.add(&notify.Alert{Time: testStart, Namespace: "apps", Pod: "web", Container: "app"}, []string{notify.TypeMattermost})
+	c.silences.update(func(items map[string]api.Silence) bool {
+		items["1"] = api.Silence{ID: "1", Namespace: "apps", Until: testStart.Add(time.Hour)}
+		return true
+	})
+	clock.Step(5 * time.Minute)
+
+	recorder := httptest.NewRecorder()
+	c.DashboardHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/dashboard", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{"<td>5m0s</td><td>apps</td><td>web</td>", "<td>55m0s</td>", "updated 2024-01-01 12:05:00 UTC"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard does not contain %q:\n%s", want, body)
+		}
+	}
+}
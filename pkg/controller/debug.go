@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// debugState is a snapshot of the in-memory state of the controller.
+type debugState struct {
+	Namespaces  []string             `json:"namespaces"`
+	QueueLength int                  `json:"queueLength"`
+	Pending     int                  `json:"pendingNotifications"`
+	Goroutines  int                  `json:"goroutines"`
+	Timeouts    map[string]time.Time `json:"timeouts"`
+	Alerts      []alertRecord        `json:"alerts"`
+	Shards      []string             `json:"shards,omitempty"`
+}
+
+func (c *Controller) debugState() *debugState {
+	state := &debugState{
+		QueueLength: c.queue.Len(),
+		Pending:     c.dispatcher.pending(),
+		Goroutines:  runtime.NumGoroutine(),
+		Timeouts:    c.timeouts.Snapshot(),
+		Alerts:      c.history.list(),
+	}
+	c.podsMu.RLock()
+	for namespace := range c.pods {
+		state.Namespaces = append(state.Namespaces, namespace)
+	}
+	c.podsMu.RUnlock()
+	sort.Strings(state.Namespaces)
+	if c.shard != nil {
+		state.Shards = c.shard.Members()
+	}
+	return state
+}
+
+// registerDebugHandlers serves pprof profiles at /debug/pprof and the controller state at /debug/state.
+func (c *Controller) registerDebugHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(c.debugState())
+	})
+}
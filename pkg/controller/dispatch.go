@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// delivery is a notification waiting to be sent.
+type delivery struct {
+	notifier string
+	channel  string
+	send     func(ctx context.Context) error
+	// done is called with the result once the notification has been sent or dropped, if set.
+	done func(err error)
+	// attempt counts the failed attempts to send the notification.
+	attempt int
+}
+
+// key identifies the channel of the notifier the delivery is sent to, which is kept in order.
+func (d delivery) key() string {
+	return d.notifier + "/" + d.channel
+}
+
+// dispatcher sends notifications in the background using a bounded pool of senders. All notifications
+// for a channel are handled by the same sender, which keeps them in order. Failed notifications are
+// retried after a delay, so that they do not hold up the other channels of the sender. Until then,
+// the channel is blocked and later notifications for it wait behind the failed one.
+type dispatcher struct {
+	senders    []chan delivery
+	retries    int
+	retryDelay time.Duration
+	clock      clock.WithDelayedExecution
+	wg         sync.WaitGroup
+	ctx        context.Context
+
+	// mu guards closed, which is set once the senders have been closed, waiting, which holds
+	// the failed notifications until they are retried, and blocked, which holds the notifications
+	// waiting behind them by channel.
+	mu      sync.RWMutex
+	closed  bool
+	waiting map[clock.Timer]delivery
+	blocked map[string][]delivery
+}
+
+// errDispatcherStopped is returned for notifications enqueued after the dispatcher stopped.
+var errDispatcherStopped = errors.New("notification dispatcher stopped")
+
+// newDispatcher creates a dispatcher with the given number of senders, each buffering up to queueSize
+// notifications. Failed deliveries are retried up to retries times with exponentially growing delays.
+func newDispatcher(senders, queueSize, retries int, clk clock.WithDelayedExecution) *dispatcher {
+	d := &dispatcher{
+		senders:    make([]chan delivery, senders),
+		retries:    retries,
+		retryDelay: time.Second,
+		clock:      clk,
+		ctx:        context.Background(),
+		waiting:    make(map[clock.Timer]delivery),
+		blocked:    make(map[string][]delivery),
+	}
+	for i := range d.senders {
+		d.senders[i] = make(chan delivery, queueSize)
+	}
+	return d
+}
+
+// Run starts the senders, which keep running until stop is called.
+func (d *dispatcher) Run(ctx context.Context) {
+	d.ctx = ctx
+	for _, sender := range d.senders {
+		d.wg.Add(1)
+		go func(sender chan delivery) {
+			defer d.wg.Done()
+			for delivery := range sender {
+				d.deliver(ctx, delivery)
+			}
+		}(sender)
+	}
+}
+
+// deliver sends the delivery unless its channel is blocked by a failed one, in which case it waits
+// behind it. Once a delivery is settled, the deliveries waiting behind it are sent in order.
+func (d *dispatcher) deliver(ctx context.Context, delivery delivery) {
+	if delivery.attempt == 0 && d.block(delivery) {
+		return
+	}
+	for d.attempt(ctx, delivery) {
+		next, ok := d.unblock(delivery.key())
+		if !ok {
+			return
+		}
+		delivery = next
+	}
+}
+
+// attempt sends the delivery and reports whether it is settled, i.e. it has been sent or dropped,
+// or false if it is retried.
+func (d *dispatcher) attempt(ctx context.Context, delivery delivery) bool {
+	err := delivery.send(ctx)
+	if err == nil {
+		delivery.finish(nil)
+		return true
+	}
+	delivery.attempt++
+	if delivery.attempt > d.retries || ctx.Err() != nil || errors.Is(err, errCircuitOpen) || utils.IsPermanent(err) || !d.retry(delivery) {
+		delivery.drop(err)
+		return true
+	}
+	klog.ErrorS(err, "Sending notification failed, retrying", "notifier", delivery.notifier, "channel", delivery.channel,
+		"delay", d.retryDelay<<(delivery.attempt-1))
+	return false
+}
+
+// block queues the delivery behind the failed delivery of its channel and reports whether the
+// channel is blocked.
+func (d *dispatcher) block(delivery delivery) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queued, ok := d.blocked[delivery.key()]
+	if ok {
+		d.blocked[delivery.key()] = append(queued, delivery)
+	}
+	return ok
+}
+
+// unblock returns the next delivery waiting behind a settled one, or unblocks the channel if there
+// is none.
+func (d *dispatcher) unblock(key string) (delivery, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queued, ok := d.blocked[key]
+	if !ok || len(queued) == 0 {
+		delete(d.blocked, key)
+		return delivery{}, false
+	}
+	d.blocked[key] = queued[1:]
+	return queued[0], true
+}
+
+// retry blocks the channel of the delivery and passes the delivery to its sender again once the
+// delay of its attempt passed, growing exponentially. It reports false if the dispatcher stopped.
+func (d *dispatcher) retry(delivery delivery) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+	if _, ok := d.blocked[delivery.key()]; !ok {
+		d.blocked[delivery.key()] = nil
+	}
+	d.schedule(delivery, d.retryDelay<<(delivery.attempt-1))
+	return true
+}
+
+// schedule passes the delivery to its sender after the delay, the caller must hold mu.
+func (d *dispatcher) schedule(delivery delivery, delay time.Duration) {
+	var timer clock.Timer
+	timer = d.clock.AfterFunc(delay, func() {
+		// Fake clocks call the function with their lock held, which must not wait for mu.
+		go func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			// The delivery is not waiting anymore if the dispatcher stopped in the meantime.
+			if _, ok := d.waiting[timer]; !ok {
+				return
+			}
+			delete(d.waiting, timer)
+			if err := d.send(delivery); err != nil {
+				// The delivery must not be dropped, as later deliveries for the channel wait behind it.
+				klog.ErrorS(err, "Could not queue notification for retry, retrying later", "notifier", delivery.notifier, "channel", delivery.channel)
+				d.schedule(delivery, delay)
+			}
+		}()
+	})
+	d.waiting[timer] = delivery
+}
+
+func (d delivery) drop(err error) {
+	klog.ErrorS(err, "Dropping notification", "notifier", d.notifier, "channel", d.channel, "attempts", d.attempt)
+	deliveriesFailed.WithLabelValues(d.notifier).Inc()
+	d.finish(err)
+}
+
+func (d delivery) finish(err error) {
+	if d.done != nil {
+		d.done(err)
+	}
+}
+
+// enqueue queues a notification for the channel of the notifier without blocking. It fails if the
+// sender is full or the dispatcher stopped. If done is not nil, it is called with the result of
+// the delivery.
+func (d *dispatcher) enqueue(notifier, channel string, send func(ctx context.Context) error, done func(err error)) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return errDispatcherStopped
+	}
+	return d.send(delivery{notifier: notifier, channel: channel, send: send, done: done})
+}
+
+// send passes the delivery to the sender of its channel, the caller must hold mu.
+func (d *dispatcher) send(delivery delivery) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(delivery.key()))
+	select {
+	case d.senders[hash.Sum32()%uint32(len(d.senders))] <- delivery:
+		return nil
+	default:
+		return fmt.Errorf("notification queue of channel %q is full", delivery.channel)
+	}
+}
+
+// pending returns the number of queued notifications, including those waiting to be retried and
+// those waiting behind them.
+func (d *dispatcher) pending() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	n := len(d.waiting)
+	for _, queued := range d.blocked {
+		n += len(queued)
+	}
+	for _, sender := range d.senders {
+		n += len(sender)
+	}
+	return n
+}
+
+// flush delivers the queued notifications in the calling goroutine. It must only be used if the
+// dispatcher is not running.
+func (d *dispatcher) flush(ctx context.Context) {
+	for _, sender := range d.senders {
+		for len(sender) > 0 {
+			d.deliver(ctx, <-sender)
+		}
+	}
+}
+
+// stop waits for the queued notifications to be sent and makes a last attempt to send those waiting
+// to be retried, followed by those waiting behind them. Notifications enqueued afterwards fail.
+func (d *dispatcher) stop() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, sender := range d.senders {
+			close(sender)
+		}
+	}
+	waiting := d.waiting
+	d.waiting = make(map[clock.Timer]delivery)
+	d.mu.Unlock()
+	d.wg.Wait()
+	for timer, delivery := range waiting {
+		timer.Stop()
+		d.deliver(d.ctx, delivery)
+	}
+}
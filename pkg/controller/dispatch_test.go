@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// sendLog records the notifications sent by a dispatcher.
+type sendLog struct {
+	mu   sync.Mutex
+	sent []string
+	// settled receives the name of each notification once it has been sent or dropped.
+	settled chan string
+}
+
+func newSendLog() *sendLog {
+	return &sendLog{settled: make(chan string, 100)}
+}
+
+// enqueue queues a notification named name for the channel, failing the first failures attempts.
+func (l *sendLog) enqueue(t *testing.T, d *dispatcher, channel, name string, failures int) {
+	t.Helper()
+	attempts := 0
+	err := d.enqueue("mattermost", channel, func(ctx context.Context) error {
+		attempts++
+		if attempts <= failures {
+			return errors.New("unavailable")
+		}
+		l.mu.Lock()
+		l.sent = append(l.sent, name)
+		l.mu.Unlock()
+		return nil
+	}, func(err error) {
+		l.settled <- name
+	})
+	if err != nil {
+		t.Fatalf("could not enqueue %s: %v", name, err)
+	}
+}
+
+func (l *sendLog) wait(t *testing.T, names ...string) {
+	t.Helper()
+	for range names {
+		select {
+		case <-l.settled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", names)
+		}
+	}
+}
+
+func (l *sendLog) expect(t *testing.T, want ...string) {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(want) == 0 && len(l.sent) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(l.sent, want) {
+		t.Errorf("expected %v to be sent, got %v", want, l.sent)
+	}
+}
+
+// waitForTimers waits until the dispatcher scheduled the given number of retries.
+func waitForTimers(t *testing.T, clock *testingclock.FakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.Waiters() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d retries to be scheduled", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherRetriesInChannelOrder(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := newDispatcher(1, 10, 3, clock)
+	d.Run(context.Background())
+	defer d.stop()
+	log := newSendLog()
+
+	log.enqueue(t, d, "alerts", "alert", 2)
+	waitForTimers(t, clock, 1)
+	// The resolution waits behind the alert, while other channels are not held up.
+	log.enqueue(t, d, "alerts", "resolution", 0)
+	log.enqueue(t, d, "other", "other", 0)
+	log.wait(t, "other")
+	log.expect(t, "other")
+	if pending := d.pending(); pending != 2 {
+		t.Errorf("expected alert and resolution to be pending, got %d", pending)
+	}
+
+	clock.Step(time.Second - time.Millisecond)
+	log.expect(t, "other")
+	clock.Step(time.Millisecond)
+	// The second attempt fails as well and is retried after twice the delay.
+	waitForTimers(t, clock, 1)
+	clock.Step(2 * time.Second)
+	log.wait(t, "alert", "resolution")
+	log.expect(t, "other", "alert", "resolution")
+	if pending := d.pending(); pending != 0 {
+		t.Errorf("expected no pending notifications, got %d", pending)
+	}
+}
+
+func TestDispatcherDropsAfterRetries(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := newDispatcher(1, 10, 1, clock)
+	d.Run(context.Background())
+	defer d.stop()
+	log := newSendLog()
+
+	log.enqueue(t, d, "alerts", "alert", 2)
+	waitForTimers(t, clock, 1)
+	log.enqueue(t, d, "alerts", "resolution", 0)
+	clock.Step(time.Second)
+	log.wait(t, "alert", "resolution")
+	log.expect(t, "resolution")
+}
+
+func TestDispatcherBoundedPool(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := newDispatcher(1, 1, 0, clock)
+	d.Run(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := d.enqueue("mattermost", "alerts", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// The sender is busy, so one notification is buffered and the next one is rejected.
+	noop := func(ctx context.Context) error { return nil }
+	if err := d.enqueue("mattermost", "other", noop, nil); err != nil {
+		t.Fatalf("expected notification to be buffered, got %v", err)
+	}
+	if err := d.enqueue("mattermost", "third", noop, nil); err == nil {
+		t.Error("expected full queue to reject notification")
+	}
+	close(release)
+	d.stop()
+	if err := d.enqueue("mattermost", "alerts", noop, nil); err != errDispatcherStopped {
+		t.Errorf("expected stopped dispatcher to reject notifications, got %v", err)
+	}
+}
+
+func TestDispatcherConcurrency(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	const senders = 3
+	d := newDispatcher(senders, 100, 0, clock)
+	d.Run(context.Background())
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	for _, channel := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		if err := d.enqueue("mattermost", channel, func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.stop()
+	if maxRunning > senders {
+		t.Errorf("expected at most %d concurrent sends, got %d", senders, maxRunning)
+	}
+}
+
+func TestDispatcherStopSendsWaiting(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := newDispatcher(1, 10, 3, clock)
+	d.Run(context.Background())
+	log := newSendLog()
+
+	log.enqueue(t, d, "alerts", "alert", 1)
+	log.enqueue(t, d, "down", "down", 5)
+	waitForTimers(t, clock, 2)
+	log.enqueue(t, d, "alerts", "resolution", 0)
+	log.enqueue(t, d, "down", "behind down", 0)
+	// The retries are not due yet, but stopping makes a last attempt in order.
+	d.stop()
+	log.wait(t, "alert", "resolution", "down", "behind down")
+	log.mu.Lock()
+	sent := append([]string(nil), log.sent...)
+	log.mu.Unlock()
+	if index(sent, "alert") < 0 || index(sent, "alert") > index(sent, "resolution") || index(sent, "down") >= 0 || index(sent, "behind down") < 0 {
+		t.Errorf("expected alert, resolution and the notification behind the dropped one to be sent, got %v", sent)
+	}
+	if pending := d.pending(); pending != 0 {
+		t.Errorf("expected no pending notifications, got %d", pending)
+	}
+}
+
+func index(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
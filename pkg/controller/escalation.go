@@ -0,0 +1,371 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/mattermost/mattermost-server/model"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// escalationInterval is how often alerts are checked for being overdue.
+	escalationInterval = 30 * time.Second
+	// actionMaxBody limits the size of button requests.
+	actionMaxBody = 64 << 10
+)
+
+// pendingEscalation is an alert waiting to be acknowledged.
+type pendingEscalation struct {
+	alert     *notify.Alert
+	notifiers []string
+	// postIDs are the Mattermost posts of the alert, which acknowledge it once reacted to.
+	postIDs []string
+	// since is the time of the first alert.
+	since    time.Time
+	deadline time.Time
+}
+
+// persistedEscalation is the form an alert waiting to be acknowledged is persisted in.
+type persistedEscalation struct {
+	Alert     *notify.Alert `json:"alert"`
+	Notifiers []string      `json:"notifiers"`
+	PostIDs   []string      `json:"postIDs,omitempty"`
+	Since     time.Time     `json:"since"`
+	Deadline  time.Time     `json:"deadline"`
+}
+
+// escalations tracks the alerts waiting to be acknowledged by fingerprint. The zero value is ready
+// to use.
+type escalations struct {
+	mu      sync.Mutex
+	pending map[string]*pendingEscalation
+}
+
+// arm starts waiting for the acknowledgement of the alert until deadline and returns the time of
+// the first alert. Repeated alerts of a container keep the first deadline.
+func (e *escalations) arm(alert *notify.Alert, notifiers []string, deadline time.Time) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending == nil {
+		e.pending = make(map[string]*pendingEscalation)
+	}
+	pending, ok := e.pending[alert.Fingerprint]
+	if !ok {
+		pending = &pendingEscalation{since: alert.Time, deadline: deadline}
+		e.pending[alert.Fingerprint] = pending
+	}
+	pending.alert, pending.notifiers = alert, notifiers
+	return pending.since
+}
+
+// posted adds a Mattermost post of the alert with the given fingerprint.
+func (e *escalations) posted(fingerprint, postID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if pending, ok := e.pending[fingerprint]; ok && postID != "" {
+		pending.postIDs = append(pending.postIDs, postID)
+	}
+}
+
+// get returns the alert with the given fingerprint if it is waiting to be acknowledged.
+func (e *escalations) get(fingerprint string) (*notify.Alert, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	pending, ok := e.pending[fingerprint]
+	if !ok {
+		return nil, false
+	}
+	return pending.alert, true
+}
+
+// cancel stops waiting for the alert, e.g. once the container recovered.
+func (e *escalations) cancel(fingerprint string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.pending, fingerprint)
+}
+
+// due removes and returns the alerts whose deadline passed.
+func (e *escalations) due(now time.Time) []pendingEscalation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var due []pendingEscalation
+	for fingerprint, pending := range e.pending {
+		if now.Before(pending.deadline) {
+			continue
+		}
+		due = append(due, *pending)
+		delete(e.pending, fingerprint)
+	}
+	return due
+}
+
+// snapshot returns the alerts waiting to be acknowledged whose namespace is accepted by owns.
+func (e *escalations) snapshot(owns func(namespace string) bool) map[string]persistedEscalation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	pending := make(map[string]persistedEscalation)
+	for fingerprint, escalation := range e.pending {
+		if owns(escalation.alert.Namespace) {
+			pending[fingerprint] = persistedEscalation{
+				Alert:     escalation.alert,
+				Notifiers: escalation.notifiers,
+				PostIDs:   escalation.postIDs,
+				Since:     escalation.since,
+				Deadline:  escalation.deadline,
+			}
+		}
+	}
+	return pending
+}
+
+// restore adds the persisted alerts waiting to be acknowledged, keeping those already waiting.
+// Deadlines which passed in the meantime are escalated right away.
+func (e *escalations) restore(pending map[string]persistedEscalation) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending == nil {
+		e.pending = make(map[string]*pendingEscalation)
+	}
+	for fingerprint, persisted := range pending {
+		if _, ok := e.pending[fingerprint]; ok || persisted.Alert == nil {
+			continue
+		}
+		e.pending[fingerprint] = &pendingEscalation{
+			alert:     persisted.Alert,
+			notifiers: persisted.Notifiers,
+			postIDs:   persisted.PostIDs,
+			since:     persisted.Since,
+			deadline:  persisted.Deadline,
+		}
+	}
+}
+
+// armEscalation waits for the acknowledgement of the alert if its severity is escalated, and adds
+// the acknowledge button if the external URL, the action secret and the state ConfigMap are
+// configured. Without the state ConfigMap, the replicas do not share the acknowledgements.
+func (c *Controller) armEscalation(cfg *config.Config, alert *notify.Alert, notifiers []string) {
+	escalation, ok := cfg.Escalations[alert.Severity]
+	if !ok || alert.Synthetic {
+		return
+	}
+	since := c.escalations.arm(alert, notifiers, c.clock.Now().Add(escalation.After.Duration))
+	if cfg.ExternalURL == "" || c.stateConfigMap == "" || !c.acks.canSign() {
+		return
+	}
+	token, err := c.acks.token(alert.Fingerprint, since)
+	if err != nil {
+		klog.ErrorS(err, "Adding the acknowledge button failed", "pod", klog.KRef(alert.Namespace, alert.Pod))
+		return
+	}
+	// The button only carries what identifies the alert, everything else is taken from the
+	// firing state when it is clicked.
+	alert.Actions = []notify.Action{{
+		Name: cfg.Catalogs.Translate(alert.Locale, "Acknowledge"),
+		URL:  strings.TrimSuffix(cfg.ExternalURL, "/") + "/actions",
+		Context: map[string]interface{}{
+			"fingerprint": alert.Fingerprint,
+			"token":       token,
+			"firedAt":     since.Format(time.RFC3339),
+		},
+	}}
+}
+
+// findAlert returns the alert with the given fingerprint if it is firing or waiting to be
+// acknowledged in any cluster, including those of other replicas persisted in the state ConfigMap.
+func (c *Controller) findAlert(fingerprint string) (*notify.Alert, bool) {
+	controllers := append([]*Controller{c}, c.clusters...)
+	for _, controller := range controllers {
+		if alert, ok := controller.escalations.get(fingerprint); ok {
+			return alert, true
+		}
+		if firing, ok := controller.firing.get(fingerprint); ok {
+			return firing.alert, true
+		}
+	}
+	for _, controller := range controllers {
+		if controller.stateConfigMap == "" {
+			continue
+		}
+		_, namespaces, _, err := controller.readState()
+		if err != nil {
+			klog.ErrorS(err, "Reading the state failed", "configMap", klog.KRef(controller.stateNamespace, controller.stateConfigMap))
+			continue
+		}
+		for _, state := range namespaces {
+			if escalation, ok := state.Escalations[fingerprint]; ok && escalation.Alert != nil {
+				return escalation.Alert, true
+			}
+			for _, firing := range state.Firing {
+				if firing.Alert != nil && firing.Alert.Fingerprint == fingerprint {
+					return firing.Alert, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// runEscalations escalates the alerts nobody acknowledged in time until stopCh is closed.
+func (c *Controller) runEscalations(ctx context.Context, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.clock.After(escalationInterval):
+		}
+		due := c.escalations.due(c.clock.Now())
+		if len(due) == 0 {
+			continue
+		}
+		// Learn about the buttons clicked on other replicas first.
+		if err := c.syncAcknowledgements(); err != nil {
+			klog.ErrorS(err, "Synchronizing acknowledgements failed")
+		}
+		for _, pending := range due {
+			c.escalate(ctx, pending)
+		}
+	}
+}
+
+// escalate re-sends the alert with the mentions of its escalation to the escalation channel and
+// notifiers, unless somebody reacted to one of its posts.
+func (c *Controller) escalate(ctx context.Context, pending pendingEscalation) {
+	cfg, mattermost := c.settings()
+	alert := pending.alert
+	if ack, ok := c.acks.acknowledged(alert.Fingerprint, pending.since); ok {
+		klog.InfoS("Not escalating acknowledged alert", "pod", klog.KRef(alert.Namespace, alert.Pod), "container", alert.Container, "user", ack.User)
+		return
+	}
+	for _, postID := range pending.postIDs {
+		reaction, err := mattermost.FirstReaction(ctx, postID)
+		if err != nil {
+			klog.ErrorS(err, "Checking the reactions to an alert failed", "pod", klog.KRef(alert.Namespace, alert.Pod), "post", postID)
+			continue
+		}
+		if reaction == nil {
+			continue
+		}
+		user, err := mattermost.UserName(ctx, reaction.UserId)
+		if err != nil {
+			user = reaction.UserId
+		}
+		c.acknowledge(api.Acknowledgement{
+			Fingerprint: alert.Fingerprint,
+			Time:        time.Unix(0, reaction.CreateAt*int64(time.Millisecond)),
+			FiredAt:     pending.since,
+			User:        user,
+			Via:         "reaction",
+			Cluster:     alert.Cluster,
+			Namespace:   alert.Namespace,
+			Pod:         alert.Pod,
+			Container:   alert.Container,
+			Severity:    alert.Severity,
+		})
+		return
+	}
+	escalation, ok := cfg.Escalations[alert.Severity]
+	if !ok {
+		return
+	}
+	escalated := *alert
+	escalated.Time = c.clock.Now()
+	escalated.Title = cfg.Catalogs.Translate(alert.Locale, "Escalated") + ": " + alert.Title
+	escalated.Text = alert.Text + "\n\n" + cfg.Catalogs.Sprintf(alert.Locale, "Nobody acknowledged this alert within %s.", escalation.After.Duration)
+	escalated.Mentions = append(append([]string(nil), alert.Mentions...), escalation.Mentions...)
+	escalated.Actions = nil
+	if escalation.Channel != "" {
+		escalated.Channel = escalation.Channel
+	}
+	names := pending.notifiers
+	if len(escalation.Notifiers) > 0 {
+		names = escalation.Notifiers
+	}
+	klog.InfoS("Escalating unacknowledged alert", "pod", klog.KRef(alert.Namespace, alert.Pod), "container", alert.Container,
+		"channel", escalated.Channel, "notifiers", names)
+	// Escalations are delivered besides the alerts, so they are neither counted nor spooled as
+	// alerts.
+	for _, name := range names {
+		notifier := c.notifier(name)
+		if notifier == nil {
+			klog.ErrorS(nil, "Dropping escalation for unknown notifier", "notifier", name)
+			continue
+		}
+		c.enqueueDelivery(ctx, "escalate", name, &escalated, notifier.Send, nil)
+	}
+}
+
+// actionRequest is the request Mattermost sends when a button is clicked.
+type actionRequest struct {
+	UserID  string                 `json:"user_id"`
+	PostID  string                 `json:"post_id"`
+	Context map[string]interface{} `json:"context"`
+}
+
+// ActionHandler returns a HTTP handler for the buttons of alerts. Requests are authenticated by
+// the token in the context of the button, which every replica sharing the action secret can
+// verify. It covers the fingerprint and the time the alert fired, the rest of the acknowledgement
+// is taken from the firing alert.
+func (c *Controller) ActionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req actionRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, actionMaxBody)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		value := func(key string) string {
+			s, _ := req.Context[key].(string)
+			return s
+		}
+		fingerprint := value("fingerprint")
+		firedAt, err := time.Parse(time.RFC3339, value("firedAt"))
+		if err != nil || !c.acks.verify(fingerprint, firedAt, value("token")) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		// Only authenticated clicks read the state ConfigMap, to learn the acknowledgements of
+		// the other replicas.
+		if err := c.syncAcknowledgements(); err != nil {
+			klog.ErrorS(err, "Synchronizing acknowledgements failed")
+		}
+		text := "Acknowledged, the alert will not be escalated."
+		if ack, ok := c.acks.acknowledged(fingerprint, firedAt); ok {
+			text = fmt.Sprintf("The alert has already been acknowledged by %s.", ack.User)
+		} else if alert, ok := c.findAlert(fingerprint); !ok {
+			text = "The alert is no longer firing."
+		} else {
+			_, mattermost := c.settings()
+			user, err := mattermost.UserName(r.Context(), req.UserID)
+			if err != nil {
+				user = req.UserID
+			}
+			c.acknowledge(api.Acknowledgement{
+				Fingerprint: fingerprint,
+				Time:        c.clock.Now(),
+				FiredAt:     firedAt,
+				User:        user,
+				Via:         "button",
+				Cluster:     alert.Cluster,
+				Namespace:   alert.Namespace,
+				Pod:         alert.Pod,
+				Container:   alert.Container,
+				Severity:    alert.Severity,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&model.PostActionIntegrationResponse{EphemeralText: text})
+	})
+}
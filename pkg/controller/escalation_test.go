@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testActionSecret = "0123456789abcdef0123456789abcdef"
+
+func TestAcknowledgeTokenCoversFiringTime(t *testing.T) {
+	var acks acknowledgements
+	firedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := acks.token("f", firedAt); err == nil {
+		t.Fatal("expected token without secret to fail")
+	}
+	if err := acks.setSecret("too short"); err == nil {
+		t.Fatal("expected short secret to be rejected")
+	}
+	if err := acks.setSecret(testActionSecret + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := acks.token("f", firedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replicas sharing the secret accept the tokens of each other.
+	var other acknowledgements
+	if err := other.setSecret(testActionSecret); err != nil {
+		t.Fatal(err)
+	}
+	if !other.verify("f", firedAt, token) {
+		t.Error("token of another replica was rejected")
+	}
+	if !acks.verify("f", firedAt.In(time.FixedZone("CET", 3600)), token) {
+		t.Error("token was rejected")
+	}
+	if acks.verify("f", firedAt.Add(time.Hour), token) {
+		t.Error("token of an earlier alert was accepted")
+	}
+	if acks.verify("g", firedAt, token) {
+		t.Error("token of another alert was accepted")
+	}
+}
+
+func TestFindAlertUsesPersistedState(t *testing.T) {
+	c, _ := newTestController(t)
+	c.stateConfigMap, c.stateNamespace = "mattermost-informer-state", "default"
+	alert := &notify.Alert{Time: c.clock.Now(), Fingerprint: "f", Namespace: "payments", Pod: "api", Container: "app", Severity: "critical"}
+	c.firing.add(alert, []string{"mattermost"})
+	if err := c.saveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another replica serving the button click finds the alert in the state ConfigMap.
+	other, _ := newTestController(t)
+	other.stateClient = c.stateClient
+	other.stateConfigMap, other.stateNamespace = c.stateConfigMap, c.stateNamespace
+	found, ok := other.findAlert("f")
+	if !ok || found.Namespace != "payments" || found.Severity != "critical" {
+		t.Fatalf("findAlert() = %+v, %v", found, ok)
+	}
+	if _, ok := other.findAlert("g"); ok {
+		t.Error("found an alert which is not firing")
+	}
+}
+
+func TestActionHandlerVerifiesBeforeReadingState(t *testing.T) {
+	c, _ := newTestController(t)
+	c.stateConfigMap, c.stateNamespace = "mattermost-informer-state", "default"
+	if err := c.acks.setSecret(testActionSecret); err != nil {
+		t.Fatal(err)
+	}
+	firedAt := testStart.Add(-time.Hour)
+	// Another replica persisted an acknowledgement of the alert.
+	other, _ := newTestController(t)
+	other.stateClient = c.stateClient
+	other.stateConfigMap, other.stateNamespace = c.stateConfigMap, c.stateNamespace
+	other.acknowledge(api.Acknowledgement{Fingerprint: "f", Time: testStart, FiredAt: firedAt, User: "bob", Via: "button"})
+	configMap, err := c.stateClient.CoreV1().ConfigMaps("default").Get(context.Background(), c.stateConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(configMap.Data[acknowledgementsKey], "secret") {
+		t.Errorf("expected the secret not to be persisted, got %s", configMap.Data[acknowledgementsKey])
+	}
+	stateClient := c.stateClient.(*fake.Clientset)
+	stateClient.ClearActions()
+
+	token, err := c.acks.token("f", firedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	click := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(actionRequest{UserID: "u", Context: map[string]interface{}{
+			"fingerprint": "f",
+			"token":       token,
+			"firedAt":     firedAt.Format(time.RFC3339),
+		}})
+		recorder := httptest.NewRecorder()
+		c.ActionHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/actions", bytes.NewReader(body)))
+		return recorder
+	}
+	if recorder := click("forged"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected forged token to be rejected, got %d", recorder.Code)
+	}
+	if actions := stateClient.Actions(); len(actions) != 0 {
+		t.Errorf("expected unauthenticated click not to access the state, got %v", actions)
+	}
+	recorder := click(token)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "already been acknowledged by bob") {
+		t.Errorf("expected acknowledgement of the other replica to be reported, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
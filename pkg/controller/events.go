@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Reasons of the events recorded on pods for notifications.
+const (
+	eventReasonNotified   = "MattermostNotified"
+	eventReasonSuppressed = "NotificationSuppressed"
+	eventReasonFailed     = "NotificationFailed"
+	eventReasonInvalid    = "InvalidAnnotation"
+)
+
+// recordEvent records an event on the pod, if an event recorder is configured.
+func (c *Controller) recordEvent(pod *v1.Pod, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Eventf(pod, eventType, reason, messageFmt, args...)
+}
+
+// warnInvalidAnnotation logs and records an event about an invalid annotation of the pod once per
+// pod and value, instead of on every update and resync.
+func (c *Controller) warnInvalidAnnotation(pod *v1.Pod, annotation, value string, err error) {
+	if !c.invalid.add(podKey(pod) + "/" + annotation + "=" + value) {
+		return
+	}
+	klog.InfoS("Pod has invalid annotation, ignoring it", "pod", klog.KObj(pod), "annotation", annotation, "err", err)
+	c.recordEvent(pod, v1.EventTypeWarning, eventReasonInvalid, "Ignoring invalid annotation %s: %v", annotation, err)
+}
+
+// notificationRecorder returns a callback recording the result of sending the alert for the pod
+// via the named notifier, both as event and NotificationRecord.
+func (c *Controller) notificationRecorder(pod *v1.Pod, alert *notify.Alert, notifier string) func(string, error) {
+	return func(postID string, err error) {
+		if err != nil {
+			c.recordEvent(pod, v1.EventTypeWarning, eventReasonFailed,
+				"Sending notification for container %s via %s failed: %v", alert.Container, notifier, err)
+			return
+		}
+		c.recordEvent(pod, v1.EventTypeNormal, eventReasonNotified,
+			"Sent notification for container %s via %s", alert.Container, notifier)
+		c.recordNotification(alert, notifier, postID)
+		if notifier == notify.TypeMattermost {
+			c.escalations.posted(alert.Fingerprint, postID)
+		}
+	}
+}
+
+// keySet is a set of keys, e.g. to record an event only once per container. The zero value is
+// ready to use.
+type keySet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// add adds the key and reports whether it has not been in the set before.
+func (s *keySet) add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	if s.keys == nil {
+		s.keys = make(map[string]struct{})
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+func (s *keySet) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+}
+
+// deletePrefix removes all keys starting with prefix.
+func (s *keySet) deletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.keys {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.keys, key)
+		}
+	}
+}
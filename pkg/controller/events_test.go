@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestInvalidAnnotationIsWarnedOnce(t *testing.T) {
+	c, _ := newTestController(t)
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}
+
+	for i := 0; i < 3; i++ {
+		c.warnInvalidAnnotation(pod, annotationMattermostBackoff, "soon", errors.New("invalid"))
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(recorder.Events))
+	}
+	<-recorder.Events
+
+	// A different value is warned about again, as is the same value once the pod is gone.
+	c.warnInvalidAnnotation(pod, annotationMattermostBackoff, "later", errors.New("invalid"))
+	c.clearTimeout(podKey(pod))
+	c.warnInvalidAnnotation(pod, annotationMattermostBackoff, "soon", errors.New("invalid"))
+	if len(recorder.Events) != 2 {
+		t.Fatalf("got %d events, want 2", len(recorder.Events))
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// resolveInterval is how often recovering containers are checked for having been ready long enough.
+const resolveInterval = 15 * time.Second
+
+// recoverContainer resolves the alert of a ready container once it stayed ready for the configured
+// time, or right away if no delay is configured.
+func (c *Controller) recoverContainer(ctx context.Context, key string) {
+	cfg, _ := c.settings()
+	if cfg.Flapping.ResolveAfter.Duration <= 0 {
+		c.resolveAlerts(ctx, c.firing.take(key))
+		return
+	}
+	c.firing.recover(key, c.clock.Now())
+}
+
+// relapseContainer stops the recovery of a container which is not ready anymore. Once it crashed
+// again too often, a single notification announces that it is flapping and further alerts are
+// suppressed until it stays ready, or does not relapse for the flapping window.
+func (c *Controller) relapseContainer(ctx context.Context, pod *v1.Pod, container *v1.ContainerStatus) {
+	var crashedAt time.Time
+	if terminated := container.State.Terminated; terminated != nil {
+		crashedAt = terminated.FinishedAt.Time
+	} else if terminated := container.LastTerminationState.Terminated; terminated != nil {
+		crashedAt = terminated.FinishedAt.Time
+	}
+	cfg, _ := c.settings()
+	firing, started := c.firing.relapse(podKey(pod)+"/"+container.Name, crashedAt, c.clock.Now(),
+		cfg.Flapping.Threshold, cfg.Flapping.Window.Duration)
+	if !started {
+		return
+	}
+	if silence, ok := c.silences.match(pod.Namespace, pod.Name, container.Name); ok {
+		klog.InfoS("Flapping notification silenced", "pod", klog.KObj(pod), "container", container.Name, "silence", silence.ID)
+		return
+	}
+	alert := *firing.alert
+	alert.Time = c.clock.Now()
+	alert.Title = cfg.Catalogs.Translate(alert.Locale, "Flapping") + ": " + alert.Title
+	alert.Text = cfg.Catalogs.Sprintf(alert.Locale, "Container %s of pod %s recovered and crashed again %d times within %s. Further alerts are suppressed until it stays ready for %s.",
+		alert.Container, alert.Pod, len(firing.relapses), cfg.Flapping.Window.Duration, cfg.Flapping.ResolveAfter.Duration)
+	alert.Logs = ""
+	alert.RestartCount = container.RestartCount
+	klog.InfoS("Container is flapping", "pod", klog.KObj(pod), "container", container.Name, "relapses", len(firing.relapses))
+	for _, name := range firing.notifiers {
+		c.enqueueAlert(ctx, name, &alert, nil)
+	}
+}
+
+// runPendingResolves resolves the alerts of containers which stayed ready for long enough until
+// stopCh is closed.
+func (c *Controller) runPendingResolves(ctx context.Context, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.clock.After(resolveInterval):
+		}
+		cfg, _ := c.settings()
+		alerts := c.firing.takeRecovered(c.clock.Now().Add(-cfg.Flapping.ResolveAfter.Duration))
+		for _, firing := range alerts {
+			// The backoff starts over once no container of the pod is alerted anymore.
+			pod := firing.alert.Namespace + "/" + firing.alert.Pod
+			if !c.firing.hasPod(pod) {
+				c.backoffs.deletePrefix(pod + "/")
+			}
+		}
+		c.resolveAlerts(ctx, alerts)
+	}
+}
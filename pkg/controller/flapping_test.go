@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+func TestFlappingExpiresWithoutRelapse(t *testing.T) {
+	var firing firingAlerts
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	firing.add(&notify.Alert{Time: start, Namespace: "apps", Pod: "web", Container: "app", Fingerprint: "f"}, nil)
+	key, window := "apps/web/app", 10*time.Minute
+
+	now := start
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Minute)
+		firing.recover(key, now)
+		if _, started := firing.relapse(key, now.Add(time.Second), now.Add(time.Second), 3, window); started != (i == 2) {
+			t.Fatalf("relapse %d: started = %v", i, started)
+		}
+	}
+	if !firing.isFlapping(key, now.Add(window/2), window) {
+		t.Fatal("not flapping within the window")
+	}
+	// The container went into a crash loop and never became ready again, so it is alerted about.
+	if firing.isFlapping(key, now.Add(window+time.Second), window) {
+		t.Fatal("still flapping after the window")
+	}
+	if firing.isFlapping(key, now.Add(window/2), window) {
+		t.Fatal("flapping again without relapses")
+	}
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// helmReleaseGVR and kustomizationGVR identify the Flux resources. The versions are used if
+	// the versions preferred by the API server cannot be discovered.
+	helmReleaseGVR   = schema.GroupVersionResource{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"}
+	kustomizationGVR = schema.GroupVersionResource{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"}
+)
+
+// fluxChecks notify about Flux HelmReleases and Kustomizations failing to reconcile, watching the
+// versions preferred by the API server, so that older Flux releases are supported as well.
+func fluxChecks(client discovery.DiscoveryInterface) []resourceCheck {
+	return []resourceCheck{
+		{gvr: preferredVersion(client, helmReleaseGVR), kind: "HelmRelease", check: checkFluxReady},
+		{gvr: preferredVersion(client, kustomizationGVR), kind: "Kustomization", check: checkFluxReady},
+	}
+}
+
+// preferredVersion returns the resource in the version of its group preferred by the API server,
+// or as given if the group cannot be discovered.
+func preferredVersion(client discovery.DiscoveryInterface, gvr schema.GroupVersionResource) schema.GroupVersionResource {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		klog.ErrorS(err, "Discovering the API version failed", "group", gvr.Group, "version", gvr.Version)
+		return gvr
+	}
+	for _, group := range groups.Groups {
+		if group.Name == gvr.Group && group.PreferredVersion.Version != "" {
+			gvr.Version = group.PreferredVersion.Version
+		}
+	}
+	return gvr
+}
+
+// checkFluxReady reports a problem if the Ready condition of a Flux resource is False.
+// The reason of the condition, e.g. InstallFailed, is used as reason of the alert.
+func checkFluxReady(ctx context.Context, obj *unstructured.Unstructured) *resourceProblem {
+	status, reason, message := condition(obj, "Ready")
+	if status != "False" {
+		return nil
+	}
+	if reason == "" {
+		reason = "ReconciliationFailed"
+	}
+	return &resourceProblem{
+		Reason: reason,
+		Title:  fmt.Sprintf("%s %s failed to reconcile", obj.GetKind(), obj.GetName()),
+		Text:   fmt.Sprintf("%s %s in namespace %s failed with %s:\n```\n%s\n```", obj.GetKind(), obj.GetName(), obj.GetNamespace(), reason, message),
+	}
+}
+
+// condition returns the status, reason and message of the condition with the given type.
+func condition(obj *unstructured.Unstructured, conditionType string) (status, reason, message string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != conditionType {
+			continue
+		}
+		status, _ = cond["status"].(string)
+		reason, _ = cond["reason"].(string)
+		message, _ = cond["message"].(string)
+		return status, reason, message
+	}
+	return "", "", ""
+}
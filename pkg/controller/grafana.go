@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lnsp/mattermost-informer/pkg/grafana"
+)
+
+// GrafanaDashboard returns the JSON of a Grafana dashboard showing the metrics of the informer,
+// which uses a Prometheus data source selected by a variable.
+func GrafanaDashboard(title string) ([]byte, error) {
+	datasource := &grafana.DatasourceRef{Type: "prometheus", UID: "${datasource}"}
+	labelValues := func(name, label string) grafana.Variable {
+		return grafana.Variable{
+			Name:       name,
+			Label:      label,
+			Type:       "query",
+			Query:      fmt.Sprintf("label_values(%s, %s)", metricNotifications, name),
+			Datasource: datasource,
+			Refresh:    2,
+			Multi:      true,
+			IncludeAll: true,
+			AllValue:   ".*",
+		}
+	}
+	// Alerts sent via multiple notifiers are counted once per notifier, the notifier variable
+	// selects which of them to count.
+	selector := `namespace=~"$namespace", channel=~"$channel", notifier=~"$notifier"`
+	panels := []struct {
+		kind, title, description, unit string
+		width                          int
+		targets                        []grafana.Target
+	}{
+		{"timeseries", "Notifications", "Notifications per second by outcome.", "short", 12, []grafana.Target{{
+			Expr:         fmt.Sprintf(`sum by (outcome) (rate(%s{%s}[$__rate_interval]))`, metricNotifications, selector),
+			LegendFormat: "{{outcome}}",
+		}}},
+		{"timeseries", "Alerts by namespace", "Delivered notifications per hour by namespace.", "short", 12, []grafana.Target{{
+			Expr:         fmt.Sprintf(`sum by (namespace) (increase(%s{%s, outcome="delivered"}[1h]))`, metricNotifications, selector),
+			LegendFormat: "{{namespace}}",
+		}}},
+		{"table", "Top crashing workloads", "Workloads with the most delivered notifications in the time range.", "short", 12, []grafana.Target{{
+			Expr:    fmt.Sprintf(`topk(10, sum by (namespace, workload) (increase(%s{%s, outcome="delivered"}[$__range])))`, metricNotifications, selector),
+			Instant: true,
+			Format:  "table",
+		}}},
+		{"piechart", "Alerts by reason", "Delivered notifications in the time range by the reason of the crash.", "short", 12, []grafana.Target{{
+			Expr:         fmt.Sprintf(`sum by (reason) (increase(%s{%s, outcome="delivered"}[$__range]))`, metricNotifications, selector),
+			LegendFormat: "{{reason}}",
+			Instant:      true,
+		}}},
+		{"timeseries", "Time to recovery", "Time from the first alert of a container until it recovered.", "s", 12, []grafana.Target{
+			{
+				Expr:         fmt.Sprintf(`histogram_quantile(0.5, sum by (le) (rate(%s_bucket[$__rate_interval])))`, metricRecoveryDuration),
+				LegendFormat: "p50",
+			},
+			{
+				Expr:         fmt.Sprintf(`histogram_quantile(0.9, sum by (le) (rate(%s_bucket[$__rate_interval])))`, metricRecoveryDuration),
+				LegendFormat: "p90",
+			},
+		}},
+		{"timeseries", "Failed deliveries", "Notifications dropped after all retries and open circuit breakers by notifier.", "short", 12, []grafana.Target{
+			{
+				Expr:         fmt.Sprintf(`sum by (notifier) (increase(%s{notifier=~"$notifier"}[1h]))`, metricDeliveriesFailed),
+				LegendFormat: "dropped {{notifier}}",
+			},
+			{
+				Expr:         fmt.Sprintf(`max by (notifier) (%s{notifier=~"$notifier"})`, metricCircuitOpen),
+				LegendFormat: "circuit open {{notifier}}",
+			},
+		}},
+		{"table", "Versions", "Versions of the running informers.", "", 24, []grafana.Target{{
+			Expr:    fmt.Sprintf(`count by (version, commit, go_version) (%s)`, metricBuildInfo),
+			Instant: true,
+			Format:  "table",
+		}}},
+	}
+
+	dashboard := &grafana.Dashboard{
+		UID:           "mattermost-informer",
+		Title:         title,
+		Description:   "Notifications, crashing workloads and delivery failures of the Mattermost informer.",
+		Tags:          []string{"mattermost-informer"},
+		Timezone:      "browser",
+		Editable:      true,
+		SchemaVersion: grafana.SchemaVersion,
+		Refresh:       "1m",
+		Time:          grafana.TimeRange{From: "now-7d", To: "now"},
+		Templating: grafana.Templating{List: []grafana.Variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			labelValues("namespace", "Namespace"),
+			labelValues("channel", "Channel"),
+			labelValues("notifier", "Notifier"),
+		}},
+	}
+	x, y := 0, 0
+	for i, panel := range panels {
+		if x+panel.width > 24 {
+			x, y = 0, y+8
+		}
+		for j := range panel.targets {
+			panel.targets[j].RefID = string(rune('A' + j))
+		}
+		dashboard.Panels = append(dashboard.Panels, grafana.Panel{
+			ID:          i + 1,
+			Type:        panel.kind,
+			Title:       panel.title,
+			Description: panel.description,
+			GridPos:     grafana.GridPos{X: x, Y: y, W: panel.width, H: 8},
+			Datasource:  datasource,
+			Targets:     panel.targets,
+			FieldConfig: grafana.FieldConfig{Defaults: grafana.FieldDefaults{Unit: panel.unit}, Overrides: []interface{}{}},
+		})
+		x += panel.width
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// queueStuckAfter is the time without progress after which a non-empty workqueue is considered stuck.
+const queueStuckAfter = 5 * time.Minute
+
+// healthCheck is a named check reported by the health endpoints.
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// markProcessed records that a workqueue item has been processed.
+func (c *Controller) markProcessed() {
+	atomic.StoreInt64(&c.lastProcessed, c.clock.Now().UnixNano())
+}
+
+func (c *Controller) checkSynced() error {
+	// Standby replicas do not run any informers until they are elected.
+	if c.leaderElection && atomic.LoadInt32(&c.leading) == 0 {
+		return nil
+	}
+	if c.namespaceInformer == nil || !c.namespaceInformer.HasSynced() {
+		return errors.New("namespace cache not synced")
+	}
+	c.podsMu.RLock()
+	defer c.podsMu.RUnlock()
+	for namespace, informer := range c.pods {
+		if !informer.informer.HasSynced() {
+			return fmt.Errorf("pod cache of namespace %q not synced", namespace)
+		}
+	}
+	if c.rules != nil && !c.rules.HasSynced() {
+		return errors.New("alert rule cache not synced")
+	}
+	if c.onCall != nil && !c.onCall.HasSynced() {
+		return errors.New("on-call schedule cache not synced")
+	}
+	return nil
+}
+
+func (c *Controller) checkQueue() error {
+	if c.queue.ShuttingDown() {
+		return errors.New("workqueue is shutting down")
+	}
+	last := time.Unix(0, atomic.LoadInt64(&c.lastProcessed))
+	if length := c.queue.Len(); length > 0 && c.clock.Since(last) > queueStuckAfter {
+		return fmt.Errorf("no progress since %s with %d items queued", last.Format(time.RFC3339), length)
+	}
+	return nil
+}
+
+func (c *Controller) checkMattermost() error {
+	_, mattermost := c.settings()
+	return mattermost.Ping()
+}
+
+// healthHandler reports the results of the checks, failing with 503 if any check fails.
+func healthHandler(checks ...healthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		status := http.StatusOK
+		for _, check := range checks {
+			if err := check.check(); err != nil {
+				fmt.Fprintf(&buf, "[-]%s failed: %v\n", check.name, err)
+				status = http.StatusServiceUnavailable
+			} else {
+				fmt.Fprintf(&buf, "[+]%s ok\n", check.name)
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write(buf.Bytes())
+	})
+}
+
+// HealthzHandler serves the liveness probe, failing if the workqueue is stuck. Mattermost outages
+// are only reported by the readiness probe, restarting the informer would not fix them.
+func (c *Controller) HealthzHandler() http.Handler {
+	return healthHandler(
+		healthCheck{"workqueue", c.checkQueue},
+	)
+}
+
+// ReadyzHandler serves the readiness probe, failing until all caches are synced or if Mattermost is unreachable.
+func (c *Controller) ReadyzHandler() http.Handler {
+	return healthHandler(
+		healthCheck{"informers", c.checkSynced},
+		healthCheck{"mattermost", c.checkMattermost},
+	)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckQueueDetectsStuckQueue(t *testing.T) {
+	c, clock := newTestController(t)
+	if err := c.checkQueue(); err != nil {
+		t.Errorf("empty queue is reported as stuck: %v", err)
+	}
+	c.queue.Add(workItem{Namespace: "apps", Name: "web"})
+	clock.Step(queueStuckAfter)
+	if err := c.checkQueue(); err != nil {
+		t.Errorf("queue is reported as stuck before the timeout: %v", err)
+	}
+	clock.Step(time.Second)
+	if err := c.checkQueue(); err == nil {
+		t.Error("queue without progress is not reported as stuck")
+	}
+	c.markProcessed()
+	if err := c.checkQueue(); err != nil {
+		t.Errorf("queue is reported as stuck after progress: %v", err)
+	}
+}
+
+func TestCheckAliveFollowsHealthChecks(t *testing.T) {
+	c, clock := newTestController(t)
+	if err := c.checkAlive(); err == nil {
+		t.Error("heartbeat is alive before the caches are synced")
+	}
+	// Standby replicas have no caches to sync.
+	c.leaderElection = true
+	if err := c.checkAlive(); err != nil {
+		t.Errorf("heartbeat is not alive: %v", err)
+	}
+	c.queue.Add(workItem{Namespace: "apps", Name: "web"})
+	clock.Step(queueStuckAfter + time.Second)
+	if err := c.checkAlive(); err == nil {
+		t.Error("heartbeat is alive while the queue is stuck")
+	}
+}
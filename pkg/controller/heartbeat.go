@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// heartbeat posts the liveness of the informer to the ops channel. When posting with a token, the
+// same post is edited on each beat instead of flooding the channel.
+type heartbeat struct {
+	postID string
+}
+
+// runHeartbeat posts a heartbeat every interval until stopCh is closed. Beats are only posted
+// while the caches are synced and the workqueue makes progress, and with sharding only by the
+// first shard.
+func (c *Controller) runHeartbeat(interval time.Duration, stopCh <-chan struct{}) {
+	var beat heartbeat
+	for {
+		if !c.runsGlobalTasks() {
+			klog.V(2).InfoS("Leaving the heartbeat to the first shard")
+		} else if err := c.checkAlive(); err != nil {
+			klog.ErrorS(err, "Skipping heartbeat")
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			if err := beat.post(ctx, c); err != nil {
+				klog.ErrorS(err, "Posting heartbeat failed")
+			}
+			cancel()
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-c.clock.After(interval):
+		}
+	}
+}
+
+// checkAlive runs the health checks besides Mattermost of the controller and those of further
+// clusters, so that the heartbeat stops while the informer could not alert.
+func (c *Controller) checkAlive() error {
+	for _, controller := range append([]*Controller{c}, c.clusters...) {
+		for _, check := range []healthCheck{{"informers", controller.checkSynced}, {"workqueue", controller.checkQueue}} {
+			err := check.check()
+			if err != nil && controller.cluster != "" {
+				return fmt.Errorf("%s of cluster %s: %v", check.name, controller.cluster, err)
+			} else if err != nil {
+				return fmt.Errorf("%s: %v", check.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *heartbeat) post(ctx context.Context, c *Controller) error {
+	cfg, mattermost := c.settings()
+	message := c.heartbeatMessage()
+	if h.postID != "" {
+		err := mattermost.EditMessage(ctx, h.postID, message)
+		if err == nil {
+			return nil
+		}
+		// The post may have been deleted, start over with a new one.
+		klog.ErrorS(err, "Editing heartbeat failed, posting a new one")
+	}
+	id, err := mattermost.PostMessage(ctx, cfg.OpsChannel, message)
+	h.postID = id
+	return err
+}
+
+// heartbeatMessage describes the watched namespaces and firing alerts of the informer.
+func (c *Controller) heartbeatMessage() string {
+	cfg, _ := c.settings()
+	namespaces, all := 0, false
+	firing := 0
+	for _, controller := range append([]*Controller{c}, c.clusters...) {
+		controller.podsMu.RLock()
+		for namespace := range controller.pods {
+			if namespace == metav1.NamespaceAll {
+				all = true
+			}
+			namespaces++
+		}
+		controller.podsMu.RUnlock()
+		firing += len(controller.firing.list())
+	}
+	watching := fmt.Sprintf("%d namespaces", namespaces)
+	if all {
+		watching = "all namespaces"
+	}
+	message := fmt.Sprintf(":heartbeat: Mattermost informer is alive, watching %s, %d firing", watching, firing)
+	if cfg.Cluster.Name != "" {
+		message += " in cluster " + cfg.Cluster.Name
+	}
+	return fmt.Sprintf("%s. Last beat at %s.", message, c.clock.Now().In(cfg.Location(cfg.OpsChannel)).Format("2006-01-02 15:04:05 MST"))
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const historySize = 20
+
+// alertRecord describes a notification that has been sent to Mattermost.
+type alertRecord struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	Reason    string    `json:"reason"`
+}
+
+// namespaceStats counts the alerts of a namespace since the informer started.
+type namespaceStats struct {
+	Namespace string
+	Alerts    int
+	Last      time.Time
+}
+
+// alertHistory keeps the most recent alerts in memory.
+type alertHistory struct {
+	mu      sync.Mutex
+	records []alertRecord
+	stats   map[string]*namespaceStats
+}
+
+func (h *alertHistory) add(record alertRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stats == nil {
+		h.stats = make(map[string]*namespaceStats)
+	}
+	stats, ok := h.stats[record.Namespace]
+	if !ok {
+		stats = &namespaceStats{Namespace: record.Namespace}
+		h.stats[record.Namespace] = stats
+	}
+	stats.Alerts++
+	stats.Last = record.Time
+	h.records = append(h.records, record)
+	if len(h.records) > historySize {
+		h.records = h.records[len(h.records)-historySize:]
+	}
+}
+
+// recordOnDelivery returns a callback adding the record once the first notification of the
+// alert has been delivered.
+func (h *alertHistory) recordOnDelivery(record alertRecord) func(postID string, err error) {
+	var once sync.Once
+	return func(postID string, err error) {
+		if err == nil {
+			once.Do(func() { h.add(record) })
+		}
+	}
+}
+
+// list returns the recorded alerts, newest first.
+func (h *alertHistory) list() []alertRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	records := make([]alertRecord, len(h.records))
+	for i, record := range h.records {
+		records[len(h.records)-1-i] = record
+	}
+	return records
+}
+
+// namespaces returns the statistics of all namespaces, those with the most alerts first.
+func (h *alertHistory) namespaces() []namespaceStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := make([]namespaceStats, 0, len(h.stats))
+	for _, s := range h.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Alerts != stats[j].Alerts {
+			return stats[i].Alerts > stats[j].Alerts
+		}
+		return stats[i].Namespace < stats[j].Namespace
+	})
+	return stats
+}
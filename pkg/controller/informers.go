@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// podInformer watches the pods of a single namespace, or all namespaces. The factory is scoped
+// to the namespace so that informers for further resources can share its configuration.
+type podInformer struct {
+	factory  informers.SharedInformerFactory
+	indexer  cache.Indexer
+	informer cache.SharedIndexInformer
+	// done is closed to stop a dynamically started informer.
+	done chan struct{}
+}
+
+// newPodInformer creates an informer for the pods in the given namespace, only listing pods
+// matching the pod and field selectors if given. Use metav1.NamespaceAll to watch all namespaces.
+// Pods leaving the field selector, e.g. by completing, are reported as deleted.
+func (c *Controller) newPodInformer(namespace string) *podInformer {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, c.resyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = c.podSelector
+			options.FieldSelector = c.podFieldSelector
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+	informer.SetTransform(stripObject)
+
+	// Bind the workqueue to a cache with the help of an informer. This way we make sure that
+	// whenever the cache is updated, the pod key is added to the workqueue.
+	// Note that when we finally process the item from the workqueue, we might see a newer version
+	// of the Pod than the version which was responsible for triggering the update.
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.enqueue(obj, reasonAdded)
+		},
+		UpdateFunc: func(old interface{}, new interface{}) {
+			c.enqueue(new, reasonUpdated)
+		},
+		DeleteFunc: func(obj interface{}) {
+			// Deleted pods may be wrapped in a tombstone, which is unwrapped by newWorkItem.
+			c.enqueue(obj, reasonDeleted)
+		},
+	})
+	return &podInformer{factory: factory, indexer: informer.GetIndexer(), informer: informer, done: make(chan struct{})}
+}
+
+// enqueue queues a pod if its namespace is watched.
+func (c *Controller) enqueue(obj interface{}, reason string) {
+	item, ok := newWorkItem(obj)
+	if ok && c.ownsNamespace(item.Namespace) {
+		c.reasons.set(item, reason)
+		c.queue.Add(item)
+	}
+}
+
+// startNamespace starts watching the pods of a namespace while the controller is running.
+func (c *Controller) startNamespace(namespace string) {
+	c.podsMu.Lock()
+	defer c.podsMu.Unlock()
+	if _, ok := c.pods[namespace]; ok {
+		return
+	}
+	informer := c.newPodInformer(namespace)
+	c.pods[namespace] = informer
+	stopCh := make(chan struct{})
+	go func() {
+		select {
+		case <-c.stopCh:
+		case <-informer.done:
+		}
+		close(stopCh)
+	}()
+	informer.factory.Start(stopCh)
+	klog.InfoS("Started watching namespace", "namespace", namespace)
+}
+
+// stopNamespace stops watching the pods of a namespace started by startNamespace.
+func (c *Controller) stopNamespace(namespace string) {
+	c.podsMu.Lock()
+	defer c.podsMu.Unlock()
+	informer, ok := c.pods[namespace]
+	if !ok {
+		return
+	}
+	close(informer.done)
+	delete(c.pods, namespace)
+	klog.InfoS("Stopped watching namespace", "namespace", namespace)
+}
+
+// watchesKey checks if the namespace of the pod key is handled by this replica.
+func (c *Controller) watchesKey(key string) bool {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	return err == nil && c.ownsNamespace(namespace)
+}
+
+// ownsNamespace checks if the namespace passes the namespace filter and belongs to the shard of this replica.
+func (c *Controller) ownsNamespace(namespace string) bool {
+	if !c.namespaceFilter.matches(namespace) {
+		return false
+	}
+	return c.shard == nil || c.shard.Owns(namespace)
+}
+
+// runsGlobalTasks checks if this replica runs the tasks done once for all namespaces, like
+// reports, which is the first shard if sharding is enabled.
+func (c *Controller) runsGlobalTasks() bool {
+	return c.shard == nil || c.shard.First()
+}
+
+// podIndexer returns the indexer holding the pods of the given namespace.
+func (c *Controller) podIndexer(namespace string) cache.Indexer {
+	c.podsMu.RLock()
+	defer c.podsMu.RUnlock()
+	if informer, ok := c.pods[namespace]; ok {
+		return informer.indexer
+	}
+	if informer, ok := c.pods[metav1.NamespaceAll]; ok {
+		return informer.indexer
+	}
+	return nil
+}
+
+// getPod returns the cached pod with the given key.
+func (c *Controller) getPod(key string) (interface{}, bool, error) {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer := c.podIndexer(namespace)
+	if indexer == nil {
+		return nil, false, nil
+	}
+	return indexer.GetByKey(key)
+}
+
+// listPods returns all cached pods in the watched namespaces.
+func (c *Controller) listPods() []*v1.Pod {
+	c.podsMu.RLock()
+	defer c.podsMu.RUnlock()
+	var pods []*v1.Pod
+	for _, informer := range c.pods {
+		for _, obj := range informer.indexer.List() {
+			if pod, ok := obj.(*v1.Pod); ok && c.ownsNamespace(pod.Namespace) {
+				pods = append(pods, pod)
+			}
+		}
+	}
+	return pods
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// podMetrics is the subset of the PodMetrics of metrics.k8s.io holding the usage of the containers.
+type podMetrics struct {
+	Containers []struct {
+		Name  string          `json:"name"`
+		Usage v1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// containerResources returns the CPU and memory requests and limits of the container and, if
+// enabled, its current usage reported by metrics-server.
+func (c *Controller) containerResources(ctx context.Context, pod *v1.Pod, name string) *notify.Resources {
+	var spec *v1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			spec = &pod.Spec.Containers[i]
+		}
+	}
+	if spec == nil {
+		return nil
+	}
+	var usage v1.ResourceList
+	if c.metricsServer {
+		var err error
+		if usage, err = c.containerUsage(ctx, pod, name); err != nil {
+			klog.ErrorS(err, "Fetching usage from metrics-server failed", "pod", klog.KObj(pod), "container", name)
+		}
+	}
+	return &notify.Resources{
+		CPU:    resourceUsage(v1.ResourceCPU, spec.Resources, usage),
+		Memory: resourceUsage(v1.ResourceMemory, spec.Resources, usage),
+	}
+}
+
+// containerUsage fetches the current usage of the container from the metrics API, nil if the
+// container has no metrics, e.g. because it is not running.
+func (c *Controller) containerUsage(ctx context.Context, pod *v1.Pod, name string) (v1.ResourceList, error) {
+	data, err := c.clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", pod.Namespace, "pods", pod.Name).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var metrics podMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("could not decode pod metrics: %v", err)
+	}
+	for _, container := range metrics.Containers {
+		if container.Name == name {
+			return container.Usage, nil
+		}
+	}
+	return nil, nil
+}
+
+func resourceUsage(name v1.ResourceName, resources v1.ResourceRequirements, usage v1.ResourceList) notify.ResourceUsage {
+	var u notify.ResourceUsage
+	if request, ok := resources.Requests[name]; ok {
+		u.Request = formatQuantity(name, request)
+	}
+	limit, limited := resources.Limits[name]
+	if limited {
+		u.Limit = formatQuantity(name, limit)
+	}
+	if used, ok := usage[name]; ok {
+		u.Usage = formatQuantity(name, used)
+		if limited && !limit.IsZero() {
+			u.UsageOfLimit = int(used.MilliValue() * 100 / limit.MilliValue())
+		}
+	}
+	return u
+}
+
+// formatQuantity formats CPU in cores or millicores and memory in MiB, since metrics-server
+// reports nanocores and KiB which are hard to compare to the requests and limits.
+func formatQuantity(name v1.ResourceName, q resource.Quantity) string {
+	if name == v1.ResourceCPU {
+		if milli := q.MilliValue(); milli%1000 != 0 {
+			return fmt.Sprintf("%dm", milli)
+		}
+		return fmt.Sprintf("%d", q.Value())
+	}
+	return fmt.Sprintf("%dMi", (q.Value()+1<<20-1)>>20)
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Names of the metrics, which are also referenced by the Grafana dashboard.
+const (
+	metricBuildInfo           = "mattermost_informer_build_info"
+	metricCircuitOpen         = "mattermost_informer_circuit_breaker_open"
+	metricDeliveriesFailed    = "mattermost_informer_deliveries_failed_total"
+	metricSyntheticDeliveries = "mattermost_informer_synthetic_deliveries_total"
+	metricRecoveryDuration    = "mattermost_informer_recovery_duration_seconds"
+	metricNotifications       = "mattermost_informer_notifications_total"
+	metricAcknowledgements    = "mattermost_informer_acknowledgements_total"
+	metricTimeToAcknowledge   = "mattermost_informer_time_to_acknowledge_seconds"
+)
+
+var (
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricBuildInfo,
+		Help: "Build information of the informer, always 1.",
+	}, []string{"version", "commit", "build_date", "go_version"})
+	circuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricCircuitOpen,
+		Help: "Whether the circuit breaker of a channel of a notifier is open, i.e. deliveries are stopped.",
+	}, []string{"notifier", "channel"})
+	deliveriesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricDeliveriesFailed,
+		Help: "Number of notifications dropped after all retries failed.",
+	}, []string{"notifier"})
+	syntheticDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricSyntheticDeliveries,
+		Help: "Number of deliveries of synthetic crashes by result, either success or failure.",
+	}, []string{"notifier", "result"})
+	recoveryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricRecoveryDuration,
+		Help:    "Time from the first alert of a container until it recovered, by severity.",
+		Buckets: prometheus.ExponentialBuckets(60, 2, 10),
+	}, []string{"severity"})
+	notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricNotifications,
+		Help: "Number of notifications by the namespace, workload and reason of the alert, the channel, the notifier and the outcome, either delivered or failed.",
+	}, []string{"namespace", "workload", "reason", "channel", "notifier", "outcome"})
+	acknowledgementsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricAcknowledgements,
+		Help: "Number of acknowledged alerts by namespace and how they were acknowledged, either button or reaction.",
+	}, []string{"namespace", "via"})
+	timeToAcknowledge = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricTimeToAcknowledge,
+		Help:    "Time from the first alert of a container until it was acknowledged, by severity.",
+		Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+	}, []string{"severity"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(buildInfo, circuitOpen, deliveriesFailed, syntheticDeliveries, recoveryDuration, notifications,
+		acknowledgementsTotal, timeToAcknowledge)
+	buildInfo.WithLabelValues(version.Version, version.Commit, version.BuildDate, runtime.Version()).Set(1)
+}
+
+// countNotification counts the outcome of a crash alert, once it has been delivered or finally
+// failed. Other messages, e.g. resolutions, flapping notices and escalations, are not counted,
+// and synthetic alerts are counted separately.
+func countNotification(alert *notify.Alert, notifier string, err error) {
+	if alert.Synthetic {
+		return
+	}
+	outcome := "delivered"
+	if err != nil {
+		outcome = "failed"
+	}
+	notifications.WithLabelValues(alert.Namespace, metricWorkload(alert.Workload), alert.Reason, alert.Channel, notifier, outcome).Inc()
+}
+
+// metricWorkload returns the workload label of a metric. Bare pods and jobs typically have
+// generated names, so only their kind is kept to bound the number of series.
+func metricWorkload(workload string) string {
+	kind, _, _ := strings.Cut(workload, "/")
+	switch kind {
+	case "Pod", "Job":
+		return kind
+	}
+	return workload
+}
@@ -0,0 +1,18 @@
+package controller
+
+import "testing"
+
+func TestMetricWorkload(t *testing.T) {
+	for workload, want := range map[string]string{
+		"Deployment/payments":        "Deployment/payments",
+		"CronJob/backup":             "CronJob/backup",
+		"Job/migrate-28391520":       "Job",
+		"Pod/debug-shell-x7k2p":      "Pod",
+		"":                           "",
+		"StatefulSet/postgres-shard": "StatefulSet/postgres-shard",
+	} {
+		if got := metricWorkload(workload); got != want {
+			t.Errorf("metricWorkload(%q) = %q, want %q", workload, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// newNamespaceInformer creates an informer caching all namespaces. If a namespace selector is set,
+// the pods of matching namespaces are watched as namespaces come and go.
+func (c *Controller) newNamespaceInformer() (cache.Indexer, cache.SharedIndexInformer) {
+	informer := c.factory.Core().V1().Namespaces().Informer()
+	informer.SetTransform(stripObject)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.namespaceChanged,
+		UpdateFunc: func(old, new interface{}) {
+			c.namespaceChanged(new)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if namespace, ok := obj.(*v1.Namespace); ok && c.namespaceSelector != nil {
+				c.stopNamespace(namespace.Name)
+			}
+		},
+	})
+	return informer.GetIndexer(), informer
+}
+
+func (c *Controller) namespaceChanged(obj interface{}) {
+	namespace, ok := obj.(*v1.Namespace)
+	if !ok || c.namespaceSelector == nil {
+		return
+	}
+	if c.namespaceSelector.Matches(labels.Set(namespace.Labels)) && c.ownsNamespace(namespace.Name) {
+		c.startNamespace(namespace.Name)
+	} else {
+		c.stopNamespace(namespace.Name)
+	}
+}
+
+// rebalance re-evaluates the namespaces and pods after the shard members changed.
+func (c *Controller) rebalance() {
+	if c.namespaces != nil {
+		for _, obj := range c.namespaces.List() {
+			c.namespaceChanged(obj)
+		}
+	}
+	for _, pod := range c.listPods() {
+		c.enqueue(pod, reasonRebalance)
+	}
+}
+
+// annotation returns the value of a pod annotation. If the pod is not annotated, the
+// annotations of the workloads owning it and then of its namespace are used as defaults.
+func (c *Controller) annotation(ctx context.Context, pod *v1.Pod, key string) string {
+	if value, ok := pod.GetObjectMeta().GetAnnotations()[key]; ok {
+		return value
+	}
+	for _, annotations := range c.ownerAnnotations(ctx, pod) {
+		if value, ok := annotations[key]; ok {
+			return value
+		}
+	}
+	return c.namespaceAnnotation(pod.Namespace, key)
+}
+
+// locale returns the locale requested by an espe.tech/mattermost-locale annotation, or that of the
+// configuration if it is unset or has no catalog. An unsupported locale is warned about on the pod,
+// or logged once per namespace for annotations not taken from a pod.
+func (c *Controller) locale(cfg *config.Config, pod *v1.Pod, namespace, value string) string {
+	if value == "" {
+		return cfg.Locale
+	}
+	if i18n.Supported(value, cfg.Catalogs) {
+		return value
+	}
+	err := fmt.Errorf("locale %q has no catalog, must be one of %v or configured in catalogs", value, i18n.Locales())
+	if pod != nil {
+		c.warnInvalidAnnotation(pod, annotationMattermostLocale, value, err)
+	} else if c.invalid.add(namespace + "//" + annotationMattermostLocale + "=" + value) {
+		// The empty pod name keeps the key apart from those of the pods in the namespace.
+		klog.InfoS("Invalid annotation, ignoring it", "namespace", namespace, "annotation", annotationMattermostLocale, "err", err)
+	}
+	return cfg.Locale
+}
+
+// namespaceAnnotation returns the value of a namespace annotation, empty if the namespace is unknown.
+func (c *Controller) namespaceAnnotation(namespace, key string) string {
+	if c.namespaces == nil {
+		return ""
+	}
+	obj, exists, err := c.namespaces.GetByKey(namespace)
+	if err != nil || !exists {
+		return ""
+	}
+	return obj.(*v1.Namespace).GetAnnotations()[key]
+}
+
+// namespaceFilter selects namespaces by glob patterns like "team-*".
+type namespaceFilter struct {
+	// include selects the namespaces, empty includes all.
+	include []string
+	// exclude takes precedence over include.
+	exclude []string
+}
+
+func newNamespaceFilter(include, exclude []string) (namespaceFilter, error) {
+	for _, pattern := range append(include, exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return namespaceFilter{}, fmt.Errorf("invalid namespace pattern %q: %v", pattern, err)
+		}
+	}
+	return namespaceFilter{include: include, exclude: exclude}, nil
+}
+
+func (f namespaceFilter) matches(namespace string) bool {
+	if len(f.include) > 0 && !matchPattern(f.include, namespace) {
+		return false
+	}
+	return !matchPattern(f.exclude, namespace)
+}
+
+func matchPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
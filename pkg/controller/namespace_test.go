@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLocale(t *testing.T) {
+	c, _ := newTestController(t)
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+	cfg, _ := c.settings()
+	cfg.Locale = "fr"
+	cfg.Catalogs = i18n.Catalogs{"nl": {}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}
+
+	for value, want := range map[string]string{"": "fr", "de": "de", "nl-BE": "nl-BE", "klingon": "fr"} {
+		if got := c.locale(cfg, pod, pod.Namespace, value); got != want {
+			t.Errorf("locale of annotation %q is %q, want %q", value, got, want)
+		}
+	}
+	if got := c.locale(cfg, nil, pod.Namespace, "klingon"); got != "fr" {
+		t.Errorf("locale of namespace annotation is %q, want fr", got)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("got %d events about the invalid locale, want 1", len(recorder.Events))
+	}
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/klog/v2"
+)
+
+// assignOnCall mentions the users and groups on call according to the named OnCallSchedule, and
+// sends them the alert directly if the schedule asks for it.
+func (c *Controller) assignOnCall(namespace, name string, alert *notify.Alert) {
+	if c.onCall == nil || name == "" {
+		return
+	}
+	schedule, ok := c.onCall.Get(namespace, name)
+	if !ok {
+		klog.InfoS("On-call schedule not found", "namespace", namespace, "schedule", name)
+		return
+	}
+	onCall, err := c.onCall.OnCall(schedule, c.clock.Now())
+	if err != nil {
+		klog.ErrorS(err, "Resolving who is on call failed", "schedule", klog.KRef(schedule.Namespace, schedule.Name))
+		return
+	}
+	alert.Mentions = onCall.Mentions()
+	if schedule.DirectMessage {
+		alert.DirectMessages = onCall.Users
+	}
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"os"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/oncall"
+	"github.com/lnsp/mattermost-informer/pkg/rules"
+	"github.com/lnsp/mattermost-informer/pkg/slo"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/clock"
+)
+
+// Options configure a controller run.
+type Options struct {
+	// ConfigPath is the location of the configuration file.
+	ConfigPath string
+	// ConfigResource is the name of a MattermostInformer resource in the informer's namespace.
+	// If set, the configuration is read from the resource instead of the configuration file.
+	ConfigResource string
+	// Namespaces are the watched namespaces, defaults to the namespace the informer runs in.
+	Namespaces []string
+	// Clusters are further clusters whose pods are watched in the same namespaces, given as
+	// name=kubeconfig or name=kubeconfig:context.
+	Clusters []string
+	// AllNamespaces watches the pods in all namespaces.
+	AllNamespaces bool
+	// NamespaceSelector watches all namespaces matching the label selector, starting and stopping
+	// to watch namespaces as their labels change.
+	NamespaceSelector string
+	// NamespaceInclude and NamespaceExclude filter the watched namespaces by glob patterns.
+	NamespaceInclude []string
+	NamespaceExclude []string
+	// PodSelector restricts the watched pods to those matching the label selector.
+	// Selected pods are monitored without being annotated.
+	PodSelector string
+	// PodFieldSelector restricts the watched pods on the server, by default to pods which have not completed.
+	PodFieldSelector string
+	// OptOut monitors all pods in the watched namespace unless they are annotated to be ignored.
+	OptOut bool
+	// AlertRules enables watching AlertRule resources in the watched namespace.
+	AlertRules bool
+	// OnCallSchedules enables watching OnCallSchedule resources to mention the users on call.
+	OnCallSchedules bool
+	// WatchRollouts notifies about degraded Argo Rollouts and failed analysis runs.
+	WatchRollouts bool
+	// WatchFlux notifies about Flux HelmReleases and Kustomizations failing to reconcile.
+	WatchFlux bool
+	// WatchAutoscaler notifies about events of the Cluster Autoscaler or Karpenter failing to scale up.
+	WatchAutoscaler bool
+	// WatchArgoCD notifies about Argo CD Applications in ArgoCDNamespace which are degraded or
+	// out of sync for longer than ArgoCDGracePeriod, linking to the Argo CD UI at ArgoCDURL.
+	WatchArgoCD       bool
+	ArgoCDNamespace   string
+	ArgoCDURL         string
+	ArgoCDGracePeriod time.Duration
+	// RestartAnomalies alerts when the restart rate of a workload deviates from its baseline.
+	RestartAnomalies bool
+	// MetricsServer adds the current CPU and memory usage reported by metrics-server to the
+	// requests and limits of crashing containers in alerts.
+	MetricsServer bool
+	// ServiceLevelObjectives warns about ServiceLevelObjectives in the watched namespaces whose
+	// error budget burns too fast, which requires NotificationRecords.
+	ServiceLevelObjectives bool
+	// Workers is the number of pods processed in parallel.
+	Workers int
+	// ResyncPeriod is the interval in which all pods are re-evaluated, 0 disables resyncs.
+	ResyncPeriod time.Duration
+	// QueueBaseDelay and QueueMaxDelay bound the exponentially growing delay between retries of a pod.
+	QueueBaseDelay time.Duration
+	QueueMaxDelay  time.Duration
+	// MaxRetries is the number of times a failing pod is retried before it is dropped.
+	MaxRetries int
+	// Sharding splits the watched namespaces between all replicas running with sharding enabled.
+	Sharding bool
+	// ShardLeaseDuration is the time after which a replica that stopped renewing its lease
+	// loses its namespaces to the remaining replicas.
+	ShardLeaseDuration time.Duration
+	// Senders is the number of notifications sent in parallel, SendQueueSize the number of
+	// notifications buffered per sender and SendRetries how often failed deliveries are retried.
+	Senders       int
+	SendQueueSize int
+	SendRetries   int
+	// CircuitBreakerFailures is the number of consecutive failures after which deliveries to a
+	// notifier are stopped for CircuitBreakerCooldown.
+	CircuitBreakerFailures int
+	CircuitBreakerCooldown time.Duration
+	// SpoolDir is a directory undelivered notifications are persisted in until they can be
+	// delivered, spooling is disabled if empty. SpoolMaxEntries bounds the number of notifications,
+	// which are dropped once they have been spooled for SpoolMaxAge or failed SpoolMaxAttempts times.
+	SpoolDir         string
+	SpoolMaxEntries  int
+	SpoolMaxAge      time.Duration
+	SpoolMaxAttempts int
+	// StateTTL is the time after which the notification state of a pod is forgotten, it must
+	// exceed the longest backoff. StateMaxEntries bounds the number of entries kept in memory.
+	StateTTL        time.Duration
+	StateMaxEntries int
+	// StateConfigMap is the name of a ConfigMap in the informer's namespace the notification
+	// timeouts are persisted to, so that restarts do not cause duplicate notifications.
+	StateConfigMap string
+	// ActionSecretFile is the file, e.g. of a mounted Secret, holding the secret which signs the
+	// acknowledge buttons of alerts. All replicas must share it, buttons are only shown if it is set.
+	ActionSecretFile string
+	// NotificationRecords persists every sent notification as NotificationRecord resource in the
+	// informer's namespace, which are deleted after NotificationRecordRetention.
+	NotificationRecords         bool
+	NotificationRecordRetention time.Duration
+	// WeeklyReport posts a weekly summary of the alerts computed from the NotificationRecords
+	// to each channel, which requires NotificationRecords.
+	WeeklyReport bool
+	// TopCrashers posts a leaderboard of the workloads with the most alerts to the ops channel as
+	// scheduled by the configuration, which requires NotificationRecords.
+	TopCrashers bool
+	// ShutdownTimeout is the time given to process the queued pods after a termination signal.
+	ShutdownTimeout time.Duration
+	// StartupNotice and ShutdownNotice post a notice to the ops channel when the informer starts
+	// or shuts down.
+	StartupNotice  bool
+	ShutdownNotice bool
+	// HeartbeatInterval is the interval in which the liveness of the informer is posted to the ops
+	// channel, 0 disables the heartbeat.
+	HeartbeatInterval time.Duration
+	// OTLPEndpoint is the address of an OpenTelemetry collector traces are exported to, empty disables tracing.
+	OTLPEndpoint string
+	// OTLPInsecure disables TLS for the connection to the collector.
+	OTLPInsecure bool
+	// SentryDSN reports errors dropped by the controller to Sentry, empty disables reporting.
+	SentryDSN         string
+	SentryEnvironment string
+	// SkipPermissionCheck disables verifying the RBAC permissions on startup.
+	SkipPermissionCheck bool
+	// LeaderElect runs the controller on a single elected replica, the other replicas stand by.
+	LeaderElect bool
+	// MetricsAddr is the address Prometheus metrics are served on, 0 disables serving metrics.
+	MetricsAddr string
+	// EnableDebug serves pprof profiles and the internal state under /debug.
+	EnableDebug bool
+	// DebugAddr is the address the debug handlers and the dashboard are served on, separate from
+	// the public listener.
+	DebugAddr string
+	// GRPCAddr is the address alerts are streamed on via gRPC, empty disables streaming. The stream
+	// is served with TLS using GRPCCertFile and GRPCKeyFile, unless GRPCInsecure is set.
+	GRPCAddr     string
+	GRPCCertFile string
+	GRPCKeyFile  string
+	GRPCInsecure bool
+	// EnableDashboard serves a read-only overview of the alerts and silences under /dashboard.
+	EnableDashboard bool
+	// SyntheticCrashInterval is the interval in which a synthetic crash is injected to verify the
+	// delivery of alerts end to end, 0 disables injection.
+	SyntheticCrashInterval time.Duration
+	// Clock is the source of the time of alerts, backoffs, grace periods and silences, by default
+	// the system clock. It also delays the retries of notifications. Tests may use a fake clock to make
+	// them deterministic.
+	Clock clock.WithDelayedExecution
+	// Mattermost overrides the non-empty Mattermost settings of the configuration file.
+	Mattermost utils.MattermostConfig
+}
+
+// DefaultOptions returns the options used if no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		ConfigPath:             config.DefaultPath,
+		Workers:                1,
+		QueueBaseDelay:         5 * time.Millisecond,
+		QueueMaxDelay:          1000 * time.Second,
+		MaxRetries:             5,
+		StateTTL:               24 * time.Hour,
+		Senders:                4,
+		SendQueueSize:          1000,
+		SendRetries:            3,
+		CircuitBreakerFailures: 5,
+		CircuitBreakerCooldown: time.Minute,
+		SpoolMaxEntries:        1000,
+		SpoolMaxAge:            24 * time.Hour,
+		SpoolMaxAttempts:       100,
+		StateMaxEntries:        10000,
+		PodFieldSelector:       "status.phase!=Succeeded,status.phase!=Failed",
+		ShardLeaseDuration:     30 * time.Second,
+		ShutdownTimeout:        20 * time.Second,
+		MetricsAddr:            ":9090",
+		DebugAddr:              "localhost:6060",
+		ArgoCDNamespace:        "argocd",
+		ArgoCDGracePeriod:      5 * time.Minute,
+
+		NotificationRecordRetention: 30 * 24 * time.Hour,
+	}
+}
+
+// watchedResources lists the custom resources watched besides pods.
+func (opts *Options) watchedResources() []schema.GroupVersionResource {
+	var resources []schema.GroupVersionResource
+	if opts.AlertRules {
+		resources = append(resources, rules.GVR)
+	}
+	if opts.WatchRollouts {
+		resources = append(resources, rolloutGVR)
+	}
+	if opts.WatchFlux {
+		resources = append(resources, helmReleaseGVR, kustomizationGVR)
+	}
+	if opts.ServiceLevelObjectives {
+		resources = append(resources, slo.GVR)
+	}
+	if opts.OnCallSchedules {
+		resources = append(resources, oncall.GVR)
+	}
+	return resources
+}
+
+// loadResource loads the configuration resource and applies the overrides.
+func (opts *Options) loadResource(client dynamic.Interface, namespace string) (*config.Config, error) {
+	cfg, err := config.LoadResource(client, namespace, opts.ConfigResource)
+	if err != nil {
+		return nil, err
+	}
+	opts.apply(cfg)
+	return cfg, nil
+}
+
+// LoadConfig loads the configuration file and applies the overrides.
+func (opts *Options) LoadConfig() (*config.Config, error) {
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	opts.apply(cfg)
+	return cfg, nil
+}
+
+func (opts *Options) apply(cfg *config.Config) {
+	override := func(value *string, with string) {
+		if with != "" {
+			*value = with
+		}
+	}
+	override(&cfg.Mattermost.URL, opts.Mattermost.URL)
+	override(&cfg.Mattermost.Team, opts.Mattermost.Team)
+	override(&cfg.Mattermost.Channel, opts.Mattermost.Channel)
+	override(&cfg.Mattermost.TokenFile, opts.Mattermost.TokenFile)
+	override(&cfg.Mattermost.WebhookURL, opts.Mattermost.WebhookURL)
+}
+
+// shardIdentity returns the name of the replica, the pod name if exposed as POD_NAME or the hostname.
+func (opts *Options) shardIdentity() (string, error) {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name, nil
+	}
+	return os.Hostname()
+}
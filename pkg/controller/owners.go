@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ownerCacheTTL is how long the annotations of a workload are cached.
+	ownerCacheTTL = time.Minute
+	// maxOwnerDepth limits how many owners are walked, e.g. Pod, ReplicaSet, Deployment.
+	maxOwnerDepth = 3
+)
+
+type ownerEntry struct {
+	annotations map[string]string
+	owner       *metav1.OwnerReference
+	fetched     time.Time
+}
+
+// ownerCache caches the annotations and controlling owners of workloads by UID.
+type ownerCache struct {
+	mu      sync.Mutex
+	entries map[types.UID]ownerEntry
+}
+
+// get returns the entry of the workload unless it has been fetched longer than the TTL before now.
+func (c *ownerCache) get(uid types.UID, now time.Time) (ownerEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[uid]
+	if !ok || now.Sub(entry.fetched) > ownerCacheTTL {
+		return ownerEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *ownerCache) put(uid types.UID, entry ownerEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[types.UID]ownerEntry)
+	}
+	for key, old := range c.entries {
+		if entry.fetched.Sub(old.fetched) > ownerCacheTTL {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[uid] = entry
+}
+
+// getOwner fetches a workload referenced by an owner reference. Unsupported kinds return nil.
+func (c *Controller) getOwner(ctx context.Context, namespace string, ref *metav1.OwnerReference) (metav1.Object, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	options := metav1.GetOptions{}
+	switch ref.Kind {
+	case "ReplicaSet":
+		return c.clientset.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, options)
+	case "Deployment":
+		return c.clientset.AppsV1().Deployments(namespace).Get(ctx, ref.Name, options)
+	case "StatefulSet":
+		return c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, ref.Name, options)
+	case "DaemonSet":
+		return c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, ref.Name, options)
+	case "Job":
+		return c.clientset.BatchV1().Jobs(namespace).Get(ctx, ref.Name, options)
+	case "CronJob":
+		return c.clientset.BatchV1().CronJobs(namespace).Get(ctx, ref.Name, options)
+	}
+	return nil, nil
+}
+
+// workloadOwner is a workload controlling a pod.
+type workloadOwner struct {
+	kind        string
+	name        string
+	annotations map[string]string
+}
+
+// ownerAnnotations returns the annotations of the workloads controlling the pod, nearest owner first.
+func (c *Controller) ownerAnnotations(ctx context.Context, pod *v1.Pod) []map[string]string {
+	var annotations []map[string]string
+	for _, owner := range c.controllers(ctx, pod) {
+		annotations = append(annotations, owner.annotations)
+	}
+	return annotations
+}
+
+// workload returns the kind/name of the outermost workload controlling the pod, e.g. the Deployment
+// of a ReplicaSet. Pods without a supported controller are their own workload.
+func (c *Controller) workload(ctx context.Context, pod *v1.Pod) string {
+	owners := c.controllers(ctx, pod)
+	if len(owners) == 0 {
+		return "Pod/" + pod.Name
+	}
+	owner := owners[len(owners)-1]
+	return owner.kind + "/" + owner.name
+}
+
+// controllers returns the workloads controlling the pod, nearest owner first.
+func (c *Controller) controllers(ctx context.Context, pod *v1.Pod) []workloadOwner {
+	var owners []workloadOwner
+	ref := metav1.GetControllerOf(pod)
+	for depth := 0; ref != nil && depth < maxOwnerDepth; depth++ {
+		entry, ok := c.owners.get(ref.UID, c.clock.Now())
+		if !ok {
+			owner, err := c.getOwner(ctx, pod.Namespace, ref)
+			if err != nil {
+				klog.ErrorS(err, "Fetching owner failed", "pod", klog.KObj(pod), "kind", ref.Kind, "owner", ref.Name)
+				break
+			}
+			if owner == nil {
+				break
+			}
+			entry = ownerEntry{
+				annotations: owner.GetAnnotations(),
+				owner:       metav1.GetControllerOf(owner),
+				fetched:     c.clock.Now(),
+			}
+			c.owners.put(ref.UID, entry)
+		}
+		owners = append(owners, workloadOwner{kind: ref.Kind, name: ref.Name, annotations: entry.annotations})
+		ref = entry.owner
+	}
+	return owners
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOwnersAreCachedUntilTTL(t *testing.T) {
+	controller := true
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "apps", Name: "web", UID: types.UID("deployment"),
+		Annotations: map[string]string{annotationMattermostChannel: "team-web"},
+	}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "apps", Name: "web-0",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: deployment.UID, Controller: &controller}},
+	}}
+	c, clock := newTestController(t, deployment)
+	ctx := context.Background()
+	deployments := c.clientset.AppsV1().Deployments("apps")
+
+	if annotations := c.ownerAnnotations(ctx, pod); len(annotations) != 1 || annotations[0][annotationMattermostChannel] != "team-web" {
+		t.Fatalf("owner annotations are %v", annotations)
+	}
+	deployment.Annotations[annotationMattermostChannel] = "team-platform"
+	if _, err := deployments.Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Step(ownerCacheTTL)
+	if annotations := c.ownerAnnotations(ctx, pod); annotations[0][annotationMattermostChannel] != "team-web" {
+		t.Errorf("owner was fetched again within the TTL: %v", annotations)
+	}
+	clock.Step(time.Second)
+	if annotations := c.ownerAnnotations(ctx, pod); annotations[0][annotationMattermostChannel] != "team-platform" {
+		t.Errorf("owner was not fetched again after the TTL: %v", annotations)
+	}
+}
@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+)
+
+// publish streams an event of the alert to the subscribers of the gRPC API, if enabled.
+func (c *Controller) publish(eventType stream.EventType, alert *notify.Alert, notifier string, err error) {
+	if c.events == nil {
+		return
+	}
+	event := &stream.Event{Type: eventType, Time: c.clock.Now(), Notifier: notifier, Alert: alert}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	c.events.Publish(event)
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// permission is an access the informer requires, Namespace is empty for cluster-wide access.
+type permission struct {
+	Namespace   string
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+}
+
+func (p permission) String() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	scope := "cluster-wide"
+	if p.Namespace != "" {
+		scope = "in namespace " + p.Namespace
+	}
+	return fmt.Sprintf("%s %s %s", p.Verb, resource, scope)
+}
+
+// requiredPermissions lists the permissions needed to watch the pods and the resources enabled by
+// the options in the namespaces of a cluster.
+func requiredPermissions(opts *Options, namespaces []string) []permission {
+	permissions := namespacePermissions(namespaces, opts.watchedResources())
+	if opts.WatchAutoscaler {
+		for _, namespace := range namespaces {
+			permissions = append(permissions,
+				permission{Namespace: namespace, Verb: "list", Resource: "events"},
+				permission{Namespace: namespace, Verb: "watch", Resource: "events"},
+			)
+		}
+	}
+	if opts.MetricsServer {
+		for _, namespace := range namespaces {
+			permissions = append(permissions, permission{Namespace: namespace, Verb: "get", Group: "metrics.k8s.io", Resource: "pods"})
+		}
+	}
+	if opts.WatchArgoCD {
+		permissions = append(permissions,
+			permission{Namespace: opts.ArgoCDNamespace, Verb: "list", Group: applicationGVR.Group, Resource: applicationGVR.Resource},
+			permission{Namespace: opts.ArgoCDNamespace, Verb: "watch", Group: applicationGVR.Group, Resource: applicationGVR.Resource},
+		)
+	}
+	return permissions
+}
+
+// namespacePermissions lists the permissions needed to watch the pods and the given resources in the namespaces.
+func namespacePermissions(namespaces []string, resources []schema.GroupVersionResource) []permission {
+	permissions := []permission{
+		{Verb: "list", Resource: "namespaces"},
+		{Verb: "watch", Resource: "namespaces"},
+	}
+	for _, namespace := range namespaces {
+		permissions = append(permissions,
+			permission{Namespace: namespace, Verb: "list", Resource: "pods"},
+			permission{Namespace: namespace, Verb: "watch", Resource: "pods"},
+			permission{Namespace: namespace, Verb: "get", Resource: "pods", Subresource: "log"},
+			permission{Namespace: namespace, Verb: "create", Resource: "events"},
+		)
+		for _, resource := range resources {
+			permissions = append(permissions,
+				permission{Namespace: namespace, Verb: "list", Group: resource.Group, Resource: resource.Resource},
+				permission{Namespace: namespace, Verb: "watch", Group: resource.Group, Resource: resource.Resource},
+			)
+		}
+	}
+	return permissions
+}
+
+// checkPermissions verifies the permissions of the service account using SelfSubjectAccessReviews
+// and returns an error listing all missing permissions.
+func (c *Controller) checkPermissions(permissions []permission) error {
+	var missing []string
+	for _, p := range permissions {
+		review, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   p.Namespace,
+					Verb:        p.Verb,
+					Group:       p.Group,
+					Resource:    p.Resource,
+					Subresource: p.Subresource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to review permission to %s: %v", p, err)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, p.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("service account is missing permissions to %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// recordCollectionInterval is the interval in which expired NotificationRecords are deleted.
+	recordCollectionInterval = time.Hour
+	// recordWriteQueueSize is the number of NotificationRecord writes buffered for the writer.
+	recordWriteQueueSize = 1000
+)
+
+// recordWrite is a write of NotificationRecords waiting to be performed.
+type recordWrite struct {
+	op    string
+	write func(ctx context.Context) error
+}
+
+// queueRecordWrite queues a write of NotificationRecords, so that neither the senders nor the
+// workers wait for the API server. Writes are dropped if the queue is full.
+func (c *Controller) queueRecordWrite(op string, write func(ctx context.Context) error) {
+	select {
+	case c.recordWrites <- recordWrite{op: op, write: write}:
+	default:
+		klog.ErrorS(nil, "Dropping notification record write, the queue is full", "op", op)
+	}
+}
+
+// runRecordWriter performs the queued writes until stopCh is closed.
+func (c *Controller) runRecordWriter(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case write := <-c.recordWrites:
+			c.writeRecord(write)
+		}
+	}
+}
+
+// flushRecordWrites performs the queued writes in the calling goroutine.
+func (c *Controller) flushRecordWrites() {
+	for {
+		select {
+		case write := <-c.recordWrites:
+			c.writeRecord(write)
+		default:
+			return
+		}
+	}
+}
+
+func (c *Controller) writeRecord(write recordWrite) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := write.write(ctx); err != nil {
+		klog.ErrorS(err, "Writing notification records failed", "op", write.op)
+	}
+}
+
+// recordNotification persists the notification sent via the named notifier, if enabled.
+func (c *Controller) recordNotification(alert *notify.Alert, notifier, postID string) {
+	if c.records == nil {
+		return
+	}
+	spec := &records.Spec{
+		Fingerprint:       alert.Fingerprint,
+		Cluster:           alert.Cluster,
+		Namespace:         alert.Namespace,
+		Pod:               alert.Pod,
+		Container:         alert.Container,
+		Workload:          alert.Workload,
+		Reason:            alert.Reason,
+		TerminationReason: alert.TerminationReason,
+		Severity:          alert.Severity,
+		Title:             alert.Title,
+		Text:              alert.Text,
+		Channel:           alert.Channel,
+		Notifier:          notifier,
+		PostID:            postID,
+		SentAt:            metav1.NewTime(alert.Time),
+	}
+	c.queueRecordWrite("create", func(ctx context.Context) error {
+		return c.records.Create(ctx, spec)
+	})
+}
+
+// restoreFiring loads the unresolved alerts from the NotificationRecords, so that they are resolved
+// after a restart.
+func (c *Controller) restoreFiring(ctx context.Context) error {
+	unresolved, err := c.records.Unresolved(ctx)
+	if err != nil {
+		return err
+	}
+	notifiers := make(map[string][]string)
+	alerts := make(map[string]*notify.Alert)
+	for _, record := range unresolved {
+		spec := record.Spec
+		if c.clusterController(spec.Cluster) == nil {
+			klog.InfoS("Ignoring notification record of unknown cluster", "record", record.Name, "cluster", spec.Cluster)
+			continue
+		}
+		if !containsString(notifiers[spec.Fingerprint], spec.Notifier) {
+			notifiers[spec.Fingerprint] = append(notifiers[spec.Fingerprint], spec.Notifier)
+		}
+		alerts[spec.Fingerprint] = &notify.Alert{
+			Time:              spec.SentAt.Time,
+			Fingerprint:       spec.Fingerprint,
+			Cluster:           spec.Cluster,
+			Namespace:         spec.Namespace,
+			Pod:               spec.Pod,
+			Container:         spec.Container,
+			Workload:          spec.Workload,
+			Reason:            spec.Reason,
+			TerminationReason: spec.TerminationReason,
+			Severity:          spec.Severity,
+			Title:             spec.Title,
+			Text:              spec.Text,
+			Channel:           spec.Channel,
+		}
+	}
+	for fingerprint, alert := range alerts {
+		c.clusterController(alert.Cluster).firing.add(alert, notifiers[fingerprint])
+	}
+	klog.InfoS("Restored unresolved alerts", "count", len(alerts))
+	return nil
+}
+
+// runRecordCollection deletes expired NotificationRecords until stopCh is closed.
+func (c *Controller) runRecordCollection(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		if err := c.records.Collect(ctx); err != nil {
+			klog.ErrorS(err, "Deleting expired notification records failed")
+		}
+	}, recordCollectionInterval, stopCh)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
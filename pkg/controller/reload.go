@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// settings returns the current configuration and Mattermost client.
+func (c *Controller) settings() (*config.Config, *utils.MattermostClient) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config, c.mattermost
+}
+
+// location returns the time zone times are displayed in for the channel.
+func (c *Controller) location(channel string) *time.Location {
+	cfg, _ := c.settings()
+	return cfg.Location(channel)
+}
+
+// notifier returns the notifier with the given name, nil if unknown.
+func (c *Controller) notifier(name string) notify.Notifier {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.notifiers[name]
+}
+
+// newNotifiers creates the notifiers of the configuration. The Mattermost client of the
+// configuration is available as the mattermost notifier. Notifiers using the time get the clock,
+// those translating labels the catalogs of the configuration.
+func newNotifiers(cfg *config.Config, mattermost *utils.MattermostClient, clock clock.PassiveClock) (map[string]notify.Notifier, error) {
+	mattermostNotifier := notify.NewMattermost(mattermost)
+	mattermostNotifier.SetCatalogs(cfg.Catalogs)
+	notifiers := map[string]notify.Notifier{
+		notify.TypeMattermost: mattermostNotifier,
+	}
+	for _, notifierCfg := range cfg.Notifiers {
+		notifier, err := notify.New(notifierCfg.Type, notifierCfg.Config)
+		if err != nil {
+			return nil, fmt.Errorf("could not create notifier %s: %v", notifierCfg.Name, err)
+		}
+		if clocked, ok := notifier.(notify.Clocked); ok {
+			clocked.SetClock(clock)
+		}
+		if localized, ok := notifier.(notify.Localized); ok {
+			localized.SetCatalogs(cfg.Catalogs)
+		}
+		notifiers[notifierCfg.Name] = notifier
+	}
+	return notifiers, nil
+}
+
+// Reload applies a new configuration. If the Mattermost settings changed, a new client is
+// created; the old configuration stays in effect if the connection fails.
+func (c *Controller) Reload(cfg *config.Config) {
+	old, mattermost := c.settings()
+	if !reflect.DeepEqual(old.Mattermost, cfg.Mattermost) {
+		var err error
+		mattermost, err = utils.NewMattermostClient(cfg.Mattermost)
+		if err != nil {
+			klog.ErrorS(err, "Keeping previous configuration, connecting to Mattermost failed")
+			return
+		}
+	}
+	if old.ListenAddr != cfg.ListenAddr {
+		klog.InfoS("Changing the listen address requires a restart", "listenAddr", cfg.ListenAddr)
+	}
+	c.apply(cfg, mattermost)
+}
+
+// Reauthenticate reconnects to Mattermost with the current configuration, e.g. after the token
+// file has been rotated. The previous client stays in use if the connection fails.
+func (c *Controller) Reauthenticate() {
+	cfg, _ := c.settings()
+	mattermost, err := utils.NewMattermostClient(cfg.Mattermost)
+	if err != nil {
+		klog.ErrorS(err, "Keeping previous Mattermost client, reconnecting failed")
+		return
+	}
+	klog.InfoS("Reconnected to Mattermost with the rotated token")
+	c.apply(cfg, mattermost)
+}
+
+// apply replaces the configuration and Mattermost client of the controller and the controllers
+// of further clusters.
+func (c *Controller) apply(cfg *config.Config, mattermost *utils.MattermostClient) {
+	notifiers, err := newNotifiers(cfg, mattermost, c.clock)
+	if err != nil {
+		klog.ErrorS(err, "Keeping previous configuration")
+		return
+	}
+	c.setSettings(cfg, mattermost, notifiers)
+	for _, cluster := range c.clusters {
+		cluster.setSettings(cfg, mattermost, notifiers)
+	}
+}
+
+func (c *Controller) setSettings(cfg *config.Config, mattermost *utils.MattermostClient, notifiers map[string]notify.Notifier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = cfg
+	c.mattermost = mattermost
+	c.notifiers = notifiers
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/client"
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"github.com/lnsp/mattermost-informer/pkg/rules"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// renderSampleTime is the time of crashes rendered without a termination time.
+var renderSampleTime = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// RenderRequest selects the templates and the pod rendered by Render.
+type RenderRequest struct {
+	// TemplatePath is a YAML file with title and text templates replacing those of the configuration.
+	TemplatePath string
+	// PodPath is a manifest of a pod, Pod is namespace/name of a live pod read using Kubeconfig and
+	// Context. If neither is set, the sample is rendered.
+	PodPath    string
+	Pod        string
+	Kubeconfig string
+	Context    string
+	// Container is the rendered container of the pod, by default the first one which crashed.
+	Container string
+	// Locale is the locale rendered in, by default the locale of the configuration.
+	Locale string
+	Sample TestAlert
+}
+
+// Render renders the title and text of the alert for a pod, as they would be sent. Pods without a
+// termination time and the sample crash at a fixed time, so that the output can be compared to golden files.
+func Render(opts Options, req RenderRequest) ([]byte, error) {
+	cfg, err := opts.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	templates := &cfg.Templates
+	if req.TemplatePath != "" {
+		if templates, err = readTemplates(req.TemplatePath); err != nil {
+			return nil, err
+		}
+	}
+	data, err := renderData(cfg, req)
+	if err != nil {
+		return nil, err
+	}
+	locale := req.Locale
+	if locale == "" {
+		locale = cfg.Locale
+	}
+	if locale != "" && !i18n.Supported(locale, cfg.Catalogs) {
+		return nil, fmt.Errorf("locale %q has no catalog, must be one of %v or configured in catalogs", locale, i18n.Locales())
+	}
+	title, text, err := templates.Render(cfg.Catalogs, locale, data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%s\n\n%s\n", title, text)), nil
+}
+
+// readTemplates reads and compiles templates from a YAML file with title and text.
+func readTemplates(path string) (*config.Templates, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read templates: %v", err)
+	}
+	templates := &config.Templates{}
+	if err := yaml.UnmarshalStrict(data, templates); err != nil {
+		return nil, fmt.Errorf("could not parse templates: %v", err)
+	}
+	if err := templates.Compile(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// renderData returns the template data for the requested pod, or the sample.
+func renderData(cfg *config.Config, req RenderRequest) (*alertData, error) {
+	cluster, environment := cfg.Cluster.Identity("")
+	if req.PodPath == "" && req.Pod == "" {
+		if req.Container != "" {
+			req.Sample.Container = req.Container
+		}
+		severity, err := ParseSeverity(req.Sample.Severity)
+		if err != nil {
+			return nil, err
+		}
+		channel, _ := cfg.Routing(renderSampleTime, req.Sample.Namespace, severity.String(), req.Sample.Reason)
+		return &alertData{
+			Namespace:    req.Sample.Namespace,
+			Pod:          req.Sample.Pod,
+			Container:    req.Sample.Container,
+			Reason:       req.Sample.Reason,
+			Severity:     severity.String(),
+			RestartCount: 5,
+			Cluster:      cluster,
+			Environment:  environment,
+			Time:         renderSampleTime.In(cfg.Location(channel)),
+		}, nil
+	}
+	pod, clientset, err := renderPod(req)
+	if err != nil {
+		return nil, err
+	}
+	container, err := renderContainer(pod, req.Container)
+	if err != nil {
+		return nil, err
+	}
+	c := NewController(cfg, clientset, nil, nil)
+	reason := rules.Default.Reason(container)
+	if reason == "" {
+		reason = terminationReason(container)
+	}
+	severity := c.severity(context.TODO(), pod).String()
+	crashedAt := renderSampleTime
+	if terminated := container.LastTerminationState.Terminated; terminated != nil && !terminated.FinishedAt.IsZero() {
+		crashedAt = terminated.FinishedAt.Time
+	}
+	channel := c.annotation(context.TODO(), pod, annotationMattermostChannel)
+	if channel == "" {
+		channel, _ = cfg.Routing(crashedAt, pod.Namespace, severity, reason, terminationReason(container))
+	}
+	return &alertData{
+		Namespace:    pod.Namespace,
+		Pod:          pod.Name,
+		Container:    container.Name,
+		Reason:       reason,
+		Severity:     severity,
+		RestartCount: container.RestartCount,
+		Cluster:      cluster,
+		Environment:  environment,
+		Time:         crashedAt.In(cfg.Location(channel)),
+	}, nil
+}
+
+// renderPod reads the pod from its manifest or the cluster. The returned clientset is used to
+// look up the owners of the pod.
+func renderPod(req RenderRequest) (*v1.Pod, kubernetes.Interface, error) {
+	if req.PodPath != "" {
+		objects, err := readManifest(req.PodPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, obj := range objects {
+			if obj.GetAPIVersion() != "v1" || obj.GetKind() != "Pod" {
+				continue
+			}
+			pod := &v1.Pod{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+				return nil, nil, fmt.Errorf("%s: pod %s: %v", req.PodPath, obj.GetName(), err)
+			}
+			return pod, fake.NewSimpleClientset(pod), nil
+		}
+		return nil, nil, fmt.Errorf("%s contains no pod", req.PodPath)
+	}
+	parts := strings.SplitN(req.Pod, "/", 2)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("pod %q must be given as namespace/name", req.Pod)
+	}
+	clientset, err := client.FromKubeconfig(req.Kubeconfig, req.Context)
+	if err != nil {
+		return nil, nil, err
+	}
+	pod, err := clientset.CoreV1().Pods(parts[0]).Get(context.TODO(), parts[1], metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get pod: %v", err)
+	}
+	return pod, clientset, nil
+}
+
+// renderContainer returns the status of the named container, or of the first which crashed.
+func renderContainer(pod *v1.Pod, name string) (*v1.ContainerStatus, error) {
+	statuses := pod.Status.ContainerStatuses
+	for i := range statuses {
+		if name != "" && statuses[i].Name == name {
+			return &statuses[i], nil
+		}
+		if name == "" && (statuses[i].RestartCount > 0 || statuses[i].State.Waiting != nil) {
+			return &statuses[i], nil
+		}
+	}
+	if name != "" {
+		return nil, fmt.Errorf("pod %s has no status of container %s", pod.Name, name)
+	}
+	return nil, fmt.Errorf("pod %s has no crashed container", pod.Name)
+}
+
+// CompareGolden compares the output to the golden file at path, or replaces the golden file if
+// update is set. Differences are reported with the first differing line.
+func CompareGolden(path string, output []byte, update bool) error {
+	if update {
+		return ioutil.WriteFile(path, output, 0o644)
+	}
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read golden file: %v", err)
+	}
+	if bytes.Equal(golden, output) {
+		return nil
+	}
+	want, got := strings.Split(string(golden), "\n"), strings.Split(string(output), "\n")
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var wantLine, gotLine string
+		if i < len(want) {
+			wantLine = want[i]
+		}
+		if i < len(got) {
+			gotLine = got[i]
+		}
+		if wantLine != gotLine {
+			return fmt.Errorf("output differs from %s in line %d:\n- %s\n+ %s", path, i+1, wantLine, gotLine)
+		}
+	}
+	return fmt.Errorf("output differs from %s", path)
+}
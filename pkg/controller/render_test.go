@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestRenderGolden(t *testing.T) {
+	sample := TestAlert{Namespace: "default", Pod: "informer-test", Container: "app", Reason: "CrashLoopBackOff", Severity: "warning"}
+	tests := []struct {
+		name   string
+		config string
+		req    RenderRequest
+	}{
+		{name: "sample", config: "default.yaml", req: RenderRequest{Sample: sample}},
+		{name: "sample-de", config: "default.yaml", req: RenderRequest{Sample: sample, Locale: "de"}},
+		{name: "oom", config: "custom.yaml", req: RenderRequest{Sample: sample, PodPath: "oom-pod.yaml"}},
+		{name: "oom-template", config: "default.yaml", req: RenderRequest{Sample: sample, PodPath: "oom-pod.yaml", TemplatePath: "templates.yaml"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", "render")
+			opts := DefaultOptions()
+			opts.ConfigPath = filepath.Join(dir, test.config)
+			if test.req.PodPath != "" {
+				test.req.PodPath = filepath.Join(dir, test.req.PodPath)
+			}
+			if test.req.TemplatePath != "" {
+				test.req.TemplatePath = filepath.Join(dir, test.req.TemplatePath)
+			}
+			output, err := Render(opts, test.req)
+			if err != nil {
+				t.Fatalf("rendering failed: %v", err)
+			}
+			if err := CompareGolden(filepath.Join(dir, test.name+".golden"), output, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCompareGoldenReportsFirstDifference(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alert.golden")
+	if err := CompareGolden(path, []byte("title\n\nold text\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareGolden(path, []byte("title\n\nold text\n"), false); err != nil {
+		t.Errorf("identical output differs: %v", err)
+	}
+	err := CompareGolden(path, []byte("title\n\nnew text\n"), false)
+	if err == nil {
+		t.Fatal("changed output does not differ")
+	}
+	if want := "line 3:\n- old text\n+ new text"; !strings.Contains(err.Error(), want) {
+		t.Errorf("difference is reported as %q, want it to contain %q", err, want)
+	}
+}
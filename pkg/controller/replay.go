@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/rules"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+)
+
+// replayEvent is a change of a pod read from a fixture.
+type replayEvent struct {
+	eventType watch.EventType
+	pod       *v1.Pod
+}
+
+// Replay runs the pods of the given fixtures through detection, routing and templating and writes
+// the messages which would be sent to out, without contacting Kubernetes or any notifier.
+// Fixtures are YAML or JSON manifests, lists as printed by kubectl get -o yaml, or recorded watch
+// streams of {"type": ..., "object": ...} events. Namespaces, workloads and AlertRules of the
+// fixtures are taken into account. Pods are replayed in order, so repeated crashes of a pod within
+// the backoff interval are suppressed just like in the cluster.
+func Replay(opts Options, fixtures []string, out io.Writer) error {
+	cfg, err := opts.LoadConfig()
+	if err != nil {
+		return err
+	}
+	// Playbook runs and Loki queries would reach external systems.
+	cfg.Playbook.ID = ""
+	cfg.Loki.URL = ""
+	// Fixtures carry no timing, so alerts are resolved as soon as the containers are ready.
+	cfg.Flapping.ResolveAfter.Duration = 0
+
+	var (
+		events     []replayEvent
+		objects    []runtime.Object
+		namespaces []*v1.Namespace
+		alertRules []runtime.Object
+	)
+	for _, path := range fixtures {
+		items, err := readReplayFixture(path)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj := item.Object
+			if obj.GetAPIVersion() == "v1" && obj.GetKind() == "Pod" {
+				pod := &v1.Pod{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+					return fmt.Errorf("%s: pod %s: %v", path, obj.GetName(), err)
+				}
+				events = append(events, replayEvent{eventType: item.Type, pod: pod})
+				continue
+			}
+			if obj.GroupVersionKind().GroupKind() == rules.GVR.GroupVersion().WithKind("AlertRule").GroupKind() {
+				alertRules = append(alertRules, obj)
+				continue
+			}
+			typed, err := scheme.Scheme.New(obj.GroupVersionKind())
+			if err != nil {
+				return fmt.Errorf("%s: unsupported resource %s %s", path, obj.GetKind(), obj.GetName())
+			}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+				return fmt.Errorf("%s: %s %s: %v", path, obj.GetKind(), obj.GetName(), err)
+			}
+			if namespace, ok := typed.(*v1.Namespace); ok {
+				namespaces = append(namespaces, namespace)
+			}
+			objects = append(objects, typed)
+		}
+	}
+
+	printer := &replayPrinter{out: out}
+	controller := NewController(cfg, fake.NewSimpleClientset(objects...), nil, nil)
+	controller.notifiers = map[string]notify.Notifier{notify.TypeMattermost: printer.notifier(notify.TypeMattermost)}
+	for _, notifier := range cfg.Notifiers {
+		controller.notifiers[notifier.Name] = printer.notifier(notifier.Name)
+	}
+	controller.dispatcher = newDispatcher(1, 1000, 0, controller.clock)
+	controller.recorder = printer
+	controller.optOut = opts.OptOut || opts.PodSelector != ""
+	controller.namespaces = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, namespace := range namespaces {
+		controller.namespaces.Add(namespace)
+	}
+	if len(alertRules) > 0 {
+		global, _ := utils.Namespace()
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{rules.GVR: "AlertRuleList"}, alertRules...)
+		controller.rules = rules.NewStore(client, "", global)
+		stop := make(chan struct{})
+		defer close(stop)
+		go controller.rules.Run(stop)
+		if !cache.WaitForCacheSync(stop, controller.rules.HasSynced) {
+			return fmt.Errorf("failed to load alert rules")
+		}
+	}
+	podSelector := labels.Everything()
+	if opts.PodSelector != "" {
+		if podSelector, err = labels.Parse(opts.PodSelector); err != nil {
+			return fmt.Errorf("invalid pod selector: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	for _, event := range events {
+		pod := event.pod
+		if !podSelector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if event.eventType == watch.Deleted {
+			controller.clearTimeout(podKey(pod))
+			controller.resolveAlerts(ctx, controller.firing.takePod(podKey(pod)))
+		} else {
+			controller.handlePodUpdate(ctx, pod)
+		}
+		controller.dispatcher.flush(ctx)
+	}
+	if printer.sent == 0 {
+		fmt.Fprintln(out, "No notifications would be sent.")
+	}
+	return nil
+}
+
+// replayItem is an object of a fixture, with the type of the watch event it was recorded in.
+type replayItem struct {
+	Type   watch.EventType
+	Object *unstructured.Unstructured
+}
+
+// readReplayFixture reads the objects of a fixture, expanding lists and watch events.
+func readReplayFixture(path string) ([]replayItem, error) {
+	objects, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []replayItem
+	for _, obj := range objects {
+		eventType, _, _ := unstructured.NestedString(obj.Object, "type")
+		if event, ok, _ := unstructured.NestedMap(obj.Object, "object"); ok && obj.GetKind() == "" {
+			if eventType == string(watch.Bookmark) || eventType == string(watch.Error) {
+				continue
+			}
+			items = append(items, replayItem{Type: watch.EventType(eventType), Object: &unstructured.Unstructured{Object: event}})
+			continue
+		}
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				items = append(items, replayItem{Type: watch.Added, Object: item.(*unstructured.Unstructured)})
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		}
+		items = append(items, replayItem{Type: watch.Added, Object: obj})
+	}
+	return items, nil
+}
+
+// replayPrinter prints the notifications and suppressions of a replay.
+type replayPrinter struct {
+	mu   sync.Mutex
+	out  io.Writer
+	sent int
+}
+
+// replayNotifier prints the alerts delivered to the named notifier.
+type replayNotifier struct {
+	printer *replayPrinter
+	name    string
+}
+
+func (p *replayPrinter) notifier(name string) notify.Notifier {
+	return &replayNotifier{printer: p, name: name}
+}
+
+func (n *replayNotifier) Send(ctx context.Context, alert *notify.Alert) error {
+	p := n.printer
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent++
+	channel := alert.Channel
+	if channel == "" {
+		channel = "(default)"
+	}
+	fmt.Fprintf(p.out, "--- %s/%s/%s via %s to %s\n", alert.Namespace, alert.Pod, alert.Container, n.name, channel)
+	fmt.Fprintf(p.out, "Severity: %s, reason: %s", alert.Severity, alert.Reason)
+	if alert.Priority != "" {
+		fmt.Fprintf(p.out, ", priority: %s", alert.Priority)
+	}
+	if alert.Emoji != "" {
+		fmt.Fprintf(p.out, ", emoji: %s", alert.Emoji)
+	}
+	fmt.Fprintf(p.out, "\n%s\n%s\n", alert.Title, alert.Text)
+	for _, link := range alert.Links {
+		fmt.Fprintf(p.out, "%s: %s\n", link.Title, link.URL)
+	}
+	fmt.Fprintln(p.out)
+	return nil
+}
+
+func (n *replayNotifier) Resolve(ctx context.Context, alert *notify.Alert) error {
+	p := n.printer
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "--- %s/%s/%s resolved via %s\n\n", alert.Namespace, alert.Pod, alert.Container, n.name)
+	return nil
+}
+
+// Event, Eventf and AnnotatedEventf implement record.EventRecorder to print suppressed notifications.
+func (p *replayPrinter) Event(object runtime.Object, eventType, reason, message string) {
+	if reason != eventReasonSuppressed {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := "unknown"
+	if pod, ok := object.(*v1.Pod); ok {
+		name = podKey(pod)
+	}
+	fmt.Fprintf(p.out, "--- %s: %s\n\n", name, strings.TrimSuffix(message, "."))
+}
+
+func (p *replayPrinter) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	p.Event(object, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (p *replayPrinter) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventType, reason, messageFmt string, args ...interface{}) {
+	p.Eventf(object, eventType, reason, messageFmt, args...)
+}
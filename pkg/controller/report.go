@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	"k8s.io/klog/v2"
+)
+
+const (
+	reportWeek = 7 * 24 * time.Hour
+	// reportWorkloads is the number of top crashing workloads listed per channel.
+	reportWorkloads = 5
+)
+
+// crashStats counts alerts and the time until they recovered.
+type crashStats struct {
+	alerts int
+	// recovered is the number of resolved alerts and recovery their total time to recovery.
+	recovered int
+	recovery  time.Duration
+}
+
+func (s *crashStats) add(record *records.Record) {
+	s.alerts++
+	sentAt := record.Spec.SentAt.Time
+	if resolved := record.Status.ResolvedAt; resolved != nil && resolved.After(sentAt) {
+		s.recovered++
+		s.recovery += resolved.Sub(sentAt)
+	}
+}
+
+func (s *crashStats) mttr() string {
+	if s.recovered == 0 {
+		return "-"
+	}
+	return (s.recovery / time.Duration(s.recovered)).Round(time.Minute).String()
+}
+
+// weekStats summarizes the alerts of a channel in a week, in total and per namespace and workload.
+// As there is no digest mode batching alerts, the weekly report is the digest these statistics
+// are presented in.
+type weekStats struct {
+	crashStats
+	namespaces map[string]*crashStats
+	workloads  map[string]*crashStats
+}
+
+func (s *weekStats) add(record *records.Record) {
+	if s.namespaces == nil {
+		s.namespaces = make(map[string]*crashStats)
+		s.workloads = make(map[string]*crashStats)
+	}
+	spec := &record.Spec
+	workload := spec.Workload
+	if workload == "" {
+		workload = "Pod/" + spec.Pod
+	}
+	s.crashStats.add(record)
+	addStats(s.namespaces, spec.Namespace, record)
+	addStats(s.workloads, spec.Namespace+"/"+workload, record)
+}
+
+func addStats(groups map[string]*crashStats, key string, record *records.Record) {
+	stats, ok := groups[key]
+	if !ok {
+		stats = &crashStats{}
+		groups[key] = stats
+	}
+	stats.add(record)
+}
+
+// channelReport compares the alerts of a channel in the reported week to the week before.
+type channelReport struct {
+	current, previous weekStats
+	// notifiers are the names of the notifiers the alerts have been sent to the channel with.
+	notifiers []string
+}
+
+// uniqueAlerts returns the records of the alerts, counting alerts sent via multiple notifiers
+// once and leaving out synthetic ones.
+func uniqueAlerts(list []records.Record) []*records.Record {
+	var alerts []*records.Record
+	seen := make(map[string]bool)
+	for i := range list {
+		spec := &list[i].Spec
+		key := fmt.Sprintf("%s/%d", spec.Fingerprint, spec.SentAt.Unix())
+		if seen[key] || spec.Namespace == syntheticNamespace {
+			continue
+		}
+		seen[key] = true
+		alerts = append(alerts, &list[i])
+	}
+	return alerts
+}
+
+// buildWeeklyReports summarizes the records of alerts sent in the week before end and the week
+// before that per channel.
+func buildWeeklyReports(list []records.Record, end time.Time) map[string]*channelReport {
+	reports := make(map[string]*channelReport)
+	inReport := func(spec *records.Spec) bool {
+		age := end.Sub(spec.SentAt.Time)
+		return age > 0 && age <= 2*reportWeek && spec.Namespace != syntheticNamespace
+	}
+	for i := range list {
+		spec := &list[i].Spec
+		if !inReport(spec) {
+			continue
+		}
+		report, ok := reports[spec.Channel]
+		if !ok {
+			report = &channelReport{}
+			reports[spec.Channel] = report
+		}
+		if !containsString(report.notifiers, spec.Notifier) {
+			report.notifiers = append(report.notifiers, spec.Notifier)
+		}
+	}
+	for _, record := range uniqueAlerts(list) {
+		spec := &record.Spec
+		if !inReport(spec) {
+			continue
+		}
+		report := reports[spec.Channel]
+		if end.Sub(spec.SentAt.Time) <= reportWeek {
+			report.current.add(record)
+		} else {
+			report.previous.add(record)
+		}
+	}
+	return reports
+}
+
+// message renders the report of the week ending at end.
+func (r *channelReport) message(end time.Time, location *time.Location) string {
+	var buf bytes.Buffer
+	start := end.Add(-reportWeek)
+	fmt.Fprintf(&buf, "#### Weekly reliability report\n%s to %s\n\n", start.In(location).Format("Mon, 2 Jan 15:04"),
+		end.In(location).Format("Mon, 2 Jan 15:04 MST"))
+	buf.WriteString("| | This week | Previous week |\n|:--|--:|--:|\n")
+	fmt.Fprintf(&buf, "| Alerts | %d | %d (%s) |\n", r.current.alerts, r.previous.alerts, change(r.current.alerts, r.previous.alerts))
+	fmt.Fprintf(&buf, "| Mean time to recovery | %s | %s |\n", r.current.mttr(), r.previous.mttr())
+	if r.current.alerts == 0 {
+		buf.WriteString("\nNo alerts this week.")
+		return buf.String()
+	}
+	buf.WriteString("\n##### Namespaces\n| Namespace | Alerts | Previous week | Mean time to recovery |\n|:--|--:|--:|--:|\n")
+	for _, namespace := range rankStats(r.current.namespaces, 0) {
+		stats := r.current.namespaces[namespace]
+		previous := 0
+		if p, ok := r.previous.namespaces[namespace]; ok {
+			previous = p.alerts
+		}
+		fmt.Fprintf(&buf, "| %s | %d | %d (%s) | %s |\n", namespace, stats.alerts, previous, change(stats.alerts, previous), stats.mttr())
+	}
+	buf.WriteString("\n##### Top crashing workloads\n| Workload | Alerts | Mean time to recovery |\n|:--|--:|--:|\n")
+	for _, workload := range rankStats(r.current.workloads, reportWorkloads) {
+		stats := r.current.workloads[workload]
+		fmt.Fprintf(&buf, "| `%s` | %d | %s |\n", workload, stats.alerts, stats.mttr())
+	}
+	return buf.String()
+}
+
+// rankStats returns the keys of the stats with the most alerts first, at most limit keys unless 0.
+func rankStats(stats map[string]*crashStats, limit int) []string {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if a, b := stats[keys[i]].alerts, stats[keys[j]].alerts; a != b {
+			return a > b
+		}
+		return keys[i] < keys[j]
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// change describes the relative change from previous to current, e.g. +50%.
+func change(current, previous int) string {
+	if previous == 0 {
+		if current == 0 {
+			return "±0%"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+d%%", (current-previous)*100/previous)
+}
+
+// nextReport returns the first time after now the report is scheduled at.
+func nextReport(now time.Time, weekday time.Weekday, hour int, location *time.Location) time.Time {
+	local := now.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, location)
+	next = next.AddDate(0, 0, (int(weekday)-int(local.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// runWeeklyReports posts the weekly reports on schedule until stopCh is closed.
+func (c *Controller) runWeeklyReports(stopCh <-chan struct{}) {
+	c.runSchedule("weekly report", func(cfg *config.Config) config.Report { return cfg.WeeklyReport }, c.postWeeklyReports, stopCh)
+}
+
+// runSchedule calls post with the scheduled time whenever the report returned by schedule is due,
+// until stopCh is closed. The schedule is looked up again after each post to pick up reloads.
+func (c *Controller) runSchedule(name string, schedule func(*config.Config) config.Report,
+	post func(context.Context, time.Time) error, stopCh <-chan struct{}) {
+	for {
+		cfg, _ := c.settings()
+		report := schedule(cfg)
+		next := nextReport(c.clock.Now(), report.Day(), report.Hour, cfg.Location(""))
+		klog.V(2).InfoS("Scheduled report", "report", name, "time", next)
+		select {
+		case <-stopCh:
+			return
+		case <-c.clock.After(next.Sub(c.clock.Now())):
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		if err := post(ctx, next); err != nil {
+			klog.ErrorS(err, "Posting report failed", "report", name)
+		}
+		cancel()
+	}
+}
+
+// postWeeklyReports posts the report of the week ending at end to each channel alerts have been
+// sent to in the last two weeks, via the chat notifiers the alerts were routed to. With sharding,
+// only the first shard posts the reports, as all shards share the records.
+func (c *Controller) postWeeklyReports(ctx context.Context, end time.Time) error {
+	if !c.runsGlobalTasks() {
+		klog.V(2).InfoS("Leaving the weekly report to the first shard")
+		return nil
+	}
+	list, err := c.records.List(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, _ := c.settings()
+	for channel, report := range buildWeeklyReports(list, end) {
+		message := report.message(end, cfg.Location(channel))
+		for _, name := range report.notifiers {
+			messenger, ok := c.notifier(name).(notify.Messenger)
+			if !ok {
+				continue
+			}
+			if err := messenger.Message(ctx, channel, message); err != nil {
+				klog.ErrorS(err, "Posting weekly report failed", "channel", channel, "notifier", name)
+				continue
+			}
+			klog.InfoS("Posted weekly report", "channel", channel, "notifier", name, "alerts", report.current.alerts)
+		}
+	}
+	return nil
+}
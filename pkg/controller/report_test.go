@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildWeeklyReports(t *testing.T) {
+	end := testStart
+	record := func(namespace, channel, notifier string, age time.Duration) records.Record {
+		return records.Record{Spec: records.Spec{
+			Fingerprint: namespace + "/api",
+			Namespace:   namespace,
+			Pod:         "api",
+			Channel:     channel,
+			Notifier:    notifier,
+			SentAt:      metav1.NewTime(end.Add(-age)),
+		}}
+	}
+	list := []records.Record{
+		// An alert sent via two notifiers counts once.
+		record("shop", "alerts", "mattermost", time.Hour),
+		record("shop", "alerts", "pagerduty", time.Hour),
+		record("shop", "alerts", "mattermost", 8*24*time.Hour),
+		record("apps", "apps", "slack", 2*time.Hour),
+		record(syntheticNamespace, "ops", "mattermost", time.Hour),
+		record("old", "old", "mattermost", 15*24*time.Hour),
+	}
+
+	reports := buildWeeklyReports(list, end)
+	var channels []string
+	for channel := range reports {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	if !reflect.DeepEqual(channels, []string{"alerts", "apps"}) {
+		t.Fatalf("reports for channels %v, want alerts and apps", channels)
+	}
+	alerts := reports["alerts"]
+	if alerts.current.alerts != 1 || alerts.previous.alerts != 1 {
+		t.Errorf("alerts channel has %d alerts this week and %d the week before, want 1 and 1", alerts.current.alerts, alerts.previous.alerts)
+	}
+	if !reflect.DeepEqual(alerts.notifiers, []string{"mattermost", "pagerduty"}) {
+		t.Errorf("alerts channel report is sent via %v, want the notifiers of its alerts", alerts.notifiers)
+	}
+}
@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"k8s.io/klog/v2"
+)
+
+// firingAlert is an alert which has been sent but not resolved yet.
+type firingAlert struct {
+	alert     *notify.Alert
+	notifiers []string
+	// since is the time of the first alert, which is kept when the alert is repeated.
+	since time.Time
+	// recoveredAt is set while the container is ready, but not for long enough to resolve the
+	// alert. lastRecovery is the start of the last recovery, which is cleared once the container
+	// crashed again.
+	recoveredAt  time.Time
+	lastRecovery time.Time
+	// relapses are the times the container crashed again while recovering, flapping is set once
+	// they have been notified.
+	relapses []time.Time
+	flapping bool
+}
+
+// firingAlerts keeps the sent alerts per namespace/pod/container key until they are resolved.
+type firingAlerts struct {
+	mu     sync.Mutex
+	alerts map[string]firingAlert
+}
+
+func (f *firingAlerts) add(alert *notify.Alert, notifiers []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.alerts == nil {
+		f.alerts = make(map[string]firingAlert)
+	}
+	key := alert.Namespace + "/" + alert.Pod + "/" + alert.Container
+	firing := firingAlert{alert: alert, notifiers: notifiers, since: alert.Time}
+	if previous, ok := f.alerts[key]; ok && previous.alert.Fingerprint == alert.Fingerprint {
+		firing.since = previous.since
+		firing.lastRecovery = previous.lastRecovery
+		firing.relapses = previous.relapses
+		firing.flapping = previous.flapping
+	}
+	f.alerts[key] = firing
+}
+
+// recover marks the alert of the container with the given key as recovering, unless it already is.
+func (f *firingAlerts) recover(key string, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	firing, ok := f.alerts[key]
+	if !ok || !firing.recoveredAt.IsZero() {
+		return
+	}
+	firing.recoveredAt, firing.lastRecovery = now, now
+	f.alerts[key] = firing
+}
+
+// relapse stops the recovery of the container with the given key, which is not ready anymore. If
+// it crashed at crashedAt after its last recovery, this is counted as relapse. It returns the
+// alert and true once the container relapsed threshold times within window, i.e. started flapping.
+func (f *firingAlerts) relapse(key string, crashedAt, now time.Time, threshold int, window time.Duration) (firingAlert, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	firing, ok := f.alerts[key]
+	if !ok {
+		return firingAlert{}, false
+	}
+	firing.recoveredAt = time.Time{}
+	if !firing.lastRecovery.IsZero() && crashedAt.After(firing.lastRecovery) {
+		firing.lastRecovery = time.Time{}
+		var relapses []time.Time
+		for _, relapse := range firing.relapses {
+			if now.Sub(relapse) < window {
+				relapses = append(relapses, relapse)
+			}
+		}
+		firing.relapses = append(relapses, now)
+	}
+	started := threshold > 0 && !firing.flapping && len(firing.relapses) >= threshold
+	if started {
+		firing.flapping = true
+	}
+	f.alerts[key] = firing
+	return firing, started
+}
+
+// isFlapping checks if the container with the given key has been notified as flapping and
+// relapsed within the window. Once it did not relapse for the window, e.g. because it went from
+// flapping into a crash loop and is never ready anymore, it stops flapping, so that it is alerted
+// about again.
+func (f *firingAlerts) isFlapping(key string, now time.Time, window time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	firing, ok := f.alerts[key]
+	if !ok || !firing.flapping {
+		return false
+	}
+	if n := len(firing.relapses); n > 0 && now.Sub(firing.relapses[n-1]) < window {
+		return true
+	}
+	firing.flapping = false
+	firing.relapses = nil
+	f.alerts[key] = firing
+	return false
+}
+
+// hasPod checks if any container of the pod with the given namespace/name key has a firing alert.
+func (f *firingAlerts) hasPod(pod string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key := range f.alerts {
+		if strings.HasPrefix(key, pod+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// takeRecovered removes and returns the alerts of the containers which have been recovering since
+// before the given time.
+func (f *firingAlerts) takeRecovered(before time.Time) []firingAlert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var alerts []firingAlert
+	for key, alert := range f.alerts {
+		if !alert.recoveredAt.IsZero() && !alert.recoveredAt.After(before) {
+			alerts = append(alerts, alert)
+			delete(f.alerts, key)
+		}
+	}
+	return alerts
+}
+
+// list returns the firing alerts, the most recent first.
+func (f *firingAlerts) list() []firingAlert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	alerts := make([]firingAlert, 0, len(f.alerts))
+	for _, alert := range f.alerts {
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].alert.Time.After(alerts[j].alert.Time) })
+	return alerts
+}
+
+// get returns the firing alert with the given fingerprint.
+func (f *firingAlerts) get(fingerprint string) (firingAlert, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, alert := range f.alerts {
+		if alert.alert.Fingerprint == fingerprint {
+			return alert, true
+		}
+	}
+	return firingAlert{}, false
+}
+
+// snapshot returns the firing alerts of the namespaces for which owns returns true by key.
+func (f *firingAlerts) snapshot(owns func(namespace string) bool) map[string]persistedFiring {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	alerts := make(map[string]persistedFiring)
+	for key, firing := range f.alerts {
+		if owns(firing.alert.Namespace) {
+			alerts[key] = persistedFiring{
+				Alert:        firing.alert,
+				Notifiers:    firing.notifiers,
+				Since:        firing.since,
+				RecoveredAt:  firing.recoveredAt,
+				LastRecovery: firing.lastRecovery,
+				Relapses:     firing.relapses,
+				Flapping:     firing.flapping,
+			}
+		}
+	}
+	return alerts
+}
+
+// restore adds the persisted alerts, keeping those which are already firing.
+func (f *firingAlerts) restore(alerts map[string]persistedFiring) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.alerts == nil {
+		f.alerts = make(map[string]firingAlert)
+	}
+	for key, persisted := range alerts {
+		if _, ok := f.alerts[key]; ok || persisted.Alert == nil {
+			continue
+		}
+		f.alerts[key] = firingAlert{
+			alert:        persisted.Alert,
+			notifiers:    persisted.Notifiers,
+			since:        persisted.Since,
+			recoveredAt:  persisted.RecoveredAt,
+			lastRecovery: persisted.LastRecovery,
+			relapses:     persisted.Relapses,
+			flapping:     persisted.Flapping,
+		}
+	}
+}
+
+// take removes and returns the alert of the container with the given key.
+func (f *firingAlerts) take(key string) []firingAlert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	alert, ok := f.alerts[key]
+	if !ok {
+		return nil
+	}
+	delete(f.alerts, key)
+	return []firingAlert{alert}
+}
+
+// takePod removes and returns the alerts of all containers of the pod with the given namespace/name key.
+func (f *firingAlerts) takePod(pod string) []firingAlert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var alerts []firingAlert
+	for key, alert := range f.alerts {
+		if strings.HasPrefix(key, pod+"/") {
+			alerts = append(alerts, alert)
+			delete(f.alerts, key)
+		}
+	}
+	return alerts
+}
+
+// resolveAlerts notifies all notifiers of the alerts which support resolving them. The other
+// notifiers receive a resolved message if sendResolved is configured.
+func (c *Controller) resolveAlerts(ctx context.Context, alerts []firingAlert) {
+	cfg, _ := c.settings()
+	for _, firing := range alerts {
+		// The time to recovery is measured from the first alert of the container.
+		alert := *firing.alert
+		alert.RecoveredAfter = c.clock.Since(firing.since).Round(time.Second)
+		if !alert.Synthetic {
+			recoveryDuration.WithLabelValues(alert.Severity).Observe(alert.RecoveredAfter.Seconds())
+		}
+		c.publish(stream.EventResolved, &alert, "", nil)
+		c.escalations.cancel(alert.Fingerprint)
+		if c.records != nil && !alert.Synthetic {
+			fingerprint, resolvedAt := alert.Fingerprint, c.clock.Now()
+			c.queueRecordWrite("resolve", func(ctx context.Context) error {
+				return c.records.Resolve(ctx, fingerprint, resolvedAt)
+			})
+		}
+		// Resolutions are delivered besides the alerts, so they are neither counted nor spooled as
+		// alerts, unless the alert itself is still spooled.
+		for _, name := range firing.notifiers {
+			klog.InfoS("Resolving alert", "pod", klog.KRef(alert.Namespace, alert.Pod),
+				"container", alert.Container, "notifier", name, "recoveredAfter", alert.RecoveredAfter)
+			notifier := c.notifier(name)
+			if resolver, ok := notifier.(notify.Resolver); ok {
+				if !c.spoolResolve(name, &alert) {
+					c.enqueueDelivery(ctx, "resolve", name, &alert, resolver.Resolve, nil)
+				}
+			} else if cfg.SendResolved && notifier != nil {
+				if resolved := resolvedMessage(cfg, &alert); !c.spoolResolve(name, resolved) {
+					c.enqueueDelivery(ctx, "resolve", name, resolved, notifier.Send, nil)
+				}
+			}
+		}
+	}
+}
+
+// resolvedMessage returns the message announcing the recovery of the alert.
+func resolvedMessage(cfg *config.Config, alert *notify.Alert) *notify.Alert {
+	resolved := *alert
+	resolved.Title = cfg.Catalogs.Translate(alert.Locale, "Resolved") + ": " + alert.Title
+	resolved.Text = cfg.Catalogs.Sprintf(alert.Locale, "Container %s of pod %s recovered after %s.", alert.Container, alert.Pod, alert.RecoveredAfter)
+	resolved.Logs = ""
+	resolved.Priority = ""
+	resolved.IncidentURL = ""
+	if color, ok := cfg.Colors["resolved"]; ok {
+		resolved.Color = color
+	}
+	return &resolved
+}
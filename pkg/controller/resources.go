@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// resourceProblem describes why a watched resource is unhealthy.
+type resourceProblem struct {
+	// Reason identifies the problem, a resource is notified again if its reason changes.
+	Reason string
+	Title  string
+	Text   string
+	Links  []notify.Link
+	// Namespace is used for routing instead of the namespace of the resource, if set.
+	Namespace string
+}
+
+// resourceCheck reports the problems of resources of a kind, nil if a resource is healthy.
+type resourceCheck struct {
+	gvr  schema.GroupVersionResource
+	kind string
+	// gracePeriod is how long a problem has to persist before it is notified.
+	gracePeriod time.Duration
+	// unfiltered also checks resources outside the watched namespaces, e.g. in a central namespace.
+	unfiltered bool
+	check      func(ctx context.Context, obj *unstructured.Unstructured) *resourceProblem
+}
+
+// resourceState tracks the problem of an unhealthy resource.
+type resourceState struct {
+	problem *resourceProblem
+	since   time.Time
+	// firing is set once the problem has been notified.
+	firing *firingAlert
+}
+
+// resourceWatcher notifies about problems of watched resources and resolves them once the
+// resources are healthy again.
+type resourceWatcher struct {
+	controller *Controller
+	check      resourceCheck
+	informer   cache.SharedIndexInformer
+
+	mu     sync.Mutex
+	states map[string]*resourceState
+}
+
+// watchResources adds a watcher for the resources of the check in the given namespace, or all
+// namespaces if metav1.NamespaceAll is given. It must be called before the controller is run.
+func (c *Controller) watchResources(client dynamic.Interface, namespace string, check resourceCheck) {
+	// Resyncs re-evaluate problems whose grace period has passed without further updates.
+	resync := time.Duration(0)
+	if check.gracePeriod > 0 {
+		resync = time.Minute
+		if check.gracePeriod < resync {
+			resync = check.gracePeriod
+		}
+	}
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, resync, namespace, nil)
+	w := &resourceWatcher{
+		controller: c,
+		check:      check,
+		informer:   factory.ForResource(check.gvr).Informer(),
+		states:     make(map[string]*resourceState),
+	}
+	w.informer.SetTransform(stripObject)
+	w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.update,
+		UpdateFunc: func(old, new interface{}) { w.update(new) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if resource, ok := obj.(*unstructured.Unstructured); ok {
+				w.resolve(resource.GetNamespace() + "/" + resource.GetName())
+			}
+		},
+	})
+	c.resourceWatchers = append(c.resourceWatchers, w)
+}
+
+// watchesNamespace checks if the pods of the namespace are watched by this replica.
+func (c *Controller) watchesNamespace(namespace string) bool {
+	return c.podIndexer(namespace) != nil && c.ownsNamespace(namespace)
+}
+
+// handles checks if this replica handles the resource. Resources outside the watched namespaces
+// are handled by the replica owning their namespace, so that they are notified only once.
+func (w *resourceWatcher) handles(resource *unstructured.Unstructured) bool {
+	if !w.check.unfiltered {
+		return w.controller.watchesNamespace(resource.GetNamespace())
+	}
+	return w.controller.shard == nil || w.controller.shard.Owns(resource.GetNamespace())
+}
+
+func (w *resourceWatcher) update(obj interface{}) {
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok || !w.handles(resource) {
+		return
+	}
+	key := resource.GetNamespace() + "/" + resource.GetName()
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	problem := w.check.check(ctx, resource)
+	if problem == nil {
+		w.resolve(key)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.states[key]
+	if !ok || state.problem.Reason != problem.Reason {
+		state = &resourceState{problem: problem, since: w.controller.clock.Now()}
+		w.states[key] = state
+	}
+	state.problem = problem
+	if state.firing != nil || w.controller.clock.Since(state.since) < w.check.gracePeriod {
+		return
+	}
+	state.firing = w.controller.sendResourceNotification(ctx, w.check.kind, resource, problem)
+}
+
+// resolve forgets the problem of the resource with the given key and resolves its alert.
+func (w *resourceWatcher) resolve(key string) {
+	w.mu.Lock()
+	state, ok := w.states[key]
+	delete(w.states, key)
+	w.mu.Unlock()
+	if !ok || state.firing == nil {
+		return
+	}
+	klog.InfoS("Resource is healthy again", "kind", w.check.kind, "resource", key)
+	w.controller.resolveAlerts(context.Background(), []firingAlert{*state.firing})
+}
+
+// sendResourceNotification notifies about the problem of a resource using the routes of the
+// configuration. The channel, severity and message annotations of the resource are respected.
+func (c *Controller) sendResourceNotification(ctx context.Context, kind string, resource *unstructured.Unstructured, problem *resourceProblem) *firingAlert {
+	cfg, _ := c.settings()
+	severity := cfg.DefaultSeverity
+	if value := resource.GetAnnotations()[annotationMattermostSeverity]; value != "" {
+		if _, err := ParseSeverity(value); err == nil {
+			severity = value
+		}
+	}
+	workload := kind + "/" + resource.GetName()
+	namespace := problem.Namespace
+	if namespace == "" {
+		namespace = resource.GetNamespace()
+	}
+	alert := &notify.Alert{
+		Time:        c.clock.Now(),
+		Fingerprint: c.fingerprint(resource.GetNamespace(), workload, ""),
+		Namespace:   namespace,
+		Workload:    workload,
+		Reason:      problem.Reason,
+		Severity:    severity,
+		Title:       problem.Title,
+		Text:        withCustomMessage(resource.GetAnnotations()[annotationMattermostMessage], problem.Text),
+		Links:       problem.Links,
+		Channel:     resource.GetAnnotations()[annotationMattermostChannel],
+		Priority:    cfg.Priorities[severity],
+	}
+	locale := resource.GetAnnotations()[annotationMattermostLocale]
+	if locale == "" {
+		locale = c.namespaceAnnotation(namespace, annotationMattermostLocale)
+	}
+	alert.Locale = c.locale(cfg, nil, namespace, locale)
+	c.identify(cfg, alert)
+	channel, names := cfg.Routing(alert.Time, alert.Namespace, severity, problem.Reason)
+	if alert.Channel == "" {
+		alert.Channel = channel
+	}
+	if emoji, ok := cfg.Emojis[problem.Reason]; ok {
+		alert.Emoji = emoji
+	} else {
+		alert.Emoji = cfg.Emojis[severity]
+	}
+	if icon, ok := cfg.Icons[problem.Reason]; ok {
+		alert.IconURL = icon
+	} else {
+		alert.IconURL = cfg.Icons[severity]
+	}
+	if color, ok := cfg.Colors[problem.Reason]; ok {
+		alert.Color = color
+	} else {
+		alert.Color = cfg.Colors[severity]
+	}
+	klog.InfoS("Sending resource notification", "kind", kind, "resource", klog.KObj(resource),
+		"reason", problem.Reason, "channel", alert.Channel, "notifiers", names)
+	c.publish(stream.EventDetected, alert, "", nil)
+	recordHistory := c.history.recordOnDelivery(alertRecord{
+		Time:      alert.Time,
+		Namespace: alert.Namespace,
+		Pod:       workload,
+		Reason:    problem.Reason,
+	})
+	for _, name := range names {
+		name := name
+		recordNotification := func(postID string, err error) {
+			if err == nil {
+				c.recordNotification(alert, name, postID)
+			}
+			recordHistory(postID, err)
+		}
+		c.enqueueAlert(ctx, name, alert, recordNotification)
+	}
+	return &firingAlert{alert: alert, notifiers: names}
+}
+
+// runResourceWatchers runs the resource watchers until stopCh is closed and their handlers returned.
+func (c *Controller) runResourceWatchers(stopCh <-chan struct{}) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for _, w := range c.resourceWatchers {
+		wg.Add(1)
+		go func(w *resourceWatcher) {
+			defer wg.Done()
+			w.informer.Run(stopCh)
+		}(w)
+	}
+	return &wg
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// rolloutGVR identifies Argo Rollouts.
+var rolloutGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+
+// rolloutAnalysisPaths are the status fields of the analysis runs of canary and blue-green rollouts.
+var rolloutAnalysisPaths = [][]string{
+	{"status", "canary", "currentStepAnalysisRunStatus"},
+	{"status", "canary", "currentBackgroundAnalysisRunStatus"},
+	{"status", "blueGreen", "prePromotionAnalysisRunStatus"},
+	{"status", "blueGreen", "postPromotionAnalysisRunStatus"},
+}
+
+// rolloutCheck notifies about Argo Rollouts which are degraded or whose analysis failed.
+func (c *Controller) rolloutCheck() resourceCheck {
+	return resourceCheck{gvr: rolloutGVR, kind: "Rollout", check: c.checkRollout}
+}
+
+func (c *Controller) checkRollout(ctx context.Context, rollout *unstructured.Unstructured) *resourceProblem {
+	var reason, message string
+	if phase, _, _ := unstructured.NestedString(rollout.Object, "status", "phase"); phase == "Degraded" {
+		reason = "RolloutDegraded"
+		message, _, _ = unstructured.NestedString(rollout.Object, "status", "message")
+	}
+	for _, path := range rolloutAnalysisPaths {
+		run, ok, _ := unstructured.NestedStringMap(rollout.Object, path...)
+		if !ok || (run["status"] != "Failed" && run["status"] != "Error") {
+			continue
+		}
+		if reason == "" {
+			reason = "AnalysisRunFailed"
+		}
+		message += fmt.Sprintf("\nAnalysis run %s: %s", run["name"], run["message"])
+	}
+	if reason == "" {
+		return nil
+	}
+	text := fmt.Sprintf("Rollout %s in namespace %s is degraded: %s", rollout.GetName(), rollout.GetNamespace(), strings.TrimSpace(message))
+	stableHash, _, _ := unstructured.NestedString(rollout.Object, "status", "stableRS")
+	canaryHash, _, _ := unstructured.NestedString(rollout.Object, "status", "currentPodHash")
+	if stable := c.replicaSetImages(ctx, rollout, stableHash); stable != "" {
+		text += "\n\nStable: " + stable
+	}
+	if canaryHash != stableHash {
+		if canary := c.replicaSetImages(ctx, rollout, canaryHash); canary != "" {
+			text += "\nCanary: " + canary
+		}
+	}
+	return &resourceProblem{
+		Reason: reason,
+		Title:  fmt.Sprintf("Rollout %s is degraded", rollout.GetName()),
+		Text:   text,
+	}
+}
+
+// replicaSetImages lists the container images of the ReplicaSet of a rollout with the given pod template hash.
+func (c *Controller) replicaSetImages(ctx context.Context, rollout *unstructured.Unstructured, hash string) string {
+	if hash == "" {
+		return ""
+	}
+	replicaSet, err := c.clientset.AppsV1().ReplicaSets(rollout.GetNamespace()).Get(ctx, rollout.GetName()+"-"+hash, metav1.GetOptions{})
+	if err != nil {
+		klog.ErrorS(err, "Fetching ReplicaSet of rollout failed", "rollout", klog.KObj(rollout), "hash", hash)
+		return ""
+	}
+	var images []string
+	for _, container := range replicaSet.Spec.Template.Spec.Containers {
+		images = append(images, fmt.Sprintf("`%s`", container.Image))
+	}
+	return strings.Join(images, ", ")
+}
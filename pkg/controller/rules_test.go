@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lnsp/mattermost-informer/pkg/rules"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestMinRestarts(t *testing.T) {
+	c, _ := newTestController(t)
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+	crashing := &v1.ContainerStatus{
+		Name:         "api",
+		RestartCount: 2,
+		State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}
+	tests := []struct {
+		name       string
+		annotation string
+		rule       *rules.Rule
+		want       bool
+	}{
+		{name: "default", rule: rules.Default, want: true},
+		{name: "annotation", annotation: "3", rule: rules.Default, want: false},
+		{name: "invalid annotation", annotation: "many", rule: rules.Default, want: true},
+		{name: "rule", annotation: "1", rule: &rules.Rule{Name: "strict", Selector: rules.Default.Selector, Reasons: rules.Default.Reasons, MinRestarts: 5}, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}
+			if test.annotation != "" {
+				pod.Annotations = map[string]string{annotationMattermostMinRestarts: test.annotation}
+			}
+			rule := c.withMinRestarts(context.Background(), pod, test.rule)
+			if got := rule.Matches(pod, crashing); got != test.want {
+				t.Errorf("rule matches %v, want %v", got, test.want)
+			}
+		})
+	}
+	if rules.Default.MinRestarts != 0 {
+		t.Errorf("default rule was changed to require %d restarts", rules.Default.MinRestarts)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("got %d events about the invalid annotation, want 1", len(recorder.Events))
+	}
+}
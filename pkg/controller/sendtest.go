@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// TestAlert describes the synthetic crash sent by SendTest.
+type TestAlert struct {
+	Namespace string
+	Pod       string
+	Container string
+	Reason    string
+	Severity  string
+}
+
+// SendTest sends a synthetic crash notification using the configuration with its routes and
+// templates, and waits until all routed notifiers delivered it. It returns the names of the
+// notifiers, or an error listing every notifier that failed.
+func SendTest(opts Options, test TestAlert) ([]string, error) {
+	cfg, err := opts.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var now clock.PassiveClock = clock.RealClock{}
+	if opts.Clock != nil {
+		now = opts.Clock
+	}
+	alert, names, err := newTestAlert(cfg, test, now.Now())
+	if err != nil {
+		return nil, err
+	}
+	mattermost, err := utils.NewMattermostClient(cfg.Mattermost)
+	if err != nil {
+		return nil, err
+	}
+	notifiers, err := newNotifiers(cfg, mattermost, now)
+	if err != nil {
+		return nil, err
+	}
+	var failed []string
+	for _, name := range names {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		err := notifiers[name].Send(ctx, alert)
+		cancel()
+		if err != nil {
+			klog.ErrorS(err, "Sending test notification failed", "notifier", name, "channel", alert.Channel)
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		klog.InfoS("Sent test notification", "notifier", name, "channel", alert.Channel)
+	}
+	if len(failed) > 0 {
+		return names, fmt.Errorf("test notification failed for %s", strings.Join(failed, "; "))
+	}
+	return names, nil
+}
+
+// newTestAlert renders the synthetic crash at the given time and returns it with the names of the
+// notifiers it is routed to.
+func newTestAlert(cfg *config.Config, test TestAlert, now time.Time) (*notify.Alert, []string, error) {
+	severity, err := ParseSeverity(test.Severity)
+	if err != nil {
+		return nil, nil, err
+	}
+	const restartCount = 5
+	channel, names := cfg.Routing(now, test.Namespace, severity.String(), test.Reason)
+	cluster, environment := cfg.Cluster.Identity("")
+	title, text, err := cfg.Templates.Render(cfg.Catalogs, cfg.Locale, &alertData{
+		Namespace:    test.Namespace,
+		Pod:          test.Pod,
+		Container:    test.Container,
+		Reason:       test.Reason,
+		Severity:     severity.String(),
+		RestartCount: restartCount,
+		Cluster:      cluster,
+		Environment:  environment,
+		Time:         now.In(cfg.Location(channel)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	alert := &notify.Alert{
+		Time:         now,
+		Fingerprint:  notify.Fingerprint(test.Namespace, test.Pod, test.Container),
+		Namespace:    test.Namespace,
+		Pod:          test.Pod,
+		Container:    test.Container,
+		Workload:     "Pod/" + test.Pod,
+		Reason:       test.Reason,
+		Severity:     severity.String(),
+		RestartCount: restartCount,
+		Title:        title,
+		Text:         text,
+		Logs:         "This is a test notification sent by mattermost-informer send-test.\n",
+		Channel:      channel,
+		Priority:     cfg.Priorities[severity.String()],
+		Emoji:        cfg.Emojis[severity.String()],
+		IconURL:      cfg.Icons[severity.String()],
+		Color:        cfg.Colors[severity.String()],
+		Locale:       cfg.Locale,
+	}
+	(&Controller{}).identify(cfg, alert)
+	return alert, names, nil
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Severity describes how urgent an alert is.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// ParseSeverity parses a severity name like "warning".
+func ParseSeverity(name string) (Severity, error) {
+	for severity, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return severity, nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q", name)
+}
+
+const annotationMattermostSeverity = "espe.tech/mattermost-severity"
+
+func (c *Controller) severity(ctx context.Context, pod *v1.Pod) Severity {
+	cfg, _ := c.settings()
+	fallback, err := ParseSeverity(cfg.DefaultSeverity)
+	if err != nil {
+		fallback = SeverityWarning
+	}
+	value := c.annotation(ctx, pod, annotationMattermostSeverity)
+	if value == "" {
+		return fallback
+	}
+	severity, err := ParseSeverity(value)
+	if err != nil {
+		klog.ErrorS(err, "Pod has invalid severity annotation", "pod", klog.KObj(pod))
+		return fallback
+	}
+	return severity
+}
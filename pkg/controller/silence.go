@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/api"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// silencesKey holds the silences in the state ConfigMap.
+const silencesKey = "silences.json"
+
+// silences suppress the notifications of matching containers until they expire.
+type silences struct {
+	clock clock.PassiveClock
+
+	mu    sync.Mutex
+	items map[string]api.Silence
+}
+
+// update applies the change to the silences and reports whether it changed them.
+func (s *silences) update(change func(items map[string]api.Silence) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = make(map[string]api.Silence)
+	}
+	return change(s.items)
+}
+
+// replace sets the silences to the persisted ones.
+func (s *silences) replace(items map[string]api.Silence) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = items
+}
+
+// list returns the active silences, those expiring first first.
+func (s *silences) list() []api.Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireSilences(s.items, s.clock.Now())
+	active := make([]api.Silence, 0, len(s.items))
+	for _, silence := range s.items {
+		active = append(active, silence)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Until.Before(active[j].Until) })
+	return active
+}
+
+// match returns the active silence matching the container, if any.
+func (s *silences) match(namespace, pod, container string) (api.Silence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireSilences(s.items, s.clock.Now())
+	for _, silence := range s.items {
+		if silence.Namespace != "" && silence.Namespace != namespace {
+			continue
+		}
+		if silence.Container != "" && silence.Container != container {
+			continue
+		}
+		if matched, _ := path.Match(silence.Pod, pod); silence.Pod != "" && !matched {
+			continue
+		}
+		return silence, true
+	}
+	return api.Silence{}, false
+}
+
+// expireSilences deletes the expired silences and reports whether there were any.
+func expireSilences(items map[string]api.Silence, now time.Time) bool {
+	expired := false
+	for id, silence := range items {
+		if !silence.Until.After(now) {
+			delete(items, id)
+			expired = true
+		}
+	}
+	return expired
+}
+
+// createSilence stores the silence under a new ID and returns it.
+func (c *Controller) createSilence(silence api.Silence) (api.Silence, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return api.Silence{}, fmt.Errorf("could not generate silence ID: %v", err)
+	}
+	silence.ID = hex.EncodeToString(id)
+	_, err := c.updateSilences(func(items map[string]api.Silence) bool {
+		items[silence.ID] = silence
+		return true
+	})
+	return silence, err
+}
+
+// deleteSilence deletes the silence with the given ID and reports whether it existed.
+func (c *Controller) deleteSilence(id string) (bool, error) {
+	return c.updateSilences(func(items map[string]api.Silence) bool {
+		_, ok := items[id]
+		delete(items, id)
+		return ok
+	})
+}
+
+// syncSilences adopts the silences persisted in the state ConfigMap, e.g. by other replicas.
+func (c *Controller) syncSilences() error {
+	_, err := c.updateSilences(func(map[string]api.Silence) bool { return false })
+	return err
+}
+
+// updateSilences applies the change to the silences and reports whether it changed them. With a
+// state ConfigMap, the change is applied to the persisted silences, which replace those in memory,
+// so that silences survive restarts and are applied by every replica.
+func (c *Controller) updateSilences(change func(items map[string]api.Silence) bool) (bool, error) {
+	if c.stateConfigMap == "" {
+		return c.silences.update(change), nil
+	}
+	var changed bool
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := c.stateClient.CoreV1().ConfigMaps(c.stateNamespace).Get(context.TODO(), c.stateConfigMap, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			configMap = nil
+		} else if err != nil {
+			return err
+		}
+		items := make(map[string]api.Silence)
+		if configMap != nil {
+			if data, ok := configMap.Data[silencesKey]; ok {
+				if err := json.Unmarshal([]byte(data), &items); err != nil {
+					klog.ErrorS(err, "Discarding invalid silences", "configMap", klog.KRef(c.stateNamespace, c.stateConfigMap))
+				}
+			}
+		}
+		changed = change(items)
+		if expired := expireSilences(items, c.clock.Now()); changed || expired {
+			data, err := json.Marshal(items)
+			if err != nil {
+				return err
+			}
+			if err := c.writeState(configMap, map[string][]byte{silencesKey: data}); err != nil {
+				return err
+			}
+		}
+		c.silences.replace(items)
+		return nil
+	})
+	if err != nil {
+		return false, &stateError{err: err}
+	}
+	return changed, nil
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const slashLogLines = 30
+
+const slashHelp = "Usage:\n" +
+	"* `/informer status [pod-or-prefix]` shows the status of watched pods\n" +
+	"* `/informer logs [namespace/]<pod> [container]` shows the most recent log lines of a pod\n" +
+	"* `/informer alerts` lists the most recent alerts\n" +
+	"* `/informer top [window]` lists the workloads with the most alerts, e.g. in the last `24h` or `7d`"
+
+// SlashCommandHandler returns a HTTP handler serving the /informer slash command.
+// Requests not carrying the configured token are rejected.
+func (c *Controller) SlashCommandHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfg, _ := c.settings()
+		if cfg.SlashToken == "" || subtle.ConstantTimeCompare([]byte(r.PostForm.Get("token")), []byte(cfg.SlashToken)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		text := c.handleSlashCommand(r.Context(), r.PostForm.Get("channel_name"), strings.Fields(r.PostForm.Get("text")))
+		response := &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         text,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response.ToJson()))
+	})
+}
+
+func (c *Controller) handleSlashCommand(ctx context.Context, channel string, args []string) string {
+	if len(args) == 0 {
+		return slashHelp
+	}
+	switch args[0] {
+	case "status":
+		filter := ""
+		if len(args) > 1 {
+			filter = args[1]
+		}
+		return c.slashStatus(filter)
+	case "logs":
+		if len(args) < 2 {
+			return slashHelp
+		}
+		container := ""
+		if len(args) > 2 {
+			container = args[2]
+		}
+		return c.slashLogs(ctx, args[1], container)
+	case "alerts":
+		return c.slashAlerts(channel)
+	case "top":
+		window := ""
+		if len(args) > 1 {
+			window = args[1]
+		}
+		return c.slashTop(ctx, channel, window)
+	}
+	return slashHelp
+}
+
+// slashStatus renders a table of all watched pods whose name starts with filter.
+func (c *Controller) slashStatus(filter string) string {
+	var pods []*v1.Pod
+	for _, pod := range c.listPods() {
+		if strings.HasPrefix(pod.Name, filter) {
+			pods = append(pods, pod)
+		}
+	}
+	if len(pods) == 0 {
+		return fmt.Sprintf("No pods matching `%s` found.", filter)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString("| Pod | Phase | Ready | Restarts | Reason |\n")
+	buf.WriteString("|:----|:------|:------|:---------|:-------|\n")
+	for _, pod := range pods {
+		var ready, restarts int
+		var reasons []string
+		for _, container := range pod.Status.ContainerStatuses {
+			if container.Ready {
+				ready++
+			}
+			restarts += int(container.RestartCount)
+			if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+				reasons = append(reasons, container.State.Waiting.Reason)
+			}
+		}
+		fmt.Fprintf(&buf, "| %s/%s | %s | %d/%d | %d | %s |\n", pod.Namespace, pod.Name, pod.Status.Phase,
+			ready, len(pod.Status.ContainerStatuses), restarts, strings.Join(reasons, ", "))
+	}
+	return buf.String()
+}
+
+// findPod looks up a watched pod by namespace/name or, if no namespace is given, by name.
+func (c *Controller) findPod(name string) *v1.Pod {
+	if strings.Contains(name, "/") {
+		obj, exists, err := c.getPod(name)
+		if err != nil || !exists || !c.watchesKey(name) {
+			return nil
+		}
+		return obj.(*v1.Pod)
+	}
+	for _, pod := range c.listPods() {
+		if pod.Name == name {
+			return pod
+		}
+	}
+	return nil
+}
+
+// slashLogs returns the most recent log lines of the given pod.
+func (c *Controller) slashLogs(ctx context.Context, name, container string) string {
+	pod := c.findPod(name)
+	if pod == nil {
+		return fmt.Sprintf("Pod `%s` is not watched by the informer.", name)
+	}
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+	logs, err := c.podLogs(ctx, pod, container, slashLogLines)
+	if err != nil {
+		klog.ErrorS(err, "Fetching logs failed", "pod", klog.KObj(pod), "container", container)
+		return fmt.Sprintf("Could not fetch logs of container %s in pod %s.", container, name)
+	}
+	return fmt.Sprintf("Logs of container %s in pod %s:\n```\n%s```", container, name, logs)
+}
+
+// slashAlerts lists the most recently sent alerts with times in the time zone of the channel.
+func (c *Controller) slashAlerts(channel string) string {
+	records := c.history.list()
+	if len(records) == 0 {
+		return "No alerts have been sent recently."
+	}
+	location := c.location(channel)
+	var buf bytes.Buffer
+	buf.WriteString("| Time | Pod | Container | Reason |\n")
+	buf.WriteString("|:-----|:----|:----------|:-------|\n")
+	for _, record := range records {
+		fmt.Fprintf(&buf, "| %s | %s | %s | %s |\n", record.Time.In(location).Format("2006-01-02 15:04:05 MST"),
+			record.Pod, record.Container, record.Reason)
+	}
+	return buf.String()
+}
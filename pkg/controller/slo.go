@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	"github.com/lnsp/mattermost-informer/pkg/slo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// sloRecordsMaxAge is how long the notification records are reused for evaluating all objectives.
+	sloRecordsMaxAge = 30 * time.Second
+	// sloMaxOpenInterval bounds the downtime of records which were never resolved although their
+	// alert is not firing anymore, e.g. because the resolution was lost in a restart.
+	sloMaxOpenInterval = 24 * time.Hour
+)
+
+// sloCheck warns about ServiceLevelObjectives whose error budget burns too fast, as computed from
+// the notification records. They are re-evaluated every minute.
+func (c *Controller) sloCheck() resourceCheck {
+	var (
+		mu        sync.Mutex
+		list      []records.Record
+		fetchedAt time.Time
+	)
+	cachedRecords := func(ctx context.Context) ([]records.Record, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if c.clock.Since(fetchedAt) < sloRecordsMaxAge {
+			return list, nil
+		}
+		var err error
+		if list, err = c.records.List(ctx); err != nil {
+			return nil, err
+		}
+		fetchedAt = c.clock.Now()
+		return list, nil
+	}
+	return resourceCheck{
+		gvr:         slo.GVR,
+		kind:        "ServiceLevelObjective",
+		gracePeriod: time.Minute,
+		check: func(ctx context.Context, obj *unstructured.Unstructured) *resourceProblem {
+			objective, err := slo.FromResource(obj)
+			if err != nil {
+				klog.ErrorS(err, "Ignoring invalid service level objective", "slo", klog.KObj(obj))
+				return nil
+			}
+			list, err := cachedRecords(ctx)
+			if err != nil {
+				klog.ErrorS(err, "Listing notification records failed", "slo", klog.KObj(obj))
+				return nil
+			}
+			return c.checkObjective(objective, list)
+		},
+	}
+}
+
+// checkObjective reports a problem if the error budget of the objective is exhausted or burns
+// faster than one of its alerts allows. The workload counts as unavailable while any of its
+// containers has a firing crash alert, regardless of how many replicas are still serving.
+func (c *Controller) checkObjective(objective *slo.Objective, list []records.Record) *resourceProblem {
+	cfg, _ := c.settings()
+	cluster, _ := cfg.Cluster.Identity(c.cluster)
+	now := c.clock.Now()
+	var intervals []slo.Interval
+	for _, record := range uniqueAlerts(list) {
+		spec := &record.Spec
+		if spec.Namespace != objective.Namespace || spec.Workload != objective.Workload || spec.Cluster != cluster {
+			continue
+		}
+		interval := slo.Interval{Start: spec.SentAt.Time, End: now}
+		if resolved := record.Status.ResolvedAt; resolved != nil {
+			interval.End = resolved.Time
+		} else if _, firing := c.firing.get(spec.Fingerprint); !firing {
+			if capped := interval.Start.Add(sloMaxOpenInterval); capped.Before(now) {
+				interval.End = capped
+			}
+		}
+		intervals = append(intervals, interval)
+	}
+	budget := objective.Budget()
+	downtime := slo.Downtime(intervals, now.Add(-objective.Window), now)
+	left := budget - downtime
+	if left < 0 {
+		left = 0
+	}
+	summary := fmt.Sprintf("%s of the %s error budget of the %v%% availability target over %s are left.",
+		left.Round(time.Second), budget.Round(time.Second), objective.Target, objective.Window)
+	if downtime >= budget {
+		return &resourceProblem{
+			Reason: "ErrorBudgetExhausted",
+			Title:  fmt.Sprintf("Error budget of %s is exhausted", objective.Workload),
+			Text: fmt.Sprintf("Workload %s in namespace %s was unavailable for %s, exhausting the error budget of its objective %s. %s",
+				objective.Workload, objective.Namespace, downtime.Round(time.Second), objective.Name, summary),
+		}
+	}
+	for _, alert := range objective.Alerts {
+		rate := objective.BurnRate(slo.Downtime(intervals, now.Add(-alert.Window), now), alert.Window)
+		if rate < alert.BurnRate {
+			continue
+		}
+		return &resourceProblem{
+			Reason: "ErrorBudgetBurn",
+			Title:  fmt.Sprintf("Error budget of %s is burning", objective.Workload),
+			Text: fmt.Sprintf("Workload %s in namespace %s burned the error budget of its objective %s at %.1f times the sustainable rate over the last %s. %s",
+				objective.Workload, objective.Namespace, objective.Name, rate, alert.Window, summary),
+		}
+	}
+	return nil
+}
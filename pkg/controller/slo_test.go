@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	"github.com/lnsp/mattermost-informer/pkg/slo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUnresolvedRecordsAreCapped(t *testing.T) {
+	c, _ := newTestController(t)
+	objective := &slo.Objective{Namespace: "shop", Name: "payments", Workload: "Deployment/payments", Target: 80, Window: 7 * 24 * time.Hour}
+	fingerprint := notify.Fingerprint("shop", "payments-5d8f", "api")
+	list := []records.Record{{Spec: records.Spec{
+		Fingerprint: fingerprint,
+		Namespace:   "shop",
+		Pod:         "payments-5d8f",
+		Container:   "api",
+		Workload:    "Deployment/payments",
+		SentAt:      metav1.NewTime(testStart.Add(-72 * time.Hour)),
+	}}}
+
+	// The budget of 33.6 hours covers the capped downtime of a lost resolution.
+	if problem := c.checkObjective(objective, list); problem != nil {
+		t.Errorf("unresolved record exhausted the budget: %s", problem.Text)
+	}
+	c.firing.add(&notify.Alert{Namespace: "shop", Pod: "payments-5d8f", Container: "api", Fingerprint: fingerprint}, nil)
+	if problem := c.checkObjective(objective, list); problem == nil || problem.Reason != "ErrorBudgetExhausted" {
+		t.Errorf("firing alert did not exhaust the budget, got %+v", problem)
+	}
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SoakOptions configure a soak run.
+type SoakOptions struct {
+	// Pods is the number of pods existing at a time, spread over Namespaces namespaces.
+	Pods       int
+	Namespaces int
+	// Rate is the number of pod updates per second.
+	Rate int
+	// CrashRatio is the fraction of updates reporting a crashing container, ChurnRatio the fraction
+	// of updates replacing a pod by a new one, e.g. as by a rollout.
+	CrashRatio float64
+	ChurnRatio float64
+	Workers    int
+	// Duration is the length of the run. Resource usage is sampled every SampleInterval and compared
+	// to the first sample taken after Warmup.
+	Duration       time.Duration
+	SampleInterval time.Duration
+	Warmup         time.Duration
+	// MaxHeapGrowth is the tolerated growth of the heap relative to the baseline, e.g. 0.2 for 20%,
+	// and MaxGoroutineGrowth the tolerated number of additional goroutines.
+	MaxHeapGrowth      float64
+	MaxGoroutineGrowth int
+}
+
+// soakSample is the resource usage at a point in time.
+type soakSample struct {
+	heap       uint64
+	goroutines int
+	states     int
+	firing     int
+}
+
+func takeSoakSample(c *Controller) soakSample {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return soakSample{
+		heap:       stats.HeapInuse,
+		goroutines: runtime.NumGoroutine(),
+		states:     len(c.timeouts.Snapshot()),
+		firing:     len(c.firing.list()),
+	}
+}
+
+// Soak runs the controller against generated pod updates and churn for the given duration, like
+// Benchmark without an API server or notifier, and writes a sample of the resource usage to out
+// every sample interval. It fails as soon as the heap or the number of goroutines grew beyond the
+// tolerated drift since the end of the warmup, which indicates a leak, e.g. of the state of
+// replaced pods.
+func Soak(opts SoakOptions, out io.Writer) error {
+	if opts.Pods <= 0 || opts.Namespaces <= 0 || opts.Rate <= 0 || opts.Workers <= 0 {
+		return fmt.Errorf("pods, namespaces, rate and workers must be positive")
+	}
+	if opts.SampleInterval <= 0 || opts.Warmup < 0 || opts.Duration <= opts.Warmup {
+		return fmt.Errorf("sample interval must be positive and the duration must exceed the warmup")
+	}
+	c, notifier, err := newBenchController(opts.Pods)
+	if err != nil {
+		return err
+	}
+	indexer := c.pods[metav1.NamespaceAll].indexer
+	pods := make([]*v1.Pod, opts.Pods)
+	generations := make([]int, opts.Pods)
+	restarts := make([]int32, opts.Pods)
+	for i := range pods {
+		pods[i] = benchPod(i, 0, opts.Namespaces)
+	}
+	random := rand.New(rand.NewSource(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.dispatcher.Run(ctx)
+	var workers sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			c.runWorker(ctx)
+		}()
+	}
+	defer func() {
+		c.queue.ShutDown()
+		workers.Wait()
+		c.dispatcher.stop()
+	}()
+
+	// Updates are generated in batches every tick to sustain high rates.
+	const tick = 10 * time.Millisecond
+	batch := opts.Rate * int(tick) / int(time.Second)
+	if batch < 1 {
+		batch = 1
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	sampler := time.NewTicker(opts.SampleInterval)
+	defer sampler.Stop()
+	start := time.Now()
+	end := start.Add(opts.Duration)
+	var baseline *soakSample
+	updates := 0
+	fmt.Fprintf(out, "%-10s %10s %14s %10s %8s %8s %14s\n", "elapsed", "updates", "heap", "goroutines", "states", "firing", "notifications")
+	for {
+		select {
+		case <-ticker.C:
+			for i := 0; i < batch; i++ {
+				index := random.Intn(len(pods))
+				if random.Float64() < opts.ChurnRatio {
+					indexer.Delete(pods[index])
+					c.enqueue(pods[index], reasonDeleted)
+					generations[index]++
+					restarts[index] = 0
+					pods[index] = benchPod(index, generations[index], opts.Namespaces)
+				}
+				pod := pods[index].DeepCopy()
+				crash := random.Float64() < opts.CrashRatio
+				if crash {
+					restarts[index]++
+				}
+				setBenchStatus(pod, crash, restarts[index])
+				obj, _ := stripObject(pod)
+				if err := indexer.Update(obj); err != nil {
+					return err
+				}
+				c.enqueue(obj, reasonUpdated)
+			}
+			updates += batch
+		case now := <-sampler.C:
+			sample := takeSoakSample(c)
+			elapsed := now.Sub(start).Truncate(time.Second)
+			fmt.Fprintf(out, "%-10s %10d %14d %10d %8d %8d %14d\n", elapsed, updates, sample.heap,
+				sample.goroutines, sample.states, sample.firing, notifier.count())
+			if elapsed < opts.Warmup {
+				continue
+			}
+			if baseline == nil {
+				baseline = &sample
+				continue
+			}
+			if limit := float64(baseline.heap) * (1 + opts.MaxHeapGrowth); float64(sample.heap) > limit {
+				return fmt.Errorf("heap grew from %d to %d bytes, more than %.0f%%", baseline.heap, sample.heap, opts.MaxHeapGrowth*100)
+			}
+			if sample.goroutines > baseline.goroutines+opts.MaxGoroutineGrowth {
+				return fmt.Errorf("goroutines grew from %d to %d", baseline.goroutines, sample.goroutines)
+			}
+			if now.After(end) {
+				return nil
+			}
+		}
+	}
+}
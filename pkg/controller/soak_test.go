@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSoakRejectsInvalidOptions(t *testing.T) {
+	valid := SoakOptions{Pods: 10, Namespaces: 1, Rate: 10, Workers: 1, Duration: time.Second, SampleInterval: time.Second}
+	for name, change := range map[string]func(*SoakOptions){
+		"no pods":           func(o *SoakOptions) { o.Pods = 0 },
+		"no workers":        func(o *SoakOptions) { o.Workers = 0 },
+		"no sampling":       func(o *SoakOptions) { o.SampleInterval = 0 },
+		"warmup too long":   func(o *SoakOptions) { o.Warmup = o.Duration },
+		"negative warmup":   func(o *SoakOptions) { o.Warmup = -time.Second },
+		"no updates":        func(o *SoakOptions) { o.Rate = 0 },
+		"no namespaces":     func(o *SoakOptions) { o.Namespaces = 0 },
+		"negative duration": func(o *SoakOptions) { o.Duration = -time.Second },
+	} {
+		opts := valid
+		change(&opts)
+		if err := Soak(opts, &bytes.Buffer{}); err == nil {
+			t.Errorf("%s: options were accepted", name)
+		}
+	}
+}
+
+func TestSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak runs for seconds")
+	}
+	var out bytes.Buffer
+	err := Soak(SoakOptions{
+		Pods:               200,
+		Namespaces:         5,
+		Rate:               2000,
+		CrashRatio:         0.05,
+		ChurnRatio:         0.05,
+		Workers:            2,
+		Duration:           3 * time.Second,
+		SampleInterval:     500 * time.Millisecond,
+		Warmup:             time.Second,
+		MaxHeapGrowth:      1,
+		MaxGoroutineGrowth: 10,
+	}, &out)
+	if err != nil {
+		t.Fatalf("soak failed: %v\n%s", err, out.String())
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) < 5 {
+		t.Errorf("soak printed %d lines, want a header and a sample per interval:\n%s", len(lines), out.String())
+	}
+}
+
+// TestReplacedPodStateIsDropped covers the leak soak runs are meant to catch: the notification
+// state of a crashed pod must not outlive the pod.
+func TestReplacedPodStateIsDropped(t *testing.T) {
+	c, _, err := newBenchController(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.dispatcher.Run(ctx)
+	defer c.dispatcher.stop()
+	indexer := c.pods[metav1.NamespaceAll].indexer
+
+	pod := benchPod(0, 0, 1)
+	setBenchStatus(pod, true, 5)
+	obj, _ := stripObject(pod)
+	if err := indexer.Add(obj); err != nil {
+		t.Fatal(err)
+	}
+	c.enqueue(obj, reasonUpdated)
+	c.processNextItem(ctx)
+	if states := len(c.timeouts.Snapshot()); states == 0 {
+		t.Fatal("crash of pod was not recorded")
+	}
+
+	if err := indexer.Delete(obj); err != nil {
+		t.Fatal(err)
+	}
+	c.enqueue(obj, reasonDeleted)
+	c.processNextItem(ctx)
+	if states := c.timeouts.Snapshot(); len(states) != 0 {
+		t.Errorf("state %v of deleted pod was kept", states)
+	}
+}
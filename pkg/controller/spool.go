@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/spool"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// spoolFlushInterval is the interval in which delivering spooled notifications is retried.
+const spoolFlushInterval = 30 * time.Second
+
+// alertSpool is the spool of undelivered notifications shared by the controllers of all clusters.
+// Entries are dropped once they have been spooled for maxAge or failed maxAttempts times.
+type alertSpool struct {
+	*spool.Spool
+	maxAge      time.Duration
+	maxAttempts int
+
+	mu sync.Mutex
+	// done holds the callbacks of the spooled alerts by entry name. They are kept in memory only,
+	// alerts delivered from the spool after a restart are not reported.
+	done map[string]func(postID string, err error)
+}
+
+func newAlertSpool(s *spool.Spool, maxAge time.Duration, maxAttempts int) *alertSpool {
+	return &alertSpool{Spool: s, maxAge: maxAge, maxAttempts: maxAttempts, done: make(map[string]func(string, error))}
+}
+
+// add spools the entry, done is called once it has been delivered or dropped.
+func (s *alertSpool) add(entry *spool.Entry, now time.Time, done func(postID string, err error)) error {
+	if err := s.Add(entry, now); err != nil {
+		return err
+	}
+	if done != nil {
+		s.mu.Lock()
+		s.done[entry.Name()] = done
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// finish removes the entry and calls its callback with the result of the delivery.
+func (s *alertSpool) finish(entry *spool.Entry, postID string, err error) {
+	if err := s.Remove(entry); err != nil {
+		klog.ErrorS(err, "Removing spooled notification failed", "notifier", entry.Notifier)
+	}
+	s.mu.Lock()
+	done := s.done[entry.Name()]
+	delete(s.done, entry.Name())
+	s.mu.Unlock()
+	if done != nil {
+		done(postID, err)
+	}
+}
+
+// spoolAlert persists an alert the named notifier failed to deliver and reports whether it has
+// been spooled. The result of its delivery from the spool is passed to done.
+func (c *Controller) spoolAlert(name string, alert *notify.Alert, done func(postID string, err error)) bool {
+	if c.spool == nil {
+		return false
+	}
+	if err := c.spool.add(&spool.Entry{Notifier: name, Alert: alert}, c.clock.Now(), done); err != nil {
+		klog.ErrorS(err, "Spooling undelivered notification failed", "pod", klog.KRef(alert.Namespace, alert.Pod), "notifier", name)
+		return false
+	}
+	klog.InfoS("Spooled undelivered notification", "pod", klog.KRef(alert.Namespace, alert.Pod), "notifier", name)
+	return true
+}
+
+// spoolResolve spools the resolution of an alert which is still spooled for the named notifier,
+// so that it is delivered after the alert. It reports false if the alert is not spooled.
+func (c *Controller) spoolResolve(name string, alert *notify.Alert) bool {
+	if c.spool == nil || !c.spool.Contains(name, alert.Fingerprint) {
+		return false
+	}
+	if err := c.spool.add(&spool.Entry{Notifier: name, Alert: alert, Resolve: true}, c.clock.Now(), nil); err != nil {
+		klog.ErrorS(err, "Spooling resolution failed", "pod", klog.KRef(alert.Namespace, alert.Pod), "notifier", name)
+	}
+	return true
+}
+
+// flushSpool delivers the spooled notifications, oldest first, skipping notifiers whose circuit
+// breaker is open. Delivered notifications mention the time they were raised at. Notifications
+// of an alert wait until the alert has been delivered, or are dropped with it.
+func (c *Controller) flushSpool(ctx context.Context) {
+	entries, err := c.spool.Entries()
+	if err != nil {
+		klog.ErrorS(err, "Reading spooled notifications failed")
+		return
+	}
+	blocked := make(map[string]bool)
+	dropped := make(map[string]bool)
+	for _, entry := range entries {
+		key := entry.Notifier + "/" + entry.Alert.Fingerprint
+		if dropped[key] {
+			c.spool.finish(entry, "", fmt.Errorf("spooled alert has been dropped"))
+			continue
+		}
+		if blocked[key] {
+			continue
+		}
+		notifier := c.notifier(entry.Notifier)
+		if notifier == nil {
+			c.dropSpooled(entry, fmt.Errorf("unknown notifier %s", entry.Notifier))
+			dropped[key] = true
+			continue
+		}
+		if age := c.clock.Since(entry.SpooledAt); age > c.spool.maxAge {
+			c.dropSpooled(entry, fmt.Errorf("notification has been spooled for %s", age.Round(time.Second)))
+			dropped[key] = true
+			continue
+		}
+		breaker := c.breakers.get(entry.Notifier, entry.Alert.Channel)
+		if !breaker.allow() {
+			blocked[key] = true
+			continue
+		}
+		postID, err := c.deliverSpooled(ctx, notifier, entry)
+		breaker.record(err)
+		if err != nil {
+			entry.Attempts++
+			if utils.IsPermanent(err) || entry.Attempts >= c.spool.maxAttempts {
+				c.dropSpooled(entry, err)
+				dropped[key] = true
+				continue
+			}
+			klog.ErrorS(err, "Delivering spooled notification failed", "pod", klog.KRef(entry.Alert.Namespace, entry.Alert.Pod), "notifier", entry.Notifier, "attempts", entry.Attempts)
+			if err := c.spool.Update(entry); err != nil {
+				klog.ErrorS(err, "Updating spooled notification failed", "notifier", entry.Notifier)
+			}
+			blocked[key] = true
+			continue
+		}
+		klog.InfoS("Delivered spooled notification", "pod", klog.KRef(entry.Alert.Namespace, entry.Alert.Pod), "notifier", entry.Notifier, "raised", entry.Alert.Time, "resolve", entry.Resolve)
+		c.spool.finish(entry, postID, nil)
+	}
+}
+
+// deliverSpooled sends the spooled entry and returns the ID of the post if the notifier reports it.
+func (c *Controller) deliverSpooled(ctx context.Context, notifier notify.Notifier, entry *spool.Entry) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	alert := *entry.Alert
+	if entry.Resolve {
+		if resolver, ok := notifier.(notify.Resolver); ok {
+			return "", resolver.Resolve(ctx, &alert)
+		}
+		return "", notifier.Send(ctx, &alert)
+	}
+	alert.Text += fmt.Sprintf("\n\n_Delivery was delayed, this alert was raised at %s._", alert.Time.In(c.location(alert.Channel)).Format(time.RFC1123))
+	if poster, ok := notifier.(notify.Poster); ok {
+		return poster.Post(ctx, &alert)
+	}
+	return "", notifier.Send(ctx, &alert)
+}
+
+// dropSpooled removes a spooled notification which cannot be delivered.
+func (c *Controller) dropSpooled(entry *spool.Entry, err error) {
+	klog.ErrorS(err, "Dropping spooled notification", "pod", klog.KRef(entry.Alert.Namespace, entry.Alert.Pod), "notifier", entry.Notifier, "attempts", entry.Attempts)
+	deliveriesFailed.WithLabelValues(entry.Notifier).Inc()
+	c.spool.finish(entry, "", err)
+}
+
+// runSpoolFlush periodically delivers the spooled notifications until stopCh is closed.
+func (c *Controller) runSpoolFlush(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		c.flushSpool(context.Background())
+	}, spoolFlushInterval, stopCh)
+}
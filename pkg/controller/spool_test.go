@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/spool"
+	"github.com/lnsp/mattermost-informer/pkg/testutil"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// newSpoolingController creates a test controller spooling to a temporary directory, whose
+// mattermost notifier is the returned fake.
+func newSpoolingController(t *testing.T, maxAge time.Duration, maxAttempts int) (*Controller, *testingclock.FakeClock, *testutil.Notifier) {
+	t.Helper()
+	c, clock := newTestController(t)
+	s, err := spool.New(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.spool = newAlertSpool(s, maxAge, maxAttempts)
+	notifier := testutil.NewNotifier()
+	c.notifiers = map[string]notify.Notifier{notify.TypeMattermost: notifier}
+	return c, clock, notifier
+}
+
+func TestSpooledResolutionWaitsForAlert(t *testing.T) {
+	c, clock, notifier := newSpoolingController(t, time.Hour, 3)
+	alert := &notify.Alert{Fingerprint: "f", Namespace: "apps", Pod: "web", Time: testStart}
+	var postID string
+	var result error
+	if !c.spoolAlert(notify.TypeMattermost, alert, func(id string, err error) { postID, result = id, err }) {
+		t.Fatal("alert was not spooled")
+	}
+	clock.Step(time.Minute)
+	if !c.spoolResolve(notify.TypeMattermost, alert) {
+		t.Fatal("resolution was not spooled")
+	}
+
+	notifier.Fail(1, errors.New("unavailable"))
+	c.flushSpool(context.Background())
+	if len(notifier.Sent()) != 0 || len(notifier.Resolved()) != 0 {
+		t.Fatalf("expected nothing to be delivered, got %d alerts and %d resolutions", len(notifier.Sent()), len(notifier.Resolved()))
+	}
+	if c.spool.Len() != 2 {
+		t.Fatalf("expected alert and resolution to stay spooled, got %d entries", c.spool.Len())
+	}
+
+	c.flushSpool(context.Background())
+	sent := notifier.Sent()
+	if len(sent) != 1 || len(notifier.Resolved()) != 1 {
+		t.Fatalf("expected alert and resolution to be delivered, got %d alerts and %d resolutions", len(sent), len(notifier.Resolved()))
+	}
+	if !strings.Contains(sent[0].Text, "Delivery was delayed") {
+		t.Errorf("expected delayed alert to mention its time, got %q", sent[0].Text)
+	}
+	if postID != "0" || result != nil {
+		t.Errorf("expected callback with post ID 0, got %q, %v", postID, result)
+	}
+	if c.spool.Len() != 0 {
+		t.Errorf("expected empty spool, got %d entries", c.spool.Len())
+	}
+}
+
+func TestSpooledAlertsAreDropped(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		// flushes before the alert is dropped, with wait passing before each flush.
+		flushes int
+		wait    time.Duration
+	}{
+		{name: "after max attempts", maxAttempts: 2, flushes: 2, wait: time.Minute},
+		{name: "after max age", maxAttempts: 5, flushes: 2, wait: 40 * time.Minute},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, clock, notifier := newSpoolingController(t, time.Hour, test.maxAttempts)
+			alert := &notify.Alert{Fingerprint: "f", Namespace: "apps", Pod: "web", Time: testStart}
+			var result error
+			c.spoolAlert(notify.TypeMattermost, alert, func(id string, err error) { result = err })
+			c.spoolResolve(notify.TypeMattermost, alert)
+			notifier.Fail(-1, errors.New("unavailable"))
+			for i := 0; i < test.flushes; i++ {
+				if c.spool.Len() != 2 {
+					t.Fatalf("expected alert and resolution to stay spooled before flush %d, got %d entries", i+1, c.spool.Len())
+				}
+				clock.Step(test.wait)
+				c.flushSpool(context.Background())
+			}
+			if c.spool.Len() != 0 {
+				t.Errorf("expected alert and resolution to be dropped, got %d entries", c.spool.Len())
+			}
+			if result == nil {
+				t.Error("expected callback with error")
+			}
+			notifier.Reset()
+			c.flushSpool(context.Background())
+			if len(notifier.Sent()) != 0 || len(notifier.Resolved()) != 0 {
+				t.Error("expected dropped notifications not to be delivered")
+			}
+		})
+	}
+}
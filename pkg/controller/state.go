@@ -0,0 +1,312 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	stateKey = "timeouts.json"
+	// namespacesKey holds the notification state besides the timeouts by namespace.
+	namespacesKey     = "namespaces.json"
+	stateSyncInterval = 30 * time.Second
+)
+
+// stateError is returned if a change could not be persisted in the state ConfigMap.
+type stateError struct {
+	err error
+}
+
+func (e *stateError) Error() string {
+	return fmt.Sprintf("could not persist state: %v", e.err)
+}
+
+// namespaceState is the notification state of a namespace besides the timeouts. It is persisted
+// so that alerts are resolved, and not repeated, after a restart or once another replica takes
+// over the namespace.
+type namespaceState struct {
+	Firing map[string]persistedFiring `json:"firing,omitempty"`
+	// Backoffs are the notifications sent per timeout key since the pod last recovered.
+	Backoffs map[string]int `json:"backoffs,omitempty"`
+	// Rates are the restart rates per namespace/workload key, so that their baseline does not
+	// have to be learned again.
+	Rates map[string]persistedRate `json:"rates,omitempty"`
+	// Escalations are the alerts waiting to be acknowledged by fingerprint, so that they are
+	// escalated after a restart or by the replica taking over the namespace.
+	Escalations map[string]persistedEscalation `json:"escalations,omitempty"`
+}
+
+// persistedFiring is the form a firing alert is persisted in.
+type persistedFiring struct {
+	Alert        *notify.Alert `json:"alert"`
+	Notifiers    []string      `json:"notifiers"`
+	Since        time.Time     `json:"since"`
+	RecoveredAt  time.Time     `json:"recoveredAt,omitempty"`
+	LastRecovery time.Time     `json:"lastRecovery,omitempty"`
+	Relapses     []time.Time   `json:"relapses,omitempty"`
+	Flapping     bool          `json:"flapping,omitempty"`
+}
+
+// loadState restores the notification timeouts and the state of the owned namespaces from the
+// state ConfigMap.
+func (c *Controller) loadState() error {
+	timeouts, namespaces, _, err := c.readState()
+	if err != nil {
+		return err
+	}
+	owned := make(map[string]time.Time)
+	for key, timeout := range timeouts {
+		if c.ownsNamespace(keyNamespace(key)) {
+			owned[key] = timeout
+		}
+	}
+	c.timeouts.Restore(owned)
+	firing := 0
+	for namespace, state := range namespaces {
+		if !c.ownsNamespace(namespace) {
+			continue
+		}
+		c.firing.restore(state.Firing)
+		c.backoffs.restore(state.Backoffs)
+		c.escalations.restore(state.Escalations)
+		if c.anomalies != nil {
+			c.anomalies.restore(state.Rates)
+		}
+		firing += len(state.Firing)
+	}
+	klog.InfoS("Restored notification state", "configMap", klog.KRef(c.stateNamespace, c.stateConfigMap), "timeouts", len(owned), "firing", firing)
+	if c.cluster != "" {
+		// The silences and acknowledgements are shared with the local controller, which
+		// synchronizes them.
+		return nil
+	}
+	if err := c.syncSilences(); err != nil {
+		return err
+	}
+	return c.syncAcknowledgements()
+}
+
+// readState returns the timeouts and namespace states stored in the state ConfigMap, the ConfigMap
+// is nil if it does not exist yet.
+func (c *Controller) readState() (map[string]time.Time, map[string]namespaceState, *v1.ConfigMap, error) {
+	configMap, err := c.stateClient.CoreV1().ConfigMaps(c.stateNamespace).Get(context.TODO(), c.stateConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return map[string]time.Time{}, map[string]namespaceState{}, nil, nil
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+	timeouts := make(map[string]time.Time)
+	if data, ok := configMap.Data[stateKey]; ok {
+		if err := json.Unmarshal([]byte(data), &timeouts); err != nil {
+			klog.ErrorS(err, "Discarding invalid state", "configMap", klog.KRef(c.stateNamespace, c.stateConfigMap), "key", stateKey)
+		}
+	}
+	namespaces := make(map[string]namespaceState)
+	if data, ok := configMap.Data[namespacesKey]; ok {
+		if err := json.Unmarshal([]byte(data), &namespaces); err != nil {
+			klog.ErrorS(err, "Discarding invalid state", "configMap", klog.KRef(c.stateNamespace, c.stateConfigMap), "key", namespacesKey)
+		}
+	}
+	return timeouts, namespaces, configMap, nil
+}
+
+// namespaceStates returns the state of the owned namespaces.
+func (c *Controller) namespaceStates() map[string]namespaceState {
+	states := make(map[string]namespaceState)
+	for key, firing := range c.firing.snapshot(c.ownsNamespace) {
+		state := states[firing.Alert.Namespace]
+		if state.Firing == nil {
+			state.Firing = make(map[string]persistedFiring)
+		}
+		state.Firing[key] = firing
+		states[firing.Alert.Namespace] = state
+	}
+	for key, step := range c.backoffs.snapshot(c.ownsNamespace) {
+		state := states[keyNamespace(key)]
+		if state.Backoffs == nil {
+			state.Backoffs = make(map[string]int)
+		}
+		state.Backoffs[key] = step
+		states[keyNamespace(key)] = state
+	}
+	for fingerprint, escalation := range c.escalations.snapshot(c.ownsNamespace) {
+		state := states[escalation.Alert.Namespace]
+		if state.Escalations == nil {
+			state.Escalations = make(map[string]persistedEscalation)
+		}
+		state.Escalations[fingerprint] = escalation
+		states[escalation.Alert.Namespace] = state
+	}
+	if c.anomalies == nil {
+		return states
+	}
+	for key, rate := range c.anomalies.snapshot(c.ownsNamespace) {
+		state := states[keyNamespace(key)]
+		if state.Rates == nil {
+			state.Rates = make(map[string]persistedRate)
+		}
+		state.Rates[key] = rate
+		states[keyNamespace(key)] = state
+	}
+	return states
+}
+
+// saveState writes the notification timeouts and the state of the owned namespaces to the state
+// ConfigMap, keeping those written by other replicas.
+func (c *Controller) saveState() error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	// Resolve the alerts of pods which have been deleted while the informer was not running, once
+	// the caches are synced.
+	synced := c.checkSynced() == nil
+	if synced {
+		for _, firing := range c.firing.list() {
+			pod := firing.alert.Namespace + "/" + firing.alert.Pod
+			if !c.ownsNamespace(firing.alert.Namespace) || firing.alert.Synthetic {
+				continue
+			}
+			if _, exists, _ := c.getPod(pod); !exists {
+				c.resolveAlerts(context.Background(), c.firing.takePod(pod))
+			}
+		}
+		for key := range c.backoffs.snapshot(c.ownsNamespace) {
+			if _, exists, _ := c.getPod(keyPod(key)); !exists {
+				c.backoffs.deletePrefix(keyPod(key) + "/")
+			}
+		}
+	}
+	states := c.namespaceStates()
+	encoded, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	if !atomic.CompareAndSwapInt32(&c.stateDirty, 1, 0) && bytes.Equal(encoded, c.savedStates) {
+		return nil
+	}
+	// Forget the timeouts of pods which no longer exist, once the caches are synced.
+	owned := c.timeouts.Snapshot()
+	for key := range owned {
+		if synced {
+			if _, exists, _ := c.getPod(keyPod(key)); !exists {
+				c.timeouts.Delete(key)
+				delete(owned, key)
+			}
+		}
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		timeouts, namespaces, configMap, err := c.readState()
+		if err != nil {
+			return err
+		}
+		for key := range timeouts {
+			if c.ownsNamespace(keyNamespace(key)) {
+				delete(timeouts, key)
+			}
+		}
+		for key, timeout := range owned {
+			timeouts[key] = timeout
+		}
+		for namespace := range namespaces {
+			if c.ownsNamespace(namespace) {
+				delete(namespaces, namespace)
+			}
+		}
+		for namespace, state := range states {
+			namespaces[namespace] = state
+		}
+		timeoutData, err := json.Marshal(timeouts)
+		if err != nil {
+			return err
+		}
+		namespaceData, err := json.Marshal(namespaces)
+		if err != nil {
+			return err
+		}
+		return c.writeState(configMap, map[string][]byte{stateKey: timeoutData, namespacesKey: namespaceData})
+	})
+	if err != nil {
+		c.markStateDirty()
+		return err
+	}
+	c.savedStates = encoded
+	return nil
+}
+
+// writeState stores the data by key in the state ConfigMap, which is created if nil.
+func (c *Controller) writeState(configMap *v1.ConfigMap, data map[string][]byte) error {
+	configMaps := c.stateClient.CoreV1().ConfigMaps(c.stateNamespace)
+	create := configMap == nil
+	if create {
+		configMap = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: c.stateConfigMap}}
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	for key, value := range data {
+		configMap.Data[key] = string(value)
+	}
+	var err error
+	if create {
+		_, err = configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{})
+	} else {
+		_, err = configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+func (c *Controller) markStateDirty() {
+	atomic.StoreInt32(&c.stateDirty, 1)
+}
+
+// saveStates persists the notification state of the controller and those of further clusters.
+func (c *Controller) saveStates() {
+	if err := c.saveState(); err != nil {
+		klog.ErrorS(err, "Persisting notification state failed")
+	}
+	for _, cluster := range c.clusters {
+		if err := cluster.saveState(); err != nil {
+			klog.ErrorS(err, "Persisting notification state failed", "cluster", cluster.cluster)
+		}
+	}
+}
+
+// runStateSync periodically persists the notification states and acknowledgements, and adopts the
+// silences, until stopCh is closed.
+func (c *Controller) runStateSync(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		c.saveStates()
+		if err := c.syncSilences(); err != nil {
+			klog.ErrorS(err, "Synchronizing silences failed")
+		}
+		if err := c.syncAcknowledgements(); err != nil {
+			klog.ErrorS(err, "Synchronizing acknowledgements failed")
+		}
+	}, stateSyncInterval, stopCh)
+}
+
+// keyPod returns the namespace/name part of a timeout key.
+func keyPod(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 2 {
+		return key
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+func keyNamespace(key string) string {
+	return strings.SplitN(key, "/", 2)[0]
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+func TestBackoffStepsArePersisted(t *testing.T) {
+	c, _ := newTestController(t)
+	c.stateConfigMap, c.stateNamespace = "mattermost-informer-state", "default"
+	key := "default/api/default/default"
+	c.backoffs.inc(key)
+	c.backoffs.inc(key)
+	if err := c.saveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, _ := newTestController(t)
+	restarted.stateClient = c.stateClient
+	restarted.stateConfigMap, restarted.stateNamespace = c.stateConfigMap, c.stateNamespace
+	if err := restarted.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if step := restarted.backoffs.get(key); step != 2 {
+		t.Errorf("restored backoff step %d, want 2", step)
+	}
+}
+
+func TestPendingEscalationsArePersisted(t *testing.T) {
+	c, _ := newTestController(t)
+	c.stateConfigMap, c.stateNamespace = "mattermost-informer-state", "default"
+	now := c.clock.Now()
+	alert := &notify.Alert{Time: now, Fingerprint: "f", Namespace: "default", Pod: "api", Container: "app", Severity: "critical"}
+	c.escalations.arm(alert, []string{"mattermost"}, now.Add(time.Minute))
+	c.escalations.posted("f", "post")
+	if err := c.saveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, _ := newTestController(t)
+	restarted.stateClient = c.stateClient
+	restarted.stateConfigMap, restarted.stateNamespace = c.stateConfigMap, c.stateNamespace
+	if err := restarted.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if due := restarted.escalations.due(now); len(due) != 0 {
+		t.Fatalf("escalation due before its deadline")
+	}
+	due := restarted.escalations.due(now.Add(time.Minute))
+	if len(due) != 1 || due[0].alert.Pod != "api" || len(due[0].postIDs) != 1 || !due[0].since.Equal(now) {
+		t.Fatalf("restored escalations %+v", due)
+	}
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/stream"
+	"k8s.io/klog/v2"
+)
+
+const (
+	syntheticNamespace = "mattermost-informer-synthetic"
+	syntheticContainer = "synthetic"
+	syntheticReason    = "SyntheticCrash"
+)
+
+// runSyntheticCrashes injects a synthetic crash every interval until stopCh is closed.
+func (c *Controller) runSyntheticCrashes(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			c.injectSyntheticCrash(context.Background())
+		}
+	}
+}
+
+// injectSyntheticCrash renders an alert for a fabricated crash and delivers it to the synthetic
+// channel via Mattermost, followed by the recovery of the container, which resolves the alert.
+// The pod does not exist, so the alert skips everything looking it up: minimum restarts,
+// backoffs, owners, logs and resources. Routes are ignored, so that no other notifier is paged,
+// and no events, records or history are written.
+func (c *Controller) injectSyntheticCrash(ctx context.Context) {
+	cfg, _ := c.settings()
+	channel := cfg.SyntheticChannel
+	if channel == "" {
+		channel = cfg.OpsChannel
+	}
+	now := c.clock.Now()
+	pod := fmt.Sprintf("synthetic-crash-%d", now.Unix())
+	cluster, environment := cfg.Cluster.Identity(c.cluster)
+	title, text, err := cfg.Templates.Render(cfg.Catalogs, cfg.Locale, &alertData{
+		Namespace:    syntheticNamespace,
+		Pod:          pod,
+		Container:    syntheticContainer,
+		Reason:       syntheticReason,
+		Severity:     SeverityInfo.String(),
+		RestartCount: 1,
+		Cluster:      cluster,
+		Environment:  environment,
+		Time:         now.In(cfg.Location(channel)),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Rendering synthetic crash failed")
+		return
+	}
+	alert := &notify.Alert{
+		Time:              now,
+		Fingerprint:       c.fingerprint(syntheticNamespace, pod, syntheticContainer),
+		Namespace:         syntheticNamespace,
+		Pod:               pod,
+		Container:         syntheticContainer,
+		Reason:            syntheticReason,
+		TerminationReason: syntheticReason,
+		Severity:          SeverityInfo.String(),
+		RestartCount:      1,
+		Title:             "[Synthetic] " + title,
+		Text:              text,
+		Channel:           channel,
+		Locale:            cfg.Locale,
+		Synthetic:         true,
+	}
+	c.identify(cfg, alert)
+	klog.InfoS("Injecting synthetic crash", "pod", klog.KRef(syntheticNamespace, pod), "channel", channel)
+	c.publish(stream.EventDetected, alert, "", nil)
+	notifiers := []string{notify.TypeMattermost}
+	if c.enqueueAlert(ctx, notify.TypeMattermost, alert, nil) {
+		c.firing.add(alert, notifiers)
+	}
+	c.recoverContainer(ctx, syntheticNamespace+"/"+pod+"/"+syntheticContainer)
+}
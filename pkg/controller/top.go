@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/records"
+	"k8s.io/klog/v2"
+)
+
+// buildTopCrashers counts the alerts sent between since and until per workload.
+func buildTopCrashers(list []records.Record, since, until time.Time) *weekStats {
+	stats := &weekStats{}
+	for _, record := range uniqueAlerts(list) {
+		sentAt := record.Spec.SentAt.Time
+		if sentAt.After(since) && !sentAt.After(until) {
+			stats.add(record)
+		}
+	}
+	return stats
+}
+
+// topCrashersMessage renders the limit workloads with the most alerts between since and until.
+func topCrashersMessage(stats *weekStats, since, until time.Time, limit int, location *time.Location) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#### Top crashing workloads\n%s to %s\n\n", since.In(location).Format("Mon, 2 Jan 15:04"),
+		until.In(location).Format("Mon, 2 Jan 15:04 MST"))
+	if stats.alerts == 0 {
+		buf.WriteString("No alerts in this time.")
+		return buf.String()
+	}
+	buf.WriteString("| # | Workload | Alerts | Mean time to recovery |\n|--:|:--|--:|--:|\n")
+	for i, workload := range rankStats(stats.workloads, limit) {
+		workloadStats := stats.workloads[workload]
+		fmt.Fprintf(&buf, "| %d | `%s` | %d | %s |\n", i+1, workload, workloadStats.alerts, workloadStats.mttr())
+	}
+	fmt.Fprintf(&buf, "\n%d alerts of %d workloads in total.", stats.alerts, len(stats.workloads))
+	return buf.String()
+}
+
+// parseWindow parses a duration like 12h, additionally accepting days like 7d.
+func parseWindow(s string) (time.Duration, error) {
+	var window time.Duration
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if window, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be positive")
+	}
+	return window, nil
+}
+
+// slashTop lists the workloads with the most alerts in the window, by default the one of the
+// configuration.
+func (c *Controller) slashTop(ctx context.Context, channel, window string) string {
+	if c.records == nil {
+		return "The leaderboard requires the informer to run with `--notification-records`."
+	}
+	cfg, _ := c.settings()
+	duration := cfg.TopCrashers.Window.Duration
+	if window != "" {
+		var err error
+		if duration, err = parseWindow(window); err != nil {
+			return fmt.Sprintf("Invalid window `%s`, use e.g. `24h` or `7d`.", window)
+		}
+	}
+	list, err := c.records.List(ctx)
+	if err != nil {
+		klog.ErrorS(err, "Listing notification records failed")
+		return "Could not list the notification records."
+	}
+	now := c.clock.Now()
+	stats := buildTopCrashers(list, now.Add(-duration), now)
+	return topCrashersMessage(stats, now.Add(-duration), now, cfg.TopCrashers.Limit, c.location(channel))
+}
+
+// runTopCrashers posts the leaderboard on schedule until stopCh is closed.
+func (c *Controller) runTopCrashers(stopCh <-chan struct{}) {
+	c.runSchedule("top crashers", func(cfg *config.Config) config.Report { return cfg.TopCrashers.Report }, c.postTopCrashers, stopCh)
+}
+
+// postTopCrashers posts the leaderboard of the window ending at end. With sharding, only the first
+// shard posts it, as all shards share the records.
+func (c *Controller) postTopCrashers(ctx context.Context, end time.Time) error {
+	if !c.runsGlobalTasks() {
+		klog.V(2).InfoS("Leaving the top crashers to the first shard")
+		return nil
+	}
+	list, err := c.records.List(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, mattermost := c.settings()
+	channel := cfg.TopCrashers.Channel
+	if channel == "" {
+		channel = cfg.OpsChannel
+	}
+	since := end.Add(-cfg.TopCrashers.Window.Duration)
+	stats := buildTopCrashers(list, since, end)
+	if err := mattermost.Send(ctx, channel, topCrashersMessage(stats, since, end, cfg.TopCrashers.Limit, cfg.Location(channel))); err != nil {
+		return err
+	}
+	klog.InfoS("Posted top crashers", "channel", channel, "alerts", stats.alerts)
+	return nil
+}
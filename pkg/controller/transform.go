@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const annotationLastApplied = "kubectl.kubernetes.io/last-applied-configuration"
+
+// stripObject drops the parts of cached objects which are never read by the controller
+// to reduce the memory used by the informers.
+func stripObject(obj interface{}) (interface{}, error) {
+	if accessor, ok := obj.(metav1.Object); ok {
+		accessor.SetManagedFields(nil)
+		if annotations := accessor.GetAnnotations(); annotations != nil {
+			delete(annotations, annotationLastApplied)
+		}
+	}
+	if pod, ok := obj.(*v1.Pod); ok {
+		stripPodSpec(&pod.Spec)
+	}
+	return obj, nil
+}
+
+// stripPodSpec keeps the container names, images and resources, dropping the remaining spec.
+func stripPodSpec(spec *v1.PodSpec) {
+	stripContainers(spec.InitContainers)
+	stripContainers(spec.Containers)
+	spec.EphemeralContainers = nil
+	spec.Volumes = nil
+	spec.Affinity = nil
+	spec.Tolerations = nil
+	spec.TopologySpreadConstraints = nil
+	spec.ImagePullSecrets = nil
+}
+
+func stripContainers(containers []v1.Container) {
+	for i := range containers {
+		containers[i] = v1.Container{
+			Name:      containers[i].Name,
+			Image:     containers[i].Image,
+			Resources: containers[i].Resources,
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"github.com/lnsp/mattermost-informer/pkg/rules"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/utils/clock"
+)
+
+// channelChecker is implemented by notifiers able to verify that a channel exists.
+type channelChecker interface {
+	CheckChannel(channel string) error
+}
+
+// Validate checks the configuration file, or the MattermostInformer and AlertRule resources of
+// the given manifests, and returns all problems found. Unless offline, it connects to the
+// Mattermost servers and verifies that the channels of routes and rules exist.
+func Validate(opts Options, manifests []string, offline bool) []error {
+	var (
+		errs       []error
+		cfg        *config.Config
+		alertRules []*rules.Rule
+	)
+	if len(manifests) == 0 {
+		var err error
+		if cfg, err = opts.LoadConfig(); err != nil {
+			return []error{err}
+		}
+	}
+	for _, path := range manifests {
+		objects, err := readManifest(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, obj := range objects {
+			manifestCfg, rule, err := parseResource(obj)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s %s: %v", path, obj.GetKind(), obj.GetName(), err))
+				continue
+			}
+			if manifestCfg != nil && cfg == nil {
+				opts.apply(manifestCfg)
+				cfg = manifestCfg
+			}
+			if rule != nil {
+				alertRules = append(alertRules, rule)
+			}
+		}
+	}
+	if offline {
+		return errs
+	}
+	if cfg == nil {
+		// Manifests without a configuration are checked against the configuration file.
+		var err error
+		if cfg, err = opts.LoadConfig(); err != nil {
+			return append(errs, fmt.Errorf("cannot verify channels: %v", err))
+		}
+	}
+	return append(errs, checkChannels(cfg, alertRules)...)
+}
+
+// readManifest reads the resources of a YAML file, which may contain multiple documents.
+func readManifest(path string) ([]*unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var objects []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(obj.Object) > 0 {
+			objects = append(objects, obj)
+		}
+	}
+}
+
+// parseResource parses a MattermostInformer or AlertRule resource.
+func parseResource(obj *unstructured.Unstructured) (*config.Config, *rules.Rule, error) {
+	switch obj.GroupVersionKind().GroupKind() {
+	case config.ResourceGVR.GroupVersion().WithKind("MattermostInformer").GroupKind():
+		cfg, err := config.FromResource(obj)
+		return cfg, nil, err
+	case rules.GVR.GroupVersion().WithKind("AlertRule").GroupKind():
+		rule, err := rules.FromResource(obj)
+		return nil, rule, err
+	}
+	return nil, nil, fmt.Errorf("unsupported resource of %s", obj.GetAPIVersion())
+}
+
+// checkChannels connects to the Mattermost servers and verifies the channels of the routes and rules.
+func checkChannels(cfg *config.Config, alertRules []*rules.Rule) []error {
+	mattermost, err := utils.NewMattermostClient(cfg.Mattermost)
+	if err != nil {
+		return []error{fmt.Errorf("mattermost: %v", err)}
+	}
+	notifiers, err := newNotifiers(cfg, mattermost, clock.RealClock{})
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	check := func(source, name, channel string) {
+		checker, ok := notifiers[name].(channelChecker)
+		if !ok || channel == "" {
+			return
+		}
+		if err := checker.CheckChannel(channel); err != nil {
+			errs = append(errs, fmt.Errorf("%s: notifier %s: %v", source, name, err))
+		}
+	}
+	for i, route := range cfg.Routes {
+		names := route.Notifiers
+		if len(names) == 0 {
+			names = []string{notify.TypeMattermost}
+		}
+		for _, name := range names {
+			check(fmt.Sprintf("route %d", i+1), name, route.Channel)
+		}
+	}
+	for _, rule := range alertRules {
+		check(fmt.Sprintf("AlertRule %s/%s", rule.Namespace, rule.Name), notify.TypeMattermost, rule.Channel)
+	}
+	return errs
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Reasons for queueing a pod.
+const (
+	reasonAdded     = "added"
+	reasonUpdated   = "updated"
+	reasonDeleted   = "deleted"
+	reasonRebalance = "rebalance"
+	reasonRetry     = "retry"
+)
+
+// workItem identifies a pod queued for processing. It only holds the namespace and name, so that
+// the queue collapses all updates of a pod waiting to be processed into a single item.
+type workItem struct {
+	Namespace string
+	Name      string
+}
+
+// key returns the namespace/name key of the pod in the informer caches.
+func (w workItem) key() string {
+	return w.Namespace + "/" + w.Name
+}
+
+// newWorkItem creates a work item for a pod, unwrapping the tombstones of deleted pods.
+func newWorkItem(obj interface{}) (workItem, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return workItem{}, false
+	}
+	return workItem{Namespace: pod.Namespace, Name: pod.Name}, true
+}
+
+// queueReasons keeps the latest reason a pod has been queued for until it is processed. The zero
+// value is ready to use.
+type queueReasons struct {
+	mu      sync.Mutex
+	reasons map[workItem]string
+}
+
+func (r *queueReasons) set(item workItem, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reasons == nil {
+		r.reasons = make(map[workItem]string)
+	}
+	r.reasons[item] = reason
+}
+
+// take removes and returns the reason the item has last been queued for.
+func (r *queueReasons) take(item workItem) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reason := r.reasons[item]
+	delete(r.reasons, item)
+	return reason
+}
@@ -0,0 +1,93 @@
+// Package grafana models the subset of the Grafana dashboard JSON used by the bundled dashboard.
+package grafana
+
+// SchemaVersion is the version of the dashboard JSON model the dashboards are written in.
+const SchemaVersion = 39
+
+// Dashboard is a Grafana dashboard which can be imported using the UI or provisioned from a file.
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Timezone      string     `json:"timezone"`
+	Editable      bool       `json:"editable"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh,omitempty"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+// TimeRange is a time range relative to now, e.g. now-7d to now.
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Templating holds the variables of a dashboard.
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard variable, either selecting the data source or label values.
+type Variable struct {
+	Name       string         `json:"name"`
+	Label      string         `json:"label,omitempty"`
+	Type       string         `json:"type"`
+	Query      string         `json:"query"`
+	Datasource *DatasourceRef `json:"datasource,omitempty"`
+	// Refresh 2 updates the values whenever the time range changes.
+	Refresh    int  `json:"refresh,omitempty"`
+	Multi      bool `json:"multi,omitempty"`
+	IncludeAll bool `json:"includeAll,omitempty"`
+	// AllValue replaces the values selected by All, e.g. .* to match empty values as well.
+	AllValue string `json:"allValue,omitempty"`
+}
+
+// DatasourceRef references a data source, usually through the datasource variable.
+type DatasourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// Panel is a panel of a dashboard, like a time series or a table.
+type Panel struct {
+	ID          int            `json:"id"`
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	GridPos     GridPos        `json:"gridPos"`
+	Datasource  *DatasourceRef `json:"datasource,omitempty"`
+	Targets     []Target       `json:"targets,omitempty"`
+	FieldConfig FieldConfig    `json:"fieldConfig"`
+}
+
+// GridPos places a panel on the grid of 24 columns.
+type GridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Target is a Prometheus query of a panel.
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	// Instant queries only the end of the time range, e.g. for tables.
+	Instant bool   `json:"instant,omitempty"`
+	Format  string `json:"format,omitempty"`
+}
+
+// FieldConfig configures how the values of a panel are displayed.
+type FieldConfig struct {
+	Defaults  FieldDefaults `json:"defaults"`
+	Overrides []interface{} `json:"overrides"`
+}
+
+// FieldDefaults holds the unit of the values, e.g. s or short.
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
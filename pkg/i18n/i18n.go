@@ -0,0 +1,158 @@
+// Package i18n translates the titles, texts and field labels of alerts.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale messages are written in, it needs no catalog.
+const DefaultLocale = "en"
+
+// Catalog maps English messages to their translation. Messages may be format strings.
+type Catalog map[string]string
+
+// Catalogs are message catalogs by locale, e.g. de or pt-BR.
+type Catalogs map[string]Catalog
+
+var builtin = Catalogs{
+	"de": {
+		"Crash loop detected!": "Absturzschleife erkannt!",
+		"Container %s of pod %s keeps crashing, maybe its time to intervene.": "Container %s von Pod %s stürzt wiederholt ab, vielleicht ist es Zeit einzugreifen.",
+		"Resolved":     "Behoben",
+		"Logs":         "Logs",
+		"Reason":       "Grund",
+		"Severity":     "Schweregrad",
+		"Incident":     "Vorfall",
+		"Links":        "Links",
+		"Playbook run": "Playbook-Ausführung",
+		"Kibana logs":  "Kibana-Logs",
+		"Cluster":      "Cluster",
+		"Namespace":    "Namespace",
+		"Pod":          "Pod",
+		"Container":    "Container",
+		"Restarts":     "Neustarts",
+
+		"Last crash at %s.": "Letzter Absturz um %s.",
+
+		"[... %d lines omitted ...]":                 "[... %d Zeilen ausgelassen ...]",
+		"[... %d characters omitted ...]":            "[... %d Zeichen ausgelassen ...]",
+		"Complete logs":                              "Vollständige Logs",
+		"Container %s of pod %s recovered after %s.": "Container %s von Pod %s hat sich nach %s erholt.",
+
+		"Flapping": "Instabil",
+		"Container %s of pod %s recovered and crashed again %d times within %s. Further alerts are suppressed until it stays ready for %s.": "Container %s von Pod %s hat sich erholt und ist %d Mal innerhalb von %s erneut abgestürzt. Weitere Alarme werden unterdrückt, bis er %s lang bereit bleibt.",
+
+		"Acknowledge": "Bestätigen",
+		"Escalated":   "Eskaliert",
+		"Nobody acknowledged this alert within %s.": "Niemand hat diesen Alarm innerhalb von %s bestätigt.",
+
+		"Unusual restart rate of %s": "Ungewöhnliche Neustartrate von %s",
+		"Workload %s in namespace %s restarted %d times since %s, while it usually restarts %.1f ± %.1f times per %s.": "Workload %s in Namespace %s wurde %d Mal neu gestartet (seit %s), während er üblicherweise %.1f ± %.1f Mal pro %s neu startet.",
+
+		"Resources":                    "Ressourcen",
+		"CPU":                          "CPU",
+		"Memory":                       "Arbeitsspeicher",
+		"requests %s":                  "Requests %s",
+		"limits %s":                    "Limits %s",
+		"usage %s":                     "Verbrauch %s",
+		"usage %s (%d%% of the limit)": "Verbrauch %s (%d%% des Limits)",
+		"no requests or limits":        "keine Requests oder Limits",
+	},
+	"fr": {
+		"Crash loop detected!": "Boucle de plantage détectée !",
+		"Container %s of pod %s keeps crashing, maybe its time to intervene.": "Le conteneur %s du pod %s plante en boucle, il est peut-être temps d'intervenir.",
+		"Resolved":     "Résolu",
+		"Logs":         "Journaux",
+		"Reason":       "Raison",
+		"Severity":     "Gravité",
+		"Incident":     "Incident",
+		"Links":        "Liens",
+		"Playbook run": "Exécution du playbook",
+		"Kibana logs":  "Journaux Kibana",
+		"Cluster":      "Cluster",
+		"Namespace":    "Namespace",
+		"Pod":          "Pod",
+		"Container":    "Conteneur",
+		"Restarts":     "Redémarrages",
+
+		"Last crash at %s.": "Dernier plantage à %s.",
+
+		"[... %d lines omitted ...]":                 "[... %d lignes omises ...]",
+		"[... %d characters omitted ...]":            "[... %d caractères omis ...]",
+		"Complete logs":                              "Journaux complets",
+		"Container %s of pod %s recovered after %s.": "Le conteneur %s du pod %s s'est rétabli après %s.",
+
+		"Flapping": "Instable",
+		"Container %s of pod %s recovered and crashed again %d times within %s. Further alerts are suppressed until it stays ready for %s.": "Le conteneur %s du pod %s s'est rétabli puis a de nouveau planté %d fois en %s. Les alertes suivantes sont supprimées jusqu'à ce qu'il reste prêt pendant %s.",
+
+		"Acknowledge": "Acquitter",
+		"Escalated":   "Escaladé",
+		"Nobody acknowledged this alert within %s.": "Personne n'a acquitté cette alerte en %s.",
+
+		"Unusual restart rate of %s": "Taux de redémarrage inhabituel de %s",
+		"Workload %s in namespace %s restarted %d times since %s, while it usually restarts %.1f ± %.1f times per %s.": "Le workload %s du namespace %s a redémarré %d fois depuis %s, alors qu'il redémarre habituellement %.1f ± %.1f fois par %s.",
+
+		"Resources":                    "Ressources",
+		"CPU":                          "CPU",
+		"Memory":                       "Mémoire",
+		"requests %s":                  "requests %s",
+		"limits %s":                    "limites %s",
+		"usage %s":                     "utilisation %s",
+		"usage %s (%d%% of the limit)": "utilisation %s (%d%% de la limite)",
+		"no requests or limits":        "ni requests ni limites",
+	},
+}
+
+// Locales returns the locales with a builtin catalog besides the default locale.
+func Locales() []string {
+	locales := []string{DefaultLocale}
+	for locale := range builtin {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// Supported checks if messages can be translated to the locale using the builtin catalogs or
+// the given ones. Regional locales like de-CH are supported if their language is.
+func Supported(locale string, catalogs Catalogs) bool {
+	for _, l := range []string{locale, language(locale)} {
+		if l == DefaultLocale || builtin[l] != nil || catalogs[l] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Translate returns the translation of the message, or the message if the locale has none. The
+// catalogs take precedence over the builtin ones, e.g. those of the configuration, and the catalog
+// of a regional locale like de-CH falls back to the catalog of its language.
+func (c Catalogs) Translate(locale, message string) string {
+	if locale == "" || locale == DefaultLocale {
+		return message
+	}
+	for _, l := range []string{locale, language(locale)} {
+		if translation, ok := c[l][message]; ok {
+			return translation
+		}
+		if translation, ok := builtin[l][message]; ok {
+			return translation
+		}
+	}
+	return message
+}
+
+// Sprintf translates the format and formats it with the arguments.
+func (c Catalogs) Sprintf(locale, format string, args ...interface{}) string {
+	return fmt.Sprintf(c.Translate(locale, format), args...)
+}
+
+// language returns the language of a locale, e.g. pt for pt-BR or pt_BR.
+func language(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
@@ -0,0 +1,43 @@
+package i18n
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	catalogs := Catalogs{
+		"de":    {"Logs": "Protokolle"},
+		"nl":    {"Logs": "Logboeken"},
+		"de-CH": {"Pod": "Pöd"},
+	}
+	tests := []struct {
+		catalogs Catalogs
+		locale   string
+		message  string
+		want     string
+	}{
+		{nil, "", "Logs", "Logs"},
+		{nil, "de", "Logs", "Logs"},
+		{nil, "de", "Reason", "Grund"},
+		{nil, "de-CH", "Reason", "Grund"},
+		{nil, "nl", "Logs", "Logs"},
+		{catalogs, "de", "Logs", "Protokolle"},
+		{catalogs, "de", "Reason", "Grund"},
+		{catalogs, "nl_BE", "Logs", "Logboeken"},
+		{catalogs, "de-CH", "Pod", "Pöd"},
+		{catalogs, "de-CH", "Logs", "Protokolle"},
+		{catalogs, "fr", "Untranslated", "Untranslated"},
+	}
+	for _, test := range tests {
+		if got := test.catalogs.Translate(test.locale, test.message); got != test.want {
+			t.Errorf("Translate(%q, %q) = %q, want %q", test.locale, test.message, got, test.want)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	catalogs := Catalogs{"nl": {}}
+	for locale, want := range map[string]bool{"": false, "en": true, "de": true, "de-AT": true, "nl": true, "nl-BE": true, "xx": false} {
+		if got := Supported(locale, catalogs); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", locale, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package informer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/client"
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/lnsp/mattermost-informer/pkg/state"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Informer notifies about crashing pods from within another program, e.g. an operator. Unlike the
+// mattermost-informer binary it serves no HTTP endpoints and neither elects a leader nor shards.
+// Further notifier types are added with notify.Register and configured like the built-in types.
+type Informer struct {
+	opts       controller.Options
+	cfg        *config.Config
+	restConfig *rest.Config
+	clientset  kubernetes.Interface
+	namespaces []string
+	store      state.Store
+}
+
+// Option configures an Informer.
+type Option func(*Informer)
+
+// WithOptions replaces the controller options, which default to controller.DefaultOptions. Options
+// which only apply to the binary, like the metrics address, are ignored.
+func WithOptions(opts controller.Options) Option {
+	return func(i *Informer) {
+		i.opts = opts
+	}
+}
+
+// WithConfig sets the configuration, by default it is loaded from the ConfigPath of the options.
+func WithConfig(cfg *config.Config) Option {
+	return func(i *Informer) {
+		i.cfg = cfg
+	}
+}
+
+// WithRESTConfig sets the connection to the cluster, by default the in-cluster configuration is used.
+func WithRESTConfig(restConfig *rest.Config) Option {
+	return func(i *Informer) {
+		i.restConfig = restConfig
+	}
+}
+
+// WithClientset sets the client used to watch the pods, taking precedence over WithRESTConfig.
+func WithClientset(clientset kubernetes.Interface) Option {
+	return func(i *Informer) {
+		i.clientset = clientset
+	}
+}
+
+// WithNamespaces sets the watched namespaces, by default all namespaces are watched.
+func WithNamespaces(namespaces ...string) Option {
+	return func(i *Informer) {
+		i.namespaces = namespaces
+	}
+}
+
+// WithStateStore replaces the in-memory store of the time of the last notifications.
+func WithStateStore(store state.Store) Option {
+	return func(i *Informer) {
+		i.store = store
+	}
+}
+
+// NewInformer creates an informer with the given options.
+func NewInformer(opts ...Option) *Informer {
+	i := &Informer{
+		opts: controller.DefaultOptions(),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Run watches the pods and sends notifications until ctx is cancelled. The pods remaining in the
+// queue are processed before Run returns.
+func (i *Informer) Run(ctx context.Context) error {
+	cfg := i.cfg
+	if cfg == nil {
+		var err error
+		if cfg, err = i.opts.LoadConfig(); err != nil {
+			return err
+		}
+	}
+	clientset, err := i.newClientset()
+	if err != nil {
+		return err
+	}
+	mattermost, err := utils.NewMattermostClient(cfg.Mattermost)
+	if err != nil {
+		return err
+	}
+	namespaces := i.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	c, err := controller.NewForClientset(i.opts, cfg, clientset, mattermost, namespaces)
+	if err != nil {
+		return err
+	}
+	if i.store != nil {
+		c.SetStateStore(i.store)
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	defer close(done)
+	// The queue is drained after ctx is cancelled, the requests are aborted after the shutdown timeout.
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		close(stop)
+		select {
+		case <-time.After(i.opts.ShutdownTimeout):
+			cancelWork()
+		case <-done:
+		}
+	}()
+	c.Run(workCtx, i.opts.Workers, stop)
+	return nil
+}
+
+func (i *Informer) newClientset() (kubernetes.Interface, error) {
+	if i.clientset != nil {
+		return i.clientset, nil
+	}
+	if i.restConfig == nil {
+		return client.InCluster()
+	}
+	clientset, err := kubernetes.NewForConfig(i.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create clientset: %v", err)
+	}
+	return clientset, nil
+}
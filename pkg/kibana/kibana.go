@@ -0,0 +1,71 @@
+package kibana
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Config configures links to the logs of alerts in Kibana Discover.
+type Config struct {
+	// URL is the base URL of Kibana, e.g. https://kibana.example.com. No link is added if empty.
+	URL string `json:"url"`
+	// IndexPattern is the ID of the index pattern (data view) holding the logs.
+	IndexPattern string `json:"indexPattern"`
+	// Query is a Go template of the KQL query with the fields .Namespace, .Pod and .Container.
+	Query string `json:"query"`
+	// Before and After define the time range around the crash.
+	Before metav1.Duration `json:"before"`
+	After  metav1.Duration `json:"after"`
+
+	query *template.Template
+}
+
+// DefaultQuery selects the logs of a container using the fields set by Filebeat.
+const DefaultQuery = `kubernetes.namespace:"{{.Namespace}}" and kubernetes.pod.name:"{{.Pod}}" and kubernetes.container.name:"{{.Container}}"`
+
+// Compile parses the query template, it must be called before DiscoverURL.
+func (cfg *Config) Compile() error {
+	query := cfg.Query
+	if query == "" {
+		query = DefaultQuery
+	}
+	var err error
+	if cfg.query, err = template.New("query").Parse(query); err != nil {
+		return fmt.Errorf("invalid Kibana query: %v", err)
+	}
+	return nil
+}
+
+// Enabled reports whether links to Kibana are added.
+func (cfg *Config) Enabled() bool {
+	return cfg.URL != ""
+}
+
+// DiscoverURL returns a link to Discover showing the logs of the container around the given time.
+func (cfg *Config) DiscoverURL(namespace, pod, container string, at time.Time) (string, error) {
+	var query bytes.Buffer
+	if err := cfg.query.Execute(&query, map[string]string{
+		"Namespace": namespace,
+		"Pod":       pod,
+		"Container": container,
+	}); err != nil {
+		return "", fmt.Errorf("could not render Kibana query: %v", err)
+	}
+	global := fmt.Sprintf("(time:(from:%s,to:%s))",
+		rison(at.Add(-cfg.Before.Duration).UTC().Format(time.RFC3339)),
+		rison(at.Add(cfg.After.Duration).UTC().Format(time.RFC3339)))
+	app := fmt.Sprintf("(index:%s,query:(language:kuery,query:%s))", rison(cfg.IndexPattern), rison(query.String()))
+	return fmt.Sprintf("%s/app/discover#/?_g=%s&_a=%s", strings.TrimSuffix(cfg.URL, "/"),
+		url.QueryEscape(global), url.QueryEscape(app)), nil
+}
+
+// rison encodes a string in Rison, the URL state format of Kibana.
+func rison(s string) string {
+	return "'" + strings.NewReplacer("!", "!!", "'", "!'").Replace(s) + "'"
+}
@@ -0,0 +1,143 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Config configures fetching logs from Loki.
+type Config struct {
+	// URL is the base URL of Loki, e.g. http://loki.monitoring:3100. Loki is not used if empty.
+	URL string `json:"url"`
+	// Query is a Go template of the LogQL stream selector with the fields .Namespace, .Pod and .Container.
+	Query string `json:"query"`
+	// Window is how far back logs are fetched.
+	Window metav1.Duration `json:"window"`
+	// Limit is the maximum number of log lines.
+	Limit int `json:"limit"`
+	// Replace uses the logs from Loki instead of the Kubernetes logs API, by default they are appended.
+	Replace bool `json:"replace"`
+	// TenantID is sent in the X-Scope-OrgID header for multi-tenant Loki installations.
+	TenantID string `json:"tenantID"`
+	// BearerTokenFile is read for the token sent in the Authorization header.
+	BearerTokenFile string `json:"bearerTokenFile"`
+
+	query *template.Template
+}
+
+// DefaultQuery selects the logs of a container using the labels set by Promtail.
+const DefaultQuery = `{namespace="{{.Namespace}}", pod="{{.Pod}}", container="{{.Container}}"}`
+
+// Compile parses the query template, it must be called before creating a client.
+func (cfg *Config) Compile() error {
+	query := cfg.Query
+	if query == "" {
+		query = DefaultQuery
+	}
+	var err error
+	if cfg.query, err = template.New("query").Parse(query); err != nil {
+		return fmt.Errorf("invalid Loki query: %v", err)
+	}
+	return nil
+}
+
+// Enabled reports whether logs are fetched from Loki.
+func (cfg *Config) Enabled() bool {
+	return cfg.URL != ""
+}
+
+// Client queries container logs from Loki.
+type Client struct {
+	cfg    *Config
+	client *http.Client
+}
+
+// NewClient creates a client for the compiled configuration.
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type queryResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result []struct {
+			Values [][2]string `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+type entry struct {
+	timestamp int64
+	line      string
+}
+
+// Logs returns the log lines of the container in the configured window before end, oldest first.
+func (c *Client) Logs(ctx context.Context, namespace, pod, container string, end time.Time) ([]byte, error) {
+	var query bytes.Buffer
+	if err := c.cfg.query.Execute(&query, map[string]string{
+		"Namespace": namespace,
+		"Pod":       pod,
+		"Container": container,
+	}); err != nil {
+		return nil, fmt.Errorf("could not render Loki query: %v", err)
+	}
+	params := url.Values{}
+	params.Set("query", query.String())
+	params.Set("start", strconv.FormatInt(end.Add(-c.cfg.Window.Duration).UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	params.Set("limit", strconv.Itoa(c.cfg.Limit))
+	params.Set("direction", "backward")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.cfg.URL, "/")+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create Loki request: %v", err)
+	}
+	if c.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.cfg.TenantID)
+	}
+	if c.cfg.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(c.cfg.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read Loki token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query Loki: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("querying Loki failed with status %s: %s", resp.Status, body)
+	}
+	var result queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("could not decode Loki response: %v", err)
+	}
+	var entries []entry
+	for _, stream := range result.Data.Result {
+		for _, value := range stream.Values {
+			timestamp, _ := strconv.ParseInt(value[0], 10, 64)
+			entries = append(entries, entry{timestamp: timestamp, line: value[1]})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].timestamp < entries[j].timestamp })
+	var logs bytes.Buffer
+	for _, e := range entries {
+		logs.WriteString(strings.TrimSuffix(e.line, "\n"))
+		logs.WriteByte('\n')
+	}
+	return logs.Bytes(), nil
+}
@@ -0,0 +1,378 @@
+package mattermostfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// Post is a message received by the fake server, via the API or the incoming webhook.
+type Post struct {
+	ID string
+	// Channel is the name of the channel, empty for webhook posts without a channel.
+	Channel     string
+	Message     string
+	Attachments []*model.SlackAttachment
+	Priority    string
+	IconURL     string
+	Webhook     bool
+}
+
+// PlaybookRun is a run started using the Playbooks API.
+type PlaybookRun struct {
+	ID          string
+	PlaybookID  string
+	Name        string
+	Description string
+}
+
+// File is a file uploaded to a channel.
+type File struct {
+	ID      string
+	Channel string
+	Name    string
+	Data    []byte
+}
+
+// Server is an in-memory Mattermost server implementing the subset of the API used by the
+// informer: authentication, team and channel lookup, posts, file uploads, playbook runs and an
+// incoming webhook. It is meant for asserting the messages produced by the informer in tests.
+type Server struct {
+	*httptest.Server
+	// Token is the access token accepted by the server.
+	Token string
+
+	user *model.User
+	team *model.Team
+
+	mu       sync.Mutex
+	channels map[string]*model.Channel
+	posts    []Post
+	runs     []PlaybookRun
+	files    []File
+	// failures is the number of following post requests answered with failStatus.
+	failures   int
+	failStatus int
+	changed    *sync.Cond
+}
+
+// NewServer starts a server with the given team and channels, accepting the given token.
+// It must be closed after use.
+func NewServer(token, team string, channels ...string) *Server {
+	s := &Server{
+		Token:    token,
+		user:     &model.User{Id: model.NewId(), Username: "informer"},
+		team:     &model.Team{Id: model.NewId(), Name: team, DisplayName: team},
+		channels: make(map[string]*model.Channel),
+	}
+	s.changed = sync.NewCond(&s.mu)
+	for _, channel := range channels {
+		s.AddChannel(channel)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/", s.authenticated(s.serveAPI))
+	mux.HandleFunc("/plugins/playbooks/api/v0/runs", s.authenticated(s.servePlaybookRun))
+	mux.HandleFunc("/hooks/", s.serveWebhook)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns the configuration connecting to the server, with the first channel as default channel.
+func (s *Server) Config() utils.MattermostConfig {
+	cfg := utils.MattermostConfig{URL: s.URL, Token: s.Token, Team: s.team.Name}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.channels {
+		if cfg.Channel == "" || name < cfg.Channel {
+			cfg.Channel = name
+		}
+	}
+	return cfg
+}
+
+// WebhookURL returns the URL of the incoming webhook, which posts to the given default channel.
+func (s *Server) WebhookURL(channel string) string {
+	return s.URL + "/hooks/" + channel
+}
+
+// AddChannel creates a channel in the team.
+func (s *Server) AddChannel(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[name] = &model.Channel{Id: model.NewId(), TeamId: s.team.Id, Name: name, DisplayName: name}
+}
+
+// FailPosts answers the next n post requests with the given HTTP status, e.g. to simulate an outage.
+func (s *Server) FailPosts(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures, s.failStatus = n, status
+}
+
+// Posts returns the received posts in the order they were received.
+func (s *Server) Posts() []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Post(nil), s.posts...)
+}
+
+// WaitForPosts waits until at least n posts have been received and returns them. It fails once
+// the timeout has passed, since notifications are sent in the background.
+func (s *Server) WaitForPosts(n int, timeout time.Duration) ([]Post, error) {
+	deadline := time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		s.changed.Broadcast()
+		s.mu.Unlock()
+	})
+	defer deadline.Stop()
+	start := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.posts) < n {
+		if time.Since(start) >= timeout {
+			return append([]Post(nil), s.posts...), fmt.Errorf("received %d of %d posts within %s", len(s.posts), n, timeout)
+		}
+		s.changed.Wait()
+	}
+	return append([]Post(nil), s.posts...), nil
+}
+
+// PlaybookRuns returns the started playbook runs.
+func (s *Server) PlaybookRuns() []PlaybookRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PlaybookRun(nil), s.runs...)
+}
+
+// Files returns the uploaded files.
+func (s *Server) Files() []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]File(nil), s.files...)
+}
+
+// Reset forgets the received posts, playbook runs and files.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts, s.runs, s.files = nil, nil, nil
+}
+
+func (s *Server) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fields := strings.Fields(r.Header.Get(model.HEADER_AUTH))
+		if len(fields) != 2 || !strings.EqualFold(fields[0], model.HEADER_BEARER) || fields[1] != s.Token {
+			writeError(w, http.StatusUnauthorized, "api.context.session_expired.app_error", "invalid or expired session")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v4/"), "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(path) == 2 && path[0] == "users" && path[1] == "me":
+		writeJSON(w, http.StatusOK, s.user)
+	case r.Method == http.MethodGet && len(path) == 3 && path[0] == "teams" && path[1] == "name":
+		if path[2] != s.team.Name {
+			writeError(w, http.StatusNotFound, "app.team.get_by_name.missing.app_error", "team not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.team)
+	case r.Method == http.MethodGet && len(path) == 5 && path[0] == "teams" && path[2] == "channels" && path[3] == "name":
+		s.mu.Lock()
+		channel, ok := s.channels[path[4]]
+		s.mu.Unlock()
+		if path[1] != s.team.Id || !ok {
+			writeError(w, http.StatusNotFound, "app.channel.get_by_name.missing.app_error", "channel not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, channel)
+	case r.Method == http.MethodPost && len(path) == 1 && path[0] == "posts":
+		s.serveCreatePost(w, r)
+	case r.Method == http.MethodPost && len(path) == 1 && path[0] == "files":
+		s.serveUploadFile(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "api.context.404.app_error", "not implemented by the fake server")
+	}
+}
+
+// apiPost is a post with the priority metadata sent by the informer.
+type apiPost struct {
+	*model.Post
+	Metadata *struct {
+		Priority *struct {
+			Priority string `json:"priority"`
+		} `json:"priority"`
+	} `json:"metadata"`
+}
+
+func (s *Server) serveCreatePost(w http.ResponseWriter, r *http.Request) {
+	var req apiPost
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Post == nil {
+		writeError(w, http.StatusBadRequest, "api.post.create_post.invalid.app_error", "invalid post")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail(w) {
+		return
+	}
+	channel := s.channelName(req.ChannelId)
+	if channel == "" {
+		writeError(w, http.StatusForbidden, "api.context.permissions.app_error", "unknown channel")
+		return
+	}
+	post := Post{
+		ID:          model.NewId(),
+		Channel:     channel,
+		Message:     req.Message,
+		Attachments: attachments(req.Props["attachments"]),
+	}
+	if url, ok := req.Props["override_icon_url"].(string); ok {
+		post.IconURL = url
+	}
+	if req.Metadata != nil && req.Metadata.Priority != nil {
+		post.Priority = req.Metadata.Priority.Priority
+	}
+	s.addPost(post)
+	req.Post.Id = post.ID
+	req.Post.CreateAt = model.GetMillis()
+	writeJSON(w, http.StatusCreated, req.Post)
+}
+
+func (s *Server) serveUploadFile(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "api.file.upload_file.invalid.app_error", "invalid upload")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response := &model.FileUploadResponse{}
+	for _, headers := range r.MultipartForm.File {
+		for _, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "api.file.upload_file.invalid.app_error", "invalid upload")
+				return
+			}
+			data, _ := io.ReadAll(file)
+			file.Close()
+			id := model.NewId()
+			s.files = append(s.files, File{ID: id, Channel: s.channelName(r.FormValue("channel_id")), Name: header.Filename, Data: data})
+			response.FileInfos = append(response.FileInfos, &model.FileInfo{Id: id, Name: header.Filename, Size: int64(len(data))})
+		}
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func (s *Server) servePlaybookRun(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		PlaybookID  string `json:"playbook_id"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeError(w, http.StatusBadRequest, "playbooks.invalid", "invalid playbook run")
+		return
+	}
+	run := PlaybookRun{ID: model.NewId(), PlaybookID: req.PlaybookID, Name: req.Name, Description: req.Description}
+	s.mu.Lock()
+	s.runs = append(s.runs, run)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]string{"id": run.ID})
+}
+
+func (s *Server) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		*model.IncomingWebhookRequest
+		Priority *struct {
+			Priority string `json:"priority"`
+		} `json:"priority"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil || req.IncomingWebhookRequest == nil {
+		http.Error(w, "invalid webhook request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail(w) {
+		return
+	}
+	post := Post{
+		ID:          model.NewId(),
+		Channel:     req.ChannelName,
+		Message:     req.Text,
+		Attachments: req.Attachments,
+		IconURL:     req.IconURL,
+		Webhook:     true,
+	}
+	if post.Channel == "" {
+		post.Channel = strings.TrimPrefix(r.URL.Path, "/hooks/")
+	}
+	if req.Priority != nil {
+		post.Priority = req.Priority.Priority
+	}
+	s.addPost(post)
+	w.Write([]byte("ok"))
+}
+
+// channelName returns the name of the channel with the given ID, or an empty string if there is
+// none. The lock must be held.
+func (s *Server) channelName(id string) string {
+	for name, channel := range s.channels {
+		if channel.Id == id {
+			return name
+		}
+	}
+	return ""
+}
+
+// fail answers the request with the configured failure, if any. The lock must be held.
+func (s *Server) fail(w http.ResponseWriter) bool {
+	if s.failures <= 0 {
+		return false
+	}
+	s.failures--
+	writeError(w, s.failStatus, "api.fake.failure", "simulated failure")
+	return true
+}
+
+// addPost records a post. The lock must be held.
+func (s *Server) addPost(post Post) {
+	s.posts = append(s.posts, post)
+	s.changed.Broadcast()
+}
+
+// attachments decodes the attachments of the post properties.
+func attachments(prop interface{}) []*model.SlackAttachment {
+	if prop == nil {
+		return nil
+	}
+	data, err := json.Marshal(prop)
+	if err != nil {
+		return nil
+	}
+	var attachments []*model.SlackAttachment
+	json.Unmarshal(data, &attachments)
+	return attachments
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, id, message string) {
+	writeJSON(w, status, model.NewAppError("fake", id, nil, message, status))
+}
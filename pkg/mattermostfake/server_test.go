@@ -0,0 +1,129 @@
+package mattermostfake
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+func newClient(t *testing.T, cfg utils.MattermostConfig) *utils.MattermostClient {
+	t.Helper()
+	client, err := utils.NewMattermostClient(cfg)
+	if err != nil {
+		t.Fatalf("connecting to fake server failed: %v", err)
+	}
+	return client
+}
+
+func TestTokenIsChecked(t *testing.T) {
+	server := NewServer("token", "ops", "alerts")
+	defer server.Close()
+	cfg := server.Config()
+	cfg.Token = "wrong"
+	if _, err := utils.NewMattermostClient(cfg); err == nil {
+		t.Error("client with wrong token was accepted")
+	}
+}
+
+func TestPostsAreRecorded(t *testing.T) {
+	server := NewServer("token", "ops", "alerts", "team-apps")
+	defer server.Close()
+	client := newClient(t, server.Config())
+	ctx := context.Background()
+
+	id, err := client.SendAttachements(ctx, "team-apps", utils.PostOptions{Priority: "urgent", IconURL: "https://example.com/icon.png"},
+		&model.SlackAttachment{Title: "Crash loop detected!", Text: "Container app of pod web keeps crashing."})
+	if err != nil {
+		t.Fatalf("posting failed: %v", err)
+	}
+	if err := client.Send(ctx, "", "Informer started"); err != nil {
+		t.Fatalf("posting failed: %v", err)
+	}
+
+	posts, err := server.WaitForPosts(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alert := posts[0]
+	if alert.ID != id || alert.Channel != "team-apps" || alert.Priority != "urgent" || alert.IconURL != "https://example.com/icon.png" {
+		t.Errorf("post was recorded as %+v", alert)
+	}
+	if len(alert.Attachments) != 1 || alert.Attachments[0].Title != "Crash loop detected!" {
+		t.Errorf("attachments were recorded as %+v", alert.Attachments)
+	}
+	if posts[1].Channel != "alerts" || posts[1].Message != "Informer started" {
+		t.Errorf("message to default channel was recorded as %+v", posts[1])
+	}
+}
+
+func TestUnknownChannelIsRejected(t *testing.T) {
+	server := NewServer("token", "ops", "alerts")
+	defer server.Close()
+	client := newClient(t, server.Config())
+	if err := client.CheckChannel("missing"); err == nil {
+		t.Error("unknown channel was found")
+	}
+	server.AddChannel("missing")
+	if err := client.CheckChannel("missing"); err != nil {
+		t.Errorf("added channel was not found: %v", err)
+	}
+}
+
+func TestFailPosts(t *testing.T) {
+	server := NewServer("token", "ops", "alerts")
+	defer server.Close()
+	client := newClient(t, server.Config())
+	ctx := context.Background()
+
+	server.FailPosts(1, http.StatusServiceUnavailable)
+	if err := client.Send(ctx, "", "first"); err == nil || utils.IsPermanent(err) {
+		t.Errorf("failed post returned %v, want a temporary error", err)
+	}
+	if err := client.Send(ctx, "", "second"); err != nil {
+		t.Errorf("post after failures failed: %v", err)
+	}
+	if posts := server.Posts(); len(posts) != 1 || posts[0].Message != "second" {
+		t.Errorf("recorded posts are %+v, want only the second", posts)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	server := NewServer("token", "ops", "alerts")
+	defer server.Close()
+	client := newClient(t, server.Config())
+
+	id, err := client.UploadFile(context.Background(), "", "logs.txt", []byte("panic: boom"))
+	if err != nil {
+		t.Fatalf("uploading failed: %v", err)
+	}
+	files := server.Files()
+	if len(files) != 1 || files[0].ID != id || files[0].Channel != "alerts" || string(files[0].Data) != "panic: boom" {
+		t.Errorf("uploaded files are %+v", files)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	server := NewServer("token", "ops", "alerts")
+	defer server.Close()
+	client := newClient(t, utils.MattermostConfig{WebhookURL: server.WebhookURL("alerts")})
+
+	if _, err := client.SendAttachements(context.Background(), "", utils.PostOptions{Priority: "important"}, &model.SlackAttachment{Title: "Crash loop detected!"}); err != nil {
+		t.Fatalf("posting via webhook failed: %v", err)
+	}
+	posts := server.Posts()
+	if len(posts) != 1 || !posts[0].Webhook || posts[0].Channel != "alerts" || posts[0].Priority != "important" {
+		t.Errorf("webhook posts are %+v", posts)
+	}
+}
+
+func TestWaitForPostsTimesOut(t *testing.T) {
+	server := NewServer("token", "ops", "alerts")
+	defer server.Close()
+	if _, err := server.WaitForPosts(1, 10*time.Millisecond); err == nil {
+		t.Error("waiting for missing post did not fail")
+	}
+}
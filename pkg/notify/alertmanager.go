@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// TypeAlertmanager is the type of the notifier forwarding alerts to Alertmanager.
+const TypeAlertmanager = "alertmanager"
+
+func init() {
+	Register(TypeAlertmanager, func(config []byte) (Notifier, error) {
+		var cfg AlertmanagerConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid Alertmanager configuration: %v", err)
+		}
+		return NewAlertmanager(cfg)
+	})
+}
+
+// AlertmanagerConfig configures the Alertmanager notifier.
+type AlertmanagerConfig struct {
+	// URL is the base URL of Alertmanager, e.g. http://alertmanager.monitoring:9093.
+	URL string `json:"url"`
+	// AlertName is the alertname label of the alerts, defaults to ContainerCrashLooping.
+	AlertName string `json:"alertName"`
+	// Labels are added to all alerts.
+	Labels map[string]string `json:"labels"`
+	// ResolveTimeout is how long an alert is firing unless it is sent again or resolved, defaults to 1h.
+	ResolveTimeout string `json:"resolveTimeout"`
+	// BearerToken is sent in the Authorization header, it may also be read from BearerTokenFile.
+	BearerToken     string `json:"bearerToken"`
+	BearerTokenFile string `json:"bearerTokenFile"`
+}
+
+// Alertmanager pushes alerts to the v2 API of Alertmanager, where they are subject to its routing,
+// silences and inhibitions.
+type Alertmanager struct {
+	client         *http.Client
+	url            string
+	headers        http.Header
+	alertName      string
+	labels         map[string]string
+	resolveTimeout time.Duration
+	clock          clock.PassiveClock
+}
+
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// NewAlertmanager creates an Alertmanager notifier.
+func NewAlertmanager(cfg AlertmanagerConfig) (*Alertmanager, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	am := &Alertmanager{
+		client:         &http.Client{Timeout: requestTimeout},
+		url:            strings.TrimSuffix(cfg.URL, "/") + "/api/v2/alerts",
+		headers:        make(http.Header),
+		alertName:      cfg.AlertName,
+		labels:         cfg.Labels,
+		resolveTimeout: time.Hour,
+		clock:          clock.RealClock{},
+	}
+	if am.alertName == "" {
+		am.alertName = "ContainerCrashLooping"
+	}
+	if cfg.ResolveTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.ResolveTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolveTimeout: %v", err)
+		}
+		am.resolveTimeout = timeout
+	}
+	token, err := readSecret(cfg.BearerToken, cfg.BearerTokenFile)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		am.headers.Set("Authorization", "Bearer "+token)
+	}
+	return am, nil
+}
+
+// SetClock sets the source of the time alerts end at.
+func (a *Alertmanager) SetClock(clock clock.PassiveClock) {
+	a.clock = clock
+}
+
+func (a *Alertmanager) Send(ctx context.Context, alert *Alert) error {
+	return a.post(ctx, alert, alert.Time, a.clock.Now().Add(a.resolveTimeout))
+}
+
+func (a *Alertmanager) Resolve(ctx context.Context, alert *Alert) error {
+	return a.post(ctx, alert, alert.Time, a.clock.Now())
+}
+
+func (a *Alertmanager) post(ctx context.Context, alert *Alert, startsAt, endsAt time.Time) error {
+	labels := map[string]string{
+		"alertname": a.alertName,
+		"namespace": alert.Namespace,
+		"pod":       alert.Pod,
+		"container": alert.Container,
+		"reason":    alert.Reason,
+		"severity":  alert.Severity,
+	}
+	if alert.Cluster != "" {
+		labels["cluster"] = alert.Cluster
+	}
+	if alert.Environment != "" {
+		labels["environment"] = alert.Environment
+	}
+	if kind, name, ok := strings.Cut(alert.Workload, "/"); ok {
+		labels["workload_kind"] = kind
+		labels["workload"] = name
+	}
+	for key, value := range a.labels {
+		labels[key] = value
+	}
+	annotations := map[string]string{
+		"summary":     alert.Title,
+		"description": alert.Text,
+	}
+	if alert.TerminationReason != "" {
+		annotations["termination_reason"] = alert.TerminationReason
+	}
+	if alert.IncidentURL != "" {
+		annotations["incident_url"] = alert.IncidentURL
+	}
+	payload, err := json.Marshal([]alertmanagerAlert{{
+		Labels:      labels,
+		Annotations: annotations,
+		StartsAt:    startsAt,
+		EndsAt:      endsAt,
+	}})
+	if err != nil {
+		return fmt.Errorf("could not encode alert: %v", err)
+	}
+	return postJSON(ctx, a.client, a.url, a.headers, payload)
+}
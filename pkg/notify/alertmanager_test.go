@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestAlertmanagerEndsAt(t *testing.T) {
+	var received []alertmanagerAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alerts []alertmanagerAlert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		received = append(received, alerts...)
+	}))
+	defer server.Close()
+	am, err := NewAlertmanager(AlertmanagerConfig{URL: server.URL, ResolveTimeout: "30m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashedAt := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	clock := testingclock.NewFakePassiveClock(crashedAt.Add(time.Minute))
+	am.SetClock(clock)
+	alert := &Alert{Time: crashedAt, Namespace: "apps", Pod: "web", Container: "app"}
+
+	if err := am.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	clock.SetTime(crashedAt.Add(10 * time.Minute))
+	if err := am.Resolve(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("received %d alerts, want 2", len(received))
+	}
+	for i, want := range []time.Time{crashedAt.Add(31 * time.Minute), crashedAt.Add(10 * time.Minute)} {
+		if !received[i].StartsAt.Equal(crashedAt) || !received[i].EndsAt.Equal(want) {
+			t.Errorf("alert %d lasts from %s to %s, want %s to %s", i+1, received[i].StartsAt, received[i].EndsAt, crashedAt, want)
+		}
+	}
+}
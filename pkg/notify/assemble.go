@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+)
+
+// Mattermost rejects posts whose message exceeds 16383 runes and whose properties, which hold the
+// attachments, exceed 800000 runes. Logs are kept well below the latter so that the post stays
+// readable, the complete logs are uploaded as file or posted in the thread of the alert instead.
+const (
+	maxTextRunes = utils.MaxMessageRunes
+	maxLogRunes  = utils.MaxMessageRunes
+	// markerRunes is reserved for the marker of omitted lines.
+	markerRunes = 64
+)
+
+// fitAlert returns a copy of the alert fitting into the size limits of a Mattermost post. Logs
+// exceeding their budget are trimmed to their first and last lines, in which case the complete
+// logs are returned as overflow.
+func fitAlert(alert *Alert, catalogs i18n.Catalogs) (fitted *Alert, overflow string) {
+	copied := *alert
+	copied.Text = truncateRunes(alert.Text, maxTextRunes)
+	if logs, truncated := truncateLines(alert.Logs, maxLogRunes, catalogs, alert.Locale); truncated {
+		copied.Logs = logs
+		overflow = alert.Logs
+	}
+	return &copied, overflow
+}
+
+// truncateRunes cuts s to at most max runes, ending it with an ellipsis if it has been cut.
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
+
+// truncateLines trims s to at most max runes by keeping as many lines from its head and tail as
+// fit and replacing the others by a marker, so that both the start of the output and the error
+// leading to a crash are kept.
+func truncateLines(s string, max int, catalogs i18n.Catalogs, locale string) (string, bool) {
+	if utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	lines := strings.SplitAfter(s, "\n")
+	budget := max - markerRunes
+	var head, tail []string
+	i, j := 0, len(lines)-1
+	for i <= j {
+		next, fromHead := lines[j], false
+		if len(head) <= len(tail) {
+			next, fromHead = lines[i], true
+		}
+		size := utf8.RuneCountInString(next)
+		if size > budget {
+			break
+		}
+		budget -= size
+		if fromHead {
+			head = append(head, next)
+			i++
+		} else {
+			tail = append(tail, next)
+			j--
+		}
+	}
+	if len(head) == 0 {
+		// The first line alone exceeds the budget, keep the start and end of the logs.
+		runes := []rune(s)
+		half := (max - markerRunes) / 2
+		omitted := catalogs.Sprintf(locale, "[... %d characters omitted ...]", len(runes)-2*half)
+		return string(runes[:half]) + "\n" + omitted + "\n" + string(runes[len(runes)-half:]), true
+	}
+	var b strings.Builder
+	for _, line := range head {
+		b.WriteString(line)
+	}
+	if !strings.HasSuffix(head[len(head)-1], "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(catalogs.Sprintf(locale, "[... %d lines omitted ...]", j-i+1) + "\n")
+	for k := len(tail) - 1; k >= 0; k-- {
+		b.WriteString(tail[k])
+	}
+	return b.String(), true
+}
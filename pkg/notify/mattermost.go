@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"github.com/mattermost/mattermost-server/model"
+	"k8s.io/klog/v2"
+)
+
+// DefaultColor is the color of attachments of alerts without a color.
+const DefaultColor = "#AD2200"
+
+// TypeMattermost is the type of the Mattermost notifier, which is also used for the
+// Mattermost server configured at the top level of the configuration.
+const TypeMattermost = "mattermost"
+
+// MattermostConfig configures a further Mattermost server.
+type MattermostConfig struct {
+	utils.MattermostConfig
+	// Channels maps the channel names of routes and annotations to channels of this server,
+	// other channel names are used as is.
+	Channels map[string]string `json:"channels"`
+}
+
+func init() {
+	Register(TypeMattermost, func(config []byte) (Notifier, error) {
+		var cfg MattermostConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid Mattermost configuration: %v", err)
+		}
+		client, err := utils.NewMattermostClient(cfg.MattermostConfig)
+		if err != nil {
+			return nil, err
+		}
+		mattermost := NewMattermost(client)
+		mattermost.channels = cfg.Channels
+		return mattermost, nil
+	})
+}
+
+// Mattermost posts alerts as message attachments.
+type Mattermost struct {
+	client   *utils.MattermostClient
+	channels map[string]string
+	catalogs i18n.Catalogs
+}
+
+// NewMattermost creates a notifier posting with the given client.
+func NewMattermost(client *utils.MattermostClient) *Mattermost {
+	return &Mattermost{client: client}
+}
+
+// SetCatalogs sets the catalogs labels are translated with besides the builtin ones.
+func (m *Mattermost) SetCatalogs(catalogs i18n.Catalogs) {
+	m.catalogs = catalogs
+}
+
+func (m *Mattermost) Send(ctx context.Context, alert *Alert) error {
+	_, err := m.Post(ctx, alert)
+	return err
+}
+
+// Post sends the alert and returns the ID of the post, which is empty when posting via a webhook.
+func (m *Mattermost) Post(ctx context.Context, alert *Alert) (string, error) {
+	opts := utils.PostOptions{
+		Priority: alert.Priority,
+		IconURL:  alert.IconURL,
+		Message:  strings.Join(alert.Mentions, " "),
+	}
+	channel := alert.Channel
+	if mapped, ok := m.channels[channel]; ok {
+		channel = mapped
+	}
+	fitted, overflow := fitAlert(alert, m.catalogs)
+	if overflow != "" && m.client.CanUpload() {
+		name := fmt.Sprintf("%s-%s.log", alert.Pod, alert.Container)
+		if id, err := m.client.UploadFile(ctx, channel, name, []byte(overflow)); err == nil {
+			opts.FileIDs = []string{id}
+			overflow = ""
+		} else {
+			klog.ErrorS(err, "Uploading the complete logs failed, replying with them instead", "pod", klog.KRef(alert.Namespace, alert.Pod))
+		}
+	}
+	attachment := newAttachment(fitted, m.catalogs, markdownLink)
+	for _, action := range alert.Actions {
+		attachment.Actions = append(attachment.Actions, &model.PostAction{
+			Type:        model.POST_ACTION_TYPE_BUTTON,
+			Name:        action.Name,
+			Integration: &model.PostActionIntegration{URL: action.URL, Context: action.Context},
+		})
+	}
+	id, err := m.client.SendAttachements(ctx, channel, opts, attachment)
+	if err == nil {
+		m.sendDirectMessages(ctx, alert, attachment)
+	}
+	if err != nil || overflow == "" || !m.client.CanReply() {
+		return id, err
+	}
+	// The post only holds the trimmed logs, the complete logs follow in its thread.
+	message := fmt.Sprintf("%s:\n```\n%s```", m.catalogs.Translate(alert.Locale, "Complete logs"), overflow)
+	if err := m.client.Reply(ctx, channel, id, utils.SplitMessage(message, utils.MaxMessageRunes)...); err != nil {
+		klog.ErrorS(err, "Replying with the complete logs failed", "pod", klog.KRef(alert.Namespace, alert.Pod))
+	}
+	return id, nil
+}
+
+// Message posts the text to the channel.
+func (m *Mattermost) Message(ctx context.Context, channel, text string) error {
+	if mapped, ok := m.channels[channel]; ok {
+		channel = mapped
+	}
+	return m.client.Send(ctx, channel, text)
+}
+
+// sendDirectMessages sends the attachment to the users on call. Failures are only logged, as the
+// alert has been posted to the channel already.
+func (m *Mattermost) sendDirectMessages(ctx context.Context, alert *Alert, attachment *model.SlackAttachment) {
+	opts := utils.PostOptions{Priority: alert.Priority, IconURL: alert.IconURL}
+	for _, user := range alert.DirectMessages {
+		if _, err := m.client.SendAttachements(ctx, "@"+user, opts, attachment); err != nil {
+			klog.ErrorS(err, "Sending direct message failed", "pod", klog.KRef(alert.Namespace, alert.Pod), "user", user)
+		}
+	}
+}
+
+// CheckChannel verifies that the channel alerts routed to the given channel are posted to exists.
+func (m *Mattermost) CheckChannel(channel string) error {
+	if mapped, ok := m.channels[channel]; ok {
+		channel = mapped
+	}
+	return m.client.CheckChannel(channel)
+}
+
+func markdownLink(title, url string) string {
+	return fmt.Sprintf("[%s](%s)", title, url)
+}
+
+// newAttachment renders the alert as a message attachment, which is understood by Mattermost
+// and Slack, with the labels translated using the catalogs. Links are rendered using the given
+// function.
+func newAttachment(alert *Alert, catalogs i18n.Catalogs, link func(title, url string) string) *model.SlackAttachment {
+	label := func(message string) string {
+		return catalogs.Translate(alert.Locale, message)
+	}
+	attachment := &model.SlackAttachment{
+		Color: DefaultColor,
+		Text:  alert.Text,
+		Title: alert.Title,
+		Fields: []*model.SlackAttachmentField{
+			{
+				Title: label("Logs"),
+				Value: "```\n" + alert.Logs + "```",
+			},
+		},
+	}
+	if alert.Color != "" {
+		attachment.Color = alert.Color
+	}
+	attachment.Footer = alert.Identity()
+	if alert.Emoji != "" {
+		attachment.Title = fmt.Sprintf(":%s: %s", alert.Emoji, attachment.Title)
+	}
+	if alert.TerminationReason != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: label("Reason"),
+			Value: alert.TerminationReason,
+		})
+	}
+	if alert.Resources != nil {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: label("Resources"),
+			Value: fmt.Sprintf("%s: %s\n%s: %s", label("CPU"), alert.Resources.CPU.Describe(catalogs, alert.Locale),
+				label("Memory"), alert.Resources.Memory.Describe(catalogs, alert.Locale)),
+		})
+	}
+	attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+		Title: label("Severity"),
+		Value: alert.Severity,
+		Short: true,
+	})
+	if alert.IncidentURL != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: label("Incident"),
+			Value: link(label("Playbook run"), alert.IncidentURL),
+			Short: true,
+		})
+	}
+	if len(alert.Links) > 0 {
+		links := make([]string, len(alert.Links))
+		for i, l := range alert.Links {
+			links[i] = link(l.Title, l.URL)
+		}
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: label("Links"),
+			Value: strings.Join(links, " | "),
+			Short: true,
+		})
+	}
+	return attachment
+}
@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"k8s.io/utils/clock"
+)
+
+// Alert describes a crashing container.
+type Alert struct {
+	Time time.Time `json:"time"`
+	// Fingerprint identifies the crashing container across notifications.
+	Fingerprint string `json:"fingerprint"`
+	Namespace   string `json:"namespace"`
+	Pod         string `json:"pod"`
+	Container   string `json:"container"`
+	// Cluster is the name of the cluster the alert originates from and Environment its
+	// environment, e.g. production. Both are empty unless configured.
+	Cluster     string `json:"cluster,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	// Workload is the kind/name of the workload controlling the pod, e.g. Deployment/payments.
+	Workload          string `json:"workload,omitempty"`
+	Reason            string `json:"reason"`
+	TerminationReason string `json:"terminationReason,omitempty"`
+	Severity          string `json:"severity"`
+	RestartCount      int32  `json:"restartCount"`
+	Title             string `json:"title"`
+	Text              string `json:"text"`
+	Logs              string `json:"logs,omitempty"`
+	IncidentURL       string `json:"incidentURL,omitempty"`
+	// Resources are the requests, limits and usage of the container, nil if unknown.
+	Resources *Resources `json:"resources,omitempty"`
+	// Links point to further information, e.g. the logs in an external system.
+	Links []Link `json:"links,omitempty"`
+	// Actions are buttons rendered by Mattermost. They are not serialized, as their context
+	// authenticates the button.
+	Actions []Action `json:"-"`
+	// Channel is the channel the alert is routed to, empty for the default channel of the backend.
+	Channel string `json:"channel,omitempty"`
+	// Mentions are the users and groups on call, e.g. @alice, and DirectMessages the users the
+	// alert is also sent to directly, both empty without an on-call schedule.
+	Mentions       []string `json:"mentions,omitempty"`
+	DirectMessages []string `json:"directMessages,omitempty"`
+	// Priority, Emoji, IconURL and Color style the message if supported by the backend.
+	Priority string `json:"priority,omitempty"`
+	Emoji    string `json:"emoji,omitempty"`
+	IconURL  string `json:"iconURL,omitempty"`
+	Color    string `json:"color,omitempty"`
+	// RecoveredAfter is the time from the first alert until the container recovered, it is only set
+	// when resolving alerts.
+	RecoveredAfter time.Duration `json:"recoveredAfter,omitempty"`
+	// Locale is the language field labels are rendered in, empty for English.
+	Locale string `json:"locale,omitempty"`
+	// Synthetic marks alerts injected to verify the delivery, which are not caused by a crash.
+	Synthetic bool `json:"synthetic,omitempty"`
+}
+
+// Identity describes the cluster and environment of the alert, e.g. "prod-eu (production)".
+// It is empty if neither is known.
+func (a *Alert) Identity() string {
+	switch {
+	case a.Cluster != "" && a.Environment != "":
+		return a.Cluster + " (" + a.Environment + ")"
+	case a.Cluster != "":
+		return a.Cluster
+	}
+	return a.Environment
+}
+
+// Resources describe the CPU and memory of a container.
+type Resources struct {
+	CPU    ResourceUsage `json:"cpu"`
+	Memory ResourceUsage `json:"memory"`
+}
+
+// ResourceUsage holds quantities like 250m or 512Mi, each empty if not set or unknown.
+type ResourceUsage struct {
+	Request string `json:"request,omitempty"`
+	Limit   string `json:"limit,omitempty"`
+	Usage   string `json:"usage,omitempty"`
+	// UsageOfLimit is the usage in percent of the limit, 0 if either is unknown.
+	UsageOfLimit int `json:"usageOfLimit,omitempty"`
+}
+
+// Describe summarizes the quantities, e.g. "requests 250m, limits 1, usage 120m (12% of the limit)".
+func (u *ResourceUsage) Describe(catalogs i18n.Catalogs, locale string) string {
+	var parts []string
+	if u.Request != "" {
+		parts = append(parts, catalogs.Sprintf(locale, "requests %s", u.Request))
+	}
+	if u.Limit != "" {
+		parts = append(parts, catalogs.Sprintf(locale, "limits %s", u.Limit))
+	}
+	if u.UsageOfLimit > 0 {
+		parts = append(parts, catalogs.Sprintf(locale, "usage %s (%d%% of the limit)", u.Usage, u.UsageOfLimit))
+	} else if u.Usage != "" {
+		parts = append(parts, catalogs.Sprintf(locale, "usage %s", u.Usage))
+	}
+	if len(parts) == 0 {
+		return catalogs.Translate(locale, "no requests or limits")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Link is a titled URL.
+type Link struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Action is a button posting its context to URL when clicked.
+type Action struct {
+	Name    string
+	URL     string
+	Context map[string]interface{}
+}
+
+// Notifier delivers alerts to a notification backend.
+type Notifier interface {
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// Poster is implemented by notifiers which can identify the message sent for an alert,
+// e.g. to reply to it later.
+type Poster interface {
+	Post(ctx context.Context, alert *Alert) (id string, err error)
+}
+
+// Messenger is implemented by notifiers posting to chat channels, which also receive plain
+// messages like reports.
+type Messenger interface {
+	Message(ctx context.Context, channel, text string) error
+}
+
+// Resolver is implemented by notifiers which track alerts as incidents. Resolve is called
+// once the container of a previously sent alert is ready again or its pod is gone.
+type Resolver interface {
+	Resolve(ctx context.Context, alert *Alert) error
+}
+
+// Fingerprint returns the fingerprint of alerts for the given container.
+func Fingerprint(namespace, pod, container string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + pod + "/" + container))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Clocked is implemented by notifiers deriving times from the current time, e.g. when alerts
+// expire, so that they follow the clock of the controller.
+type Clocked interface {
+	SetClock(clock clock.PassiveClock)
+}
+
+// Localized is implemented by notifiers translating the labels of alerts, so that they use the
+// catalogs of the configuration.
+type Localized interface {
+	SetCatalogs(catalogs i18n.Catalogs)
+}
+
+// Factory creates a notifier from its JSON configuration.
+type Factory func(config []byte) (Notifier, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a notifier type available to the configuration. It is meant to be called
+// from the init function of the package implementing the notifier.
+func Register(kind string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[kind]; ok {
+		panic(fmt.Sprintf("notifier type %q registered twice", kind))
+	}
+	registry[kind] = factory
+}
+
+// Types returns the registered notifier types.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var types []string
+	for kind := range registry {
+		types = append(types, kind)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates a notifier of the given type.
+func New(kind string, config []byte) (Notifier, error) {
+	registryMu.RLock()
+	factory, ok := registry[kind]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier type %q, must be one of %v", kind, Types())
+	}
+	return factory(config)
+}
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// TypeOpsgenie is the type of the Opsgenie notifier.
+const TypeOpsgenie = "opsgenie"
+
+const opsgenieURL = "https://api.opsgenie.com"
+
+func init() {
+	Register(TypeOpsgenie, func(config []byte) (Notifier, error) {
+		var cfg OpsgenieConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid Opsgenie configuration: %v", err)
+		}
+		return NewOpsgenie(cfg)
+	})
+}
+
+// OpsgenieConfig configures the Opsgenie notifier.
+type OpsgenieConfig struct {
+	// APIKey is the key of an API integration, it may also be read from APIKeyFile.
+	APIKey     string `json:"apiKey"`
+	APIKeyFile string `json:"apiKeyFile"`
+	// URL overrides the API endpoint, e.g. https://api.eu.opsgenie.com for the EU instance.
+	URL string `json:"url"`
+	// Priorities maps severities to alert priorities (P1 to P5).
+	Priorities map[string]string `json:"priorities"`
+	Tags       []string          `json:"tags"`
+}
+
+// Opsgenie creates and closes Opsgenie alerts, which are deduplicated by the fingerprint of the alert.
+type Opsgenie struct {
+	client     *http.Client
+	url        string
+	headers    http.Header
+	priorities map[string]string
+	tags       []string
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+// NewOpsgenie creates an Opsgenie notifier.
+func NewOpsgenie(cfg OpsgenieConfig) (*Opsgenie, error) {
+	apiKey, err := readSecret(cfg.APIKey, cfg.APIKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("apiKey is required")
+	}
+	baseURL := cfg.URL
+	if baseURL == "" {
+		baseURL = opsgenieURL
+	}
+	priorities := map[string]string{"critical": "P1", "warning": "P3", "info": "P5"}
+	for severity, priority := range cfg.Priorities {
+		priorities[severity] = priority
+	}
+	return &Opsgenie{
+		client:     &http.Client{Timeout: requestTimeout},
+		url:        baseURL,
+		headers:    http.Header{"Authorization": []string{"GenieKey " + apiKey}},
+		priorities: priorities,
+		tags:       cfg.Tags,
+	}, nil
+}
+
+func (o *Opsgenie) Send(ctx context.Context, alert *Alert) error {
+	// Opsgenie limits the message to 130 characters.
+	message := truncateRunes(fmt.Sprintf("%s %s/%s", alert.Title, alert.Namespace, alert.Pod), 130)
+	priority, ok := o.priorities[alert.Severity]
+	if !ok {
+		priority = "P3"
+	}
+	details := map[string]string{
+		"namespace":    alert.Namespace,
+		"pod":          alert.Pod,
+		"container":    alert.Container,
+		"reason":       alert.Reason,
+		"severity":     alert.Severity,
+		"restartCount": strconv.Itoa(int(alert.RestartCount)),
+	}
+	if alert.TerminationReason != "" {
+		details["terminationReason"] = alert.TerminationReason
+	}
+	if alert.IncidentURL != "" {
+		details["incident"] = alert.IncidentURL
+	}
+	for _, link := range alert.Links {
+		details[link.Title] = link.URL
+	}
+	description := alert.Text
+	if alert.Logs != "" {
+		description += "\n\nLogs:\n" + alert.Logs
+	}
+	payload, err := json.Marshal(&opsgenieAlert{
+		Message:     message,
+		Alias:       alert.Fingerprint,
+		Description: description,
+		Priority:    priority,
+		Source:      "mattermost-informer",
+		Entity:      alert.Namespace + "/" + alert.Pod,
+		Tags:        o.tags,
+		Details:     details,
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode alert: %v", err)
+	}
+	return postJSON(ctx, o.client, o.url+"/v2/alerts", o.headers, payload)
+}
+
+func (o *Opsgenie) Resolve(ctx context.Context, alert *Alert) error {
+	payload, err := json.Marshal(&opsgenieClose{
+		Source: "mattermost-informer",
+		Note:   fmt.Sprintf("Container %s of pod %s/%s recovered after %s", alert.Container, alert.Namespace, alert.Pod, alert.RecoveredAfter),
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode request: %v", err)
+	}
+	closeURL := o.url + "/v2/alerts/" + url.PathEscape(alert.Fingerprint) + "/close?identifierType=alias"
+	return postJSON(ctx, o.client, closeURL, o.headers, payload)
+}
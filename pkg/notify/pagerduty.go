@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// TypePagerDuty is the type of the PagerDuty notifier.
+const TypePagerDuty = "pagerduty"
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+func init() {
+	Register(TypePagerDuty, func(config []byte) (Notifier, error) {
+		var cfg PagerDutyConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid PagerDuty configuration: %v", err)
+		}
+		return NewPagerDuty(cfg)
+	})
+}
+
+// PagerDutyConfig configures the PagerDuty notifier.
+type PagerDutyConfig struct {
+	// RoutingKey is the integration key of an Events API v2 integration, it may also be read from RoutingKeyFile.
+	RoutingKey     string `json:"routingKey"`
+	RoutingKeyFile string `json:"routingKeyFile"`
+	// URL overrides the Events API endpoint.
+	URL string `json:"url"`
+}
+
+// PagerDuty triggers and resolves PagerDuty incidents using the Events API v2.
+// Incidents are deduplicated by the fingerprint of the alert.
+type PagerDuty struct {
+	client     *http.Client
+	url        string
+	routingKey string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string `json:"summary"`
+	Source        string `json:"source"`
+	Severity      string `json:"severity"`
+	Component     string `json:"component"`
+	Group         string `json:"group"`
+	Class         string `json:"class"`
+	CustomDetails *Alert `json:"custom_details"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// NewPagerDuty creates a PagerDuty notifier.
+func NewPagerDuty(cfg PagerDutyConfig) (*PagerDuty, error) {
+	routingKey, err := readSecret(cfg.RoutingKey, cfg.RoutingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if routingKey == "" {
+		return nil, fmt.Errorf("routingKey is required")
+	}
+	url := cfg.URL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	return &PagerDuty{
+		client:     &http.Client{Timeout: requestTimeout},
+		url:        url,
+		routingKey: routingKey,
+	}, nil
+}
+
+// pagerDutySeverity maps the severity of an alert to a PagerDuty severity.
+func pagerDutySeverity(severity string) string {
+	switch severity {
+	case "critical", "warning", "info":
+		return severity
+	}
+	return "error"
+}
+
+func (p *PagerDuty) Send(ctx context.Context, alert *Alert) error {
+	event := &pagerDutyEvent{
+		EventAction: "trigger",
+		Payload: &pagerDutyPayload{
+			Summary:       fmt.Sprintf("%s: container %s of pod %s/%s", alert.Title, alert.Container, alert.Namespace, alert.Pod),
+			Source:        alert.Namespace + "/" + alert.Pod,
+			Severity:      pagerDutySeverity(alert.Severity),
+			Component:     alert.Container,
+			Group:         alert.Namespace,
+			Class:         alert.Reason,
+			CustomDetails: alert,
+		},
+	}
+	if alert.IncidentURL != "" {
+		event.Links = []pagerDutyLink{{Href: alert.IncidentURL, Text: "Playbook run"}}
+	}
+	for _, link := range alert.Links {
+		event.Links = append(event.Links, pagerDutyLink{Href: link.URL, Text: link.Title})
+	}
+	return p.send(ctx, event, alert)
+}
+
+func (p *PagerDuty) Resolve(ctx context.Context, alert *Alert) error {
+	return p.send(ctx, &pagerDutyEvent{EventAction: "resolve"}, alert)
+}
+
+func (p *PagerDuty) send(ctx context.Context, event *pagerDutyEvent, alert *Alert) error {
+	event.RoutingKey = p.routingKey
+	event.DedupKey = alert.Fingerprint
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %v", err)
+	}
+	return postJSON(ctx, p.client, p.url, nil, payload)
+}
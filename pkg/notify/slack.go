@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// TypeSlack is the type of the Slack notifier.
+const TypeSlack = "slack"
+
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+func init() {
+	Register(TypeSlack, func(config []byte) (Notifier, error) {
+		var cfg SlackConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid Slack configuration: %v", err)
+		}
+		return NewSlack(cfg)
+	})
+}
+
+// SlackConfig configures the Slack notifier. Either WebhookURL or a bot token is required.
+type SlackConfig struct {
+	// WebhookURL posts via an incoming webhook, which always posts to the channel of the webhook.
+	WebhookURL string `json:"webhookURL"`
+	// Token is a bot token with the chat:write scope, it may also be read from TokenFile.
+	Token     string `json:"token"`
+	TokenFile string `json:"tokenFile"`
+	// Channel is the default channel alerts are sent to when using a token.
+	Channel string `json:"channel"`
+}
+
+// Slack posts alerts as message attachments to Slack.
+type Slack struct {
+	client         *http.Client
+	webhookURL     string
+	token          string
+	defaultChannel string
+	catalogs       i18n.Catalogs
+}
+
+type slackMessage struct {
+	Channel     string                   `json:"channel,omitempty"`
+	IconURL     string                   `json:"icon_url,omitempty"`
+	Text        string                   `json:"text,omitempty"`
+	Attachments []*model.SlackAttachment `json:"attachments,omitempty"`
+}
+
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// NewSlack creates a Slack notifier.
+func NewSlack(cfg SlackConfig) (*Slack, error) {
+	token, err := readSecret(cfg.Token, cfg.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.WebhookURL == "" && token == "":
+		return nil, fmt.Errorf("either webhookURL or token is required")
+	case token != "" && cfg.Channel == "":
+		return nil, fmt.Errorf("channel is required when using a token")
+	}
+	return &Slack{
+		client:         &http.Client{Timeout: requestTimeout},
+		webhookURL:     cfg.WebhookURL,
+		token:          token,
+		defaultChannel: cfg.Channel,
+	}, nil
+}
+
+// SetCatalogs sets the catalogs labels are translated with besides the builtin ones.
+func (s *Slack) SetCatalogs(catalogs i18n.Catalogs) {
+	s.catalogs = catalogs
+}
+
+func (s *Slack) Send(ctx context.Context, alert *Alert) error {
+	return s.send(ctx, alert.Channel, &slackMessage{
+		IconURL:     alert.IconURL,
+		Attachments: []*model.SlackAttachment{newAttachment(alert, s.catalogs, slackLink)},
+	})
+}
+
+// Message posts the text to the channel.
+func (s *Slack) Message(ctx context.Context, channel, text string) error {
+	return s.send(ctx, channel, &slackMessage{Text: text})
+}
+
+// send posts the message to the channel using the Web API, or to the channel of the webhook.
+func (s *Slack) send(ctx context.Context, channel string, msg *slackMessage) error {
+	if s.token == "" {
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("could not encode message: %v", err)
+		}
+		return postJSON(ctx, s.client, s.webhookURL, nil, payload)
+	}
+	msg.Channel = channel
+	if msg.Channel == "" {
+		msg.Channel = s.defaultChannel
+	}
+	return s.postMessage(ctx, msg)
+}
+
+func slackLink(title, url string) string {
+	return fmt.Sprintf("<%s|%s>", url, title)
+}
+
+// postMessage posts using the Web API, which reports errors in the response body.
+func (s *Slack) postMessage(ctx context.Context, msg *slackMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not encode message: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackPostMessageURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not post to Slack: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return utils.StatusError(resp.StatusCode, fmt.Errorf("posting to Slack failed with status %s: %s", resp.Status, body))
+	}
+	var result slackResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("could not decode Slack response: %v", err)
+	}
+	if !result.OK {
+		err := fmt.Errorf("posting to channel %s failed: %s", msg.Channel, result.Error)
+		if result.Error == "ratelimited" {
+			return err
+		}
+		return utils.Permanent(err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lnsp/mattermost-informer/pkg/i18n"
+)
+
+// TypeTeams is the type of the Microsoft Teams notifier.
+const TypeTeams = "teams"
+
+func init() {
+	Register(TypeTeams, func(config []byte) (Notifier, error) {
+		var cfg TeamsConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid Teams configuration: %v", err)
+		}
+		return NewTeams(cfg)
+	})
+}
+
+// TeamsConfig configures the Teams notifier.
+type TeamsConfig struct {
+	// WebhookURL is the incoming webhook of the default channel.
+	WebhookURL string `json:"webhookURL"`
+	// Channels maps channel names of routes and annotations to incoming webhooks.
+	Channels map[string]string `json:"channels"`
+}
+
+// Teams posts alerts as Adaptive Cards to Microsoft Teams incoming webhooks.
+type Teams struct {
+	client     *http.Client
+	webhookURL string
+	channels   map[string]string
+	catalogs   i18n.Catalogs
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string        `json:"contentType"`
+	Content     *adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string                   `json:"$schema"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// NewTeams creates a Teams notifier.
+func NewTeams(cfg TeamsConfig) (*Teams, error) {
+	if cfg.WebhookURL == "" {
+		return nil, fmt.Errorf("webhookURL is required")
+	}
+	return &Teams{
+		client:     &http.Client{Timeout: requestTimeout},
+		webhookURL: cfg.WebhookURL,
+		channels:   cfg.Channels,
+	}, nil
+}
+
+// SetCatalogs sets the catalogs labels are translated with besides the builtin ones.
+func (t *Teams) SetCatalogs(catalogs i18n.Catalogs) {
+	t.catalogs = catalogs
+}
+
+func (t *Teams) Send(ctx context.Context, alert *Alert) error {
+	return t.send(ctx, alert.Channel, newAdaptiveCard(alert, t.catalogs))
+}
+
+// Message posts the text to the channel as a card with a single text block.
+func (t *Teams) Message(ctx context.Context, channel, text string) error {
+	return t.send(ctx, channel, &adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body:    []map[string]interface{}{{"type": "TextBlock", "text": text, "wrap": true}},
+	})
+}
+
+// send posts the card to the incoming webhook of the channel.
+func (t *Teams) send(ctx context.Context, channel string, card *adaptiveCard) error {
+	url := t.webhookURL
+	if channelURL, ok := t.channels[channel]; ok {
+		url = channelURL
+	}
+	payload, err := json.Marshal(&teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode card: %v", err)
+	}
+	return postJSON(ctx, t.client, url, nil, payload)
+}
+
+// newAdaptiveCard renders the alert with the same fields as the Mattermost attachment.
+func newAdaptiveCard(alert *Alert, catalogs i18n.Catalogs) *adaptiveCard {
+	label := func(message string) string {
+		return catalogs.Translate(alert.Locale, message)
+	}
+	var facts []adaptiveFact
+	if identity := alert.Identity(); identity != "" {
+		facts = append(facts, adaptiveFact{Title: label("Cluster"), Value: identity})
+	}
+	facts = append(facts,
+		adaptiveFact{Title: label("Namespace"), Value: alert.Namespace},
+		adaptiveFact{Title: label("Pod"), Value: alert.Pod},
+		adaptiveFact{Title: label("Container"), Value: alert.Container})
+	if alert.TerminationReason != "" {
+		facts = append(facts, adaptiveFact{Title: label("Reason"), Value: alert.TerminationReason})
+	}
+	facts = append(facts,
+		adaptiveFact{Title: label("Severity"), Value: alert.Severity},
+		adaptiveFact{Title: label("Restarts"), Value: strconv.Itoa(int(alert.RestartCount))})
+	if alert.Resources != nil {
+		facts = append(facts,
+			adaptiveFact{Title: label("CPU"), Value: alert.Resources.CPU.Describe(catalogs, alert.Locale)},
+			adaptiveFact{Title: label("Memory"), Value: alert.Resources.Memory.Describe(catalogs, alert.Locale)})
+	}
+	card := &adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []map[string]interface{}{
+			{"type": "TextBlock", "text": alert.Title, "size": "Medium", "weight": "Bolder", "color": "Attention", "wrap": true},
+			{"type": "TextBlock", "text": alert.Text, "wrap": true},
+			{"type": "FactSet", "facts": facts},
+		},
+	}
+	if alert.Logs != "" {
+		card.Body = append(card.Body,
+			map[string]interface{}{"type": "TextBlock", "text": label("Logs"), "weight": "Bolder"},
+			map[string]interface{}{"type": "TextBlock", "text": alert.Logs, "fontType": "Monospace", "wrap": true})
+	}
+	if alert.IncidentURL != "" {
+		card.Actions = append(card.Actions, map[string]interface{}{
+			"type": "Action.OpenUrl", "title": label("Playbook run"), "url": alert.IncidentURL,
+		})
+	}
+	for _, link := range alert.Links {
+		card.Actions = append(card.Actions, map[string]interface{}{
+			"type": "Action.OpenUrl", "title": link.Title, "url": link.URL,
+		})
+	}
+	return card
+}
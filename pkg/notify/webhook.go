@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+)
+
+// TypeWebhook is the type of the generic JSON webhook notifier.
+const TypeWebhook = "webhook"
+
+const requestTimeout = 30 * time.Second
+
+func init() {
+	Register(TypeWebhook, func(config []byte) (Notifier, error) {
+		var cfg WebhookConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid webhook configuration: %v", err)
+		}
+		return NewWebhook(cfg)
+	})
+}
+
+// WebhookConfig configures the webhook notifier.
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Username and Password enable basic authentication, the password may also be read from PasswordFile.
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"passwordFile"`
+	// BearerToken is sent in the Authorization header, it may also be read from BearerTokenFile.
+	BearerToken     string `json:"bearerToken"`
+	BearerTokenFile string `json:"bearerTokenFile"`
+}
+
+// Webhook posts alerts as JSON documents to an HTTP endpoint.
+type Webhook struct {
+	client  *http.Client
+	url     string
+	headers http.Header
+}
+
+// NewWebhook creates a webhook notifier.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	headers := make(http.Header)
+	for key, value := range cfg.Headers {
+		headers.Set(key, value)
+	}
+	password, err := readSecret(cfg.Password, cfg.PasswordFile)
+	if err != nil {
+		return nil, err
+	}
+	token, err := readSecret(cfg.BearerToken, cfg.BearerTokenFile)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.Username != "" && token != "":
+		return nil, fmt.Errorf("basic authentication and bearer token are mutually exclusive")
+	case cfg.Username != "":
+		req := &http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(cfg.Username, password)
+		headers.Set("Authorization", req.Header.Get("Authorization"))
+	case token != "":
+		headers.Set("Authorization", "Bearer "+token)
+	}
+	return &Webhook{
+		client:  &http.Client{Timeout: requestTimeout},
+		url:     cfg.URL,
+		headers: headers,
+	}, nil
+}
+
+// Send posts the alert once. Failed requests are retried by the dispatcher unless the error is permanent.
+func (w *Webhook) Send(ctx context.Context, alert *Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("could not encode alert: %v", err)
+	}
+	return postJSON(ctx, w.client, w.url, w.headers, payload)
+}
+
+// postJSON posts the payload to url and fails on non-2xx responses.
+func postJSON(ctx context.Context, client *http.Client, url string, headers http.Header, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not post to %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return utils.StatusError(resp.StatusCode, fmt.Errorf("posting to %s failed with status %s: %s", req.URL.Host, resp.Status, body))
+	}
+	return nil
+}
+
+// readSecret returns value if set, otherwise the trimmed content of file.
+func readSecret(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read secret: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+)
+
+func TestWebhookSendsOnce(t *testing.T) {
+	tests := []struct {
+		status    int
+		fails     bool
+		permanent bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusServiceUnavailable, true, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusBadRequest, true, true},
+	}
+	for _, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+				t.Errorf("unexpected authorization %q", auth)
+			}
+			w.WriteHeader(test.status)
+		}))
+		webhook, err := NewWebhook(WebhookConfig{URL: server.URL, BearerToken: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = webhook.Send(context.Background(), &Alert{Namespace: "apps", Pod: "web"})
+		server.Close()
+		if (err != nil) != test.fails {
+			t.Errorf("status %d: expected failure %v, got %v", test.status, test.fails, err)
+		}
+		if utils.IsPermanent(err) != test.permanent {
+			t.Errorf("status %d: expected permanent %v, got %v", test.status, test.permanent, err)
+		}
+		// Retries are left to the dispatcher, so that they do not block its sender.
+		if requests != 1 {
+			t.Errorf("status %d: expected a single request, got %d", test.status, requests)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package oncall
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a time users are on call according to a calendar, which may recur.
+type Event struct {
+	Start, End time.Time
+	Users      []string
+	// Recurrence repeats the event, nil if it happens once. Exceptions are the starts of
+	// occurrences which have been deleted or moved.
+	Recurrence *Recurrence
+	Exceptions []time.Time
+}
+
+// Recurrence is a daily or weekly recurrence rule of an event, the rules on-call rotations use.
+type Recurrence struct {
+	// Weekly is set for weekly recurrences, which happen on Days, daily ones otherwise.
+	Weekly   bool
+	Interval int
+	// Count and Until bound the occurrences, if set.
+	Count     int
+	Until     time.Time
+	Days      []time.Weekday
+	WeekStart time.Weekday
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var icalUnescaper = strings.NewReplacer(`\\`, `\`, `\,`, ",", `\;`, ";", `\n`, "\n", `\N`, "\n")
+
+// ParseICal reads the on-call events of an iCal calendar. The users on call are mentioned in the
+// summary or description of an event, e.g. "On call: @alice", events without mentions are
+// skipped. Times without a time zone are read in the given location. Daily and weekly recurrences
+// are supported including their exceptions, calendars with other recurrences are rejected, as
+// they would resolve to nobody being on call.
+func ParseICal(r io.Reader, location *time.Location) ([]Event, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Long lines are folded by starting the continuation with a space or tab.
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read calendar: %v", err)
+	}
+
+	var (
+		events  []Event
+		event   *Event
+		text    string
+		inEvent bool
+		// uids holds the UID of each event, moved the starts of the occurrences moved by other
+		// events per UID.
+		uids         []string
+		uid          string
+		recurrenceID time.Time
+		moved        = make(map[string][]time.Time)
+	)
+	for _, line := range lines {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		nameAndParams, value := strings.Split(line[:colon], ";"), line[colon+1:]
+		name := strings.ToUpper(nameAndParams[0])
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event, text, inEvent, uid, recurrenceID = &Event{}, "", true, "", time.Time{}
+		case name == "END" && strings.EqualFold(value, "VEVENT") && inEvent:
+			inEvent = false
+			if !recurrenceID.IsZero() {
+				moved[uid] = append(moved[uid], recurrenceID)
+			}
+			event.Users = mentions(text)
+			if len(event.Users) == 0 || event.Start.IsZero() {
+				continue
+			}
+			if event.End.IsZero() {
+				event.End = event.Start.Add(24 * time.Hour)
+			}
+			events, uids = append(events, *event), append(uids, uid)
+		case !inEvent:
+		case name == "DTSTART" || name == "DTEND" || name == "RECURRENCE-ID":
+			t, err := parseICalTime(value, nameAndParams[1:], location)
+			if err != nil {
+				return nil, err
+			}
+			switch name {
+			case "DTSTART":
+				event.Start = t
+			case "DTEND":
+				event.End = t
+			default:
+				recurrenceID = t
+			}
+		case name == "EXDATE":
+			for _, value := range strings.Split(value, ",") {
+				t, err := parseICalTime(value, nameAndParams[1:], location)
+				if err != nil {
+					return nil, err
+				}
+				event.Exceptions = append(event.Exceptions, t)
+			}
+		case name == "RRULE":
+			recurrence, err := parseRecurrence(value, location)
+			if err != nil {
+				return nil, err
+			}
+			event.Recurrence = recurrence
+		case name == "RDATE":
+			return nil, fmt.Errorf("recurrence dates are not supported")
+		case name == "UID":
+			uid = value
+		case name == "SUMMARY" || name == "DESCRIPTION":
+			text += " " + icalUnescaper.Replace(value)
+		}
+	}
+	for i := range events {
+		if events[i].Recurrence != nil {
+			events[i].Exceptions = append(events[i].Exceptions, moved[uids[i]]...)
+		}
+	}
+	return events, nil
+}
+
+// parseRecurrence parses a recurrence rule like FREQ=WEEKLY;INTERVAL=2;BYDAY=MO. Only the parts
+// of daily and weekly rules are supported.
+func parseRecurrence(rule string, location *time.Location) (*Recurrence, error) {
+	recurrence := &Recurrence{Interval: 1, WeekStart: time.Monday}
+	frequency := ""
+	for _, part := range strings.Split(rule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			frequency = strings.ToUpper(value)
+		case "INTERVAL":
+			recurrence.Interval, err = strconv.Atoi(value)
+			if err == nil && recurrence.Interval < 1 {
+				err = fmt.Errorf("interval must be positive")
+			}
+		case "COUNT":
+			recurrence.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			recurrence.Until, err = parseICalTime(value, nil, location)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := icalWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported day %q in recurrence rule %q", day, rule)
+				}
+				recurrence.Days = append(recurrence.Days, weekday)
+			}
+		case "WKST":
+			var ok bool
+			if recurrence.WeekStart, ok = icalWeekdays[strings.ToUpper(value)]; !ok {
+				err = fmt.Errorf("unknown day %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule %q", rule)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid recurrence rule %q: %v", rule, err)
+		}
+	}
+	switch frequency {
+	case "DAILY":
+		if len(recurrence.Days) > 0 {
+			return nil, fmt.Errorf("unsupported recurrence rule %q", rule)
+		}
+	case "WEEKLY":
+		recurrence.Weekly = true
+	default:
+		return nil, fmt.Errorf("unsupported recurrence rule %q", rule)
+	}
+	return recurrence, nil
+}
+
+// Covers checks if an occurrence of the event includes the given time.
+func (e *Event) Covers(t time.Time) bool {
+	duration := e.End.Sub(e.Start)
+	covers := func(start time.Time) bool {
+		return !t.Before(start) && t.Before(start.Add(duration))
+	}
+	if e.Recurrence == nil {
+		return covers(e.Start)
+	}
+	covered := false
+	e.Recurrence.each(e.Start, func(start time.Time) bool {
+		if start.After(t) {
+			return false
+		}
+		covered = covers(start) && !e.isException(start)
+		return !covered
+	})
+	return covered
+}
+
+func (e *Event) isException(start time.Time) bool {
+	for _, exception := range e.Exceptions {
+		if exception.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// each calls yield with the starts of the occurrences of an event starting at start in order,
+// until it returns false or the occurrences end. Occurrences keep the time of the day of start in
+// its location across daylight saving time changes.
+func (r *Recurrence) each(start time.Time, yield func(time.Time) bool) {
+	n := 0
+	emit := func(occurrence time.Time) bool {
+		if r.Count > 0 && n >= r.Count || !r.Until.IsZero() && occurrence.After(r.Until) {
+			return false
+		}
+		n++
+		return yield(occurrence)
+	}
+	if !r.Weekly {
+		for i := 0; emit(start.AddDate(0, 0, i*r.Interval)); i++ {
+		}
+		return
+	}
+	days := r.Days
+	if len(days) == 0 {
+		days = []time.Weekday{start.Weekday()}
+	}
+	// The offsets of the days from the start of the week.
+	offsets := make([]int, 0, len(days))
+	for _, day := range days {
+		offsets = append(offsets, (int(day)-int(r.WeekStart)+7)%7)
+	}
+	sort.Ints(offsets)
+	week := start.AddDate(0, 0, -(int(start.Weekday())-int(r.WeekStart)+7)%7)
+	for i := 0; ; i += r.Interval {
+		for _, offset := range offsets {
+			occurrence := week.AddDate(0, 0, 7*i+offset)
+			if occurrence.Before(start) {
+				continue
+			}
+			if !emit(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// parseICalTime parses a date or date-time value, honoring the TZID parameter.
+func parseICalTime(value string, params []string, location *time.Location) (time.Time, error) {
+	for _, param := range params {
+		if strings.HasPrefix(strings.ToUpper(param), "TZID=") {
+			tz, err := time.LoadLocation(strings.Trim(param[len("TZID="):], `"`))
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid time zone of %s: %v", value, err)
+			}
+			location = tz
+		}
+	}
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if len(value) != len(layout) {
+			continue
+		}
+		if strings.HasSuffix(layout, "Z") {
+			return time.Parse(layout, value)
+		}
+		return time.ParseInLocation(layout, value, location)
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// mentions returns the names mentioned in the text without the @.
+func mentions(text string) []string {
+	var names []string
+	for _, field := range strings.Fields(text) {
+		if !strings.HasPrefix(field, "@") {
+			continue
+		}
+		name := strings.TrimRight(field[1:], ".,;:!?)")
+		if name != "" {
+			names = appendMissing(names, []string{name})
+		}
+	}
+	return names
+}
@@ -0,0 +1,83 @@
+package oncall
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const rotation = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:rotation-alice
+DTSTART;TZID=Europe/Berlin:20240101T090000
+DTEND;TZID=Europe/Berlin:20240108T090000
+RRULE:FREQ=WEEKLY;INTERVAL=2
+EXDATE;TZID=Europe/Berlin:20240129T090000
+SUMMARY:On call: @alice
+END:VEVENT
+BEGIN:VEVENT
+UID:rotation-bob
+DTSTART;TZID=Europe/Berlin:20240108T090000
+DTEND;TZID=Europe/Berlin:20240115T090000
+RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3
+SUMMARY:On call: @bob
+END:VEVENT
+BEGIN:VEVENT
+UID:rotation-alice
+RECURRENCE-ID;TZID=Europe/Berlin:20240212T090000
+DTSTART;TZID=Europe/Berlin:20240213T090000
+DTEND;TZID=Europe/Berlin:20240219T090000
+SUMMARY:On call: @carol
+END:VEVENT
+BEGIN:VEVENT
+UID:weekdays
+DTSTART:20240101T170000Z
+DTEND:20240101T180000Z
+RRULE:FREQ=DAILY;UNTIL=20240103T170000Z
+DESCRIPTION:Handover by @dave
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICalRecurrences(t *testing.T) {
+	events, err := ParseICal(strings.NewReader(rotation), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseICal() failed: %v", err)
+	}
+	schedule := &Schedule{ICalURL: "https://calendar.example.com/on-call.ics"}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	for _, test := range []struct {
+		time  time.Time
+		users string
+	}{
+		{time.Date(2024, 1, 1, 9, 0, 0, 0, berlin), "alice"},
+		{time.Date(2024, 1, 10, 12, 0, 0, 0, berlin), "bob"},
+		{time.Date(2024, 1, 15, 12, 0, 0, 0, berlin), "alice"},
+		// The occurrence of the third week is excluded.
+		{time.Date(2024, 1, 30, 12, 0, 0, 0, berlin), ""},
+		// The occurrence of the fourth week has been moved to carol.
+		{time.Date(2024, 2, 12, 12, 0, 0, 0, berlin), ""},
+		{time.Date(2024, 2, 14, 12, 0, 0, 0, berlin), "carol"},
+		// Bob's rotation ends after three occurrences.
+		{time.Date(2024, 2, 5, 12, 0, 0, 0, berlin), "bob"},
+		{time.Date(2024, 2, 20, 12, 0, 0, 0, berlin), ""},
+		// Occurrences keep their time of the day across daylight saving time changes.
+		{time.Date(2024, 4, 8, 8, 30, 0, 0, berlin), ""},
+		{time.Date(2024, 4, 8, 9, 30, 0, 0, berlin), "alice"},
+		{time.Date(2024, 1, 3, 17, 30, 0, 0, time.UTC), "alice,dave"},
+		{time.Date(2024, 1, 4, 17, 30, 0, 0, time.UTC), "alice"},
+	} {
+		if users := strings.Join(schedule.OnCall(test.time, events).Users, ","); users != test.users {
+			t.Errorf("on call at %s: got %q, want %q", test.time, users, test.users)
+		}
+	}
+}
+
+func TestParseICalRejectsUnsupportedRecurrences(t *testing.T) {
+	for _, rule := range []string{"FREQ=MONTHLY", "FREQ=WEEKLY;BYDAY=1MO", "FREQ=DAILY;BYHOUR=9"} {
+		calendar := "BEGIN:VEVENT\nDTSTART:20240101T090000Z\nRRULE:" + rule + "\nSUMMARY:@alice\nEND:VEVENT\n"
+		if _, err := ParseICal(strings.NewReader(calendar), time.UTC); err == nil {
+			t.Errorf("recurrence rule %s was accepted", rule)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package oncall resolves who is on call from OnCallSchedule resources, which either list weekly
+// shifts or point to an iCal calendar of on-call events.
+package oncall
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GVR identifies the OnCallSchedule custom resource.
+var GVR = schema.GroupVersionResource{
+	Group:    "informer.espe.tech",
+	Version:  "v1alpha1",
+	Resource: "oncallschedules",
+}
+
+// Spec is the specification of an OnCallSchedule resource.
+type Spec struct {
+	// TimeZone is the IANA time zone of the shifts, UTC by default.
+	TimeZone string      `json:"timeZone"`
+	Shifts   []ShiftSpec `json:"shifts"`
+	// ICalURL points to a calendar of on-call events, which is used instead of the shifts.
+	ICalURL string `json:"icalURL"`
+	// DirectMessage sends alerts to the users on call directly in addition to the channel.
+	DirectMessage bool `json:"directMessage"`
+}
+
+// ShiftSpec puts users and groups on call between start and end on the given days.
+type ShiftSpec struct {
+	// Days are week days like Mon or Monday, all days if empty.
+	Days []string `json:"days"`
+	// Start and End are times of the day like 09:00. Shifts ending before they start end on the
+	// next day, shifts ending when they start last a whole day.
+	Start  string   `json:"start"`
+	End    string   `json:"end"`
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+}
+
+// Shift is a parsed ShiftSpec.
+type Shift struct {
+	// Days holds the week days of the shift, nil for all days.
+	Days       map[time.Weekday]bool
+	Start, End time.Duration
+	Users      []string
+	Groups     []string
+}
+
+// Schedule determines who is on call.
+type Schedule struct {
+	Namespace     string
+	Name          string
+	Location      *time.Location
+	Shifts        []Shift
+	ICalURL       string
+	DirectMessage bool
+}
+
+// OnCall lists the Mattermost users and groups on call.
+type OnCall struct {
+	Users  []string
+	Groups []string
+}
+
+// Mentions returns the mentions of all users and groups on call, e.g. @alice.
+func (o *OnCall) Mentions() []string {
+	var mentions []string
+	for _, name := range append(append([]string(nil), o.Users...), o.Groups...) {
+		mentions = append(mentions, "@"+name)
+	}
+	return mentions
+}
+
+func (o *OnCall) add(users, groups []string) {
+	o.Users = appendMissing(o.Users, users)
+	o.Groups = appendMissing(o.Groups, groups)
+}
+
+func appendMissing(list, names []string) []string {
+outer:
+	for _, name := range names {
+		for _, existing := range list {
+			if existing == name {
+				continue outer
+			}
+		}
+		list = append(list, name)
+	}
+	return list
+}
+
+// trimMentions strips the @ of users and groups given as mentions.
+func trimMentions(names []string) []string {
+	trimmed := make([]string, 0, len(names))
+	for _, name := range names {
+		trimmed = append(trimmed, strings.TrimPrefix(name, "@"))
+	}
+	return trimmed
+}
+
+// FromResource parses a schedule from an OnCallSchedule resource.
+func FromResource(obj *unstructured.Unstructured) (*Schedule, error) {
+	data, err := json.Marshal(obj.Object["spec"])
+	if err != nil {
+		return nil, err
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	schedule := &Schedule{
+		Namespace:     obj.GetNamespace(),
+		Name:          obj.GetName(),
+		Location:      time.UTC,
+		ICalURL:       spec.ICalURL,
+		DirectMessage: spec.DirectMessage,
+	}
+	if spec.TimeZone != "" {
+		if schedule.Location, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone: %v", err)
+		}
+	}
+	if spec.ICalURL == "" && len(spec.Shifts) == 0 {
+		return nil, fmt.Errorf("neither shifts nor an iCal URL given")
+	}
+	for i, shiftSpec := range spec.Shifts {
+		shift := Shift{Users: trimMentions(shiftSpec.Users), Groups: trimMentions(shiftSpec.Groups)}
+		if len(shift.Users)+len(shift.Groups) == 0 {
+			return nil, fmt.Errorf("shift %d has neither users nor groups", i+1)
+		}
+		for _, day := range shiftSpec.Days {
+			weekday, err := utils.ParseWeekday(day)
+			if err != nil {
+				return nil, fmt.Errorf("shift %d: %v", i+1, err)
+			}
+			if shift.Days == nil {
+				shift.Days = make(map[time.Weekday]bool)
+			}
+			shift.Days[weekday] = true
+		}
+		if shift.Start, err = utils.ParseTimeOfDay(shiftSpec.Start); err != nil {
+			return nil, fmt.Errorf("shift %d: %v", i+1, err)
+		}
+		if shift.End, err = utils.ParseTimeOfDay(shiftSpec.End); err != nil {
+			return nil, fmt.Errorf("shift %d: %v", i+1, err)
+		}
+		schedule.Shifts = append(schedule.Shifts, shift)
+	}
+	return schedule, nil
+}
+
+// Covers checks if the shift includes the given time in its location. The shift of the previous
+// day is checked as well, as it may last until the given time.
+func (s *Shift) Covers(t time.Time) bool {
+	for _, offset := range []int{0, -1} {
+		day := t.AddDate(0, 0, offset)
+		if s.Days != nil && !s.Days[day.Weekday()] {
+			continue
+		}
+		midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+		start, end := midnight.Add(s.Start), midnight.Add(s.End)
+		if s.End <= s.Start {
+			end = end.Add(24 * time.Hour)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnCall returns the users and groups on call at the given time according to the shifts or the
+// events of the calendar, if the schedule uses one.
+func (s *Schedule) OnCall(now time.Time, events []Event) *OnCall {
+	onCall := &OnCall{}
+	if s.ICalURL != "" {
+		for _, event := range events {
+			if event.Covers(now) {
+				onCall.add(event.Users, nil)
+			}
+		}
+		return onCall
+	}
+	now = now.In(s.Location)
+	for _, shift := range s.Shifts {
+		if shift.Covers(now) {
+			onCall.add(shift.Users, shift.Groups)
+		}
+	}
+	return onCall
+}
@@ -0,0 +1,185 @@
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// calendarTTL is how long a fetched calendar is used before it is fetched again.
+	calendarTTL = 5 * time.Minute
+	// calendarTimeout bounds fetching a calendar.
+	calendarTimeout = 10 * time.Second
+)
+
+type calendar struct {
+	events  []Event
+	fetched time.Time
+}
+
+// Store keeps the OnCallSchedules up to date and caches the calendars they refer to.
+type Store struct {
+	informer cache.SharedIndexInformer
+	// global is the namespace holding schedules usable from all namespaces.
+	global string
+	client *http.Client
+	clock  clock.PassiveClock
+
+	mu        sync.RWMutex
+	schedules map[string]*Schedule
+	calendars map[string]calendar
+	// fetching holds the URLs of the calendars being fetched.
+	fetching map[string]bool
+}
+
+// NewStore creates a store watching the OnCallSchedules in the given namespace, or all namespaces
+// if metav1.NamespaceAll is given. Schedules are looked up in the namespace of the alert first and
+// then in the global namespace.
+func NewStore(client dynamic.Interface, namespace, global string) *Store {
+	return NewStoreWithClock(client, namespace, global, clock.RealClock{})
+}
+
+// NewStoreWithClock creates a store like NewStore, expiring fetched calendars by the given clock.
+func NewStoreWithClock(client dynamic.Interface, namespace, global string, clock clock.PassiveClock) *Store {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, namespace, nil)
+	store := &Store{
+		informer:  factory.ForResource(GVR).Informer(),
+		global:    global,
+		client:    &http.Client{Timeout: calendarTimeout},
+		clock:     clock,
+		schedules: make(map[string]*Schedule),
+		calendars: make(map[string]calendar),
+		fetching:  make(map[string]bool),
+	}
+	store.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    store.update,
+		UpdateFunc: func(old, new interface{}) { store.update(new) },
+		DeleteFunc: store.delete,
+	})
+	return store
+}
+
+// Run watches the schedules until stopCh is closed.
+func (s *Store) Run(stopCh <-chan struct{}) {
+	s.informer.Run(stopCh)
+}
+
+// HasSynced returns true once the initial schedules have been loaded.
+func (s *Store) HasSynced() bool {
+	return s.informer.HasSynced()
+}
+
+// Get returns the schedule with the given name in the namespace, or in the global namespace.
+func (s *Store) Get(namespace, name string) (*Schedule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if schedule, ok := s.schedules[namespace+"/"+name]; ok {
+		return schedule, true
+	}
+	schedule, ok := s.schedules[s.global+"/"+name]
+	return schedule, ok
+}
+
+// OnCall returns who is on call at the given time according to the schedule. Calendars are fetched
+// in the background once the schedule is loaded and whenever they are outdated, so that alerts
+// are not delayed by fetching them. Until a calendar has been fetched an error is returned, if it
+// cannot be fetched again the last fetched events are used.
+func (s *Store) OnCall(schedule *Schedule, now time.Time) (*OnCall, error) {
+	if schedule.ICalURL == "" {
+		return schedule.OnCall(now, nil), nil
+	}
+	s.mu.RLock()
+	cached, ok := s.calendars[schedule.ICalURL]
+	s.mu.RUnlock()
+	if !ok || s.clock.Since(cached.fetched) > calendarTTL {
+		go s.refresh(schedule)
+	}
+	if !ok {
+		return nil, fmt.Errorf("calendar %s has not been fetched yet", schedule.ICalURL)
+	}
+	return schedule.OnCall(now, cached.events), nil
+}
+
+// refresh fetches the calendar of the schedule, unless it is already being fetched.
+func (s *Store) refresh(schedule *Schedule) {
+	s.mu.Lock()
+	if s.fetching[schedule.ICalURL] {
+		s.mu.Unlock()
+		return
+	}
+	s.fetching[schedule.ICalURL] = true
+	s.mu.Unlock()
+	events, err := s.fetch(context.Background(), schedule)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.fetching, schedule.ICalURL)
+	if err != nil {
+		klog.ErrorS(err, "Fetching on-call calendar failed", "schedule", klog.KRef(schedule.Namespace, schedule.Name))
+		return
+	}
+	s.calendars[schedule.ICalURL] = calendar{events: events, fetched: s.clock.Now()}
+}
+
+func (s *Store) fetch(ctx context.Context, schedule *Schedule) ([]Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, schedule.ICalURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create calendar request: %v", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch calendar: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar failed with status %s", resp.Status)
+	}
+	return ParseICal(resp.Body, schedule.Location)
+}
+
+func (s *Store) update(obj interface{}) {
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key := resourceKey(resource)
+	schedule, err := FromResource(resource)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid on-call schedule", "schedule", key)
+		delete(s.schedules, key)
+		return
+	}
+	klog.InfoS("Loaded on-call schedule", "schedule", key)
+	s.schedules[key] = schedule
+	if schedule.ICalURL != "" {
+		go s.refresh(schedule)
+	}
+}
+
+func (s *Store) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules, resourceKey(resource))
+}
+
+func resourceKey(resource *unstructured.Unstructured) string {
+	return resource.GetNamespace() + "/" + resource.GetName()
+}
@@ -0,0 +1,187 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// GVR identifies the NotificationRecord custom resource.
+var GVR = schema.GroupVersionResource{
+	Group:    "informer.espe.tech",
+	Version:  "v1alpha1",
+	Resource: "notificationrecords",
+}
+
+const (
+	// labelFingerprint and labelResolved allow selecting the records of an alert and the unresolved records.
+	labelFingerprint = "informer.espe.tech/fingerprint"
+	labelResolved    = "informer.espe.tech/resolved"
+)
+
+// Spec describes a notification sent by a notifier.
+type Spec struct {
+	Fingerprint       string `json:"fingerprint"`
+	Cluster           string `json:"cluster,omitempty"`
+	Namespace         string `json:"namespace"`
+	Pod               string `json:"pod"`
+	Container         string `json:"container"`
+	Workload          string `json:"workload,omitempty"`
+	Reason            string `json:"reason"`
+	TerminationReason string `json:"terminationReason,omitempty"`
+	Severity          string `json:"severity"`
+	Title             string `json:"title"`
+	Text              string `json:"text"`
+	Channel           string `json:"channel,omitempty"`
+	Notifier          string `json:"notifier"`
+	// PostID identifies the message posted by notifiers supporting it, e.g. the Mattermost post.
+	PostID string      `json:"postID,omitempty"`
+	SentAt metav1.Time `json:"sentAt"`
+}
+
+// Status records the resolution of the alert.
+type Status struct {
+	ResolvedAt *metav1.Time `json:"resolvedAt,omitempty"`
+}
+
+// Record is a NotificationRecord resource.
+type Record struct {
+	Name   string
+	Spec   Spec
+	Status Status
+}
+
+// Store persists NotificationRecords in a namespace.
+type Store struct {
+	client    dynamic.ResourceInterface
+	retention time.Duration
+}
+
+// NewStore creates a store keeping the records in the given namespace for the retention period.
+func NewStore(client dynamic.Interface, namespace string, retention time.Duration) *Store {
+	return &Store{
+		client:    client.Resource(GVR).Namespace(namespace),
+		retention: retention,
+	}
+}
+
+// Create records a sent notification.
+func (s *Store) Create(ctx context.Context, spec *Spec) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		return fmt.Errorf("could not encode notification record: %v", err)
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": content}}
+	obj.SetGroupVersionKind(GVR.GroupVersion().WithKind("NotificationRecord"))
+	obj.SetGenerateName("notification-")
+	obj.SetLabels(map[string]string{
+		labelFingerprint: spec.Fingerprint,
+		labelResolved:    "false",
+	})
+	if _, err := s.client.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("could not create notification record: %v", err)
+	}
+	return nil
+}
+
+// Resolve marks the unresolved records of the alert with the given fingerprint as resolved.
+func (s *Store) Resolve(ctx context.Context, fingerprint string, resolvedAt time.Time) error {
+	list, err := s.client.List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s,%s=false", labelFingerprint, fingerprint, labelResolved),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list notification records: %v", err)
+	}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		labels := obj.GetLabels()
+		labels[labelResolved] = "true"
+		obj.SetLabels(labels)
+		if err := unstructured.SetNestedField(obj.Object, resolvedAt.UTC().Format(time.RFC3339), "status", "resolvedAt"); err != nil {
+			return err
+		}
+		if _, err := s.client.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("could not resolve notification record %s: %v", obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// Unresolved returns the records of alerts which have not been resolved yet.
+func (s *Store) Unresolved(ctx context.Context) ([]Record, error) {
+	return s.list(ctx, labelResolved+"=false")
+}
+
+// List returns all records kept for the retention period, the most recent first.
+func (s *Store) List(ctx context.Context) ([]Record, error) {
+	records, err := s.list(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Spec.SentAt.After(records[j].Spec.SentAt.Time) })
+	return records, nil
+}
+
+func (s *Store) list(ctx context.Context, selector string) ([]Record, error) {
+	list, err := s.client.List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("could not list notification records: %v", err)
+	}
+	records := make([]Record, 0, len(list.Items))
+	for i := range list.Items {
+		record, err := fromResource(&list.Items[i])
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid notification record", "record", list.Items[i].GetName())
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Collect deletes the records older than the retention period.
+func (s *Store) Collect(ctx context.Context) error {
+	list, err := s.client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("could not list notification records: %v", err)
+	}
+	deleted := 0
+	for _, obj := range list.Items {
+		if time.Since(obj.GetCreationTimestamp().Time) < s.retention {
+			continue
+		}
+		if err := s.client.Delete(ctx, obj.GetName(), metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("could not delete notification record %s: %v", obj.GetName(), err)
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		klog.InfoS("Deleted expired notification records", "count", deleted)
+	}
+	return nil
+}
+
+func fromResource(obj *unstructured.Unstructured) (Record, error) {
+	record := Record{Name: obj.GetName()}
+	spec, _, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil {
+		return record, fmt.Errorf("invalid spec: %v", err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &record.Spec); err != nil {
+		return record, fmt.Errorf("invalid spec: %v", err)
+	}
+	if status, ok, _ := unstructured.NestedMap(obj.Object, "status"); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(status, &record.Status); err != nil {
+			return record, fmt.Errorf("invalid status: %v", err)
+		}
+	}
+	return record, nil
+}
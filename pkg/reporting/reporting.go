@@ -0,0 +1,42 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// Setup reports the errors passed to runtime.HandleError and the panics recovered by
+// runtime.HandleCrash to Sentry. The returned function flushes the buffered events.
+func Setup(dsn, environment, release string) (func(time.Duration), error) {
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+		Release:     release,
+		ServerName:  "mattermost-informer",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up Sentry: %v", err)
+	}
+	runtime.ErrorHandlers = append(runtime.ErrorHandlers, func(ctx context.Context, err error, msg string, keysAndValues ...interface{}) {
+		sentry.WithScope(func(scope *sentry.Scope) {
+			details := sentry.Context{}
+			if msg != "" {
+				details["message"] = msg
+			}
+			for i := 0; i+1 < len(keysAndValues); i += 2 {
+				details[fmt.Sprint(keysAndValues[i])] = fmt.Sprint(keysAndValues[i+1])
+			}
+			scope.SetContext("informer", details)
+			sentry.CaptureException(err)
+		})
+	})
+	runtime.PanicHandlers = append(runtime.PanicHandlers, func(ctx context.Context, r interface{}) {
+		sentry.CurrentHub().Recover(r)
+		sentry.Flush(2 * time.Second)
+	})
+	return func(timeout time.Duration) { sentry.Flush(timeout) }, nil
+}
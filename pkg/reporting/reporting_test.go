@@ -0,0 +1,49 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/runtime"
+)
+
+func TestErrorsAreReported(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		events = append(events, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+	handlers := runtime.ErrorHandlers
+	defer func() { runtime.ErrorHandlers = handlers }()
+	runtime.ErrorHandlers = nil
+
+	dsn := strings.Replace(server.URL, "http://", "http://public@", 1) + "/1"
+	flush, err := Setup(dsn, "test", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.HandleErrorWithContext(context.Background(), errors.New("sync failed"), "Dropping pod", "pod", "apps/web")
+	flush(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %d", len(events))
+	}
+	for _, want := range []string{`"release":"v1.2.0"`, `"informer":{`, `"message":"Dropping pod"`, `"pod":"apps/web"`, "sync failed"} {
+		if !strings.Contains(events[0], want) {
+			t.Errorf("expected event to contain %s, got %s", want, events[0])
+		}
+	}
+}
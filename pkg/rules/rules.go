@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GVR identifies the AlertRule custom resource.
+var GVR = schema.GroupVersionResource{
+	Group:    "informer.espe.tech",
+	Version:  "v1alpha1",
+	Resource: "alertrules",
+}
+
+// Spec is the specification of an AlertRule resource.
+type Spec struct {
+	// Kind is the kind of resource watched, only Pod is supported.
+	Kind     string                `json:"kind"`
+	Selector *metav1.LabelSelector `json:"selector"`
+	// Reasons are the waiting or termination reasons of containers triggering the rule.
+	Reasons []string `json:"reasons"`
+	// MinRestarts is the number of restarts required before alerting.
+	MinRestarts int32           `json:"minRestarts"`
+	Backoff     metav1.Duration `json:"backoff"`
+
+	Channel   string            `json:"channel"`
+	Severity  string            `json:"severity"`
+	Templates *config.Templates `json:"templates"`
+}
+
+// Rule decides which containers are alerted on and how.
+type Rule struct {
+	Namespace   string
+	Name        string
+	Selector    labels.Selector
+	Reasons     []string
+	MinRestarts int32
+	// Backoff overrides the default backoff if non-zero.
+	Backoff time.Duration
+
+	// Channel, Severity and Templates override the defaults if set.
+	Channel   string
+	Severity  string
+	Templates *config.Templates
+}
+
+// Default is the built-in rule used when no AlertRules exist.
+var Default = &Rule{
+	Name:     "default",
+	Selector: labels.Everything(),
+	Reasons:  []string{"CrashLoopBackOff"},
+}
+
+func (r *Rule) key() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// Matches checks if the rule applies to the container of the given pod.
+func (r *Rule) Matches(pod *v1.Pod, container *v1.ContainerStatus) bool {
+	if container.Ready || container.State.Waiting == nil {
+		return false
+	}
+	if !r.Selector.Matches(labels.Set(pod.Labels)) || container.RestartCount < r.MinRestarts {
+		return false
+	}
+	return r.Reason(container) != ""
+}
+
+// Reason returns the waiting or termination reason of the container the rule triggers on, if any.
+func (r *Rule) Reason(container *v1.ContainerStatus) string {
+	candidates := []string{}
+	if container.State.Waiting != nil {
+		candidates = append(candidates, container.State.Waiting.Reason)
+	}
+	if terminated := container.LastTerminationState.Terminated; terminated != nil {
+		candidates = append(candidates, terminated.Reason)
+	}
+	for _, candidate := range candidates {
+		for _, reason := range r.Reasons {
+			if candidate == reason {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// FromResource parses a rule from an AlertRule resource.
+func FromResource(obj *unstructured.Unstructured) (*Rule, error) {
+	data, err := json.Marshal(obj.Object["spec"])
+	if err != nil {
+		return nil, err
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	if spec.Kind != "" && spec.Kind != "Pod" {
+		return nil, fmt.Errorf("unsupported kind %s", spec.Kind)
+	}
+	if len(spec.Reasons) == 0 {
+		return nil, fmt.Errorf("no reasons given")
+	}
+	selector := labels.Everything()
+	if spec.Selector != nil {
+		if selector, err = metav1.LabelSelectorAsSelector(spec.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector: %v", err)
+		}
+	}
+	if spec.Templates != nil {
+		if err := spec.Templates.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &Rule{
+		Namespace:   obj.GetNamespace(),
+		Name:        obj.GetName(),
+		Selector:    selector,
+		Reasons:     spec.Reasons,
+		MinRestarts: spec.MinRestarts,
+		Backoff:     spec.Backoff.Duration,
+		Channel:     spec.Channel,
+		Severity:    spec.Severity,
+		Templates:   spec.Templates,
+	}, nil
+}
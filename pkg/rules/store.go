@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Store keeps the AlertRules up to date.
+type Store struct {
+	informer cache.SharedIndexInformer
+	// global is the namespace holding rules applying to all namespaces.
+	global string
+
+	mu    sync.RWMutex
+	rules map[string]*Rule
+}
+
+// NewStore creates a store watching the AlertRules in the given namespace, or all namespaces
+// if metav1.NamespaceAll is given. Rules in the global namespace apply to pods in all namespaces,
+// other rules only to pods in their own namespace.
+func NewStore(client dynamic.Interface, namespace, global string) *Store {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, namespace, nil)
+	store := &Store{
+		informer: factory.ForResource(GVR).Informer(),
+		global:   global,
+		rules:    make(map[string]*Rule),
+	}
+	store.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    store.update,
+		UpdateFunc: func(old, new interface{}) { store.update(new) },
+		DeleteFunc: store.delete,
+	})
+	return store
+}
+
+// Run watches the rules until stopCh is closed.
+func (s *Store) Run(stopCh <-chan struct{}) {
+	s.informer.Run(stopCh)
+}
+
+// HasSynced returns true once the initial rules have been loaded.
+func (s *Store) HasSynced() bool {
+	return s.informer.HasSynced()
+}
+
+// List returns the valid rules applying to pods in the given namespace, ordered by name.
+func (s *Store) List(namespace string) []*Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]*Rule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		if rule.Namespace == namespace || rule.Namespace == s.global {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].key() < rules[j].key() })
+	return rules
+}
+
+func (s *Store) update(obj interface{}) {
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key := resourceKey(resource)
+	rule, err := FromResource(resource)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid alert rule", "rule", key)
+		delete(s.rules, key)
+		return
+	}
+	klog.InfoS("Loaded alert rule", "rule", key)
+	s.rules[key] = rule
+}
+
+func (s *Store) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rules, resourceKey(resource))
+}
+
+func resourceKey(resource *unstructured.Unstructured) string {
+	return resource.GetNamespace() + "/" + resource.GetName()
+}
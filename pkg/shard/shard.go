@@ -0,0 +1,164 @@
+package shard
+
+import (
+	"context"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+const (
+	leasePrefix = "mattermost-informer-shard-"
+	leaseLabel  = "espe.tech/mattermost-informer-shard"
+)
+
+// Membership tracks the live replicas using one Lease per replica. Namespaces are
+// distributed among the members by hashing their names.
+type Membership struct {
+	client        kubernetes.Interface
+	namespace     string
+	identity      string
+	leaseDuration time.Duration
+	clock         clock.PassiveClock
+	// onChange is called whenever the set of members changes.
+	onChange func()
+
+	mu      sync.RWMutex
+	members []string
+}
+
+// NewMembership creates a membership for the replica with the given identity, storing leases in namespace.
+func NewMembership(client kubernetes.Interface, namespace, identity string, leaseDuration time.Duration, onChange func()) *Membership {
+	return NewMembershipWithClock(client, namespace, identity, leaseDuration, onChange, clock.RealClock{})
+}
+
+// NewMembershipWithClock creates a membership renewing and expiring leases by the given clock.
+func NewMembershipWithClock(client kubernetes.Interface, namespace, identity string, leaseDuration time.Duration, onChange func(), clock clock.PassiveClock) *Membership {
+	return &Membership{
+		client:        client,
+		namespace:     namespace,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		clock:         clock,
+		onChange:      onChange,
+	}
+}
+
+// Owns checks if the given namespace belongs to the shard of this replica.
+func (m *Membership) Owns(namespace string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	index := sort.SearchStrings(m.members, m.identity)
+	if index == len(m.members) || m.members[index] != m.identity {
+		return false
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(namespace))
+	return int(hash.Sum32()%uint32(len(m.members))) == index
+}
+
+// First checks if this replica is the first of the live replicas, which runs the tasks done once
+// for all shards, e.g. posting reports.
+func (m *Membership) First() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.members) > 0 && m.members[0] == m.identity
+}
+
+// Members returns the identities of all live replicas.
+func (m *Membership) Members() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.members...)
+}
+
+// Sync renews the lease of this replica and refreshes the members.
+func (m *Membership) Sync() error {
+	if err := m.renew(); err != nil {
+		return err
+	}
+	leases, err := m.client.CoordinationV1().Leases(m.namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: leaseLabel})
+	if err != nil {
+		return err
+	}
+	var members []string
+	now := m.clock.Now()
+	for _, lease := range leases.Items {
+		if isLive(&lease, now) && lease.Spec.HolderIdentity != nil {
+			members = append(members, *lease.Spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+
+	m.mu.Lock()
+	changed := !reflect.DeepEqual(members, m.members)
+	m.members = members
+	m.mu.Unlock()
+	if changed {
+		klog.InfoS("Shard members changed", "members", members)
+		if m.onChange != nil {
+			m.onChange()
+		}
+	}
+	return nil
+}
+
+func isLive(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
+
+func (m *Membership) renew() error {
+	leases := m.client.CoordinationV1().Leases(m.namespace)
+	now := metav1.NewMicroTime(m.clock.Now())
+	duration := int32(m.leaseDuration / time.Second)
+	lease, err := leases.Get(context.TODO(), leasePrefix+m.identity, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = leases.Create(context.TODO(), &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   leasePrefix + m.identity,
+				Labels: map[string]string{leaseLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.RenewTime = &now
+	lease.Spec.LeaseDurationSeconds = &duration
+	_, err = leases.Update(context.TODO(), lease, metav1.UpdateOptions{})
+	return err
+}
+
+// Run keeps the lease renewed until stopCh is closed, then releases it.
+func (m *Membership) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := m.Sync(); err != nil {
+			klog.ErrorS(err, "Renewing shard lease failed")
+		}
+	}, m.leaseDuration/3, stopCh)
+	err := m.client.CoordinationV1().Leases(m.namespace).Delete(context.TODO(), leasePrefix+m.identity, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.ErrorS(err, "Releasing shard lease failed")
+	}
+}
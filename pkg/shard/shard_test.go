@@ -0,0 +1,58 @@
+package shard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestMembersExpire(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	clock := testingclock.NewFakePassiveClock(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC))
+	changes := 0
+	a := NewMembershipWithClock(client, "informer", "a", 30*time.Second, func() { changes++ }, clock)
+	b := NewMembershipWithClock(client, "informer", "b", 30*time.Second, nil, clock)
+
+	if err := b.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if members := a.Members(); !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Errorf("members are %v, want a and b", members)
+	}
+	if err := b.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.First() || b.First() {
+		t.Errorf("a is first %v, b is first %v, want only a", a.First(), b.First())
+	}
+
+	// Only a renews its lease, the lease of b expires.
+	clock.SetTime(clock.Now().Add(20 * time.Second))
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if members := a.Members(); len(members) != 2 {
+		t.Errorf("members are %v before the lease of b expired", members)
+	}
+	clock.SetTime(clock.Now().Add(20 * time.Second))
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if members := a.Members(); !reflect.DeepEqual(members, []string{"a"}) {
+		t.Errorf("members are %v after the lease of b expired, want a", members)
+	}
+	if changes != 2 {
+		t.Errorf("members changed %d times, want 2", changes)
+	}
+	for _, namespace := range []string{"apps", "payments", "monitoring"} {
+		if !a.Owns(namespace) {
+			t.Errorf("only member does not own namespace %s", namespace)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package slo evaluates the error budgets of ServiceLevelObjective resources, which set a target
+// availability for a workload. A workload is considered unavailable from the first crash alert of
+// one of its containers until the container recovered.
+package slo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GVR identifies the ServiceLevelObjective custom resource.
+var GVR = schema.GroupVersionResource{
+	Group:    "informer.espe.tech",
+	Version:  "v1alpha1",
+	Resource: "servicelevelobjectives",
+}
+
+// DefaultWindow is the time the error budget is computed over unless set.
+const DefaultWindow = 30 * 24 * time.Hour
+
+// DefaultAlerts warn about fast and slow burns of the error budget, which exhaust a 30 day budget
+// in about 2 and 5 days.
+var DefaultAlerts = []Alert{
+	{Window: time.Hour, BurnRate: 14.4},
+	{Window: 6 * time.Hour, BurnRate: 6},
+}
+
+// Spec is the specification of a ServiceLevelObjective resource.
+type Spec struct {
+	// Workload is the kind and name of the workload in the namespace of the resource, e.g.
+	// Deployment/payments.
+	Workload string `json:"workload"`
+	// Target is the availability in percent, e.g. 99.9.
+	Target float64         `json:"target"`
+	Window metav1.Duration `json:"window"`
+	Alerts []AlertSpec     `json:"alerts"`
+}
+
+// AlertSpec warns once the error budget burned at BurnRate times the sustainable rate over Window.
+type AlertSpec struct {
+	Window   metav1.Duration `json:"window"`
+	BurnRate float64         `json:"burnRate"`
+}
+
+// Alert is a burn rate threshold of an objective.
+type Alert struct {
+	Window   time.Duration
+	BurnRate float64
+}
+
+// Objective is the availability target of a workload.
+type Objective struct {
+	Namespace string
+	Name      string
+	Workload  string
+	Target    float64
+	Window    time.Duration
+	// Alerts are ordered by their burn rate, highest first.
+	Alerts []Alert
+}
+
+// FromResource parses an objective from a ServiceLevelObjective resource.
+func FromResource(obj *unstructured.Unstructured) (*Objective, error) {
+	data, err := json.Marshal(obj.Object["spec"])
+	if err != nil {
+		return nil, err
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	if !strings.Contains(spec.Workload, "/") {
+		return nil, fmt.Errorf("workload %q is not given as kind/name", spec.Workload)
+	}
+	if spec.Target <= 0 || spec.Target >= 100 {
+		return nil, fmt.Errorf("target %v is not between 0 and 100", spec.Target)
+	}
+	objective := &Objective{
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Workload:  spec.Workload,
+		Target:    spec.Target,
+		Window:    spec.Window.Duration,
+		Alerts:    DefaultAlerts,
+	}
+	if objective.Window <= 0 {
+		objective.Window = DefaultWindow
+	}
+	if len(spec.Alerts) > 0 {
+		objective.Alerts = nil
+		for _, alert := range spec.Alerts {
+			if alert.Window.Duration <= 0 || alert.Window.Duration > objective.Window || alert.BurnRate <= 0 {
+				return nil, fmt.Errorf("alert needs a positive window within the window of the objective and a positive burn rate")
+			}
+			objective.Alerts = append(objective.Alerts, Alert{Window: alert.Window.Duration, BurnRate: alert.BurnRate})
+		}
+	}
+	sort.Slice(objective.Alerts, func(i, j int) bool { return objective.Alerts[i].BurnRate > objective.Alerts[j].BurnRate })
+	return objective, nil
+}
+
+// Budget returns the downtime allowed within the window of the objective.
+func (o *Objective) Budget() time.Duration {
+	return time.Duration(float64(o.Window) * (1 - o.Target/100))
+}
+
+// BurnRate returns how many times faster than sustainable the budget burned with the given
+// downtime within the window.
+func (o *Objective) BurnRate(downtime, window time.Duration) float64 {
+	return downtime.Seconds() / (window.Seconds() * (1 - o.Target/100))
+}
+
+// Interval is a time the workload was unavailable.
+type Interval struct {
+	Start, End time.Time
+}
+
+// Downtime returns the time between since and until covered by the intervals, counting
+// overlapping intervals once.
+func Downtime(intervals []Interval, since, until time.Time) time.Duration {
+	sorted := make([]Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		if interval.Start.Before(since) {
+			interval.Start = since
+		}
+		if interval.End.After(until) {
+			interval.End = until
+		}
+		if interval.End.After(interval.Start) {
+			sorted = append(sorted, interval)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	var downtime time.Duration
+	var end time.Time
+	for _, interval := range sorted {
+		if interval.Start.Before(end) {
+			if !interval.End.After(end) {
+				continue
+			}
+			interval.Start = end
+		}
+		downtime += interval.End.Sub(interval.Start)
+		end = interval.End
+	}
+	return downtime
+}
@@ -0,0 +1,173 @@
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+// Entry is a notification which could not be delivered.
+type Entry struct {
+	Notifier string        `json:"notifier"`
+	Alert    *notify.Alert `json:"alert"`
+	// Resolve is set for the resolution of an alert which is still spooled itself.
+	Resolve   bool      `json:"resolve,omitempty"`
+	SpooledAt time.Time `json:"spooledAt"`
+	// Attempts counts the failed deliveries from the spool.
+	Attempts int `json:"attempts,omitempty"`
+
+	path string
+}
+
+// Name identifies the entry in the spool.
+func (e *Entry) Name() string {
+	return filepath.Base(e.path)
+}
+
+// Spool persists undelivered notifications as JSON files in a directory, so that they survive
+// restarts until the notifier is reachable again.
+type Spool struct {
+	dir        string
+	maxEntries int
+
+	mu sync.Mutex
+}
+
+// New creates a spool in dir, which is created if it does not exist. It holds up to maxEntries entries.
+// Temporary files left behind by writes interrupted by a crash are removed.
+func New(dir string, maxEntries int) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create spool directory: %v", err)
+	}
+	tmps, err := filepath.Glob(filepath.Join(dir, ".*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool directory: %v", err)
+	}
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+	return &Spool{dir: dir, maxEntries: maxEntries}, nil
+}
+
+// Add stores the entry, which is spooled at the given time. It fails if the spool is full.
+func (s *Spool) Add(entry *Entry, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.names()
+	if err != nil {
+		return err
+	}
+	if len(names) >= s.maxEntries {
+		return fmt.Errorf("spool is full with %d entries", len(names))
+	}
+	entry.SpooledAt = now
+	kind := "alert"
+	if entry.Resolve {
+		kind = "resolve"
+	}
+	// Names sort by the time the entries have been spooled at, which is the order they are
+	// delivered in, so that resolutions follow their alerts.
+	entry.path = filepath.Join(s.dir, fmt.Sprintf("%020d-%s-%s-%s.json", now.UnixNano(), entry.Alert.Fingerprint, entry.Notifier, kind))
+	return s.write(entry)
+}
+
+// Update stores the changed attempts of an entry.
+func (s *Spool) Update(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(entry.path); err != nil {
+		return err
+	}
+	return s.write(entry)
+}
+
+func (s *Spool) write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode entry: %v", err)
+	}
+	tmp := filepath.Join(s.dir, "."+entry.Name())
+	if err := ioutil.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("could not write entry: %v", err)
+	}
+	return os.Rename(tmp, entry.path)
+}
+
+// Contains reports whether an alert with the fingerprint is spooled for the named notifier.
+func (s *Spool) Contains(notifier, fingerprint string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, _ := s.names()
+	for _, name := range names {
+		if strings.HasSuffix(name, "-"+fingerprint+"-"+notifier+"-alert.json") {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the stored entries, oldest first. Unreadable entries are removed.
+func (s *Spool) Entries() ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		entry := &Entry{path: path}
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, entry)
+		}
+		if err != nil || entry.Alert == nil {
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Remove deletes a delivered entry.
+func (s *Spool) Remove(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Len returns the number of stored entries.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, _ := s.names()
+	return len(names)
+}
+
+func (s *Spool) names() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool directory: %v", err)
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") && !strings.HasPrefix(file.Name(), ".") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
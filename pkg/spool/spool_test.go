@@ -0,0 +1,125 @@
+package spool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+var testStart = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func TestEntriesAreOrderedBySpoolTime(t *testing.T) {
+	s, err := New(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries are added out of order, e.g. by the controllers of several clusters.
+	adds := []struct {
+		entry *Entry
+		at    time.Duration
+	}{
+		{&Entry{Notifier: "mattermost", Alert: &notify.Alert{Fingerprint: "b"}}, 2 * time.Second},
+		{&Entry{Notifier: "mattermost", Alert: &notify.Alert{Fingerprint: "a"}}, time.Second},
+		{&Entry{Notifier: "mattermost", Alert: &notify.Alert{Fingerprint: "a"}, Resolve: true}, 3 * time.Second},
+		{&Entry{Notifier: "webhook", Alert: &notify.Alert{Fingerprint: "a"}}, time.Second},
+	}
+	for _, add := range adds {
+		if err := s.Add(add.entry, testStart.Add(add.at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := s.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"01704110401000000000-a-mattermost-alert.json",
+		"01704110401000000000-a-webhook-alert.json",
+		"01704110402000000000-b-mattermost-alert.json",
+		"01704110403000000000-a-mattermost-resolve.json",
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Name() != want[i] {
+			t.Errorf("expected entry %d to be %s, got %s", i, want[i], entry.Name())
+		}
+	}
+	if !entries[0].SpooledAt.Equal(testStart.Add(time.Second)) {
+		t.Errorf("expected spool time to be stored, got %s", entries[0].SpooledAt)
+	}
+	if !s.Contains("mattermost", "b") || s.Contains("webhook", "b") {
+		t.Error("expected alert b to be spooled for mattermost only")
+	}
+}
+
+func TestFullSpool(t *testing.T) {
+	s, err := New(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &Entry{Notifier: "mattermost", Alert: &notify.Alert{Fingerprint: "a"}}
+	if err := s.Add(entry, testStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(&Entry{Notifier: "mattermost", Alert: &notify.Alert{Fingerprint: "b"}}, testStart); err == nil {
+		t.Error("expected full spool to reject entry")
+	}
+	if err := s.Remove(entry); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("expected empty spool, got %d entries", s.Len())
+	}
+	if err := s.Update(entry); err == nil {
+		t.Error("expected update of removed entry to fail")
+	}
+}
+
+func TestPartlyWrittenEntries(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &Entry{Notifier: "mattermost", Alert: &notify.Alert{Fingerprint: "a"}}
+	if err := s.Add(entry, testStart); err != nil {
+		t.Fatal(err)
+	}
+	entry.Attempts = 2
+	if err := s.Update(entry); err != nil {
+		t.Fatal(err)
+	}
+	// A crash while writing leaves a temporary file, a truncated entry could only be left behind
+	// by a file system without atomic renames.
+	tmp := filepath.Join(dir, ".01704110460000000000-b-mattermost-alert.json")
+	truncated := filepath.Join(dir, "01704110400000000000-c-mattermost-alert.json")
+	for path, data := range map[string]string{tmp: `{"notifier":"matt`, truncated: `{"notifier":"mattermost","alert":{"fing`} {
+		if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err = New(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("expected temporary file to be removed, got %v", err)
+	}
+	entries, err := s.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Alert.Fingerprint != "a" || entries[0].Attempts != 2 {
+		t.Fatalf("expected only the complete entry with its attempts, got %v", entries)
+	}
+	if _, err := os.Stat(truncated); !os.IsNotExist(err) {
+		t.Errorf("expected truncated entry to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package state
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// Store keeps the time of the last notification per key. Implementations must be safe for
+// concurrent use by multiple workers.
+type Store interface {
+	// Refresh records a notification for key unless the last one has been sent less than
+	// backoff ago, and reports whether the notification should be sent.
+	Refresh(key string, backoff time.Duration) bool
+	// Delete forgets a key.
+	Delete(key string)
+	// DeletePrefix forgets all keys starting with prefix and returns how many have been removed.
+	DeletePrefix(prefix string) int
+	// Snapshot returns a copy of all entries.
+	Snapshot() map[string]time.Time
+	// Restore adds the entries, e.g. after loading them from persistent storage.
+	Restore(entries map[string]time.Time)
+}
+
+// memoryStore is an in-memory Store evicting entries after a TTL and once it exceeds its size.
+type memoryStore struct {
+	ttl        time.Duration
+	maxEntries int
+	clock      clock.PassiveClock
+
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+// NewMemoryStore creates an in-memory store. Entries expire after ttl, which should be longer than
+// any backoff. If the store holds more than maxEntries, the oldest entries are evicted.
+func NewMemoryStore(ttl time.Duration, maxEntries int) Store {
+	return NewMemoryStoreWithClock(ttl, maxEntries, clock.RealClock{})
+}
+
+// NewMemoryStoreWithClock creates an in-memory store like NewMemoryStore, taking the time from clock.
+func NewMemoryStoreWithClock(ttl time.Duration, maxEntries int, clock clock.PassiveClock) Store {
+	return &memoryStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		clock:      clock,
+		entries:    make(map[string]time.Time),
+	}
+}
+
+func (s *memoryStore) Refresh(key string, backoff time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if last, ok := s.entries[key]; ok && now.Sub(last) < backoff && now.Sub(last) < s.ttl {
+		return false
+	}
+	s.entries[key] = now
+	s.evict(now)
+	return true
+}
+
+// evict removes expired entries and then the oldest entries until the size bound holds.
+func (s *memoryStore) evict(now time.Time) {
+	if len(s.entries) <= s.maxEntries {
+		return
+	}
+	for key, last := range s.entries {
+		if now.Sub(last) >= s.ttl {
+			delete(s.entries, key)
+		}
+	}
+	for len(s.entries) > s.maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for key, last := range s.entries {
+			if oldestKey == "" || last.Before(oldest) {
+				oldestKey, oldest = key, last
+			}
+		}
+		delete(s.entries, oldestKey)
+	}
+}
+
+func (s *memoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func (s *memoryStore) DeletePrefix(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for key := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *memoryStore) Snapshot() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	entries := make(map[string]time.Time, len(s.entries))
+	for key, last := range s.entries {
+		if now.Sub(last) < s.ttl {
+			entries[key] = last
+		}
+	}
+	return entries
+}
+
+func (s *memoryStore) Restore(entries map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, last := range entries {
+		s.entries[key] = last
+	}
+	s.evict(s.clock.Now())
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestRefresh(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemoryStoreWithClock(time.Hour, 10, clock)
+	tests := []struct {
+		name    string
+		step    time.Duration
+		backoff time.Duration
+		want    bool
+	}{
+		{"first notification", 0, 10 * time.Minute, true},
+		{"within backoff", 5 * time.Minute, 10 * time.Minute, false},
+		{"after backoff", 10 * time.Minute, 10 * time.Minute, true},
+		{"backoff longer than ttl", time.Hour, 2 * time.Hour, true},
+	}
+	for _, test := range tests {
+		clock.Step(test.step)
+		if got := store.Refresh("key", test.backoff); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestEvictionByTTL(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testingclock.NewFakeClock(start)
+	store := NewMemoryStoreWithClock(time.Hour, 2, clock)
+	store.Refresh("expired", time.Minute)
+	clock.Step(30 * time.Minute)
+	store.Refresh("fresh", time.Minute)
+	clock.Step(30 * time.Minute)
+	store.Refresh("new", time.Minute)
+
+	snapshot := store.Snapshot()
+	if _, ok := snapshot["expired"]; ok {
+		t.Error("expected expired entry to be evicted")
+	}
+	if len(snapshot) != 2 {
+		t.Errorf("expected fresh and new entries, got %v", snapshot)
+	}
+	// Expired entries are not returned even before the size bound forces their removal.
+	clock.Step(30 * time.Minute)
+	snapshot = store.Snapshot()
+	if _, ok := snapshot["fresh"]; ok || len(snapshot) != 1 {
+		t.Errorf("expected only the new entry, got %v", snapshot)
+	}
+}
+
+func TestEvictionBySize(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemoryStoreWithClock(24*time.Hour, 3, clock)
+	for i := 0; i < 5; i++ {
+		store.Refresh(fmt.Sprintf("key-%d", i), time.Minute)
+		clock.Step(time.Minute)
+	}
+	snapshot := store.Snapshot()
+	if len(snapshot) != 3 {
+		t.Fatalf("expected 3 entries, got %v", snapshot)
+	}
+	for _, key := range []string{"key-2", "key-3", "key-4"} {
+		if _, ok := snapshot[key]; !ok {
+			t.Errorf("expected newest entry %s to be kept, got %v", key, snapshot)
+		}
+	}
+
+	now := clock.Now()
+	store.Restore(map[string]time.Time{"old": now.Add(-time.Hour), "restored": now})
+	snapshot = store.Snapshot()
+	if _, ok := snapshot["old"]; ok || len(snapshot) != 3 {
+		t.Errorf("expected restore to evict the oldest entries, got %v", snapshot)
+	}
+	if _, ok := snapshot["restored"]; !ok {
+		t.Errorf("expected restored entry to be kept, got %v", snapshot)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	store := NewMemoryStore(time.Hour, 10)
+	store.Refresh("default/a", time.Minute)
+	store.Refresh("default/b", time.Minute)
+	store.Refresh("kube-system/a", time.Minute)
+	if removed := store.DeletePrefix("default/"); removed != 2 {
+		t.Errorf("expected 2 removed entries, got %d", removed)
+	}
+	store.Delete("kube-system/a")
+	if snapshot := store.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("expected empty store, got %v", snapshot)
+	}
+}
+
+// TestConcurrentAccess is meant to be run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemoryStoreWithClock(time.Hour, 50, clock)
+	var sent sync.Map
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("key-%d", i%20)
+				if store.Refresh(key, time.Hour) {
+					if _, loaded := sent.LoadOrStore(key, worker); loaded {
+						t.Errorf("notification for %s sent twice", key)
+					}
+				}
+				switch i % 50 {
+				case 10:
+					store.Snapshot()
+				case 20:
+					store.Restore(map[string]time.Time{fmt.Sprintf("restored-%d", worker): clock.Now()})
+				case 30:
+					store.DeletePrefix("restored-")
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	if snapshot := store.Snapshot(); len(snapshot) > 50 {
+		t.Errorf("expected at most 50 entries, got %d", len(snapshot))
+	}
+}
@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the name of the gRPC service streaming alert events. Its single method Subscribe
+// takes a SubscribeRequest and streams Events, as defined in stream.proto.
+const ServiceName = "informer.v1.AlertStream"
+
+// TokenMetadata is the metadata key carrying the API token.
+const TokenMetadata = "x-informer-token"
+
+// alertStreamServer is implemented by the hub to serve subscriptions.
+type alertStreamServer interface {
+	serve(req *SubscribeRequest, stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*alertStreamServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Subscribe",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			req := &SubscribeRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return srv.(alertStreamServer).serve(req, stream)
+		},
+	}},
+}
+
+func (h *Hub) serve(req *SubscribeRequest, stream grpc.ServerStream) error {
+	events, unsubscribe := h.subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if !req.Matches(event) {
+				continue
+			}
+			if err := stream.SendMsg(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ServerTLS returns the credentials of a server using the certificate and key in the given files.
+// They are read on every handshake, so that renewed certificates are used without a restart.
+func ServerTLS(certFile, keyFile string) (credentials.TransportCredentials, error) {
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return nil, fmt.Errorf("could not load TLS certificate: %v", err)
+	}
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			return &cert, err
+		},
+	}), nil
+}
+
+// Serve serves the events of the hub via gRPC on addr until the returned server is stopped.
+// Subscribers must present the token returned by token, which disables the service if empty.
+// The token is sent in plain text unless creds are given.
+func Serve(addr string, hub *Hub, token func() string, creds credentials.TransportCredentials) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %v", addr, err)
+	}
+	opts := []grpc.ServerOption{grpc.ForceServerCodec(codec{})}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(append(opts,
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			expected := token()
+			if values := md.Get(TokenMetadata); expected == "" || len(values) != 1 ||
+				subtle.ConstantTimeCompare([]byte(values[0]), []byte(expected)) != 1 {
+				return status.Error(codes.Unauthenticated, "invalid token")
+			}
+			return handler(srv, stream)
+		}),
+	)...)
+	server.RegisterService(&serviceDesc, hub)
+	go server.Serve(listener)
+	return server, nil
+}
+
+// Subscription receives the events of a subscription.
+type Subscription struct {
+	stream grpc.ClientStream
+}
+
+// Subscribe subscribes to the events matching the request using the given connection and token.
+func Subscribe(ctx context.Context, conn *grpc.ClientConn, token string, req *SubscribeRequest) (*Subscription, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, TokenMetadata, token)
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/Subscribe", grpc.ForceCodec(codec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &Subscription{stream: stream}, nil
+}
+
+// Recv blocks until the next event is received.
+func (s *Subscription) Recv() (*Event, error) {
+	event := &Event{}
+	if err := s.stream.RecvMsg(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,304 @@
+package stream
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// codec encodes the messages as protocol buffers as defined in stream.proto, so that clients in
+// any language can use code generated from it.
+type codec struct{}
+
+// message is implemented by the messages of the stream.
+type message interface {
+	marshal(b []byte) []byte
+	unmarshal(b []byte) error
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("cannot encode %T", v)
+	}
+	return m.marshal(nil), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("cannot decode %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string { return "proto" }
+
+func (r *SubscribeRequest) marshal(b []byte) []byte {
+	for _, namespace := range r.Namespaces {
+		b = appendString(b, 1, namespace, true)
+	}
+	for _, t := range r.Types {
+		b = appendString(b, 2, string(t), true)
+	}
+	return b
+}
+
+func (r *SubscribeRequest) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, _ uint64, data []byte) error {
+		switch num {
+		case 1:
+			r.Namespaces = append(r.Namespaces, string(data))
+		case 2:
+			r.Types = append(r.Types, EventType(data))
+		}
+		return nil
+	})
+}
+
+func (e *Event) marshal(b []byte) []byte {
+	b = appendString(b, 1, string(e.Type), false)
+	b = appendTimestamp(b, 2, e.Time)
+	b = appendString(b, 3, e.Notifier, false)
+	b = appendString(b, 4, e.Error, false)
+	if e.Alert != nil {
+		b = appendMessage(b, 5, marshalAlert(nil, e.Alert))
+	}
+	return b
+}
+
+func (e *Event) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, _ uint64, data []byte) (err error) {
+		switch num {
+		case 1:
+			e.Type = EventType(data)
+		case 2:
+			e.Time, err = unmarshalTimestamp(data)
+		case 3:
+			e.Notifier = string(data)
+		case 4:
+			e.Error = string(data)
+		case 5:
+			e.Alert = &notify.Alert{}
+			err = unmarshalAlert(data, e.Alert)
+		}
+		return err
+	})
+}
+
+func marshalAlert(b []byte, a *notify.Alert) []byte {
+	b = appendTimestamp(b, 1, a.Time)
+	b = appendString(b, 2, a.Fingerprint, false)
+	b = appendString(b, 3, a.Namespace, false)
+	b = appendString(b, 4, a.Pod, false)
+	b = appendString(b, 5, a.Container, false)
+	b = appendString(b, 6, a.Cluster, false)
+	b = appendString(b, 7, a.Environment, false)
+	b = appendString(b, 8, a.Workload, false)
+	b = appendString(b, 9, a.Reason, false)
+	b = appendString(b, 10, a.TerminationReason, false)
+	b = appendString(b, 11, a.Severity, false)
+	b = appendVarint(b, 12, uint64(a.RestartCount))
+	b = appendString(b, 13, a.Title, false)
+	b = appendString(b, 14, a.Text, false)
+	b = appendString(b, 15, a.Logs, false)
+	b = appendString(b, 16, a.IncidentURL, false)
+	if r := a.Resources; r != nil {
+		resources := appendMessage(nil, 1, marshalUsage(&r.CPU))
+		resources = appendMessage(resources, 2, marshalUsage(&r.Memory))
+		b = appendMessage(b, 17, resources)
+	}
+	for _, link := range a.Links {
+		b = appendMessage(b, 18, appendString(appendString(nil, 1, link.Title, false), 2, link.URL, false))
+	}
+	b = appendString(b, 19, a.Channel, false)
+	for _, mention := range a.Mentions {
+		b = appendString(b, 20, mention, true)
+	}
+	for _, user := range a.DirectMessages {
+		b = appendString(b, 21, user, true)
+	}
+	b = appendString(b, 22, a.Priority, false)
+	b = appendString(b, 23, a.Emoji, false)
+	b = appendString(b, 24, a.IconURL, false)
+	b = appendString(b, 25, a.Color, false)
+	if a.RecoveredAfter != 0 {
+		d := a.RecoveredAfter
+		b = appendMessage(b, 26, appendVarint(appendVarint(nil, 1, uint64(d/time.Second)), 2, uint64(d%time.Second)))
+	}
+	b = appendString(b, 27, a.Locale, false)
+	if a.Synthetic {
+		b = appendVarint(b, 28, 1)
+	}
+	return b
+}
+
+func marshalUsage(u *notify.ResourceUsage) []byte {
+	b := appendString(nil, 1, u.Request, false)
+	b = appendString(b, 2, u.Limit, false)
+	b = appendString(b, 3, u.Usage, false)
+	return appendVarint(b, 4, uint64(u.UsageOfLimit))
+}
+
+func unmarshalAlert(b []byte, a *notify.Alert) error {
+	fields := map[protowire.Number]*string{
+		2: &a.Fingerprint, 3: &a.Namespace, 4: &a.Pod, 5: &a.Container, 6: &a.Cluster, 7: &a.Environment,
+		8: &a.Workload, 9: &a.Reason, 10: &a.TerminationReason, 11: &a.Severity, 13: &a.Title, 14: &a.Text,
+		15: &a.Logs, 16: &a.IncidentURL, 19: &a.Channel, 22: &a.Priority, 23: &a.Emoji, 24: &a.IconURL,
+		25: &a.Color, 27: &a.Locale,
+	}
+	return decode(b, func(num protowire.Number, varint uint64, data []byte) (err error) {
+		if s, ok := fields[num]; ok {
+			*s = string(data)
+			return nil
+		}
+		switch num {
+		case 1:
+			a.Time, err = unmarshalTimestamp(data)
+		case 12:
+			a.RestartCount = int32(varint)
+		case 17:
+			a.Resources = &notify.Resources{}
+			err = decode(data, func(num protowire.Number, _ uint64, data []byte) error {
+				switch num {
+				case 1:
+					return unmarshalUsage(data, &a.Resources.CPU)
+				case 2:
+					return unmarshalUsage(data, &a.Resources.Memory)
+				}
+				return nil
+			})
+		case 18:
+			var link notify.Link
+			err = decode(data, func(num protowire.Number, _ uint64, data []byte) error {
+				switch num {
+				case 1:
+					link.Title = string(data)
+				case 2:
+					link.URL = string(data)
+				}
+				return nil
+			})
+			a.Links = append(a.Links, link)
+		case 20:
+			a.Mentions = append(a.Mentions, string(data))
+		case 21:
+			a.DirectMessages = append(a.DirectMessages, string(data))
+		case 26:
+			var seconds, nanos uint64
+			err = decode(data, func(num protowire.Number, varint uint64, _ []byte) error {
+				switch num {
+				case 1:
+					seconds = varint
+				case 2:
+					nanos = varint
+				}
+				return nil
+			})
+			a.RecoveredAfter = time.Duration(int64(seconds))*time.Second + time.Duration(int32(nanos))
+		case 28:
+			a.Synthetic = varint != 0
+		}
+		return err
+	})
+}
+
+func unmarshalUsage(b []byte, u *notify.ResourceUsage) error {
+	return decode(b, func(num protowire.Number, varint uint64, data []byte) error {
+		switch num {
+		case 1:
+			u.Request = string(data)
+		case 2:
+			u.Limit = string(data)
+		case 3:
+			u.Usage = string(data)
+		case 4:
+			u.UsageOfLimit = int(int32(varint))
+		}
+		return nil
+	})
+}
+
+// appendString appends the string as field num, empty strings are omitted unless repeated.
+func appendString(b []byte, num protowire.Number, s string, repeated bool) []byte {
+	if s == "" && !repeated {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendVarint appends the value as field num, zero is omitted. Negative integers are passed
+// sign-extended to 64 bits, as protocol buffers encode int32 and int64 alike.
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// appendTimestamp appends the time as google.protobuf.Timestamp, the zero time is omitted.
+func appendTimestamp(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	return appendMessage(b, num, appendVarint(appendVarint(nil, 1, uint64(t.Unix())), 2, uint64(t.Nanosecond())))
+}
+
+func unmarshalTimestamp(b []byte) (time.Time, error) {
+	var seconds, nanos uint64
+	err := decode(b, func(num protowire.Number, varint uint64, _ []byte) error {
+		switch num {
+		case 1:
+			seconds = varint
+		case 2:
+			nanos = varint
+		}
+		return nil
+	})
+	return time.Unix(int64(seconds), int64(int32(nanos))), err
+}
+
+// decode calls field with the number and value of each varint and length-delimited field of the
+// message, other fields are skipped. The value of varint fields is passed as varint, the content of
+// length-delimited fields as data.
+func decode(b []byte, field func(num protowire.Number, varint uint64, data []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var (
+			varint uint64
+			data   []byte
+		)
+		switch typ {
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := field(num, varint, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package stream
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var fieldPattern = regexp.MustCompile(`^(repeated )?([\w.]+) (\w+) = (\d+);$`)
+
+// loadSchema reads the messages of stream.proto, so that the encoding is checked against the field
+// numbers and types defined there. It understands the subset of the language used by the file and
+// fails on anything else in a message.
+func loadSchema(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	data, err := ioutil.ReadFile("stream.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("stream.proto"),
+		Package:    proto.String("informer.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{durationpb.File_google_protobuf_duration_proto.Path(), timestamppb.File_google_protobuf_timestamp_proto.Path()},
+	}
+	var message *descriptorpb.DescriptorProto
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "message "):
+			message = &descriptorpb.DescriptorProto{Name: proto.String(strings.Fields(line)[1])}
+			file.MessageType = append(file.MessageType, message)
+		case line == "}":
+			message = nil
+		case message == nil || line == "" || strings.HasPrefix(line, "//"):
+		default:
+			match := fieldPattern.FindStringSubmatch(line)
+			if match == nil {
+				t.Fatalf("unsupported line in message %s: %q", message.GetName(), line)
+			}
+			number, _ := strconv.Atoi(match[4])
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:   proto.String(match[3]),
+				Number: proto.Int32(int32(number)),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			if match[1] != "" {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			switch match[2] {
+			case "string":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+			case "int32":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()
+			case "bool":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum()
+			default:
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				if strings.Contains(match[2], ".") {
+					field.TypeName = proto.String("." + match[2])
+				} else {
+					field.TypeName = proto.String(".informer.v1." + match[2])
+				}
+			}
+			message.Field = append(message.Field, field)
+		}
+	}
+	schema, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	return schema
+}
+
+// setFields sets the fields of the message by their names in stream.proto.
+func setFields(t *testing.T, m protoreflect.Message, values map[string]interface{}) {
+	t.Helper()
+	for name, value := range values {
+		field := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			t.Fatalf("message %s has no field %s", m.Descriptor().Name(), name)
+		}
+		switch value := value.(type) {
+		case time.Time:
+			m.Set(field, protoreflect.ValueOfMessage(timestamppb.New(value).ProtoReflect()))
+		case time.Duration:
+			m.Set(field, protoreflect.ValueOfMessage(durationpb.New(value).ProtoReflect()))
+		case []string:
+			list := m.Mutable(field).List()
+			for _, s := range value {
+				list.Append(protoreflect.ValueOfString(s))
+			}
+		case map[string]interface{}:
+			setFields(t, m.Mutable(field).Message(), value)
+		case []map[string]interface{}:
+			list := m.Mutable(field).List()
+			for _, item := range value {
+				element := list.NewElement()
+				setFields(t, element.Message(), item)
+				list.Append(element)
+			}
+		default:
+			m.Set(field, protoreflect.ValueOf(value))
+		}
+	}
+}
+
+// requireAllFields fails unless every field of the messages defined in stream.proto is set, so that
+// fields added to it are covered by the test.
+func requireAllFields(t *testing.T, m protoreflect.Message) {
+	t.Helper()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !m.Has(field) {
+			t.Errorf("field %s is not covered by the test", field.FullName())
+			continue
+		}
+		if field.Message() == nil || field.Message().ParentFile().Path() != "stream.proto" {
+			continue
+		}
+		if field.IsList() {
+			for j := 0; j < m.Get(field).List().Len(); j++ {
+				requireAllFields(t, m.Get(field).List().Get(j).Message())
+			}
+		} else {
+			requireAllFields(t, m.Get(field).Message())
+		}
+	}
+}
+
+// checkEncoding compares the hand-written encoding of v with the encoding of the protobuf runtime
+// of want in both directions.
+func checkEncoding(t *testing.T, v message, decoded message, want protoreflect.Message) {
+	t.Helper()
+	data, err := codec{}.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dynamicpb.NewMessage(want.Descriptor())
+	if err := proto.Unmarshal(data, got); err != nil {
+		t.Fatalf("could not decode hand-written encoding: %v", err)
+	}
+	if !proto.Equal(got, want.Interface()) {
+		t.Errorf("hand-written encoding decodes to\n%s\nwant\n%s", prototext.Format(got), prototext.Format(want.Interface()))
+	}
+
+	data, err = proto.Marshal(want.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (codec{}).Unmarshal(data, decoded); err != nil {
+		t.Fatalf("could not decode encoding of the protobuf runtime: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Errorf("encoding of the protobuf runtime decodes to %+v, want %+v", decoded, v)
+	}
+}
+
+func TestEventEncoding(t *testing.T) {
+	schema := loadSchema(t)
+	raised := time.Unix(1704110400, 500)
+	alert := &notify.Alert{
+		Time:              raised,
+		Fingerprint:       "3f2c1a0",
+		Namespace:         "payments",
+		Pod:               "api-7d9f",
+		Container:         "api",
+		Cluster:           "prod-eu",
+		Environment:       "production",
+		Workload:          "Deployment/api",
+		Reason:            "CrashLoopBackOff",
+		TerminationReason: "OOMKilled",
+		Severity:          "critical",
+		RestartCount:      7,
+		Title:             "api is crashing",
+		Text:              "Container api restarted 7 times.",
+		Logs:              "panic: out of memory",
+		IncidentURL:       "https://incidents.example.com/1",
+		Resources: &notify.Resources{
+			CPU:    notify.ResourceUsage{Request: "250m", Limit: "1", Usage: "900m", UsageOfLimit: 90},
+			Memory: notify.ResourceUsage{Request: "256Mi", Limit: "512Mi", Usage: "511Mi", UsageOfLimit: 99},
+		},
+		Links:          []notify.Link{{Title: "Logs", URL: "https://logs.example.com"}, {Title: "Runbook", URL: "https://runbooks.example.com"}},
+		Channel:        "payments-alerts",
+		Mentions:       []string{"@alice", "@payments"},
+		DirectMessages: []string{"alice"},
+		Priority:       "urgent",
+		Emoji:          ":fire:",
+		IconURL:        "https://example.com/icon.png",
+		Color:          "#ff0000",
+		RecoveredAfter: 90*time.Second + 250*time.Millisecond,
+		Locale:         "de",
+		Synthetic:      true,
+	}
+	event := &Event{Type: EventFailed, Time: raised.Add(time.Second), Notifier: "webhook", Error: "unavailable", Alert: alert}
+	usage := func(u notify.ResourceUsage) map[string]interface{} {
+		return map[string]interface{}{"request": u.Request, "limit": u.Limit, "usage": u.Usage, "usage_of_limit": int32(u.UsageOfLimit)}
+	}
+	want := dynamicpb.NewMessage(schema.Messages().ByName("Event"))
+	setFields(t, want, map[string]interface{}{
+		"type":     string(event.Type),
+		"time":     event.Time,
+		"notifier": event.Notifier,
+		"error":    event.Error,
+		"alert": map[string]interface{}{
+			"time":               alert.Time,
+			"fingerprint":        alert.Fingerprint,
+			"namespace":          alert.Namespace,
+			"pod":                alert.Pod,
+			"container":          alert.Container,
+			"cluster":            alert.Cluster,
+			"environment":        alert.Environment,
+			"workload":           alert.Workload,
+			"reason":             alert.Reason,
+			"termination_reason": alert.TerminationReason,
+			"severity":           alert.Severity,
+			"restart_count":      alert.RestartCount,
+			"title":              alert.Title,
+			"text":               alert.Text,
+			"logs":               alert.Logs,
+			"incident_url":       alert.IncidentURL,
+			"resources":          map[string]interface{}{"cpu": usage(alert.Resources.CPU), "memory": usage(alert.Resources.Memory)},
+			"links": []map[string]interface{}{
+				{"title": alert.Links[0].Title, "url": alert.Links[0].URL},
+				{"title": alert.Links[1].Title, "url": alert.Links[1].URL},
+			},
+			"channel":         alert.Channel,
+			"mentions":        alert.Mentions,
+			"direct_messages": alert.DirectMessages,
+			"priority":        alert.Priority,
+			"emoji":           alert.Emoji,
+			"icon_url":        alert.IconURL,
+			"color":           alert.Color,
+			"recovered_after": alert.RecoveredAfter,
+			"locale":          alert.Locale,
+			"synthetic":       alert.Synthetic,
+		},
+	})
+	requireAllFields(t, want)
+	checkEncoding(t, event, &Event{}, want)
+
+	// Unset fields are omitted like by the protobuf runtime.
+	checkEncoding(t, &Event{}, &Event{}, dynamicpb.NewMessage(schema.Messages().ByName("Event")))
+}
+
+func TestSubscribeRequestEncoding(t *testing.T) {
+	schema := loadSchema(t)
+	request := &SubscribeRequest{Namespaces: []string{"payments", ""}, Types: []EventType{EventDetected, EventResolved}}
+	want := dynamicpb.NewMessage(schema.Messages().ByName("SubscribeRequest"))
+	setFields(t, want, map[string]interface{}{
+		"namespaces": request.Namespaces,
+		"types":      []string{string(EventDetected), string(EventResolved)},
+	})
+	requireAllFields(t, want)
+	checkEncoding(t, request, &SubscribeRequest{}, want)
+}
@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+	"k8s.io/klog/v2"
+)
+
+// EventType is the stage of an alert an event is published for.
+type EventType string
+
+const (
+	// EventDetected is published once an alert has been detected, before it is sent.
+	EventDetected EventType = "detected"
+	// EventNotified and EventFailed are published per notifier once the alert has been delivered
+	// or delivering it failed.
+	EventNotified EventType = "notified"
+	EventFailed   EventType = "failed"
+	// EventResolved is published once the alert has been resolved.
+	EventResolved EventType = "resolved"
+)
+
+// Event is published for every stage of an alert.
+type Event struct {
+	Type     EventType     `json:"type"`
+	Time     time.Time     `json:"time"`
+	Notifier string        `json:"notifier,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Alert    *notify.Alert `json:"alert"`
+}
+
+// SubscribeRequest filters the events of a subscription. Empty lists match everything.
+type SubscribeRequest struct {
+	Namespaces []string    `json:"namespaces,omitempty"`
+	Types      []EventType `json:"types,omitempty"`
+}
+
+// Matches checks if the event passes the filters of the request.
+func (r *SubscribeRequest) Matches(event *Event) bool {
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, event.Alert.Namespace) {
+		return false
+	}
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, t := range r.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriberBuffer is the number of events buffered per subscriber before events are dropped.
+const subscriberBuffer = 100
+
+// Hub broadcasts events to all subscribers. Slow subscribers miss events instead of blocking
+// the controller.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan *Event]struct{}
+}
+
+// NewHub creates a hub without subscribers.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[chan *Event]struct{})}
+}
+
+// Publish sends the event to all subscribers.
+func (h *Hub) Publish(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subscriber := range h.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			klog.InfoS("Dropping event for slow subscriber", "type", event.Type, "pod", klog.KRef(event.Alert.Namespace, event.Alert.Pod))
+		}
+	}
+}
+
+// subscribe returns a channel receiving the published events until unsubscribe is called.
+func (h *Hub) subscribe() (events <-chan *Event, unsubscribe func()) {
+	ch := make(chan *Event, subscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
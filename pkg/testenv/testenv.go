@@ -0,0 +1,164 @@
+//go:build envtest
+
+// Package testenv is only built with the envtest build tag, so that programs importing the
+// informer do not depend on envtest.
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/config"
+	"github.com/lnsp/mattermost-informer/pkg/controller"
+	"github.com/lnsp/mattermost-informer/pkg/mattermostfake"
+	"github.com/lnsp/mattermost-informer/pkg/utils"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// Container is the name of the container of the pods created by the environment.
+const Container = "app"
+
+// Environment runs the controller against a real API server and etcd started by envtest, posting
+// to a fake Mattermost server. Pods never run since there is no kubelet, their status is set by
+// the environment instead. The binaries of the API server and etcd are located using the
+// KUBEBUILDER_ASSETS environment variable, e.g. as set up by setup-envtest.
+type Environment struct {
+	Clientset  kubernetes.Interface
+	Mattermost *mattermostfake.Server
+	Controller *controller.Controller
+
+	env    *envtest.Environment
+	stop   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start starts the API server and the controller with the given configuration, watching the given
+// namespaces, which are created. The Mattermost settings of the configuration are replaced by
+// the fake server, which has the channels alerts and the channels of the routes.
+func Start(configYAML string, namespaces ...string) (*Environment, error) {
+	cfg, err := config.Parse([]byte(configYAML))
+	if err != nil {
+		return nil, err
+	}
+	channels := []string{"alerts"}
+	for _, route := range cfg.Routes {
+		if route.Channel != "" {
+			channels = append(channels, route.Channel)
+		}
+	}
+	e := &Environment{
+		env:        &envtest.Environment{},
+		Mattermost: mattermostfake.NewServer("informer-token", "informer", channels...),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	restConfig, err := e.env.Start()
+	if err != nil {
+		e.Mattermost.Close()
+		return nil, fmt.Errorf("could not start API server: %v", err)
+	}
+	if err := e.start(cfg, restConfig, namespaces); err != nil {
+		e.Mattermost.Close()
+		e.env.Stop()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Environment) start(cfg *config.Config, restConfig *rest.Config, namespaces []string) error {
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	e.Clientset = clientset
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, namespace := range namespaces {
+		ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		if _, err := clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("could not create namespace %s: %v", namespace, err)
+		}
+	}
+	cfg.Mattermost = e.Mattermost.Config()
+	cfg.Mattermost.Channel = "alerts"
+	mattermost, err := utils.NewMattermostClient(cfg.Mattermost)
+	if err != nil {
+		return err
+	}
+	opts := controller.DefaultOptions()
+	opts.SendRetries = 0
+	if e.Controller, err = controller.NewForClientset(opts, cfg, clientset, mattermost, namespaces); err != nil {
+		return err
+	}
+	var workCtx context.Context
+	workCtx, e.cancel = context.WithCancel(context.Background())
+	go func() {
+		defer close(e.done)
+		e.Controller.Run(workCtx, 1, e.stop)
+	}()
+	return nil
+}
+
+// Stop stops the controller, the API server and the fake Mattermost server.
+func (e *Environment) Stop() error {
+	close(e.stop)
+	<-e.done
+	e.cancel()
+	e.Mattermost.Close()
+	return e.env.Stop()
+}
+
+// CreatePod creates a pod with a single container and the given annotations, e.g. to enable
+// notifications with espe.tech/mattermost=inform.
+func (e *Environment) CreatePod(ctx context.Context, namespace, name string, annotations map[string]string) (*v1.Pod, error) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: Container, Image: "busybox"}},
+		},
+	}
+	return e.Clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// CrashLoop sets the status of the pod as if its container kept crashing with the given reason,
+// e.g. Error or OOMKilled.
+func (e *Environment) CrashLoop(ctx context.Context, pod *v1.Pod, restarts int32, reason string) (*v1.Pod, error) {
+	now := metav1.Now()
+	return e.setStatus(ctx, pod, v1.ContainerStatus{
+		Name:         Container,
+		Image:        "busybox",
+		RestartCount: restarts,
+		State: v1.ContainerState{
+			Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off restarting failed container"},
+		},
+		LastTerminationState: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Reason: reason, StartedAt: now, FinishedAt: now},
+		},
+	})
+}
+
+// Recover sets the status of the pod as if its container is running and ready again.
+func (e *Environment) Recover(ctx context.Context, pod *v1.Pod) (*v1.Pod, error) {
+	return e.setStatus(ctx, pod, v1.ContainerStatus{
+		Name:  Container,
+		Image: "busybox",
+		Ready: true,
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.Now()}},
+	})
+}
+
+func (e *Environment) setStatus(ctx context.Context, pod *v1.Pod, status v1.ContainerStatus) (*v1.Pod, error) {
+	current, err := e.Clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	current.Status.Phase = v1.PodRunning
+	current.Status.ContainerStatuses = []v1.ContainerStatus{status}
+	return e.Clientset.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{})
+}
@@ -0,0 +1,123 @@
+//go:build envtest
+
+package testenv
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/mattermostfake"
+)
+
+const postTimeout = 30 * time.Second
+
+func start(t *testing.T, configYAML string, namespaces ...string) *Environment {
+	t.Helper()
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, install the binaries with setup-envtest")
+	}
+	env, err := Start(configYAML, namespaces...)
+	if err != nil {
+		t.Fatalf("starting environment failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("stopping environment failed: %v", err)
+		}
+	})
+	return env
+}
+
+// crashLoop creates a pod and lets its container crash.
+func crashLoop(t *testing.T, env *Environment, namespace, name string, annotations map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	pod, err := env.CreatePod(ctx, namespace, name, annotations)
+	if err != nil {
+		t.Fatalf("creating pod failed: %v", err)
+	}
+	if _, err := env.CrashLoop(ctx, pod, 3, "Error"); err != nil {
+		t.Fatalf("updating pod status failed: %v", err)
+	}
+}
+
+func waitForPosts(t *testing.T, env *Environment, n int) []mattermostfake.Post {
+	t.Helper()
+	posts, err := env.Mattermost.WaitForPosts(n, postTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return posts
+}
+
+func attachmentText(post mattermostfake.Post) string {
+	if len(post.Attachments) == 0 {
+		return ""
+	}
+	return post.Attachments[0].Title + "\n" + post.Attachments[0].Text
+}
+
+func TestCrashLoopIsNotified(t *testing.T) {
+	env := start(t, "", "apps")
+	crashLoop(t, env, "apps", "web", map[string]string{"espe.tech/mattermost": "inform"})
+
+	posts := waitForPosts(t, env, 1)
+	if posts[0].Channel != "alerts" {
+		t.Errorf("alert was posted to channel %q, want alerts", posts[0].Channel)
+	}
+	if text := attachmentText(posts[0]); !strings.Contains(text, "Crash loop detected!") || !strings.Contains(text, "pod web") {
+		t.Errorf("alert does not describe the crash of pod web: %q", text)
+	}
+}
+
+func TestPodsWithoutAnnotationAreIgnored(t *testing.T) {
+	env := start(t, "", "apps")
+	crashLoop(t, env, "apps", "ignored", nil)
+	// Pods are processed in order, so the ignored pod would be posted before the annotated one.
+	crashLoop(t, env, "apps", "web", map[string]string{"espe.tech/mattermost": "inform"})
+
+	posts := waitForPosts(t, env, 1)
+	for _, post := range posts {
+		if strings.Contains(attachmentText(post), "pod ignored") {
+			t.Errorf("pod without annotation was notified: %q", attachmentText(post))
+		}
+	}
+}
+
+func TestAlertsAreRoutedByNamespace(t *testing.T) {
+	env := start(t, `
+routes:
+- namespaces: [payments]
+  channel: payments-alerts
+`, "apps", "payments")
+	crashLoop(t, env, "payments", "api", map[string]string{"espe.tech/mattermost": "inform"})
+
+	posts := waitForPosts(t, env, 1)
+	if posts[0].Channel != "payments-alerts" {
+		t.Errorf("alert was posted to channel %q, want payments-alerts", posts[0].Channel)
+	}
+}
+
+func TestRecoveryIsResolved(t *testing.T) {
+	env := start(t, "sendResolved: true\n", "apps")
+	ctx := context.Background()
+	pod, err := env.CreatePod(ctx, "apps", "web", map[string]string{"espe.tech/mattermost": "inform"})
+	if err != nil {
+		t.Fatalf("creating pod failed: %v", err)
+	}
+	if pod, err = env.CrashLoop(ctx, pod, 3, "Error"); err != nil {
+		t.Fatalf("updating pod status failed: %v", err)
+	}
+	waitForPosts(t, env, 1)
+	if _, err := env.Recover(ctx, pod); err != nil {
+		t.Fatalf("updating pod status failed: %v", err)
+	}
+
+	posts := waitForPosts(t, env, 2)
+	if text := attachmentText(posts[1]); !strings.HasPrefix(text, "Resolved: ") || !strings.Contains(text, "recovered") {
+		t.Errorf("recovery was not resolved: %q", text)
+	}
+}
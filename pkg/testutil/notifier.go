@@ -0,0 +1,124 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+// Notifier is a fake notifier recording the alerts it receives. It implements notify.Poster and
+// notify.Resolver, so it also receives resolved alerts. It is safe for concurrent use.
+type Notifier struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	sent     []*notify.Alert
+	resolved []*notify.Alert
+	err      error
+	failures int
+}
+
+// NewNotifier creates a notifier accepting all alerts.
+func NewNotifier() *Notifier {
+	n := &Notifier{}
+	n.cond = sync.NewCond(&n.mu)
+	return n
+}
+
+// RegisterNotifier registers the notifier as notifier type kind, so that it can be referenced by
+// the notifiers of the configuration. The configuration of the notifier is ignored.
+func RegisterNotifier(kind string, n notify.Notifier) {
+	notify.Register(kind, func([]byte) (notify.Notifier, error) {
+		return n, nil
+	})
+}
+
+func (n *Notifier) Send(ctx context.Context, alert *notify.Alert) error {
+	_, err := n.Post(ctx, alert)
+	return err
+}
+
+// Post records the alert and returns its index among the sent alerts as ID.
+func (n *Notifier) Post(ctx context.Context, alert *notify.Alert) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.fail(); err != nil {
+		return "", err
+	}
+	n.sent = append(n.sent, alert)
+	n.cond.Broadcast()
+	return fmt.Sprint(len(n.sent) - 1), nil
+}
+
+func (n *Notifier) Resolve(ctx context.Context, alert *notify.Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.fail(); err != nil {
+		return err
+	}
+	n.resolved = append(n.resolved, alert)
+	n.cond.Broadcast()
+	return nil
+}
+
+func (n *Notifier) fail() error {
+	if n.failures == 0 {
+		return nil
+	}
+	if n.failures > 0 {
+		n.failures--
+	}
+	return n.err
+}
+
+// Fail lets the next count deliveries fail with err, or all deliveries if count is negative.
+func (n *Notifier) Fail(count int, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures, n.err = count, err
+}
+
+// Sent returns the alerts sent so far.
+func (n *Notifier) Sent() []*notify.Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*notify.Alert(nil), n.sent...)
+}
+
+// Resolved returns the alerts resolved so far.
+func (n *Notifier) Resolved() []*notify.Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*notify.Alert(nil), n.resolved...)
+}
+
+// WaitForSent waits until at least count alerts have been sent and returns them. It fails after
+// the timeout.
+func (n *Notifier) WaitForSent(count int, timeout time.Duration) ([]*notify.Alert, error) {
+	timer := time.AfterFunc(timeout, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.cond.Broadcast()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for len(n.sent) < count {
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("received %d of %d alerts within %s", len(n.sent), count, timeout)
+		}
+		n.cond.Wait()
+	}
+	return append([]*notify.Alert(nil), n.sent...), nil
+}
+
+// Reset forgets the recorded alerts and failures.
+func (n *Notifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent, n.resolved = nil, nil
+	n.failures, n.err = 0, nil
+}
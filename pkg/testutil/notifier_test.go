@@ -0,0 +1,75 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/notify"
+)
+
+func TestNotifierRecordsAlerts(t *testing.T) {
+	n := NewNotifier()
+	ctx := context.Background()
+	alert := &notify.Alert{Namespace: "apps", Pod: "web", Container: "app"}
+
+	id, err := n.Post(ctx, alert)
+	if err != nil || id != "0" {
+		t.Fatalf("post returned %q, %v", id, err)
+	}
+	if err := n.Resolve(ctx, alert); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if sent := n.Sent(); len(sent) != 1 || sent[0] != alert {
+		t.Errorf("sent alerts are %v", sent)
+	}
+	if resolved := n.Resolved(); len(resolved) != 1 || resolved[0] != alert {
+		t.Errorf("resolved alerts are %v", resolved)
+	}
+	n.Reset()
+	if len(n.Sent()) != 0 || len(n.Resolved()) != 0 {
+		t.Error("reset kept recorded alerts")
+	}
+}
+
+func TestNotifierFail(t *testing.T) {
+	n := NewNotifier()
+	ctx := context.Background()
+	outage := errors.New("outage")
+
+	n.Fail(2, outage)
+	for i := 0; i < 2; i++ {
+		if err := n.Send(ctx, &notify.Alert{}); err != outage {
+			t.Errorf("delivery %d returned %v, want %v", i+1, err, outage)
+		}
+	}
+	if err := n.Send(ctx, &notify.Alert{}); err != nil {
+		t.Errorf("delivery after failures failed: %v", err)
+	}
+
+	n.Fail(-1, outage)
+	for i := 0; i < 3; i++ {
+		if err := n.Resolve(ctx, &notify.Alert{}); err != outage {
+			t.Errorf("resolve %d returned %v, want %v", i+1, err, outage)
+		}
+	}
+	if len(n.Sent()) != 1 || len(n.Resolved()) != 0 {
+		t.Errorf("failed deliveries were recorded: %d sent, %d resolved", len(n.Sent()), len(n.Resolved()))
+	}
+}
+
+func TestNotifierWaitForSent(t *testing.T) {
+	n := NewNotifier()
+	go n.Send(context.Background(), &notify.Alert{Pod: "web"})
+	sent, err := n.WaitForSent(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent[0].Pod != "web" {
+		t.Errorf("sent alerts are %v", sent)
+	}
+	if _, err := n.WaitForSent(2, 10*time.Millisecond); err == nil {
+		t.Error("waiting for missing alert did not fail")
+	}
+}
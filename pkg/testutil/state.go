@@ -0,0 +1,87 @@
+package testutil
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lnsp/mattermost-informer/pkg/state"
+)
+
+var _ state.Store = &Store{}
+
+// Store is a fake state.Store without expiry, whose time is set by the test instead of the wall
+// clock. It records the keys refreshed and is safe for concurrent use.
+type Store struct {
+	mu        sync.Mutex
+	now       time.Time
+	entries   map[string]time.Time
+	refreshed []string
+}
+
+// NewStore creates an empty store whose time starts at now.
+func NewStore(now time.Time) *Store {
+	return &Store{now: now, entries: make(map[string]time.Time)}
+}
+
+// Advance moves the time of the store forward.
+func (s *Store) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *Store) Refresh(key string, backoff time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.entries[key]; ok && s.now.Sub(last) < backoff {
+		return false
+	}
+	s.entries[key] = s.now
+	s.refreshed = append(s.refreshed, key)
+	return true
+}
+
+// Refreshed returns the keys for which Refresh allowed a notification, in order.
+func (s *Store) Refreshed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.refreshed...)
+}
+
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func (s *Store) DeletePrefix(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for key := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *Store) Snapshot() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make(map[string]time.Time, len(s.entries))
+	for key, last := range s.entries {
+		entries[key] = last
+	}
+	return entries
+}
+
+func (s *Store) Restore(entries map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, last := range entries {
+		s.entries[key] = last
+	}
+}
@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreRefresh(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStore(start)
+
+	if !s.Refresh("apps/web/app", 10*time.Minute) {
+		t.Error("first notification was suppressed")
+	}
+	s.Advance(9 * time.Minute)
+	if s.Refresh("apps/web/app", 10*time.Minute) {
+		t.Error("notification within the backoff was allowed")
+	}
+	s.Advance(time.Minute)
+	if !s.Refresh("apps/web/app", 10*time.Minute) {
+		t.Error("notification after the backoff was suppressed")
+	}
+	if want := []string{"apps/web/app", "apps/web/app"}; !reflect.DeepEqual(s.Refreshed(), want) {
+		t.Errorf("refreshed keys are %v, want %v", s.Refreshed(), want)
+	}
+	if last := s.Snapshot()["apps/web/app"]; !last.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("last notification is at %s", last)
+	}
+}
+
+func TestStoreDelete(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStore(now)
+	s.Restore(map[string]time.Time{"apps/web/app": now, "apps/web/sidecar": now, "apps/api/app": now})
+
+	if removed := s.DeletePrefix("apps/web/"); removed != 2 {
+		t.Errorf("removed %d entries by prefix, want 2", removed)
+	}
+	s.Delete("apps/api/app")
+	if entries := s.Snapshot(); len(entries) != 0 {
+		t.Errorf("entries %v were not deleted", entries)
+	}
+	if !s.Refresh("apps/web/app", time.Hour) {
+		t.Error("notification of deleted entry was suppressed")
+	}
+}
@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// Tracer creates the spans of the informer. Spans are discarded unless Setup has been called.
+var Tracer = otel.Tracer("github.com/lnsp/mattermost-informer")
+
+// Setup exports the spans to the OTLP collector listening on endpoint using gRPC.
+// The returned function flushes the remaining spans and stops the exporter.
+func Setup(endpoint string, insecure bool) (func(context.Context) error, error) {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("mattermost-informer"))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+)
+
+// PermanentError is a failure which retrying does not resolve, e.g. a request rejected as invalid
+// or unauthorized.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as permanent, nil is returned as is.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, is permanent.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// StatusError marks err as permanent if the status code is a client error, except for timeouts
+// and rate limits, which may succeed when retried.
+func StatusError(code int, err error) error {
+	if code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const playbooksAPI = "/plugins/playbooks/api/v0"
+
+type playbookRunRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OwnerUserID string `json:"owner_user_id"`
+	TeamID      string `json:"team_id"`
+	PlaybookID  string `json:"playbook_id"`
+}
+
+type playbookRun struct {
+	ID string `json:"id"`
+}
+
+// StartPlaybookRun starts a run of the given playbook and returns a link to it.
+func (client *MattermostClient) StartPlaybookRun(ctx context.Context, playbookID, name, description string) (string, error) {
+	if client.mattermost == nil {
+		return "", errors.New("playbooks are not supported in webhook mode")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(&playbookRunRequest{
+		Name:        name,
+		Description: description,
+		OwnerUserID: client.user.Id,
+		TeamID:      client.team.Id,
+		PlaybookID:  playbookID,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, appErr := client.mattermost.DoApiRequest(http.MethodPost, client.mattermost.Url+playbooksAPI+"/runs", string(payload), "")
+	if appErr != nil {
+		return "", fmt.Errorf("could not start playbook run: %v", appErr)
+	}
+	defer resp.Body.Close()
+	var run playbookRun
+	if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
+		return "", fmt.Errorf("could not decode playbook run: %v", err)
+	}
+	return client.mattermost.Url + "/playbooks/runs/" + run.ID, nil
+}
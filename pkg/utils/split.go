@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageRunes is the maximum length of a Mattermost post.
+const MaxMessageRunes = 16383
+
+const codeFence = "```"
+
+// SplitMessage splits a message into parts of at most max runes, preferably at line breaks.
+// Code blocks spanning parts are closed at the end of a part and reopened in the next one.
+func SplitMessage(msg string, max int) []string {
+	if utf8.RuneCountInString(msg) <= max {
+		return []string{msg}
+	}
+	// Reserve space for closing and reopening a code block, which is not counted in size.
+	budget := max - 2*(len(codeFence)+1)
+	var parts []string
+	var part strings.Builder
+	size, inCode := 0, false
+	flush := func() {
+		text := part.String()
+		if inCode {
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			text += codeFence
+		}
+		parts = append(parts, text)
+		part.Reset()
+		size = 0
+		if inCode {
+			part.WriteString(codeFence + "\n")
+		}
+	}
+	for _, line := range strings.SplitAfter(msg, "\n") {
+		fence := strings.HasPrefix(strings.TrimSpace(line), codeFence)
+		for line != "" {
+			n := utf8.RuneCountInString(line)
+			if size+n <= budget {
+				part.WriteString(line)
+				size += n
+				break
+			}
+			if size > 0 {
+				flush()
+				continue
+			}
+			// The line does not fit into an empty part either, it is split.
+			chunk := string([]rune(line)[:budget])
+			part.WriteString(chunk)
+			size += budget
+			line = line[len(chunk):]
+			flush()
+		}
+		if fence {
+			inCode = !inCode
+		}
+	}
+	if size > 0 {
+		parts = append(parts, part.String())
+	}
+	return parts
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTimeOfDay parses a time like 09:00 into the duration since midnight, 24:00 included.
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("time %q is not given as hh:mm", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not given as hh:mm", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not given as hh:mm", value)
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("time %q is out of range", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// ParseWeekday parses a week day like Mon or Monday, ignoring the case.
+func ParseWeekday(value string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), value) || strings.EqualFold(day.String()[:3], value) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", value)
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig configures the TLS connection to Mattermost.
+type TLSConfig struct {
+	// CertFile and KeyFile are a client certificate presented to Mattermost, e.g. for an ingress
+	// requiring mutual TLS. The files are reloaded once they change, so rotated certificates
+	// are picked up without a restart.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// CAFile verifies the certificate of Mattermost instead of the system roots.
+	CAFile string `json:"caFile"`
+}
+
+// clientConfig returns the TLS client configuration, nil if nothing is configured.
+func (cfg TLSConfig) clientConfig() (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" && cfg.CAFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		data, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA file %s contains no certificates", cfg.CAFile)
+		}
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate requires both certFile and keyFile")
+		}
+		reloader := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+		if _, err := reloader.certificate(); err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	}
+	return config, nil
+}
+
+// certReloader loads a key pair and reloads it whenever one of its files has been modified.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modified, err := latestModification(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client certificate: %v", err)
+	}
+	if r.cert != nil && modified.Equal(r.modified) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// The files may be replaced one after another, keep using the previous certificate.
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("could not load client certificate: %v", err)
+	}
+	r.cert, r.modified = &cert, modified
+	return r.cert, nil
+}
+
+func latestModification(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -1,10 +1,18 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-
-	"github.com/kelseyhightower/envconfig"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mattermost/mattermost-server/model"
 )
@@ -21,49 +29,481 @@ func Namespace() (string, error) {
 }
 
 type MattermostConfig struct {
-	User          string
-	Password      string
-	URL           string
-	Team, Channel string
+	URL string `json:"url"`
+	// Token is a bot or personal access token used to authenticate.
+	// Alternatively, the token is read from TokenFile (e.g. a mounted Secret)
+	// or the MATTERMOST_TOKEN environment variable.
+	Token     string `json:"token"`
+	TokenFile string `json:"tokenFile"`
+	Team      string `json:"team"`
+	// Channel is the default channel alerts are sent to.
+	Channel string `json:"channel"`
+	// WebhookURL switches the client to post via an incoming webhook.
+	// No user account, team or channel lookup is needed in this mode.
+	WebhookURL string `json:"webhookURL"`
+	// Proxy is the URL of the HTTP proxy Mattermost is reached through. If empty, the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are honored.
+	Proxy string    `json:"proxy"`
+	TLS   TLSConfig `json:"tls"`
 }
 
 type MattermostClient struct {
-	mattermost *model.Client4
-	user       *model.User
-	channel    *model.Channel
+	mattermost     *model.Client4
+	user           *model.User
+	team           *model.Team
+	defaultChannel string
+
+	mu       sync.Mutex
+	channels map[string]string
+
+	webhook    *http.Client
+	webhookURL string
+}
+
+// contextTransport binds the requests of a Client4, which are created without a context, to the
+// context of the call, so requests in flight are cancelled with it.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// api returns a copy of the Mattermost client whose requests are cancelled with the context.
+func (client *MattermostClient) api(ctx context.Context) *model.Client4 {
+	api := *client.mattermost
+	httpClient := http.Client{}
+	if api.HttpClient != nil {
+		httpClient = *api.HttpClient
+	}
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &contextTransport{ctx: ctx, next: next}
+	api.HttpClient = &httpClient
+	return &api
+}
+
+// channelID resolves the ID of a channel by its name. The default channel is used for empty names,
+// names like @alice resolve to the direct message channel with the user.
+func (client *MattermostClient) channelID(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		name = client.defaultChannel
+	}
+	client.mu.Lock()
+	id, ok := client.channels[name]
+	client.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	// The lock is not held during the lookup, concurrent lookups of the same channel resolve the same ID.
+	api := client.api(ctx)
+	if strings.HasPrefix(name, "@") {
+		user, resp := api.GetUserByUsername(name[1:], "")
+		if resp.Error != nil {
+			return "", StatusError(resp.StatusCode, fmt.Errorf("could not find user %s: %v", name[1:], resp.Error))
+		}
+		channel, resp := api.CreateDirectChannel(client.user.Id, user.Id)
+		if resp.Error != nil {
+			return "", fmt.Errorf("could not open direct message channel with %s: %v", name[1:], resp.Error)
+		}
+		id = channel.Id
+	} else {
+		channel, resp := api.GetChannelByName(name, client.team.Id, "")
+		if resp.Error != nil {
+			return "", StatusError(resp.StatusCode, fmt.Errorf("could not find channel %s, make sure %s is a member: %v", name, client.user.Username, resp.Error))
+		}
+		id = channel.Id
+	}
+	client.mu.Lock()
+	client.channels[name] = id
+	client.mu.Unlock()
+	return id, nil
 }
 
-func (client *MattermostClient) SendAttachements(attachements ...*model.SlackAttachment) {
-	post := &model.Post{ChannelId: client.channel.Id}
+// CheckChannel verifies that the channel with the given name exists and the user is a member.
+// Channels cannot be verified when posting via a webhook.
+func (client *MattermostClient) CheckChannel(name string) error {
+	if client.webhook != nil {
+		return nil
+	}
+	_, err := client.channelID(context.Background(), name)
+	return err
+}
+
+// PostOptions customize the appearance of a post.
+type PostOptions struct {
+	// Priority is the message priority, either "urgent", "important" or empty.
+	Priority string
+	// IconURL overrides the profile picture of the post. Emojis are shown in the title of alerts
+	// instead of replacing the picture.
+	IconURL string
+	// FileIDs are files previously uploaded with UploadFile, which are attached to the post.
+	// Posts sent via a webhook cannot carry files.
+	FileIDs []string
+	// Message is shown above the attachments, e.g. to mention users.
+	Message string
+}
+
+type postPriority struct {
+	Priority string `json:"priority"`
+}
+
+type postMetadata struct {
+	Priority *postPriority `json:"priority,omitempty"`
+}
+
+// priorityPost extends a post by the priority metadata, which is not part of the model package.
+type priorityPost struct {
+	*model.Post
+	Metadata *postMetadata `json:"metadata,omitempty"`
+}
+
+type priorityWebhookRequest struct {
+	*model.IncomingWebhookRequest
+	Priority *postPriority `json:"priority,omitempty"`
+}
+
+func (opts PostOptions) priority() *postPriority {
+	if opts.Priority == "" {
+		return nil
+	}
+	return &postPriority{Priority: opts.Priority}
+}
+
+// SendAttachements posts the attachments to the given channel, or the default channel if empty.
+// It returns the ID of the post, which is empty when posting via a webhook.
+func (client *MattermostClient) SendAttachements(ctx context.Context, channel string, opts PostOptions, attachements ...*model.SlackAttachment) (string, error) {
+	if client.webhook != nil {
+		return "", client.sendWebhook(ctx, channel, &priorityWebhookRequest{
+			IncomingWebhookRequest: &model.IncomingWebhookRequest{
+				Text:        opts.Message,
+				Attachments: attachements,
+				IconURL:     opts.IconURL,
+			},
+			Priority: opts.priority(),
+		})
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	channelID, err := client.channelID(ctx, channel)
+	if err != nil {
+		return "", err
+	}
+	post := &model.Post{ChannelId: channelID, Message: opts.Message, FileIds: opts.FileIDs}
 	model.ParseSlackAttachment(post, attachements)
-	client.mattermost.CreatePost(post)
+	if opts.IconURL != "" {
+		post.AddProp("override_icon_url", opts.IconURL)
+	}
+	if opts.Priority == "" {
+		return client.createPost(ctx, post)
+	}
+	payload, err := json.Marshal(&priorityPost{Post: post, Metadata: &postMetadata{Priority: opts.priority()}})
+	if err != nil {
+		return "", fmt.Errorf("could not encode post: %v", err)
+	}
+	resp, appErr := client.api(ctx).DoApiPost(client.mattermost.GetPostsRoute(), string(payload))
+	if appErr != nil {
+		return "", StatusError(appErr.StatusCode, fmt.Errorf("could not create post in channel %s: %v", channel, appErr))
+	}
+	defer resp.Body.Close()
+	created := model.PostFromJson(resp.Body)
+	if created == nil {
+		return "", nil
+	}
+	return created.Id, nil
+}
+
+// CanUpload reports whether files can be uploaded, which is not possible when posting via a webhook.
+func (client *MattermostClient) CanUpload() bool {
+	return client.webhook == nil
+}
+
+// UploadFile uploads a file to the given channel, or the default channel if empty, and returns its
+// ID to be attached to a post.
+func (client *MattermostClient) UploadFile(ctx context.Context, channel, name string, data []byte) (string, error) {
+	if client.webhook != nil {
+		return "", errors.New("files cannot be uploaded via a webhook")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	channelID, err := client.channelID(ctx, channel)
+	if err != nil {
+		return "", err
+	}
+	uploaded, resp := client.api(ctx).UploadFile(data, channelID, name)
+	if resp.Error != nil {
+		return "", fmt.Errorf("could not upload file %s: %v", name, resp.Error)
+	}
+	if len(uploaded.FileInfos) == 0 {
+		return "", fmt.Errorf("upload of file %s returned no file", name)
+	}
+	return uploaded.FileInfos[0].Id, nil
+}
+
+// Send posts a message to the given channel, or the default channel if empty. Messages exceeding
+// MaxMessageRunes are split, the parts following the first are posted as replies to it.
+func (client *MattermostClient) Send(ctx context.Context, channel, msg string) error {
+	parts := SplitMessage(msg, MaxMessageRunes)
+	if client.webhook != nil {
+		// Webhooks cannot reply, the parts are posted one after another instead.
+		for _, part := range parts {
+			if err := client.sendWebhook(ctx, channel, &priorityWebhookRequest{IncomingWebhookRequest: &model.IncomingWebhookRequest{Text: part}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	channelID, err := client.channelID(ctx, channel)
+	if err != nil {
+		return err
+	}
+	rootID, err := client.createPost(ctx, &model.Post{
+		ChannelId: channelID,
+		Message:   parts[0],
+	})
+	if err != nil {
+		return err
+	}
+	return client.Reply(ctx, channel, rootID, parts[1:]...)
+}
+
+// PostMessage posts a message to the given channel, or the default channel if empty, and returns
+// the ID of the post, which is empty when posting via a webhook.
+func (client *MattermostClient) PostMessage(ctx context.Context, channel, msg string) (string, error) {
+	if client.webhook != nil {
+		return "", client.sendWebhook(ctx, channel, &priorityWebhookRequest{IncomingWebhookRequest: &model.IncomingWebhookRequest{Text: msg}})
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	channelID, err := client.channelID(ctx, channel)
+	if err != nil {
+		return "", err
+	}
+	return client.createPost(ctx, &model.Post{ChannelId: channelID, Message: msg})
 }
 
-func (client *MattermostClient) Send(msg string) {
-	post := &model.Post{
-		ChannelId: client.channel.Id,
-		Message:   msg,
+// EditMessage replaces the message of the post with the given ID.
+func (client *MattermostClient) EditMessage(ctx context.Context, postID, msg string) error {
+	if client.webhook != nil {
+		return errors.New("posts cannot be edited via a webhook")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, resp := client.api(ctx).PatchPost(postID, &model.PostPatch{Message: &msg}); resp.Error != nil {
+		return fmt.Errorf("could not edit post %s: %v", postID, resp.Error)
 	}
-	client.mattermost.CreatePost(post)
+	return nil
 }
 
-func NewMattermostClient() (*MattermostClient, error) {
-	var cfg MattermostConfig
-	if err := envconfig.Process("mattermost", &cfg); err != nil {
+// FirstReaction returns the first reaction of a user other than the informer to the post with the
+// given ID, nil if there is none.
+func (client *MattermostClient) FirstReaction(ctx context.Context, postID string) (*model.Reaction, error) {
+	if client.webhook != nil {
+		return nil, errors.New("reactions cannot be read via a webhook")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reactions, resp := client.api(ctx).GetReactions(postID)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("could not get reactions of post %s: %v", postID, resp.Error)
+	}
+	var first *model.Reaction
+	for _, reaction := range reactions {
+		if reaction.UserId != client.user.Id && (first == nil || reaction.CreateAt < first.CreateAt) {
+			first = reaction
+		}
+	}
+	return first, nil
+}
+
+// UserName returns the username of the user with the given ID.
+func (client *MattermostClient) UserName(ctx context.Context, userID string) (string, error) {
+	if client.webhook != nil {
+		return "", errors.New("users cannot be looked up via a webhook")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	user, resp := client.api(ctx).GetUser(userID, "")
+	if resp.Error != nil {
+		return "", fmt.Errorf("could not get user %s: %v", userID, resp.Error)
+	}
+	return user.Username, nil
+}
+
+// CanReply reports whether replies can be posted, which is not possible when posting via a webhook.
+func (client *MattermostClient) CanReply() bool {
+	return client.webhook == nil
+}
+
+// Reply posts the messages in order as replies to the post with the given ID in the channel.
+func (client *MattermostClient) Reply(ctx context.Context, channel, rootID string, msgs ...string) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if client.webhook != nil {
+		return errors.New("replies cannot be posted via a webhook")
+	}
+	channelID, err := client.channelID(ctx, channel)
+	if err != nil {
+		return err
+	}
+	for i, msg := range msgs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := client.createPost(ctx, &model.Post{ChannelId: channelID, RootId: rootID, Message: msg}); err != nil {
+			return fmt.Errorf("could not post reply %d of %d: %v", i+1, len(msgs), err)
+		}
+	}
+	return nil
+}
+
+func (client *MattermostClient) createPost(ctx context.Context, post *model.Post) (string, error) {
+	created, resp := client.api(ctx).CreatePost(post)
+	if resp.Error != nil {
+		return "", StatusError(resp.StatusCode, fmt.Errorf("could not create post: %v", resp.Error))
+	}
+	return created.Id, nil
+}
+
+func (client *MattermostClient) sendWebhook(ctx context.Context, channel string, req *priorityWebhookRequest) error {
+	if channel == "" {
+		channel = client.defaultChannel
+	}
+	req.ChannelName = channel
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("could not encode webhook payload: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, client.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("could not create webhook request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := client.webhook.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("could not post to webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return StatusError(resp.StatusCode, fmt.Errorf("posting to webhook failed with status %s: %s", resp.Status, body))
+	}
+	return nil
+}
+
+const requestTimeout = 30 * time.Second
+
+// Ping checks that the Mattermost session is still valid. Webhooks cannot be checked without posting.
+func (client *MattermostClient) Ping() error {
+	if client.mattermost == nil {
+		return nil
+	}
+	if _, resp := client.mattermost.GetMe(""); resp.Error != nil {
+		return fmt.Errorf("session check failed: %v", resp.Error)
+	}
+	return nil
+}
+
+// LoadToken returns the configured access token.
+func (cfg *MattermostConfig) LoadToken() (string, error) {
+	if cfg.Token != "" {
+		return cfg.Token, nil
+	}
+	if cfg.TokenFile == "" {
+		if token := os.Getenv("MATTERMOST_TOKEN"); token != "" {
+			return token, nil
+		}
+		return "", errors.New("no access token configured, set token, tokenFile or MATTERMOST_TOKEN")
+	}
+	data, err := ioutil.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read access token: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("access token file %s is empty", cfg.TokenFile)
+	}
+	return token, nil
+}
+
+// newHTTPClient creates a client using the configured proxy, or the proxy of the environment if
+// none is configured, and TLS settings.
+func newHTTPClient(cfg MattermostConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	tlsConfig, err := cfg.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Timeout: requestTimeout, Transport: transport}, nil
+}
+
+// NewMattermostClient connects to Mattermost and validates the configuration.
+func NewMattermostClient(cfg MattermostConfig) (*MattermostClient, error) {
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.WebhookURL != "" {
+		return &MattermostClient{
+			webhook:        httpClient,
+			webhookURL:     cfg.WebhookURL,
+			defaultChannel: cfg.Channel,
+		}, nil
+	}
+	token, err := cfg.LoadToken()
+	if err != nil {
 		return nil, err
 	}
 	client := model.NewAPIv4Client(cfg.URL)
-	user, resp := client.Login(cfg.User, cfg.Password)
+	client.HttpClient = httpClient
+	client.SetToken(token)
+	user, resp := client.GetMe("")
 	if resp.Error != nil {
-		return nil, resp.Error
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("access token was rejected by %s, make sure it is a valid bot or personal access token: %v", cfg.URL, resp.Error)
+		}
+		return nil, fmt.Errorf("could not validate access token: %v", resp.Error)
 	}
 	team, resp := client.GetTeamByName(cfg.Team, "")
 	if resp.Error != nil {
-		return nil, resp.Error
+		return nil, fmt.Errorf("could not find team %s, make sure %s is a member: %v", cfg.Team, user.Username, resp.Error)
 	}
-	channel, resp := client.GetChannelByName(cfg.Channel, team.Id, "")
-	if resp.Error != nil {
-		return nil, resp.Error
+	mattermost := &MattermostClient{
+		mattermost:     client,
+		user:           user,
+		team:           team,
+		defaultChannel: cfg.Channel,
+		channels:       make(map[string]string),
+	}
+	if _, err := mattermost.channelID(context.Background(), cfg.Channel); err != nil {
+		return nil, err
 	}
-	return &MattermostClient{client, user, channel}, nil
+	return mattermost, nil
 }
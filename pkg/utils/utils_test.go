@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *MattermostClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	api := model.NewAPIv4Client(server.URL)
+	api.HttpClient = server.Client()
+	return &MattermostClient{
+		mattermost:     api,
+		user:           &model.User{Id: "informer", Username: "informer"},
+		team:           &model.Team{Id: "team"},
+		defaultChannel: "alerts",
+		channels:       map[string]string{"alerts": "alerts-id"},
+	}
+}
+
+func TestPostIsCancelledInFlight(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.PostMessage(ctx, "", "hello")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected cancelled post to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("post was not cancelled with its context")
+	}
+}
+
+func TestChannelLookupDoesNotBlockCachedChannels(t *testing.T) {
+	release := make(chan struct{})
+	requested := make(chan struct{})
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/channels/name/slow") {
+			http.NotFound(w, r)
+			return
+		}
+		close(requested)
+		<-release
+		json.NewEncoder(w).Encode(&model.Channel{Id: "slow-id", Name: "slow"})
+	}))
+	lookup := make(chan string, 1)
+	go func() {
+		id, err := client.channelID(context.Background(), "slow")
+		if err != nil {
+			t.Error(err)
+		}
+		lookup <- id
+	}()
+	<-requested
+	cached := make(chan string, 1)
+	go func() {
+		id, _ := client.channelID(context.Background(), "")
+		cached <- id
+	}()
+	select {
+	case id := <-cached:
+		if id != "alerts-id" {
+			t.Errorf("expected cached default channel, got %q", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached channel lookup blocked by lookup in flight")
+	}
+	close(release)
+	if id := <-lookup; id != "slow-id" {
+		t.Errorf("expected looked up channel slow-id, got %q", id)
+	}
+	if id, err := client.channelID(context.Background(), "slow"); err != nil || id != "slow-id" {
+		t.Errorf("expected channel to be cached, got %q, %v", id, err)
+	}
+}
@@ -0,0 +1,19 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Version, Commit and BuildDate are set at build time using
+// -ldflags "-X github.com/lnsp/mattermost-informer/pkg/version.Version=...".
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// String describes the build, e.g. "v1.2.0 (commit 3f2c1a0, built 2024-05-01T10:00:00Z, go1.21.5)".
+func String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", Version, Commit, BuildDate, runtime.Version())
+}